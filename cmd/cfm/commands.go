@@ -0,0 +1,500 @@
+package main
+
+import (
+	"cloud_file_manager/src/client"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (a *app) login(ctx context.Context, args []string) error {
+	flags := a.flags("login")
+	email := flags.String("email", "", "email da conta")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		return errUsage
+	}
+
+	server := a.serverAddress()
+	api := client.New(server, "", "")
+
+	var err error
+	if *email == "" {
+		if *email, err = a.prompt("Email: ", false); err != nil {
+			return err
+		}
+	}
+
+	password, err := a.prompt("Senha: ", true)
+	if err != nil {
+		return err
+	}
+
+	result, err := api.Login(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+
+	if result.MfaRequired {
+		code, err := a.prompt("Código de verificação: ", false)
+		if err != nil {
+			return err
+		}
+
+		result, err = api.LoginMfa(ctx, result.MfaToken, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	api.SetTokens(result.Token, "")
+	refreshToken, err := api.IssueRefreshToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.creds = &credentials{Server: server, Token: result.Token, RefreshToken: refreshToken}
+	if err := saveCredentials(a.creds); err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(map[string]string{"server": server})
+	}
+
+	fmt.Fprintf(a.stdout, "Login feito em %s\n", server)
+	return nil
+}
+
+func (a *app) logout(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	if err := a.connect(); err == nil {
+		err = a.client.Logout(ctx)
+		if err != nil && !errors.Is(err, client.ErrNotLoggedIn) {
+			return err
+		}
+	}
+
+	if err := removeCredentials(); err != nil {
+		return err
+	}
+
+	if !a.json {
+		fmt.Fprintln(a.stdout, "Sessão encerrada")
+	}
+	return nil
+}
+
+func (a *app) ls(ctx context.Context, args []string) error {
+	flags := a.flags("ls")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	objects, err := a.listPrefix(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(objects)
+	}
+
+	for _, object := range objects {
+		fmt.Fprintf(a.stdout, "%10s  %s  %s\n", formatBytes(object.Size), object.LastModified.Local().Format("2006-01-02 15:04"), object.Key)
+	}
+	return nil
+}
+
+func (a *app) put(ctx context.Context, args []string) error {
+	flags := a.flags("put")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	sources, destination := flags.Args(), ""
+	if len(sources) > 1 {
+		sources, destination = sources[:len(sources)-1], sources[len(sources)-1]
+	}
+
+	var tasks []transfer
+	for _, source := range sources {
+		uploads, err := planUploads(source, destination, len(sources) > 1)
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range uploads {
+			tasks = append(tasks, transfer{
+				Key:  upload.key,
+				Path: upload.path,
+				Size: upload.size,
+				run: func(ctx context.Context, progress client.Progress) error {
+					return a.client.Upload(ctx, upload.key, upload.path, progress)
+				},
+			})
+		}
+	}
+
+	return a.transfer(ctx, tasks)
+}
+
+func (a *app) get(ctx context.Context, args []string) error {
+	flags := a.flags("get")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	keys, destination := flags.Args(), ""
+	if len(keys) > 1 {
+		keys, destination = keys[:len(keys)-1], keys[len(keys)-1]
+	}
+
+	var tasks []transfer
+	for _, key := range keys {
+		downloads, err := a.planDownloads(ctx, key, destination, len(keys) > 1)
+		if err != nil {
+			return err
+		}
+
+		for _, download := range downloads {
+			tasks = append(tasks, transfer{
+				Key:  download.key,
+				Path: download.path,
+				Size: download.size,
+				run: func(ctx context.Context, progress client.Progress) error {
+					return a.client.Download(ctx, download.key, download.path, progress)
+				},
+			})
+		}
+	}
+
+	return a.transfer(ctx, tasks)
+}
+
+func (a *app) rm(ctx context.Context, args []string) error {
+	flags := a.flags("rm")
+	recursive := flags.Bool("r", false, "apaga tudo sob os prefixos")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, key := range flags.Args() {
+		if !strings.HasSuffix(key, "/") {
+			keys = append(keys, key)
+			continue
+		}
+
+		if !*recursive {
+			return fmt.Errorf("%s é uma pasta, use -r para apagá-la", key)
+		}
+
+		objects, err := a.listPrefix(ctx, key)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+		}
+	}
+
+	var deleted []string
+	for _, key := range keys {
+		if err := a.client.Delete(ctx, key); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		deleted = append(deleted, key)
+
+		if !a.json {
+			fmt.Fprintf(a.stdout, "apagado %s\n", key)
+		}
+	}
+
+	if a.json {
+		return a.printJSON(map[string][]string{"deleted": deleted})
+	}
+	return nil
+}
+
+func (a *app) mv(ctx context.Context, args []string) error {
+	flags := a.flags("mv")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	source, destination := flags.Arg(0), flags.Arg(1)
+	if strings.HasSuffix(destination, "/") {
+		destination += path.Base(source)
+	}
+
+	if err := a.client.Move(ctx, source, destination); err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(map[string]string{"source": source, "destination": destination})
+	}
+
+	fmt.Fprintf(a.stdout, "%s -> %s\n", source, destination)
+	return nil
+}
+
+func (a *app) share(ctx context.Context, args []string) error {
+	flags := a.flags("share")
+	expires := flags.Duration("expires", time.Hour, "validade do link, até 168h")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	link, err := a.client.Share(ctx, flags.Arg(0), *expires)
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(map[string]any{
+			"url":       link.URL,
+			"expiresAt": time.Now().Add(*expires).UTC(),
+		})
+	}
+
+	fmt.Fprintln(a.stdout, link.URL)
+	return nil
+}
+
+func (a *app) listPrefix(ctx context.Context, prefix string) ([]client.Object, error) {
+	objects, err := a.client.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+type plannedFile struct {
+	key  string
+	path string
+	size int64
+}
+
+// planUploads maps a local file or directory to object keys. A directory
+// keeps its layout under the destination; a file lands on the destination
+// key itself unless the destination names a folder.
+func planUploads(source string, destination string, intoFolder bool) ([]plannedFile, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		key := destination
+		if key == "" || intoFolder || strings.HasSuffix(key, "/") {
+			key = joinKey(destination, filepath.Base(source))
+		}
+		return []plannedFile{{key: key, path: source, size: info.Size()}}, nil
+	}
+
+	base := destination
+	if base == "" {
+		base = filepath.Base(filepath.Clean(source))
+	}
+
+	var files []plannedFile
+	err = filepath.WalkDir(source, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(source, current)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, plannedFile{
+			key:  joinKey(base, filepath.ToSlash(relative)),
+			path: current,
+			size: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// planDownloads maps a key, or every key under a prefix ending in "/", to
+// local paths.
+func (a *app) planDownloads(ctx context.Context, key string, destination string, intoFolder bool) ([]plannedFile, error) {
+	if !strings.HasSuffix(key, "/") {
+		target := destination
+		if target == "" {
+			target = path.Base(key)
+		} else if intoFolder || strings.HasSuffix(target, string(os.PathSeparator)) || isDir(target) {
+			target = filepath.Join(target, path.Base(key))
+		}
+		return []plannedFile{{key: key, path: target}}, nil
+	}
+
+	objects, err := a.listPrefix(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if destination == "" {
+		destination = path.Base(strings.TrimSuffix(key, "/"))
+	}
+
+	return a.prefixDownloads(objects, key, destination), nil
+}
+
+// prefixDownloads keeps the layout of the objects under prefix inside
+// destination. Keys that would land outside of it, such as "../x", are
+// left out.
+func (a *app) prefixDownloads(objects []client.Object, prefix string, destination string) []plannedFile {
+	var files []plannedFile
+	for _, object := range objects {
+		relative := strings.TrimPrefix(object.Key, prefix)
+		if relative == "" || strings.HasSuffix(relative, "/") {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(relative)) {
+			fmt.Fprintf(a.stderr, "cfm: ignorando %q, que ficaria fora de %s\n", object.Key, destination)
+			continue
+		}
+
+		files = append(files, plannedFile{
+			key:  object.Key,
+			path: filepath.Join(destination, filepath.FromSlash(relative)),
+			size: object.Size,
+		})
+	}
+
+	return files
+}
+
+func joinKey(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + name
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+type transfer struct {
+	Key   string `json:"key"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+
+	run func(ctx context.Context, progress client.Progress) error
+}
+
+// transfer runs the transfers, a.parallel at a time, and reports each one
+// as a progress bar or, with --json, in a summary.
+func (a *app) transfer(ctx context.Context, tasks []transfer) error {
+	bars := newProgress(a.stderr, a.interactive())
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+
+	for range min(a.parallel, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				task := &tasks[i]
+
+				b := bars.add(task.Key, task.Size)
+				err := task.run(ctx, bars.advance(b))
+				bars.finish(b, err)
+
+				if err != nil {
+					mu.Lock()
+					task.Error = err.Error()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range tasks {
+		if ctx.Err() != nil {
+			mu.Lock()
+			tasks[i].Error = ctx.Err().Error()
+			failed++
+			mu.Unlock()
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	bars.close()
+
+	if a.json {
+		if err := a.printJSON(tasks); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d de %d transferências falharam", failed, len(tasks))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"cloud_file_manager/src/client"
+)
+
+func TestPlanUploadsFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "foto.png")
+	os.WriteFile(file, []byte("png"), 0o644)
+
+	cases := []struct {
+		destination string
+		intoFolder  bool
+		key         string
+	}{
+		{"", false, "foto.png"},
+		{"imagens/capa.png", false, "imagens/capa.png"},
+		{"imagens/", false, "imagens/foto.png"},
+		{"imagens", true, "imagens/foto.png"},
+	}
+
+	for _, c := range cases {
+		files, err := planUploads(file, c.destination, c.intoFolder)
+		if err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+		if len(files) != 1 || files[0].key != c.key || files[0].size != 3 {
+			t.Fatalf("destino %q: esperava %s, veio %#v", c.destination, c.key, files)
+		}
+	}
+}
+
+func TestPlanUploadsDirectoryKeepsLayout(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "projeto")
+	os.MkdirAll(filepath.Join(dir, "src"), 0o755)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("b"), 0o644)
+
+	files, err := planUploads(dir, "", false)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	var keys []string
+	for _, file := range files {
+		keys = append(keys, file.key)
+	}
+	sort.Strings(keys)
+
+	if len(keys) != 2 || keys[0] != "projeto/README.md" || keys[1] != "projeto/src/main.go" {
+		t.Fatalf("chaves inesperadas %v", keys)
+	}
+}
+
+func TestPrefixDownloadsStayInsideDestination(t *testing.T) {
+	var stderr bytes.Buffer
+	a := &app{stderr: &stderr}
+
+	objects := []client.Object{
+		{Key: "fotos/praia.jpg", Size: 3},
+		{Key: "fotos/2025/"},
+		{Key: "fotos/2025/neve.jpg", Size: 4},
+		{Key: "fotos/../../.bashrc", Size: 5},
+		{Key: "fotos//etc/passwd", Size: 6},
+	}
+
+	files := a.prefixDownloads(objects, "fotos/", "destino")
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+
+	expected := []string{filepath.Join("destino", "praia.jpg"), filepath.Join("destino", "2025", "neve.jpg")}
+	if len(paths) != 2 || paths[0] != expected[0] || paths[1] != expected[1] {
+		t.Fatalf("caminhos inesperados %v", paths)
+	}
+	if stderr.Len() == 0 {
+		t.Fatalf("esperava aviso das chaves ignoradas")
+	}
+}
+
+func TestConnectHonoursServerFromEnvironment(t *testing.T) {
+	t.Setenv("CFM_SERVER", "https://cfm.example.com")
+
+	a := &app{creds: &credentials{Server: "http://localhost:8080", Token: "token"}}
+	if err := a.connect(); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if a.client.Server != "https://cfm.example.com" {
+		t.Fatalf("esperava o servidor de CFM_SERVER, veio %s", a.client.Server)
+	}
+
+	a.server = "https://outro.example.com"
+	if err := a.connect(); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if a.client.Server != "https://outro.example.com" {
+		t.Fatalf("--server deveria ter prioridade, veio %s", a.client.Server)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// credentials is what login leaves on disk for the next commands. The
+// refresh token is as good as a password for the session, so the file is
+// only readable by its owner.
+type credentials struct {
+	Server       string `json:"server"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func credentialsPath() (string, error) {
+	if path := os.Getenv("CFM_CREDENTIALS"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "cfm", "credentials.json"), nil
+}
+
+// loadCredentials returns empty credentials when the user never logged in.
+func loadCredentials() (*credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var creds credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+func saveCredentials(creds *credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+func removeCredentials() error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+// Command cfm uses the cloud file manager from a terminal.
+package main
+
+import (
+	"cloud_file_manager/src/client"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const defaultServer = "http://localhost:8000"
+
+const usage = `uso: cfm [opções] <comando> [argumentos]
+
+comandos:
+  login                      entra com email e senha e guarda a sessão
+  logout                     encerra a sessão guardada
+  ls [prefixo]               lista os arquivos
+  put <arquivo>... [destino] envia arquivos ou pastas
+  get <chave>... [destino]   baixa arquivos; chaves terminadas em / baixam a pasta
+  rm [-r] <chave>...         apaga arquivos; -r apaga pastas inteiras
+  mv <origem> <destino>      move ou renomeia um arquivo
+  share [-expires 1h] <chave>
+                             gera um link de download
+  sync [-dry-run] [-watch 1m] <pasta> <prefixo>
+                             sincroniza a pasta com o prefixo nos dois sentidos
+
+opções:
+`
+
+var errUsage = errors.New("uso inválido")
+
+type app struct {
+	server   string
+	json     bool
+	parallel int
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	creds  *credentials
+	client *client.Client
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+
+	if err := a.run(ctx, os.Args[1:]); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "cfm:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// flags registers the global options, accepted both before and after the
+// command name.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.StringVar(&a.server, "server", a.server, "endereço da API")
+	fs.BoolVar(&a.json, "json", a.json, "saída em JSON")
+	fs.IntVar(&a.parallel, "parallel", a.parallel, "transferências simultâneas")
+	return fs
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	a.parallel = 4
+
+	fs := a.flags("cfm")
+	fs.Usage = func() {
+		fmt.Fprint(a.stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	if a.parallel < 1 {
+		a.parallel = 1
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return fmt.Errorf("não foi possível ler as credenciais: %w", err)
+	}
+	a.creds = creds
+
+	command, rest := fs.Arg(0), fs.Args()[1:]
+
+	commands := map[string]func(context.Context, []string) error{
+		"login":  a.login,
+		"logout": a.logout,
+		"ls":     a.ls,
+		"put":    a.put,
+		"get":    a.get,
+		"rm":     a.rm,
+		"mv":     a.mv,
+		"share":  a.share,
+		"sync":   a.sync,
+	}
+
+	run, ok := commands[command]
+	if !ok {
+		fmt.Fprintf(a.stderr, "comando desconhecido %q\n\n", command)
+		fs.Usage()
+		return errUsage
+	}
+
+	return run(ctx, rest)
+}
+
+// connect builds the API client from the stored session.
+func (a *app) connect() error {
+	if a.creds.Token == "" && a.creds.RefreshToken == "" {
+		return errors.New("faça login primeiro com cfm login")
+	}
+
+	a.client = client.New(a.serverAddress(), a.creds.Token, a.creds.RefreshToken)
+	a.client.OnRefresh = func(token string, refreshToken string) {
+		a.creds.Token = token
+		a.creds.RefreshToken = refreshToken
+		if err := saveCredentials(a.creds); err != nil {
+			fmt.Fprintln(a.stderr, "cfm: não foi possível salvar a sessão renovada:", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *app) serverAddress() string {
+	switch {
+	case a.server != "":
+		return a.server
+	case os.Getenv("CFM_SERVER") != "":
+		return os.Getenv("CFM_SERVER")
+	case a.creds.Server != "":
+		return a.creds.Server
+	default:
+		return defaultServer
+	}
+}
+
+func (a *app) printJSON(value any) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// prompt reads a line from stdin, without echo when secret is set and
+// stdin is a terminal.
+func (a *app) prompt(label string, secret bool) (string, error) {
+	fmt.Fprint(a.stderr, label)
+
+	if file, ok := a.stdin.(*os.File); ok && secret && term.IsTerminal(int(file.Fd())) {
+		value, err := term.ReadPassword(int(file.Fd()))
+		fmt.Fprintln(a.stderr)
+		return string(value), err
+	}
+
+	var line strings.Builder
+	buffer := make([]byte, 1)
+	for {
+		n, err := a.stdin.Read(buffer)
+		if n > 0 {
+			if buffer[0] == '\n' {
+				break
+			}
+			line.WriteByte(buffer[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(line.String()), nil
+}
+
+func (a *app) interactive() bool {
+	file, ok := a.stderr.(*os.File)
+	return ok && !a.json && term.IsTerminal(int(file.Fd()))
+}
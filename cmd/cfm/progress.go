@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const barWidth = 24
+
+// progress draws one bar per running transfer. Bars are redrawn in place,
+// so it is only used when stderr is a terminal; otherwise every transfer
+// just prints a line when it finishes.
+type progress struct {
+	out         io.Writer
+	interactive bool
+
+	mu    sync.Mutex
+	bars  []*bar
+	drawn int
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+type bar struct {
+	name  string
+	total int64
+	sent  int64
+	state string
+	start time.Time
+}
+
+func newProgress(out io.Writer, interactive bool) *progress {
+	p := &progress{
+		out:         out,
+		interactive: interactive,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	if !interactive {
+		close(p.done)
+		return p
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.render()
+			case <-p.stop:
+				p.render()
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+func (p *progress) add(name string, total int64) *bar {
+	b := &bar{name: name, total: total, start: time.Now()}
+
+	p.mu.Lock()
+	p.bars = append(p.bars, b)
+	p.mu.Unlock()
+
+	return b
+}
+
+// advance is a client.Progress for the bar.
+func (p *progress) advance(b *bar) func(int64) {
+	return func(n int64) {
+		p.mu.Lock()
+		b.sent += n
+		p.mu.Unlock()
+	}
+}
+
+func (p *progress) finish(b *bar, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		b.state = "erro"
+	} else {
+		b.state = "ok"
+		if b.total == 0 {
+			b.total = b.sent
+		}
+		b.sent = b.total
+	}
+
+	if !p.interactive {
+		if err != nil {
+			fmt.Fprintf(p.out, "%s: %v\n", b.name, err)
+		} else {
+			fmt.Fprintf(p.out, "%s: %s\n", b.name, formatBytes(b.total))
+		}
+	}
+}
+
+func (p *progress) close() {
+	if p.interactive {
+		close(p.stop)
+	}
+	<-p.done
+}
+
+func (p *progress) render() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var out strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&out, "\x1b[%dA", p.drawn)
+	}
+
+	for _, b := range p.bars {
+		out.WriteString("\r\x1b[K")
+		out.WriteString(b.line())
+		out.WriteString("\n")
+	}
+	p.drawn = len(p.bars)
+
+	io.WriteString(p.out, out.String())
+}
+
+func (b *bar) line() string {
+	// Downloads only learn their size as they go.
+	total := b.total
+	if total == 0 {
+		total = b.sent
+	}
+
+	ratio := 1.0
+	if total > 0 {
+		ratio = min(float64(b.sent)/float64(total), 1)
+	}
+
+	filled := int(ratio * barWidth)
+	meter := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+
+	status := b.state
+	if status == "" {
+		elapsed := time.Since(b.start).Seconds()
+		if elapsed > 0 {
+			status = formatBytes(int64(float64(b.sent)/elapsed)) + "/s"
+		}
+	}
+
+	return fmt.Sprintf("%-32s [%s] %3.0f%% %10s %s", shorten(b.name, 32), meter, ratio*100, formatBytes(total), status)
+}
+
+func shorten(name string, size int) string {
+	runes := []rune(name)
+	if len(runes) <= size {
+		return name
+	}
+	return "…" + string(runes[len(runes)-size+1:])
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"cloud_file_manager/src/client"
+	"cloud_file_manager/src/filesync"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var actionLabels = map[filesync.ActionKind]string{
+	filesync.Upload:       "envia",
+	filesync.Download:     "baixa",
+	filesync.DeleteLocal:  "apaga local",
+	filesync.DeleteRemote: "apaga remoto",
+	filesync.Conflict:     "conflito",
+}
+
+// sync mirrors a directory with a prefix through filesync. With -watch it
+// keeps running, syncing again every interval.
+func (a *app) sync(ctx context.Context, args []string) error {
+	flags := a.flags("sync")
+	dryRun := flags.Bool("dry-run", false, "só mostra o que seria feito")
+	force := flags.Bool("force", false, "permite apagar mais arquivos que o limite de segurança")
+	threshold := flags.Float64("delete-threshold", filesync.DefaultDeleteThreshold, "fração máxima dos arquivos que uma sincronização pode apagar")
+	watch := flags.Duration("watch", 0, "sincroniza de novo a cada intervalo")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errUsage
+	}
+
+	if err := a.connect(); err != nil {
+		return err
+	}
+
+	options := filesync.Options{
+		DryRun:          *dryRun,
+		Force:           *force,
+		DeleteThreshold: *threshold,
+		Parallel:        a.parallel,
+	}
+
+	if *watch > 0 {
+		engine := filesync.New(a.client, flags.Arg(0), flags.Arg(1), options)
+		engine.Watch(ctx, *watch, func(result *filesync.Result, err error) {
+			if err := a.reportSync(result, err, true); err != nil {
+				fmt.Fprintln(a.stderr, "cfm:", err)
+			}
+		})
+		return nil
+	}
+
+	// Without a dry run the progress bars already show every action.
+	var bars *progress
+	if !*dryRun {
+		bars = newProgress(a.stderr, a.interactive())
+		options.Observe = func(action filesync.Action) (client.Progress, func(error)) {
+			b := bars.add(actionLabels[action.Kind]+" "+action.Path, action.Size)
+			return bars.advance(b), func(err error) { bars.finish(b, err) }
+		}
+	}
+
+	result, err := filesync.New(a.client, flags.Arg(0), flags.Arg(1), options).Run(ctx)
+	if bars != nil {
+		bars.close()
+	}
+
+	return a.reportSync(result, err, *dryRun)
+}
+
+// reportSync prints the outcome of a run. listActions prints one line per
+// action; the plan is also printed when the delete threshold stopped the
+// run, so the user can judge it before forcing.
+func (a *app) reportSync(result *filesync.Result, err error, listActions bool) error {
+	if result == nil {
+		return err
+	}
+
+	if a.json {
+		if printErr := a.printJSON(result); printErr != nil {
+			return printErr
+		}
+	} else if listActions || errors.Is(err, filesync.ErrTooManyDeletes) {
+		for _, action := range result.Actions {
+			line := fmt.Sprintf("%-13s %s", actionLabels[action.Kind], action.Path)
+			if action.Error != "" {
+				line += " (erro: " + action.Error + ")"
+			}
+			fmt.Fprintln(a.stdout, line)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !a.json && len(result.Actions) == 0 {
+		fmt.Fprintf(a.stdout, "%s tudo sincronizado\n", time.Now().Format("15:04:05"))
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d de %d ações falharam", result.Failed, len(result.Actions))
+	}
+	return nil
+}
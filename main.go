@@ -2,9 +2,20 @@ package main
 
 import (
 	"cloud_file_manager/src/app"
+	"fmt"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := app.RunMigrate(os.Args[2:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// setup and run app
 	err := app.SetupAndRunApp()
 	if err != nil {
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"cloud_file_manager/src/config"
+	"cloud_file_manager/src/database"
+	"cloud_file_manager/src/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrMigrateUsage = errors.New("uso: migrate up | down [passos] | status")
+
+// RunMigrate implements the migrate subcommand of the main binary.
+func RunMigrate(args []string) error {
+	if len(args) == 0 {
+		return ErrMigrateUsage
+	}
+
+	err := config.LoadENV()
+	if err != nil {
+		return err
+	}
+
+	dbConection, err := database.ConnectDB()
+	if err != nil {
+		return err
+	}
+	defer dbConection.Close()
+
+	migrator, err := migrations.NewMigrator(dbConection)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, migrator)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return ErrMigrateUsage
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Printf("Revertida %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			applied := "pendente"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	default:
+		return ErrMigrateUsage
+	}
+}
+
+func migrateUp(ctx context.Context, migrator *migrations.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		fmt.Printf("Aplicada %04d_%s\n", migration.Version, migration.Name)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Println("Banco de dados já está atualizado")
+	}
+
+	return err
+}
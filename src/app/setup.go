@@ -5,17 +5,36 @@ import (
 	"cloud_file_manager/src/config"
 	"cloud_file_manager/src/controllers"
 	"cloud_file_manager/src/database"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/mailer"
+	"cloud_file_manager/src/migrations"
+	"cloud_file_manager/src/oidc"
+	"cloud_file_manager/src/ratelimit"
 	"cloud_file_manager/src/repository"
 	"cloud_file_manager/src/routes"
+	"cloud_file_manager/src/secretbox"
+	"cloud_file_manager/src/sftpserver"
 	"cloud_file_manager/src/usecase"
 	"context"
+	"errors"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout is how long requests in flight get to finish once the
+// process is asked to stop.
+const shutdownTimeout = 30 * time.Second
+
 func SetupAndRunApp() error {
 	err := config.LoadENV()
 	if err != nil {
@@ -27,6 +46,18 @@ func SetupAndRunApp() error {
 		return err
 	}
 
+	if os.Getenv("AUTO_MIGRATE") == "true" {
+		migrator, err := migrations.NewMigrator(dbConection)
+		if err != nil {
+			return err
+		}
+
+		err = migrateUp(context.Background(), migrator)
+		if err != nil {
+			return err
+		}
+	}
+
 	cfg, err := awsConfig.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		log.Fatal(err)
@@ -35,21 +66,162 @@ func SetupAndRunApp() error {
 	client := s3.NewFromConfig(cfg)
 	presigner := s3.NewPresignClient(client)
 
+	KeySet, err := handlers.LoadKeySetFromEnv()
+	if err != nil {
+		return err
+	}
+	handlers.SetKeySet(KeySet)
+
 	server := gin.Default()
 
+	err = server.SetTrustedProxies(config.TrustedProxies())
+	if err != nil {
+		return err
+	}
+
 	server.Use(config.CORSMiddleware())
 
 	UserRepository := repository.NewUserRepository(dbConection)
+	SessionRepository := repository.NewSessionRepository(dbConection)
+	handlers.SetSessionValidator(SessionRepository)
+	UserTokenRepository := repository.NewUserTokenRepository(dbConection)
+	Mailer := mailer.NewMailerFromEnv()
+	MfaSecretBox, err := secretbox.FromEnv()
+	if err != nil {
+		return err
+	}
+	MfaRepository := repository.NewMfaRepository(dbConection, MfaSecretBox)
+	ApiKeyRepository := repository.NewApiKeyRepository(dbConection)
+	AccessKeyRepository := repository.NewAccessKeyRepository(dbConection)
+	SshKeyRepository := repository.NewSshKeyRepository(dbConection)
+	handlers.SetApiKeyValidator(ApiKeyRepository)
+	Plans, err := ratelimit.PlansFromEnv()
+	if err != nil {
+		return err
+	}
+	handlers.SetRateLimiter(handlers.NewRateLimiter(Plans, UserRepository))
 	AwsService := aws.NewAwsService(client, presigner)
-	AwsUsecase := usecase.NewAwsUsecase(AwsService)
-	UserUsecase := usecase.NewUserUseCase(UserRepository, AwsService)
+	JobQueue := jobs.NewPostgresQueue(dbConection)
+	ThumbnailRepository := repository.NewThumbnailRepository(dbConection)
+	// Thumbnails only read the user's files, so their storage queues none.
+	ThumbnailConfig := usecase.ThumbnailConfigFromEnv()
+	ThumbnailUsecase := usecase.NewThumbnailUsecase(usecase.NewStorageUsecase(AwsService, nil), ThumbnailRepository, JobQueue, ThumbnailConfig)
+	StorageUsecase := usecase.NewStorageUsecase(AwsService, &ThumbnailUsecase)
+	ThumbnailController := controllers.NewThumbnailController(ThumbnailUsecase)
+	ImageUsecase := usecase.NewImageUsecase(StorageUsecase, usecase.ImageConfigFromEnv())
+	ImageController := controllers.NewImageController(ImageUsecase)
+	UserBucketRepository := repository.NewUserBucketRepository(dbConection)
+	AwsUsecase := usecase.NewAwsUsecase(AwsService, UserBucketRepository, &ThumbnailUsecase)
+	BucketProvisioner := usecase.NewBucketProvisioner(UserRepository, AwsService, JobQueue)
+	UserUsecase := usecase.NewUserUseCase(UserRepository, BucketProvisioner, UserTokenRepository, Mailer)
 	UserController := controllers.NewUserController(UserUsecase)
-	LoginController := controllers.NewLoginController(UserUsecase)
+	LoginLockoutRepository := repository.NewLoginLockoutRepository(dbConection)
+	RateLimitStore := ratelimit.NewStoreFromEnv(dbConection)
+	LoginGuard := usecase.NewLoginGuard(RateLimitStore, LoginLockoutRepository, UserRepository, usecase.DefaultLoginGuardConfig())
+	LoginController := controllers.NewLoginController(UserUsecase, LoginGuard)
+	SessionUsecase := usecase.NewSessionUsecase(SessionRepository)
+	AuthUsecase := usecase.NewAuthUsecase(UserRepository, UserTokenRepository, SessionRepository, Mailer)
 	AwsController := controllers.NewAwsController(AwsUsecase)
+	SessionController := controllers.NewSessionController(SessionUsecase)
+	AuthController := controllers.NewAuthController(AuthUsecase)
+	MfaUsecase := usecase.NewMfaUsecase(MfaRepository, UserRepository, SessionRepository, LoginGuard)
+	MfaController := controllers.NewMfaController(MfaUsecase)
+
+	var OidcController *controllers.OidcController
+	if oidcConfig, enabled := oidc.ConfigFromEnv(); enabled {
+		IdentityRepository := repository.NewIdentityRepository(dbConection)
+		OidcProvider := oidc.NewProvider(oidcConfig, nil)
+		OidcUsecase := usecase.NewOidcUsecase(OidcProvider, oidc.NewMemoryStateStore(), UserRepository, IdentityRepository, MfaRepository, SessionRepository, BucketProvisioner)
+		controller := controllers.NewOidcController(OidcUsecase)
+		OidcController = &controller
+	}
+
+	ApiKeyUsecase := usecase.NewApiKeyUsecase(ApiKeyRepository)
+	ApiKeyController := controllers.NewApiKeyController(ApiKeyUsecase)
+	AccessKeyUsecase := usecase.NewAccessKeyUsecase(AccessKeyRepository)
+	AccessKeyController := controllers.NewAccessKeyController(AccessKeyUsecase)
+
+	AccountDeletionRepository := repository.NewAccountDeletionRepository(dbConection)
+	AccountUsecase := usecase.NewAccountUsecase(UserRepository, SessionRepository, ApiKeyRepository, AccessKeyRepository, SshKeyRepository, UserTokenRepository, Mailer, AwsService, UserBucketRepository, ThumbnailConfig.Bucket, AccountDeletionRepository, JobQueue)
+	AccountController := controllers.NewAccountController(AccountUsecase)
+
+	JobUsecase := usecase.NewJobUsecase(JobQueue)
+	JobController := controllers.NewJobController(JobUsecase)
 
-	routes.SetupRoutes(server, UserController, LoginController, AwsController)
+	DavController := controllers.NewDavController(StorageUsecase)
+	S3Controller := controllers.NewS3Controller(StorageUsecase, AccessKeyUsecase, UserUsecase, RateLimitStore)
+	ArchiveUsecase := usecase.NewArchiveUsecase(StorageUsecase)
+	ExtractionRepository := repository.NewExtractionRepository(dbConection)
+	ExtractUsecase := usecase.NewExtractUsecase(StorageUsecase, ExtractionRepository, JobQueue, usecase.ExtractLimitsFromEnv())
+	ArchiveController := controllers.NewArchiveController(ArchiveUsecase, ExtractUsecase)
 
-	server.Run(":8000")
+	SshKeyUsecase := usecase.NewSshKeyUsecase(SshKeyRepository)
+	SshKeyController := controllers.NewSshKeyController(SshKeyUsecase)
+
+	if sftpConfig, enabled := sftpserver.ConfigFromEnv(); enabled {
+		HostKey, err := sftpserver.LoadHostKey(sftpConfig.HostKeyFile)
+		if err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", sftpConfig.Addr)
+		if err != nil {
+			return err
+		}
+
+		SftpAuthUsecase := usecase.NewSftpAuthUsecase(UserRepository, MfaRepository, SshKeyRepository, LoginGuard)
+		SftpServer := sftpserver.NewServer(&StorageUsecase, &SftpAuthUsecase, HostKey)
+		go SftpServer.Serve(listener)
+	}
+
+	Worker := jobs.NewWorker(JobQueue, jobs.WorkerConfigFromEnv())
+	Worker.Register(usecase.JobProvisionStorage, BucketProvisioner.HandleJob)
+	Worker.Register(usecase.JobReconcileStorage, BucketProvisioner.Reconcile)
+	Worker.Register(usecase.JobDeleteAccount, AccountUsecase.HandleDeletionJob)
+	Worker.Register(usecase.JobExtractArchive, ExtractUsecase.HandleExtractionJob)
+	Worker.Register(usecase.JobGenerateThumbnails, ThumbnailUsecase.HandleThumbnailJob)
+	Worker.Register(jobs.JobPurge, JobQueue.HandlePurge)
+
+	Scheduler := jobs.NewScheduler(JobQueue)
+	err = Scheduler.Add("storage-reconcile", "*/15 * * * *", usecase.JobReconcileStorage, nil)
+	if err != nil {
+		return err
+	}
+	err = Scheduler.Add("jobs-purge", "0 3 * * *", jobs.JobPurge, nil)
+	if err != nil {
+		return err
+	}
+
+	// On SIGINT or SIGTERM the server stops taking requests and the process
+	// exits only once the workers are done with the jobs they were running.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	Workers := Worker.Start(ctx)
+	Scheduler.Start(ctx)
+
+	routes.SetupRoutes(server, UserController, LoginController, AwsController, SessionController, AuthController, MfaController, OidcController, ApiKeyController, AccountController, JobController, DavController, AccessKeyController, S3Controller, SshKeyController, ArchiveController, ThumbnailController, ImageController)
+	err = handlers.ValidateRateLimits()
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Addr: ":8000", Handler: server}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	err = httpServer.ListenAndServe()
+	stop()
+	Workers.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 
 	return nil
 }
@@ -1,11 +1,16 @@
 package aws
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net/url"
 	"os"
+	"path"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,6 +20,10 @@ import (
 	"github.com/aws/smithy-go"
 )
 
+// writePartSize is the part size of WriteObject, and so the memory each
+// streamed write holds.
+const writePartSize = 8 << 20
+
 type AwsService struct {
 	client    *s3.Client
 	presigner *s3.PresignClient
@@ -78,7 +87,8 @@ func (as *AwsService) CreateBucket(ctx context.Context, bucketName string) (*s3.
 
 	_, err = as.client.PutBucketCors(ctx, corsConfig)
 	if err != nil {
-		log.Fatalf("Erro configurando CORS: %v", err)
+		log.Printf("Erro configurando CORS: %v", err)
+		return nil, err
 	}
 
 	return output, nil
@@ -134,6 +144,37 @@ func (as *AwsService) ListBucketItems(ctx context.Context, bucketName string) ([
 	return objects, err
 }
 
+// ListBucketPage returns one page of up to maxKeys objects under prefix,
+// plus the token for the next page, empty on the last one.
+func (as *AwsService) ListBucketPage(ctx context.Context, bucketName string, prefix string, continuationToken string, maxKeys int32) ([]types.Object, string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		MaxKeys: aws.Int32(maxKeys),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if continuationToken != "" {
+		input.ContinuationToken = aws.String(continuationToken)
+	}
+
+	output, err := as.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		var noBucket *types.NoSuchBucket
+		if errors.As(err, &noBucket) {
+			log.Printf("O bucket %s não existe.\n", bucketName)
+			err = noBucket
+		}
+		return nil, "", err
+	}
+
+	if !aws.ToBool(output.IsTruncated) {
+		return output.Contents, "", nil
+	}
+
+	return output.Contents, aws.ToString(output.NextContinuationToken), nil
+}
+
 func (as *AwsService) GetObject(ctx context.Context, bucketName string, objectKey string, lifetimeSecs int64) (*v4.PresignedHTTPRequest, error) {
 	request, err := as.presigner.PresignGetObject(
 		ctx,
@@ -170,3 +211,349 @@ func (as *AwsService) PutObjectPresignedUrl(ctx context.Context, bucketName stri
 
 	return request, err
 }
+
+// EmptyBucket deletes every object of the bucket.
+func (as *AwsService) EmptyBucket(ctx context.Context, bucketName string) error {
+	return as.DeletePrefix(ctx, bucketName, "")
+}
+
+// DeletePrefix deletes every object under prefix in batches of up to 1000
+// keys, the DeleteObjects limit.
+func (as *AwsService) DeletePrefix(ctx context.Context, bucketName string, prefix string) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucketName),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	objectPaginator := s3.NewListObjectsV2Paginator(as.client, input)
+
+	for objectPaginator.HasMorePages() {
+		output, err := objectPaginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Não foi possível listar os objetos de %s: %v\n", bucketName, err)
+			return err
+		}
+
+		if len(output.Contents) == 0 {
+			continue
+		}
+
+		objects := make([]types.ObjectIdentifier, len(output.Contents))
+		for i, object := range output.Contents {
+			objects[i] = types.ObjectIdentifier{Key: object.Key}
+		}
+
+		deleted, err := as.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			log.Printf("Não foi possível apagar os objetos de %s: %v\n", bucketName, err)
+			return err
+		}
+
+		if len(deleted.Errors) > 0 {
+			return fmt.Errorf("não foi possível apagar %d objetos de %s: %s", len(deleted.Errors), bucketName, aws.ToString(deleted.Errors[0].Message))
+		}
+	}
+
+	return nil
+}
+
+// BucketExists tells whether the bucket is there and ours. A bucket of
+// another account comes back as an error, not as missing.
+func (as *AwsService) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	_, err := as.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucketName),
+	})
+
+	var missing *types.NotFound
+	if errors.As(err, &missing) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (as *AwsService) DeleteBucket(ctx context.Context, bucketName string) error {
+	_, err := as.client.DeleteBucket(ctx, &s3.DeleteBucketInput{
+		Bucket: aws.String(bucketName),
+	})
+	if err != nil {
+		log.Printf("Não foi possível apagar o bucket %s: %v\n", bucketName, err)
+		return err
+	}
+
+	return nil
+}
+
+func (as *AwsService) DeleteObject(ctx context.Context, bucketName string, objectKey string) error {
+	_, err := as.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Não foi possível apagar %s:%s: %v\n", bucketName, objectKey, err)
+		return err
+	}
+
+	return nil
+}
+
+// CopyObject copies an object inside the same bucket. The source key is
+// URL-encoded as CopySource requires.
+func (as *AwsService) CopyObject(ctx context.Context, bucketName string, sourceKey string, destinationKey string) error {
+	_, err := as.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		CopySource: aws.String(bucketName + "/" + url.PathEscape(sourceKey)),
+		Key:        aws.String(destinationKey),
+	})
+	if err != nil {
+		log.Printf("Não foi possível copiar %s:%s para %s: %v\n", bucketName, sourceKey, destinationKey, err)
+		return err
+	}
+
+	return nil
+}
+
+func (as *AwsService) CreateMultipartUpload(ctx context.Context, bucketName string, objectKey string) (string, error) {
+	output, err := as.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("Não foi possível iniciar o upload de %s:%s: %v\n", bucketName, objectKey, err)
+		return "", err
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+func (as *AwsService) PresignUploadPart(ctx context.Context, bucketName string, objectKey string, uploadId string, partNumber int32, lifetimeSecs int64) (*v4.PresignedHTTPRequest, error) {
+	request, err := as.presigner.PresignUploadPart(
+		ctx,
+		&s3.UploadPartInput{
+			Bucket:     aws.String(bucketName),
+			Key:        aws.String(objectKey),
+			UploadId:   aws.String(uploadId),
+			PartNumber: aws.Int32(partNumber),
+		}, func(po *s3.PresignOptions) {
+			po.Expires = time.Duration(lifetimeSecs * int64(time.Second))
+		},
+	)
+	if err != nil {
+		log.Printf("Não foi possível assinar a parte %d de %s:%s: %v\n", partNumber, bucketName, objectKey, err)
+	}
+
+	return request, err
+}
+
+func (as *AwsService) CompleteMultipartUpload(ctx context.Context, bucketName string, objectKey string, uploadId string, parts []types.CompletedPart) error {
+	_, err := as.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectKey),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		log.Printf("Não foi possível concluir o upload de %s:%s: %v\n", bucketName, objectKey, err)
+		return err
+	}
+
+	return nil
+}
+
+func (as *AwsService) AbortMultipartUpload(ctx context.Context, bucketName string, objectKey string, uploadId string) error {
+	_, err := as.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadId),
+	})
+	if err != nil {
+		log.Printf("Não foi possível cancelar o upload de %s:%s: %v\n", bucketName, objectKey, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListObjectsDelimited lists, across all pages, the objects directly under
+// prefix and the sub-prefixes ("folders") one level down.
+func (as *AwsService) ListObjectsDelimited(ctx context.Context, bucketName string, prefix string) ([]types.Object, []string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucketName),
+		Delimiter: aws.String("/"),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	var objects []types.Object
+	var prefixes []string
+	objectPaginator := s3.NewListObjectsV2Paginator(as.client, input)
+	for objectPaginator.HasMorePages() {
+		output, err := objectPaginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Não foi possível listar %s:%s: %v\n", bucketName, prefix, err)
+			return nil, nil, err
+		}
+
+		objects = append(objects, output.Contents...)
+		for _, common := range output.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(common.Prefix))
+		}
+	}
+
+	return objects, prefixes, nil
+}
+
+func (as *AwsService) HeadObject(ctx context.Context, bucketName string, objectKey string) (*s3.HeadObjectOutput, error) {
+	return as.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+}
+
+// ReadObject opens the object for streaming. byteRange is an HTTP Range
+// value such as "bytes=100-"; empty reads it whole. The caller closes Body.
+func (as *AwsService) ReadObject(ctx context.Context, bucketName string, objectKey string, byteRange string) (*s3.GetObjectOutput, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	}
+	if byteRange != "" {
+		input.Range = aws.String(byteRange)
+	}
+
+	return as.client.GetObject(ctx, input)
+}
+
+// WriteObject stores everything read from body under the key without
+// knowing its size up front. Memory stays at one part: bodies that fit in
+// it are sent with a single PutObject, bigger ones as a multipart upload.
+// It returns the ETag of the new object.
+func (as *AwsService) WriteObject(ctx context.Context, bucketName string, objectKey string, body io.Reader) (string, error) {
+	contentType := mime.TypeByExtension(path.Ext(objectKey))
+	buffer := make([]byte, writePartSize)
+
+	var uploadId string
+	var parts []types.CompletedPart
+
+	for partNumber := int32(1); ; partNumber++ {
+		n, readErr := readPart(body, buffer)
+		if readErr != nil && readErr != io.EOF {
+			if uploadId != "" {
+				as.AbortMultipartUpload(context.WithoutCancel(ctx), bucketName, objectKey, uploadId)
+			}
+			return "", readErr
+		}
+
+		if partNumber == 1 && readErr == io.EOF {
+			input := &s3.PutObjectInput{
+				Bucket:        aws.String(bucketName),
+				Key:           aws.String(objectKey),
+				Body:          bytes.NewReader(buffer[:n]),
+				ContentLength: aws.Int64(int64(n)),
+			}
+			if contentType != "" {
+				input.ContentType = aws.String(contentType)
+			}
+
+			output, err := as.client.PutObject(ctx, input)
+			if err != nil {
+				log.Printf("Não foi possível gravar %s:%s: %v\n", bucketName, objectKey, err)
+				return "", err
+			}
+			return aws.ToString(output.ETag), nil
+		}
+
+		if uploadId == "" {
+			input := &s3.CreateMultipartUploadInput{
+				Bucket: aws.String(bucketName),
+				Key:    aws.String(objectKey),
+			}
+			if contentType != "" {
+				input.ContentType = aws.String(contentType)
+			}
+
+			output, err := as.client.CreateMultipartUpload(ctx, input)
+			if err != nil {
+				log.Printf("Não foi possível iniciar o upload de %s:%s: %v\n", bucketName, objectKey, err)
+				return "", err
+			}
+			uploadId = aws.ToString(output.UploadId)
+		}
+
+		if n > 0 {
+			etag, err := as.UploadPart(ctx, bucketName, objectKey, uploadId, partNumber, bytes.NewReader(buffer[:n]), int64(n))
+			if err != nil {
+				as.AbortMultipartUpload(context.WithoutCancel(ctx), bucketName, objectKey, uploadId)
+				return "", err
+			}
+			parts = append(parts, types.CompletedPart{PartNumber: aws.Int32(partNumber), ETag: aws.String(etag)})
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	output, err := as.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucketName),
+		Key:             aws.String(objectKey),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		log.Printf("Não foi possível concluir o upload de %s:%s: %v\n", bucketName, objectKey, err)
+		as.AbortMultipartUpload(context.WithoutCancel(ctx), bucketName, objectKey, uploadId)
+		return "", err
+	}
+
+	return aws.ToString(output.ETag), nil
+}
+
+// readPart fills buffer from body. Only io.EOF ends the body; any other
+// error, io.ErrUnexpectedEOF included, means it was cut short and is
+// returned as is. io.ReadFull would take a reader's own
+// io.ErrUnexpectedEOF for a short final part.
+func readPart(body io.Reader, buffer []byte) (int, error) {
+	n := 0
+	for n < len(buffer) {
+		read, err := body.Read(buffer[n:])
+		n += read
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// UploadPart sends one part of a multipart upload through the server and
+// returns its ETag.
+func (as *AwsService) UploadPart(ctx context.Context, bucketName string, objectKey string, uploadId string, partNumber int32, body io.Reader, size int64) (string, error) {
+	output, err := as.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(bucketName),
+		Key:           aws.String(objectKey),
+		UploadId:      aws.String(uploadId),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		log.Printf("Não foi possível enviar a parte %d de %s:%s: %v\n", partNumber, bucketName, objectKey, err)
+		return "", err
+	}
+
+	return aws.ToString(output.ETag), nil
+}
+
+// ListObjectsV2 runs one raw listing request, for callers that page
+// themselves. The input names the bucket.
+func (as *AwsService) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	return as.client.ListObjectsV2(ctx, input)
+}
@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// newTestService points the service at a fake S3 that records the calls
+// it gets.
+func newTestService(t *testing.T) (*AwsService, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		query := r.URL.Query()
+
+		call := r.Method
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			call = "CreateMultipartUpload"
+			io.WriteString(w, `<InitiateMultipartUploadResult><Bucket>fotos</Bucket><Key>grande.bin</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+		case r.Method == http.MethodPut && query.Has("partNumber"):
+			call = "UploadPart"
+			w.Header().Set("ETag", `"parte"`)
+		case r.Method == http.MethodPut:
+			call = "PutObject"
+			w.Header().Set("ETag", `"objeto"`)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			call = "CompleteMultipartUpload"
+			io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"objeto"</ETag></CompleteMultipartUploadResult>`)
+		case r.Method == http.MethodDelete && query.Has("uploadId"):
+			call = "AbortMultipartUpload"
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		mu.Lock()
+		calls = append(calls, call)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-2",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("chave", "segredo", ""),
+	})
+
+	return NewAwsService(client, s3.NewPresignClient(client)), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+// cutBody yields size bytes and then fails the way a request body does
+// when the client disconnects before its Content-Length.
+func cutBody(size int) io.Reader {
+	return io.MultiReader(strings.NewReader(strings.Repeat("a", size)), &failingReader{err: io.ErrUnexpectedEOF})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (f *failingReader) Read([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestWriteObjectStoresNothingFromATruncatedBody(t *testing.T) {
+	service, calls := newTestService(t)
+
+	if _, err := service.WriteObject(context.Background(), "fotos", "notas.txt", cutBody(10)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("esperava io.ErrUnexpectedEOF, veio %v", err)
+	}
+	if made := calls(); len(made) != 0 {
+		t.Fatalf("nada deveria ser gravado, veio %v", made)
+	}
+
+	if _, err := service.WriteObject(context.Background(), "fotos", "grande.bin", cutBody(writePartSize+10)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("esperava io.ErrUnexpectedEOF, veio %v", err)
+	}
+	made := calls()
+	if strings.Join(made, ",") != "CreateMultipartUpload,UploadPart,AbortMultipartUpload" {
+		t.Fatalf("o upload deveria ser abortado, veio %v", made)
+	}
+
+	if _, err := service.WriteObject(context.Background(), "fotos", "notas.txt", strings.NewReader("inteiro")); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if made := calls(); made[len(made)-1] != "PutObject" {
+		t.Fatalf("o corpo inteiro deveria ser gravado, veio %v", made)
+	}
+}
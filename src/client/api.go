@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const pageSize = 1000
+
+// Object is an entry of the bucket listing. The API serializes S3 objects
+// with their Go field names, which decode case-insensitively into these.
+type Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// Presigned is a request signed by the server that must be sent as is,
+// straight to S3.
+type Presigned struct {
+	URL          string              `json:"URL"`
+	Method       string              `json:"Method"`
+	SignedHeader map[string][]string `json:"SignedHeader"`
+}
+
+type LoginResult struct {
+	Token       string `json:"token"`
+	MfaRequired bool   `json:"mfaRequired"`
+	MfaToken    string `json:"mfaToken"`
+}
+
+type CompletedPart struct {
+	PartNumber int32  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// Login starts a session with email and password. When the account has MFA
+// the result only carries the MfaToken to pass to LoginMfa.
+func (c *Client) Login(ctx context.Context, email string, password string) (*LoginResult, error) {
+	var result LoginResult
+	err := c.anonymous(ctx, http.MethodPost, "/login", map[string]string{
+		"email":    email,
+		"password": password,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) LoginMfa(ctx context.Context, mfaToken string, code string) (*LoginResult, error) {
+	var result LoginResult
+	err := c.anonymous(ctx, http.MethodPost, "/login/mfa", map[string]string{
+		"mfaToken": mfaToken,
+		"code":     code,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// IssueRefreshToken asks a refresh token for the current session and keeps
+// it in the client.
+func (c *Client) IssueRefreshToken(ctx context.Context) (string, error) {
+	var result struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/auth/refresh-token", nil, &result); err != nil {
+		return "", err
+	}
+
+	token, _ := c.Tokens()
+	c.SetTokens(token, result.RefreshToken)
+
+	return result.RefreshToken, nil
+}
+
+// Logout ends the session the client is logged in with.
+func (c *Client) Logout(ctx context.Context) error {
+	return c.call(ctx, http.MethodPost, "/auth/logout", nil, nil)
+}
+
+// ListObjects lists every object under prefix, following the pages of the
+// listing.
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	cursor := ""
+	for {
+		page, next, err := c.ListPage(ctx, prefix, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, page...)
+		if next == "" {
+			return objects, nil
+		}
+		cursor = next
+	}
+}
+
+// ListPage returns one page of the listing and the cursor of the next one,
+// empty when it was the last.
+func (c *Client) ListPage(ctx context.Context, prefix string, cursor string) ([]Object, string, error) {
+	query := url.Values{"prefix": {prefix}, "limit": {strconv.Itoa(pageSize)}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	var objects []Object
+	header, err := c.callWithHeader(ctx, http.MethodGet, "/aws/bucket/items?"+query.Encode(), nil, &objects)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return objects, header.Get("X-Next-Cursor"), nil
+}
+
+func (c *Client) PresignGet(ctx context.Context, key string) (*Presigned, error) {
+	var presigned Presigned
+	if err := c.call(ctx, http.MethodPost, "/aws/bucket/object", map[string]string{"key": key}, &presigned); err != nil {
+		return nil, err
+	}
+
+	return &presigned, nil
+}
+
+func (c *Client) PresignPut(ctx context.Context, key string) (*Presigned, error) {
+	var presigned Presigned
+	if err := c.call(ctx, http.MethodPost, "/aws/bucket/put", map[string]string{"key": key}, &presigned); err != nil {
+		return nil, err
+	}
+
+	return &presigned, nil
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.call(ctx, http.MethodPost, "/aws/bucket/delete", map[string]string{"key": key}, nil)
+}
+
+func (c *Client) Move(ctx context.Context, source string, destination string) error {
+	return c.call(ctx, http.MethodPost, "/aws/bucket/move", map[string]string{
+		"source":      source,
+		"destination": destination,
+	}, nil)
+}
+
+// Share returns a download link valid for the given duration; zero lets
+// the server pick its default.
+func (c *Client) Share(ctx context.Context, key string, expiresIn time.Duration) (*Presigned, error) {
+	var presigned Presigned
+	err := c.call(ctx, http.MethodPost, "/aws/bucket/share", map[string]any{
+		"key":       key,
+		"expiresIn": int64(expiresIn / time.Second),
+	}, &presigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return &presigned, nil
+}
+
+func (c *Client) StartMultipart(ctx context.Context, key string) (string, error) {
+	var result struct {
+		UploadId string `json:"uploadId"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/aws/bucket/multipart", map[string]string{"key": key}, &result); err != nil {
+		return "", err
+	}
+
+	return result.UploadId, nil
+}
+
+func (c *Client) PresignPart(ctx context.Context, key string, uploadId string, partNumber int32) (*Presigned, error) {
+	var presigned Presigned
+	err := c.call(ctx, http.MethodPost, "/aws/bucket/multipart/part", map[string]any{
+		"key":        key,
+		"uploadId":   uploadId,
+		"partNumber": partNumber,
+	}, &presigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return &presigned, nil
+}
+
+func (c *Client) CompleteMultipart(ctx context.Context, key string, uploadId string, parts []CompletedPart) error {
+	return c.call(ctx, http.MethodPost, "/aws/bucket/multipart/complete", map[string]any{
+		"key":      key,
+		"uploadId": uploadId,
+		"parts":    parts,
+	}, nil)
+}
+
+func (c *Client) AbortMultipart(ctx context.Context, key string, uploadId string) error {
+	return c.call(ctx, http.MethodPost, "/aws/bucket/multipart/abort", map[string]string{
+		"key":      key,
+		"uploadId": uploadId,
+	}, nil)
+}
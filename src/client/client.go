@@ -0,0 +1,206 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotLoggedIn = errors.New("sessão expirada, faça login novamente")
+
+// APIError is a non-2xx answer of the API, carrying the message the server
+// sent back.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("o servidor respondeu %d", e.Status)
+	}
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// IsStatus reports whether err is an APIError with the given status.
+func IsStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == status
+}
+
+// Client talks to the HTTP API. When a request is rejected with 401 and a
+// refresh token is available, it renews the access token once and retries;
+// OnRefresh is told about the new pair so it can be persisted. Transfer is
+// used for the presigned S3 requests, which have no overall timeout since
+// they may carry large files.
+type Client struct {
+	Server    string
+	HTTP      *http.Client
+	Transfer  *http.Client
+	OnRefresh func(token string, refreshToken string)
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+}
+
+func New(server string, token string, refreshToken string) *Client {
+	return &Client{
+		Server:       strings.TrimRight(server, "/"),
+		HTTP:         &http.Client{Timeout: time.Minute},
+		Transfer:     &http.Client{},
+		token:        token,
+		refreshToken: refreshToken,
+	}
+}
+
+func (c *Client) Tokens() (string, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, c.refreshToken
+}
+
+func (c *Client) SetTokens(token string, refreshToken string) {
+	c.mu.Lock()
+	c.token = token
+	c.refreshToken = refreshToken
+	c.mu.Unlock()
+}
+
+// call sends body as JSON and decodes the answer into out, which may be nil.
+func (c *Client) call(ctx context.Context, method string, path string, body any, out any) error {
+	_, err := c.callWithHeader(ctx, method, path, body, out)
+	return err
+}
+
+// callWithHeader is call for the routes that also answer in headers.
+func (c *Client) callWithHeader(ctx context.Context, method string, path string, body any, out any) (http.Header, error) {
+	payload, err := marshalBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	token, _ := c.Tokens()
+	header, err := c.send(ctx, method, path, payload, token, out)
+	if !IsStatus(err, http.StatusUnauthorized) {
+		return header, err
+	}
+
+	renewed, refreshErr := c.refresh(ctx, token)
+	if refreshErr != nil {
+		return nil, refreshErr
+	}
+
+	return c.send(ctx, method, path, payload, renewed, out)
+}
+
+// anonymous is call for the routes that come before having a token.
+func (c *Client) anonymous(ctx context.Context, method string, path string, body any, out any) error {
+	payload, err := marshalBody(body)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.send(ctx, method, path, payload, "", out)
+	return err
+}
+
+func marshalBody(body any) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	return json.Marshal(body)
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, payload []byte, token string, out any) (http.Header, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.Server+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return nil, readAPIError(response)
+	}
+
+	if out == nil {
+		return response.Header, nil
+	}
+
+	return response.Header, json.NewDecoder(response.Body).Decode(out)
+}
+
+// refresh renews the access token unless another request already did it
+// while this one was in flight.
+func (c *Client) refresh(ctx context.Context, staleToken string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != staleToken {
+		return c.token, nil
+	}
+
+	if c.refreshToken == "" {
+		return "", ErrNotLoggedIn
+	}
+
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	err := c.anonymous(ctx, http.MethodPost, "/auth/refresh", map[string]string{"refreshToken": c.refreshToken}, &tokens)
+	if err != nil {
+		if IsStatus(err, http.StatusUnauthorized) {
+			return "", ErrNotLoggedIn
+		}
+		return "", err
+	}
+
+	c.token = tokens.Token
+	c.refreshToken = tokens.RefreshToken
+
+	if c.OnRefresh != nil {
+		c.OnRefresh(tokens.Token, tokens.RefreshToken)
+	}
+
+	return tokens.Token, nil
+}
+
+func readAPIError(response *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(response.Body, 64*1024)).Decode(&body)
+
+	message := body.Message
+	if message == "" {
+		message = body.Error
+	}
+
+	return &APIError{Status: response.StatusCode, Message: message}
+}
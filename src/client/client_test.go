@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	var refreshed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/refresh":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["refreshToken"] != "refresh-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"token": "novo", "refreshToken": "refresh-2"})
+		case "/aws/bucket/items":
+			if r.Header.Get("Authorization") != "Bearer novo" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"Message": "Token inválido"})
+				return
+			}
+			w.Write([]byte(`[{"Key":"doc.txt","Size":3,"ETag":"\"abc\""}]`))
+		default:
+			t.Fatalf("rota inesperada %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, "velho", "refresh-1")
+	c.OnRefresh = func(token string, refreshToken string) {
+		refreshed = append(refreshed, token, refreshToken)
+	}
+
+	objects, err := c.ListObjects(context.Background(), "")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "doc.txt" || objects[0].Size != 3 {
+		t.Fatalf("listagem inesperada %#v", objects)
+	}
+	if len(refreshed) != 2 || refreshed[0] != "novo" || refreshed[1] != "refresh-2" {
+		t.Fatalf("esperava salvar o novo par de tokens, veio %v", refreshed)
+	}
+}
+
+func TestClientRefreshRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	c := New(server.URL, "velho", "revogado")
+
+	if _, err := c.ListObjects(context.Background(), ""); err != ErrNotLoggedIn {
+		t.Fatalf("esperava ErrNotLoggedIn, veio %v", err)
+	}
+}
+
+func TestClientUploadAndDownloadThroughPresignedURL(t *testing.T) {
+	var stored []byte
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Test") != "assinado" {
+			t.Fatalf("os cabeçalhos assinados deveriam ser repassados")
+		}
+		switch r.Method {
+		case http.MethodPut:
+			stored, _ = io.ReadAll(r.Body)
+			w.Header().Set("ETag", `"etag"`)
+		case http.MethodGet:
+			w.Write(stored)
+		}
+	}))
+	defer storage.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := http.MethodGet
+		if r.URL.Path == "/aws/bucket/put" {
+			method = http.MethodPut
+		}
+		json.NewEncoder(w).Encode(Presigned{
+			URL:          storage.URL + "/files-1/doc.txt",
+			Method:       method,
+			SignedHeader: map[string][]string{"Host": {"s3"}, "X-Amz-Test": {"assinado"}},
+		})
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "doc.txt")
+	os.WriteFile(source, []byte("conteúdo"), 0o644)
+
+	c := New(api.URL, "token", "")
+
+	var sent int64
+	if err := c.Upload(context.Background(), "doc.txt", source, func(n int64) { sent += n }); err != nil {
+		t.Fatalf("não esperava erro no envio, veio %v", err)
+	}
+	if string(stored) != "conteúdo" || sent != int64(len("conteúdo")) {
+		t.Fatalf("envio inesperado %q (%d bytes)", stored, sent)
+	}
+
+	target := filepath.Join(dir, "baixados", "doc.txt")
+	if err := c.Download(context.Background(), "doc.txt", target, nil); err != nil {
+		t.Fatalf("não esperava erro no download, veio %v", err)
+	}
+
+	data, _ := os.ReadFile(target)
+	if string(data) != "conteúdo" {
+		t.Fatalf("download inesperado %q", data)
+	}
+}
+
+func TestPartSizeStaysUnderPartLimit(t *testing.T) {
+	if PartSize(100<<20) != minPartSize {
+		t.Fatalf("arquivos médios deveriam usar a parte mínima")
+	}
+
+	size := int64(500 << 30)
+	if parts := (size + PartSize(size) - 1) / PartSize(size); parts > maxParts {
+		t.Fatalf("esperava no máximo %d partes, veio %d", maxParts, parts)
+	}
+}
+
+func TestClientListObjectsFollowsPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("prefix") != "docs/" {
+			t.Fatalf("prefixo inesperado %q", r.URL.Query().Get("prefix"))
+		}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Header().Set("X-Next-Cursor", "pagina-2")
+			w.Write([]byte(`[{"Key":"docs/a.txt"}]`))
+		case "pagina-2":
+			w.Write([]byte(`[{"Key":"docs/b.txt"}]`))
+		default:
+			t.Fatalf("cursor inesperado %q", r.URL.Query().Get("cursor"))
+		}
+	}))
+	defer server.Close()
+
+	objects, err := New(server.URL, "token", "").ListObjects(context.Background(), "docs/")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(objects) != 2 || objects[1].Key != "docs/b.txt" {
+		t.Fatalf("esperava as duas páginas, veio %#v", objects)
+	}
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// Files from this size on are sent in parts, in parallel.
+	MultipartThreshold = 64 << 20
+	minPartSize        = 16 << 20
+	maxParts           = 10000
+	partConcurrency    = 4
+	partAttempts       = 3
+)
+
+// Progress is told how many bytes were transferred since its last call.
+// A negative count takes back bytes of a part that is going to be resent.
+type Progress func(n int64)
+
+// Upload sends the file at path to key, through a presigned PUT or, for big
+// files, a multipart upload.
+func (c *Client) Upload(ctx context.Context, key string, path string, progress Progress) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() >= MultipartThreshold {
+		return c.uploadMultipart(ctx, key, file, info.Size(), progress)
+	}
+
+	presigned, err := c.PresignPut(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendPresigned(ctx, presigned, io.NewSectionReader(file, 0, info.Size()), info.Size(), progress)
+	return err
+}
+
+func (c *Client) uploadMultipart(ctx context.Context, key string, file *os.File, size int64, progress Progress) error {
+	partSize := PartSize(size)
+	count := int((size + partSize - 1) / partSize)
+
+	uploadId, err := c.StartMultipart(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([]CompletedPart, count)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var uploadErr error
+
+	for range min(partConcurrency, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				offset := int64(i) * partSize
+				section := io.NewSectionReader(file, offset, min(partSize, size-offset))
+
+				etag, err := c.uploadPart(ctx, key, uploadId, int32(i+1), section, progress)
+				if err != nil {
+					once.Do(func() {
+						uploadErr = err
+						cancel()
+					})
+					continue
+				}
+
+				parts[i] = CompletedPart{PartNumber: int32(i + 1), ETag: etag}
+			}
+		}()
+	}
+
+	for i := 0; i < count && ctx.Err() == nil; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if uploadErr == nil && ctx.Err() != nil {
+		uploadErr = ctx.Err()
+	}
+
+	if uploadErr != nil {
+		// Parts already stored are billed until the upload is aborted.
+		c.AbortMultipart(context.WithoutCancel(ctx), key, uploadId)
+		return uploadErr
+	}
+
+	return c.CompleteMultipart(ctx, key, uploadId, parts)
+}
+
+func (c *Client) uploadPart(ctx context.Context, key string, uploadId string, partNumber int32, section *io.SectionReader, progress Progress) (string, error) {
+	var err error
+
+	for attempt := 0; attempt < partAttempts; attempt++ {
+		var presigned *Presigned
+		presigned, err = c.PresignPart(ctx, key, uploadId, partNumber)
+		if err != nil {
+			return "", err
+		}
+
+		var sent atomic.Int64
+		counted := func(n int64) {
+			sent.Add(n)
+			if progress != nil {
+				progress(n)
+			}
+		}
+
+		var response *http.Response
+		response, err = c.sendPresigned(ctx, presigned, io.NewSectionReader(section, 0, section.Size()), section.Size(), counted)
+		if err == nil {
+			return response.Header.Get("ETag"), nil
+		}
+
+		if progress != nil {
+			progress(-sent.Load())
+		}
+
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+
+	return "", err
+}
+
+// Download saves key to path. The data goes to a temporary file next to it
+// first so an interrupted download never leaves a truncated file behind.
+func (c *Client) Download(ctx context.Context, key string, path string, progress Progress) error {
+	presigned, err := c.PresignGet(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	request, err := presigned.request(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.Transfer.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return &APIError{Status: response.StatusCode, Message: "não foi possível baixar " + key}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = io.Copy(temp, &progressReader{reader: response.Body, progress: progress})
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// PartSize picks the multipart part size for a file, growing it past the
+// minimum when needed to stay under S3's limit of parts.
+func PartSize(size int64) int64 {
+	partSize := int64(minPartSize)
+	if size > partSize*maxParts {
+		partSize = (size + maxParts - 1) / maxParts
+	}
+	return partSize
+}
+
+func (c *Client) sendPresigned(ctx context.Context, presigned *Presigned, body io.Reader, size int64, progress Progress) (*http.Response, error) {
+	if size > 0 {
+		body = &progressReader{reader: body, progress: progress}
+	} else {
+		body = http.NoBody
+	}
+
+	request, err := presigned.request(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = size
+
+	response, err := c.Transfer.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 300 {
+		return nil, &APIError{Status: response.StatusCode, Message: fmt.Sprintf("o S3 recusou o envio (%s)", response.Status)}
+	}
+
+	return response, nil
+}
+
+func (p *Presigned) request(ctx context.Context, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, p.Method, p.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range p.SignedHeader {
+		// Go derives Host from the URL and ignores it in the header map.
+		if http.CanonicalHeaderKey(name) == "Host" {
+			continue
+		}
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	return request, nil
+}
+
+type progressReader struct {
+	reader   io.Reader
+	progress Progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.reader.Read(b)
+	if n > 0 && pr.progress != nil {
+		pr.progress(int64(n))
+	}
+	return n, err
+}
@@ -34,10 +34,13 @@ func CORSMiddleware() gin.HandlerFunc {
 			ctx.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 			ctx.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-			ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+			ctx.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+			ctx.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, X-Next-Cursor")
 		}
 
-		if ctx.Request.Method == "OPTIONS" {
+		// Only preflights end here; a plain OPTIONS is how WebDAV clients
+		// discover the server.
+		if ctx.Request.Method == "OPTIONS" && ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
 			ctx.AbortWithStatus(204)
 			return
 		}
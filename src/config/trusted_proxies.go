@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// TrustedProxies lists the proxies allowed to set X-Forwarded-For. The
+// client IP feeds the login rate limits, so by default no proxy is trusted.
+func TrustedProxies() []string {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return nil
+	}
+
+	return strings.Split(proxies, ",")
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccessKeyController struct {
+	accessKeyUsecase usecase.AccessKeyUsecase
+}
+
+func NewAccessKeyController(usecase usecase.AccessKeyUsecase) AccessKeyController {
+	return AccessKeyController{
+		accessKeyUsecase: usecase,
+	}
+}
+
+func (kc *AccessKeyController) CreateAccessKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.CreateAccessKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := kc.accessKeyUsecase.CreateAccessKey(claimInt(claims, "userId"), *input)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAccessKey) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível criar a chave de acesso S3",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+func (kc *AccessKeyController) GetAccessKeys(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	accessKeys, err := kc.accessKeyUsecase.GetAccessKeys(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível listar as chaves de acesso S3",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, accessKeys)
+}
+
+func (kc *AccessKeyController) RevokeAccessKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	accessKeyId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da chave precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	revoked, err := kc.accessKeyUsecase.RevokeAccessKey(claimInt(claims, "userId"), accessKeyId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível revogar a chave de acesso S3",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !revoked {
+		response := handlers.Response{
+			Message: "Chave de acesso não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountController struct {
+	accountUsecase usecase.AccountUsecase
+}
+
+func NewAccountController(usecase usecase.AccountUsecase) AccountController {
+	return AccountController{
+		accountUsecase: usecase,
+	}
+}
+
+func (ac *AccountController) UpdateProfile(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.UpdateProfileDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := ac.accountUsecase.UpdateProfile(claimInt(claims, "userId"), *input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidProfile):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrEmailTaken):
+			ctx.JSON(http.StatusConflict, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível atualizar o perfil"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+func (ac *AccountController) ChangePassword(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.ChangePasswordDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = ac.accountUsecase.ChangePassword(claimInt(claims, "userId"), claimInt(claims, "sessionId"), *input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrWeakPassword):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrWrongPassword):
+			ctx.JSON(http.StatusForbidden, handlers.Response{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível alterar a senha"})
+		}
+		return
+	}
+
+	response := handlers.Response{
+		Message: "Senha alterada com sucesso",
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// DeleteAccount answers 202 with the deletion record; emptying the buckets
+// can take a while, so it runs on the job queue.
+func (ac *AccountController) DeleteAccount(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.DeleteAccountDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deletion, err := ac.accountUsecase.DeleteAccount(claimInt(claims, "userId"), input.Password)
+	if err != nil {
+		if errors.Is(err, usecase.ErrWrongPassword) {
+			ctx.JSON(http.StatusForbidden, handlers.Response{Message: err.Error()})
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível excluir a conta",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, deletion)
+}
+
+func (ac *AccountController) GetAccountDeletion(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da exclusão precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	deletion, err := ac.accountUsecase.GetAccountDeletion(id)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível consultar a exclusão",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if deletion == nil {
+		response := handlers.Response{
+			Message: "Exclusão não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, deletion)
+}
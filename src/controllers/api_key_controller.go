@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeyController struct {
+	apiKeyUsecase usecase.ApiKeyUsecase
+}
+
+func NewApiKeyController(usecase usecase.ApiKeyUsecase) ApiKeyController {
+	return ApiKeyController{
+		apiKeyUsecase: usecase,
+	}
+}
+
+func (ac *ApiKeyController) CreateApiKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.CreateApiKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := ac.apiKeyUsecase.CreateApiKey(claimInt(claims, "userId"), *input)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidApiKey) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível criar a chave de API",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, created)
+}
+
+func (ac *ApiKeyController) GetApiKeys(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := ac.apiKeyUsecase.GetApiKeys(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível listar as chaves de API",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiKeys)
+}
+
+func (ac *ApiKeyController) RevokeApiKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	apiKeyId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da chave precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	revoked, err := ac.apiKeyUsecase.RevokeApiKey(claimInt(claims, "userId"), apiKeyId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível revogar a chave de API",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !revoked {
+		response := handlers.Response{
+			Message: "Chave de API não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ArchiveController struct {
+	archiveUsecase usecase.ArchiveUsecase
+	extractUsecase usecase.ExtractUsecase
+}
+
+func NewArchiveController(archiveUsecase usecase.ArchiveUsecase, extractUsecase usecase.ExtractUsecase) ArchiveController {
+	return ArchiveController{
+		archiveUsecase: archiveUsecase,
+		extractUsecase: extractUsecase,
+	}
+}
+
+// DownloadZip streams a ZIP of a prefix or of a list of keys, built while
+// it is sent.
+func (ac *ArchiveController) DownloadZip(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.DownloadZipDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userId := claimInt(claims, "userId")
+	entries, err := ac.archiveUsecase.ZipEntries(ctx.Request.Context(), userId, *input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidZipRequest), errors.Is(err, usecase.ErrZipTooLarge):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrEmptyZip), errors.Is(err, usecase.ErrBucketNotFound):
+			ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+		default:
+			fmt.Println(err)
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível gerar o arquivo ZIP"})
+		}
+		return
+	}
+
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": usecase.ZipFileName(*input)}))
+	ctx.Status(http.StatusOK)
+
+	if err := ac.archiveUsecase.WriteZip(ctx.Request.Context(), userId, entries, ctx.Writer); err != nil {
+		// The response has started, so the status cannot change. Dropping
+		// the connection keeps the client from taking the archive as whole.
+		if conn, _, err := ctx.Writer.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// ListArchive lists the entries of a ZIP archive of the bucket.
+func (ac *ArchiveController) ListArchive(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.ObjectKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := ac.extractUsecase.ListArchive(ctx.Request.Context(), claimInt(claims, "userId"), input.ObectKey)
+	if err != nil {
+		archiveError(ctx, err, "Não foi possível listar o conteúdo do arquivo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, files)
+}
+
+// ExtractArchive queues the extraction of an archive; its progress is
+// followed with GetExtraction.
+func (ac *ArchiveController) ExtractArchive(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.ExtractArchiveDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	extraction, err := ac.extractUsecase.StartExtraction(ctx.Request.Context(), claimInt(claims, "userId"), *input)
+	if err != nil {
+		archiveError(ctx, err, "Não foi possível iniciar a extração")
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, extraction)
+}
+
+func (ac *ArchiveController) GetExtraction(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	extractionId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da extração precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	extraction, err := ac.extractUsecase.GetExtraction(claimInt(claims, "userId"), extractionId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível buscar a extração",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if extraction == nil {
+		response := handlers.Response{
+			Message: "Extração não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, extraction)
+}
+
+// archiveError answers with the status matching an archive error, falling
+// back to a 500 with the given message.
+func archiveError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, usecase.ErrUnsupportedArchive), errors.Is(err, usecase.ErrNotZipArchive):
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrInvalidArchive):
+		ctx.JSON(http.StatusUnprocessableEntity, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrObjectNotFound), errors.Is(err, usecase.ErrBucketNotFound):
+		ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+	default:
+		fmt.Println(err)
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: message})
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthController struct {
+	authUsecase usecase.AuthUsecase
+}
+
+func NewAuthController(usecase usecase.AuthUsecase) AuthController {
+	return AuthController{
+		authUsecase: usecase,
+	}
+}
+
+func (ac *AuthController) ForgotPassword(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.ForgotPasswordDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Email == "" {
+		response := handlers.Response{
+			Message: "É necessário informar o email",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	// The lookup and the mail run in the background so neither the status
+	// nor the response time tell whether the email is registered.
+	go func(email string) {
+		if err := ac.authUsecase.ForgotPassword(email); err != nil {
+			fmt.Println(err)
+		}
+	}(input.Email)
+
+	response := handlers.Response{
+		Message: "Se o email estiver cadastrado, você receberá um link para redefinir a senha",
+	}
+	ctx.JSON(http.StatusAccepted, response)
+}
+
+func (ac *AuthController) ResetPassword(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.ResetPasswordDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Token == "" {
+		response := handlers.Response{
+			Message: "É necessário o token de redefinição",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = ac.authUsecase.ResetPassword(input.Token, input.Password)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidToken) || errors.Is(err, usecase.ErrWeakPassword) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível redefinir a senha",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := handlers.Response{
+		Message: "Senha redefinida com sucesso",
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// IssueRefreshToken hands the current session a refresh token so clients
+// like the CLI can stay logged in without keeping the password around.
+func (ac *AuthController) IssueRefreshToken(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	token, err := ac.authUsecase.IssueRefreshToken(claimInt(claims, "sessionId"), handlers.ClaimScopes(claims))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidToken) {
+			ctx.JSON(http.StatusUnauthorized, handlers.Response{Message: "Sessão encerrada"})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível gerar o refresh token"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.RefreshTokenDto{RefreshToken: token})
+}
+
+func (ac *AuthController) Refresh(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.RefreshTokenDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o refresh token"})
+		return
+	}
+
+	tokens, err := ac.authUsecase.Refresh(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidToken) {
+			ctx.JSON(http.StatusUnauthorized, handlers.Response{Message: err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível renovar o token"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
@@ -5,9 +5,12 @@ import (
 	"cloud_file_manager/src/handlers"
 	"cloud_file_manager/src/usecase"
 	"cloud_file_manager/src/utils"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -102,6 +105,16 @@ func (ac *AwsController) ListBucketItems(ctx *gin.Context) {
 
 	userId := int(claims["userId"].(float64))
 
+	// Any of the paging parameters switches to a single page, with the
+	// cursor of the next one in the X-Next-Cursor header.
+	_, hasPrefix := ctx.GetQuery("prefix")
+	_, hasCursor := ctx.GetQuery("cursor")
+	_, hasLimit := ctx.GetQuery("limit")
+	if hasPrefix || hasCursor || hasLimit {
+		ac.listBucketPage(ctx, userId)
+		return
+	}
+
 	output, err := ac.awsUsecase.ListBucketItems(userId)
 	if err != nil {
 		response := handlers.Response{
@@ -209,3 +222,260 @@ func (ac *AwsController) PutObject(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, output)
 }
+
+func (ac *AwsController) listBucketPage(ctx *gin.Context, userId int) {
+	var limit int64
+	if value := ctx.Query("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: usecase.ErrInvalidPageSize.Error()})
+			return
+		}
+		limit = parsed
+	}
+
+	output, next, err := ac.awsUsecase.ListBucketPage(userId, ctx.Query("prefix"), ctx.Query("cursor"), int32(limit))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidPageSize) {
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+			return
+		}
+		objectError(ctx, err, "Não foi possível listar os items do bucket")
+		return
+	}
+
+	if output == nil {
+		output = []types.Object{}
+	}
+
+	if next != "" {
+		ctx.Header("X-Next-Cursor", next)
+	}
+	ctx.JSON(http.StatusOK, output)
+}
+
+// objectError answers with the status matching an AwsUsecase error, falling
+// back to a 500 with the given message.
+func objectError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, usecase.ErrBucketNotFound), errors.Is(err, usecase.ErrObjectNotFound):
+		ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrInvalidShareTTL), errors.Is(err, usecase.ErrInvalidPart), errors.Is(err, usecase.ErrNoParts):
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrNotText), errors.Is(err, usecase.ErrInvalidPreviewFormat), errors.Is(err, usecase.ErrInvalidPreviewSize):
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+	default:
+		fmt.Println(err)
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: message})
+	}
+}
+
+func (ac *AwsController) DeleteObject(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	objectKey, err := utils.DecodeJson[dto.ObjectKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if objectKey.ObectKey == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo"})
+		return
+	}
+
+	if err := ac.awsUsecase.DeleteObject(claimInt(claims, "userId"), objectKey.ObectKey); err != nil {
+		objectError(ctx, err, "Não foi possível apagar o arquivo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, handlers.Response{Message: "Arquivo apagado"})
+}
+
+func (ac *AwsController) MoveObject(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.MoveObjectDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Source == "" || input.Destination == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho de origem e de destino"})
+		return
+	}
+
+	if input.Source == input.Destination {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "Origem e destino são o mesmo arquivo"})
+		return
+	}
+
+	if err := ac.awsUsecase.MoveObject(claimInt(claims, "userId"), input.Source, input.Destination); err != nil {
+		objectError(ctx, err, "Não foi possível mover o arquivo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, handlers.Response{Message: "Arquivo movido"})
+}
+
+func (ac *AwsController) ShareObject(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.ShareObjectDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo"})
+		return
+	}
+
+	output, err := ac.awsUsecase.ShareObject(claimInt(claims, "userId"), input.Key, input.ExpiresIn)
+	if err != nil {
+		objectError(ctx, err, "Não foi possível compartilhar o arquivo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, output)
+}
+
+// PreviewObject shows the start of a text file without downloading it.
+func (ac *AwsController) PreviewObject(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.PreviewObjectDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo"})
+		return
+	}
+
+	preview, err := ac.awsUsecase.PreviewObject(claimInt(claims, "userId"), *input)
+	if err != nil {
+		objectError(ctx, err, "Não foi possível gerar a prévia do arquivo")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, preview)
+}
+
+func (ac *AwsController) StartMultipartUpload(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	objectKey, err := utils.DecodeJson[dto.ObjectKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if objectKey.ObectKey == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo"})
+		return
+	}
+
+	uploadId, err := ac.awsUsecase.StartMultipartUpload(claimInt(claims, "userId"), objectKey.ObectKey)
+	if err != nil {
+		objectError(ctx, err, "Não foi possível iniciar o upload")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.MultipartUploadDto{Key: objectKey.ObectKey, UploadId: uploadId})
+}
+
+func (ac *AwsController) PresignUploadPart(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.UploadPartDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" || input.UploadId == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo e o id do upload"})
+		return
+	}
+
+	output, err := ac.awsUsecase.PresignUploadPart(claimInt(claims, "userId"), input.Key, input.UploadId, input.PartNumber)
+	if err != nil {
+		objectError(ctx, err, "Não foi possível assinar a parte do upload")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, output)
+}
+
+func (ac *AwsController) CompleteMultipartUpload(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.CompleteMultipartDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" || input.UploadId == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo e o id do upload"})
+		return
+	}
+
+	if err := ac.awsUsecase.CompleteMultipartUpload(claimInt(claims, "userId"), input.Key, input.UploadId, input.Parts); err != nil {
+		objectError(ctx, err, "Não foi possível concluir o upload")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, handlers.Response{Message: "Upload concluído"})
+}
+
+func (ac *AwsController) AbortMultipartUpload(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.MultipartUploadDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" || input.UploadId == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo e o id do upload"})
+		return
+	}
+
+	if err := ac.awsUsecase.AbortMultipartUpload(claimInt(claims, "userId"), input.Key, input.UploadId); err != nil {
+		objectError(ctx, err, "Não foi possível cancelar o upload")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, handlers.Response{Message: "Upload cancelado"})
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/handlers"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// getClaims reads the claims stored by the auth middleware, writing the
+// error response itself when they are missing.
+func getClaims(ctx *gin.Context) (jwt.MapClaims, bool) {
+	claimsValue, exists := ctx.Get("claims")
+	if !exists {
+		response := handlers.Response{
+			Message: "Não foi possível achar as informações do token",
+		}
+		ctx.JSON(http.StatusUnauthorized, response)
+		return nil, false
+	}
+
+	claims, ok := claimsValue.(jwt.MapClaims)
+	if !ok {
+		response := handlers.Response{
+			Message: "Erro ao converter claims",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func claimInt(claims jwt.MapClaims, key string) int {
+	value, ok := claims[key].(float64)
+	if !ok {
+		return 0
+	}
+	return int(value)
+}
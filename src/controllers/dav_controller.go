@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dav"
+	"cloud_file_manager/src/usecase"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// DavPrefix is where the WebDAV share is mounted.
+const DavPrefix = "/dav"
+
+// DavMethods are the HTTP methods WebDAV uses, for registering the share.
+var DavMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+type DavController struct {
+	storageUsecase usecase.StorageUsecase
+	locks          *dav.LockSystems
+}
+
+func NewDavController(usecase usecase.StorageUsecase) DavController {
+	return DavController{
+		storageUsecase: usecase,
+		locks:          dav.NewLockSystems(),
+	}
+}
+
+// Serve answers any WebDAV request against the caller's bucket.
+func (dc *DavController) Serve(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+	userId := claimInt(claims, "userId")
+
+	handler := &webdav.Handler{
+		Prefix:     DavPrefix,
+		FileSystem: dav.NewFileSystem(&dc.storageUsecase, userId),
+		LockSystem: dc.locks.ForUser(userId),
+		Logger: func(request *http.Request, err error) {
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Println(request.Method, request.URL.Path, err)
+			}
+		},
+	}
+
+	handler.ServeHTTP(ctx.Writer, ctx.Request)
+}
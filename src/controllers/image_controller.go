@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type ImageController struct {
+	imageUsecase usecase.ImageUsecase
+}
+
+func NewImageController(usecase usecase.ImageUsecase) ImageController {
+	return ImageController{
+		imageUsecase: usecase,
+	}
+}
+
+func imageError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidImageParams), errors.Is(err, usecase.ErrNotImage),
+		errors.Is(err, usecase.ErrInvalidImage), errors.Is(err, usecase.ErrImageTooLarge):
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrInvalidImageSignature), errors.Is(err, usecase.ErrImageURLExpired):
+		ctx.JSON(http.StatusForbidden, handlers.Response{Message: err.Error()})
+	case errors.Is(err, usecase.ErrImagesDisabled), errors.Is(err, usecase.ErrObjectNotFound), errors.Is(err, usecase.ErrBucketNotFound):
+		ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+	default:
+		fmt.Println(err)
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: message})
+	}
+}
+
+// SignImageUrl hands out the /images URL of a transformation of one of the
+// caller's images, for use in img tags.
+func (ic *ImageController) SignImageUrl(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.ImageUrlDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Key == "" {
+		ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "É necessário o caminho do arquivo"})
+		return
+	}
+
+	params := usecase.ImageParams{Width: input.W, Height: input.H, Fit: input.Fit, Format: input.Format}
+	url, err := ic.imageUsecase.SignImageURL(claimInt(claims, "userId"), input.Key, params)
+	if err != nil {
+		imageError(ctx, err, "Não foi possível gerar o link da imagem")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.SignedImageUrlDto{URL: url})
+}
+
+// VerifyImageUrl takes the place of Authenticate on /images: the signature
+// stands for the owner of the image, whose rate limit pays for it.
+func (ic *ImageController) VerifyImageUrl(ctx *gin.Context) {
+	query := ctx.Request.URL.Query()
+	userId, userErr := strconv.Atoi(query.Get("u"))
+	width, widthErr := strconv.Atoi(query.Get("w"))
+	height, heightErr := strconv.Atoi(query.Get("h"))
+	expires, expiresErr := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err := errors.Join(userErr, widthErr, heightErr, expiresErr); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, handlers.Response{Message: usecase.ErrInvalidImageSignature.Error()})
+		return
+	}
+
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+	params := usecase.ImageParams{Width: width, Height: height, Fit: query.Get("fit"), Format: query.Get("format")}
+
+	params, err := ic.imageUsecase.VerifyImageURL(userId, key, params, expires, query.Get("sig"))
+	if err != nil {
+		imageError(ctx, err, "Não foi possível verificar o link da imagem")
+		ctx.Abort()
+		return
+	}
+
+	ctx.Set("claims", jwt.MapClaims{"userId": float64(userId)})
+	ctx.Set("imageParams", params)
+	ctx.Next()
+}
+
+// ServeImage answers a verified /images URL with the transformed image.
+// Its ETag changes with each version of the original.
+func (ic *ImageController) ServeImage(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+	params, _ := ctx.MustGet("imageParams").(usecase.ImageParams)
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+
+	variant, err := ic.imageUsecase.Variant(ctx.Request.Context(), claimInt(claims, "userId"), key, params)
+	if err != nil {
+		imageError(ctx, err, "Não foi possível buscar a imagem")
+		return
+	}
+
+	ctx.Header("ETag", variant.ETag)
+	ctx.Header("Cache-Control", "private, max-age=300")
+	if ctx.GetHeader("If-None-Match") == variant.ETag {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	body, size, err := ic.imageUsecase.OpenImage(ctx.Request.Context(), variant)
+	if err != nil {
+		imageError(ctx, err, "Não foi possível buscar a imagem")
+		return
+	}
+	defer body.Close()
+
+	ctx.DataFromReader(http.StatusOK, size, variant.ContentType, body, nil)
+}
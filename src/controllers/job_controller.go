@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobController struct {
+	jobUsecase usecase.JobUsecase
+}
+
+func NewJobController(usecase usecase.JobUsecase) JobController {
+	return JobController{
+		jobUsecase: usecase,
+	}
+}
+
+func (jc *JobController) ListJobs(ctx *gin.Context) {
+	limit := 0
+	if value := ctx.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			response := handlers.Response{
+				Message: "O limite precisa ser um número",
+			}
+			ctx.JSON(http.StatusBadRequest, response)
+			return
+		}
+		limit = parsed
+	}
+
+	jobs, err := jc.jobUsecase.ListJobs(ctx.Query("status"), limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidJobStatus) {
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível listar os jobs",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, jobs)
+}
+
+func (jc *JobController) GetJob(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id do job precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	job, err := jc.jobUsecase.GetJob(id)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível consultar o job",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if job == nil {
+		response := handlers.Response{
+			Message: "Job não encontrado",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+func (jc *JobController) RetryJob(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id do job precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	retried, err := jc.jobUsecase.RetryJob(id)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível reprocessar o job",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !retried {
+		response := handlers.Response{
+			Message: "Job não encontrado ou fora da fila de mortos",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	response := handlers.Response{
+		Message: "Job recolocado na fila",
+	}
+	ctx.JSON(http.StatusAccepted, response)
+}
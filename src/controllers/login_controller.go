@@ -5,18 +5,24 @@ import (
 	"cloud_file_manager/src/handlers"
 	"cloud_file_manager/src/usecase"
 	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type LoginController struct {
 	userUsecase usecase.UserUsecase
+	loginGuard  usecase.LoginGuard
 }
 
-func NewLoginController(usecase usecase.UserUsecase) LoginController {
+func NewLoginController(userUsecase usecase.UserUsecase, loginGuard usecase.LoginGuard) LoginController {
 	return LoginController{
-		userUsecase: usecase,
+		userUsecase: userUsecase,
+		loginGuard:  loginGuard,
 	}
 }
 
@@ -36,7 +42,39 @@ func (lc *LoginController) Login(ctx *gin.Context) {
 		return
 	}
 
+	user.IP = ctx.ClientIP()
+	user.UserAgent = ctx.Request.UserAgent()
+
+	err = lc.loginGuard.Check(user.IP, user.Email)
+	if err != nil {
+		abortLoginBlocked(ctx, err)
+		return
+	}
+
 	loginUser, err := lc.userUsecase.Login(*user)
+	if errors.Is(err, usecase.ErrInvalidCredentials) || (err == nil && loginUser == nil) {
+		if err := lc.loginGuard.RecordFailure(user.Email); err != nil {
+			abortLoginBlocked(ctx, err)
+			return
+		}
+	}
+
+	if errors.Is(err, usecase.ErrInvalidCredentials) {
+		response := handlers.Response{
+			Message: "Email ou senha inválidos",
+		}
+		ctx.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	if errors.Is(err, handlers.ErrInvalidScope) {
+		response := handlers.Response{
+			Message: "Escopo solicitado inválido",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, err)
 		return
@@ -50,5 +88,71 @@ func (lc *LoginController) Login(ctx *gin.Context) {
 		return
 	}
 
+	// The failure streak only ends once the second factor is also right.
+	if !loginUser.MfaRequired {
+		// The session exists already; a streak left behind is not worth
+		// failing the login for.
+		if err := lc.loginGuard.RecordSuccess(user.Email); err != nil {
+			fmt.Println(err)
+		}
+	}
+
 	ctx.JSON(http.StatusOK, loginUser)
+}
+
+// UnlockAccount is the admin path to clear a lockout before it expires.
+func (lc *LoginController) UnlockAccount(ctx *gin.Context) {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id do usuário precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	found, err := lc.loginGuard.Unlock(userId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível desbloquear a conta",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !found {
+		response := handlers.Response{
+			Message: "Usuário não consta na base de dados",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// abortLoginBlocked answers 429 with Retry-After when the guard refused the
+// attempt, and 500 for any other error.
+func abortLoginBlocked(ctx *gin.Context, err error) {
+	var blocked *usecase.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		response := handlers.Response{
+			Message: "Não foi possível processar o login",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	retryAfter := int(math.Ceil(blocked.RetryAfter.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+
+	message := "Muitas tentativas de login, tente novamente mais tarde"
+	if errors.Is(err, usecase.ErrAccountLocked) {
+		message = "Conta bloqueada temporariamente por excesso de tentativas"
+	}
+
+	response := handlers.Response{
+		Message: message,
+	}
+	ctx.JSON(http.StatusTooManyRequests, response)
 }
\ No newline at end of file
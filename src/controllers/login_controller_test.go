@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/usecase"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeLoginLockoutRepo struct {
+	failures    map[string]int
+	lockedUntil map[string]time.Time
+}
+
+func (f *fakeLoginLockoutRepo) GetLockedUntil(email string) (*time.Time, error) {
+	until, ok := f.lockedUntil[email]
+	if !ok {
+		return nil, nil
+	}
+	return &until, nil
+}
+
+func (f *fakeLoginLockoutRepo) RecordLoginFailure(email string) (int, error) {
+	f.failures[email]++
+	return f.failures[email], nil
+}
+
+func (f *fakeLoginLockoutRepo) LockAccount(email string, until time.Time) error {
+	f.lockedUntil[email] = until
+	return nil
+}
+
+func (f *fakeLoginLockoutRepo) ResetLoginFailures(email string) error {
+	delete(f.failures, email)
+	delete(f.lockedUntil, email)
+	return nil
+}
+
+func TestLoginControllerLocksAccountWithRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeUserRepo{
+		loginFn: func(dto.UserLoginDto) (*dto.UserResponseDto, error) {
+			return nil, bcrypt.ErrMismatchedHashAndPassword
+		},
+	}
+	lockouts := &fakeLoginLockoutRepo{failures: map[string]int{}, lockedUntil: map[string]time.Time{}}
+	guard := usecase.NewLoginGuard(ratelimit.NewMemoryStore(), lockouts, repo, usecase.DefaultLoginGuardConfig())
+	controller := NewLoginController(usecase.NewUserUseCase(repo, usecase.NewBucketProvisioner(repo, &fakeAwsClient{}, &fakeJobQueue{}), &fakeUserTokenRepo{}, &fakeMailer{}), guard)
+
+	login := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"ana@example.com","password":"errada"}`))
+		controller.Login(ctx)
+		return recorder
+	}
+
+	for i := 1; i < 5; i++ {
+		if recorder := login(); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("tentativa %d: esperava status 401, veio %d", i, recorder.Code)
+		}
+	}
+
+	recorder := login()
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("esperava status 429, veio %d", recorder.Code)
+	}
+
+	retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+	if err != nil || retryAfter != 60 {
+		t.Fatalf("esperava Retry-After 60, veio %q", recorder.Header().Get("Retry-After"))
+	}
+
+	if recorder := login(); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("conta bloqueada deveria recusar antes de verificar a senha, veio %d", recorder.Code)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MfaController struct {
+	mfaUsecase usecase.MfaUsecase
+}
+
+func NewMfaController(usecase usecase.MfaUsecase) MfaController {
+	return MfaController{
+		mfaUsecase: usecase,
+	}
+}
+
+func (mc *MfaController) Enroll(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	enrollment, err := mc.mfaUsecase.Enroll(claimInt(claims, "userId"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrMfaAlreadyEnabled) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusConflict, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível iniciar a autenticação em duas etapas",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+func (mc *MfaController) Confirm(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.MfaCodeDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := mc.mfaUsecase.Confirm(claimInt(claims, "userId"), input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidMfaCode), errors.Is(err, usecase.ErrMfaNotEnrolled):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrMfaAlreadyEnabled):
+			ctx.JSON(http.StatusConflict, handlers.Response{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{
+				Message: "Não foi possível ativar a autenticação em duas etapas",
+			})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.MfaRecoveryCodesDto{RecoveryCodes: codes})
+}
+
+func (mc *MfaController) Login(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.MfaLoginDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.MfaToken == "" || input.Code == "" {
+		response := handlers.Response{
+			Message: "Informações inválidas",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	input.IP = ctx.ClientIP()
+	input.UserAgent = ctx.Request.UserAgent()
+
+	loginUser, err := mc.mfaUsecase.CompleteLogin(*input)
+	if err != nil {
+		var blocked *usecase.LoginBlockedError
+		if errors.As(err, &blocked) {
+			abortLoginBlocked(ctx, err)
+			return
+		}
+
+		if errors.Is(err, usecase.ErrInvalidMfaCode) || errors.Is(err, handlers.ErrInvalidMfaToken) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusUnauthorized, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível concluir o login",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginUser)
+}
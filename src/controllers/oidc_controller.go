@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/oidc"
+	"cloud_file_manager/src/usecase"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OidcController struct {
+	oidcUsecase usecase.OidcUsecase
+}
+
+func NewOidcController(usecase usecase.OidcUsecase) OidcController {
+	return OidcController{
+		oidcUsecase: usecase,
+	}
+}
+
+func (oc *OidcController) Login(ctx *gin.Context) {
+	address, err := oc.oidcUsecase.StartLogin(ctx.Request.Context())
+	if err != nil {
+		fmt.Println(err)
+		response := handlers.Response{
+			Message: "Não foi possível contatar o provedor de identidade",
+		}
+		ctx.JSON(http.StatusBadGateway, response)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, address)
+}
+
+func (oc *OidcController) Callback(ctx *gin.Context) {
+	if providerError := ctx.Query("error"); providerError != "" {
+		response := handlers.Response{
+			Message: "Login recusado pelo provedor: " + providerError,
+		}
+		ctx.JSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	if code == "" || state == "" {
+		response := handlers.Response{
+			Message: "Informações inválidas",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	loginUser, err := oc.oidcUsecase.CompleteLogin(
+		ctx.Request.Context(),
+		code,
+		state,
+		ctx.ClientIP(),
+		ctx.Request.UserAgent(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidOidcState):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrEmailNotVerified):
+			ctx.JSON(http.StatusUnauthorized, handlers.Response{Message: "Não foi possível validar a identidade no provedor"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível concluir o login"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginUser)
+}
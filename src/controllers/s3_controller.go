@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/s3gateway"
+	"cloud_file_manager/src/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// S3Prefix is where the S3-compatible endpoint is mounted. Clients use
+// it as their endpoint URL with path-style addressing.
+const S3Prefix = "/s3"
+
+type S3Controller struct {
+	gateway *s3gateway.Gateway
+}
+
+func NewS3Controller(storageUsecase usecase.StorageUsecase, accessKeyUsecase usecase.AccessKeyUsecase, userUsecase usecase.UserUsecase, failures ratelimit.Store) S3Controller {
+	return S3Controller{
+		gateway: s3gateway.NewGateway(&storageUsecase, &accessKeyUsecase, &userUsecase, failures, S3Prefix),
+	}
+}
+
+// Serve answers any S3 request. The gateway authenticates it itself, with
+// the SigV4 signature of an access key, and then charges the rate limit.
+func (sc *S3Controller) Serve(ctx *gin.Context) {
+	sc.gateway.ServeHTTP(ctx.Writer, ctx.Request)
+}
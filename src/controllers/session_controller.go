@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	sessionUsecase usecase.SessionUsecase
+}
+
+func NewSessionController(usecase usecase.SessionUsecase) SessionController {
+	return SessionController{
+		sessionUsecase: usecase,
+	}
+}
+
+func (sc *SessionController) GetSessions(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	sessions, err := sc.sessionUsecase.GetSessions(claimInt(claims, "userId"), claimInt(claims, "sessionId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível listar as sessões",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func (sc *SessionController) DeleteSession(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	sessionId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da sessão precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	revoked, err := sc.sessionUsecase.RevokeSession(claimInt(claims, "userId"), sessionId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível encerrar a sessão",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !revoked {
+		response := handlers.Response{
+			Message: "Sessão não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Logout revokes the session the request was made with, along with its
+// refresh token.
+func (sc *SessionController) Logout(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := sc.sessionUsecase.RevokeSession(claimInt(claims, "userId"), claimInt(claims, "sessionId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível encerrar a sessão",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
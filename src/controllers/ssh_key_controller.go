@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SshKeyController struct {
+	sshKeyUsecase usecase.SshKeyUsecase
+}
+
+func NewSshKeyController(usecase usecase.SshKeyUsecase) SshKeyController {
+	return SshKeyController{
+		sshKeyUsecase: usecase,
+	}
+}
+
+func (sc *SshKeyController) CreateSshKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	input, err := utils.DecodeJson[dto.CreateSshKeyDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sshKey, err := sc.sshKeyUsecase.CreateSshKey(claimInt(claims, "userId"), *input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidSshKey):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrSshKeyExists):
+			ctx.JSON(http.StatusConflict, handlers.Response{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível cadastrar a chave SSH"})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, sshKey)
+}
+
+func (sc *SshKeyController) GetSshKeys(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	sshKeys, err := sc.sshKeyUsecase.GetSshKeys(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível listar as chaves SSH",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sshKeys)
+}
+
+func (sc *SshKeyController) DeleteSshKey(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	sshKeyId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Id da chave precisa ser um número",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	deleted, err := sc.sshKeyUsecase.DeleteSshKey(claimInt(claims, "userId"), sshKeyId)
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível remover a chave SSH",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !deleted {
+		response := handlers.Response{
+			Message: "Chave SSH não encontrada",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
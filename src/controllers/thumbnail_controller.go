@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/usecase"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultThumbnailSize is the box asked for when the size is left out.
+const defaultThumbnailSize = 256
+
+type ThumbnailController struct {
+	thumbnailUsecase usecase.ThumbnailUsecase
+}
+
+func NewThumbnailController(usecase usecase.ThumbnailUsecase) ThumbnailController {
+	return ThumbnailController{
+		thumbnailUsecase: usecase,
+	}
+}
+
+// GetThumbnail serves the thumbnail of ?key in the box of ?size. Browsers
+// keep it for a few minutes and revalidate it with its ETag; one still
+// being generated is answered with 202.
+func (tc *ThumbnailController) GetThumbnail(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	size := defaultThumbnailSize
+	if value := ctx.Query("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: "O tamanho precisa ser um número positivo"})
+			return
+		}
+		size = parsed
+	}
+
+	thumbnail, err := tc.thumbnailUsecase.Thumbnail(ctx.Request.Context(), claimInt(claims, "userId"), ctx.Query("key"), size)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrThumbnailPending):
+			ctx.Header("Retry-After", "2")
+			ctx.JSON(http.StatusAccepted, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrNotImage), errors.Is(err, usecase.ErrInvalidImage), errors.Is(err, usecase.ErrImageTooLarge):
+			ctx.JSON(http.StatusBadRequest, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrThumbnailsDisabled), errors.Is(err, usecase.ErrObjectNotFound), errors.Is(err, usecase.ErrBucketNotFound):
+			ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+		default:
+			fmt.Println(err)
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível buscar a miniatura"})
+		}
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, strings.Trim(thumbnail.SourceETag, `"`), thumbnail.Size)
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "private, max-age=300")
+	if ctx.GetHeader("If-None-Match") == etag {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	body, err := tc.thumbnailUsecase.OpenThumbnail(ctx.Request.Context(), thumbnail)
+	if err != nil {
+		fmt.Println(err)
+		ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível buscar a miniatura"})
+		return
+	}
+	defer body.Close()
+
+	ctx.DataFromReader(http.StatusOK, thumbnail.Bytes, thumbnail.ContentType, body, nil)
+}
@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"cloud_file_manager/src/dto"
 	"cloud_file_manager/src/handlers"
-	"cloud_file_manager/src/models"
 	"cloud_file_manager/src/usecase"
 	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -26,14 +28,15 @@ func (u *UserController) GetUsers(ctx *gin.Context) {
 	users, err := u.userUsecase.GetUsers()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, err)
+		return
 	}
 
-	ctx.JSON(http.StatusOK, users)
+	ctx.JSON(http.StatusOK, dto.ToPublicUsers(users))
 }
 
 func (u *UserController) CreateUser(ctx *gin.Context) {
 
-	user, err := utils.DecodeJson[models.User](ctx.Request.Body)
+	user, err := utils.DecodeJson[dto.CreateUserDto](ctx.Request.Body)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -81,5 +84,192 @@ func (u *UserController) GetUserById(ctx *gin.Context) {
 		ctx.JSON(http.StatusNotFound, response)
 		return
 	}
-	ctx.JSON(http.StatusOK, user)
-}
\ No newline at end of file
+	ctx.JSON(http.StatusOK, dto.ToPublicUser(*user))
+}
+
+func (u *UserController) VerifyEmail(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.VerifyEmailDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Token == "" {
+		response := handlers.Response{
+			Message: "É necessário o token de verificação",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	err = u.userUsecase.VerifyEmail(input.Token)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidToken) {
+			response := handlers.Response{
+				Message: err.Error(),
+			}
+			ctx.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		response := handlers.Response{
+			Message: "Não foi possível verificar o email",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	response := handlers.Response{
+		Message: "Email verificado com sucesso",
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (u *UserController) ResendVerification(ctx *gin.Context) {
+	input, err := utils.DecodeJson[dto.ResendVerificationDto](ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Email == "" {
+		response := handlers.Response{
+			Message: "É necessário informar o email",
+		}
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	go func(email string) {
+		if err := u.userUsecase.ResendVerification(email); err != nil {
+			fmt.Println(err)
+		}
+	}(input.Email)
+
+	response := handlers.Response{
+		Message: "Se houver uma conta pendente para este email, um novo link foi enviado",
+	}
+	ctx.JSON(http.StatusAccepted, response)
+}
+
+// GetStorageStatus lets clients poll the bucket provisioning started when the
+// email was verified.
+func (u *UserController) GetStorageStatus(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	status, err := u.userUsecase.GetStorageStatus(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível consultar o armazenamento",
+		}
+		ctx.JSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if status == nil {
+		response := handlers.Response{
+			Message: "Usuário não foi encontrado na base de dados",
+		}
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, status)
+}
+
+func (u *UserController) RetryStorage(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		return
+	}
+
+	err := u.userUsecase.RetryStorage(claimInt(claims, "userId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrStorageNotFailed):
+			ctx.JSON(http.StatusConflict, handlers.Response{Message: err.Error()})
+		case errors.Is(err, usecase.ErrUserNotFound):
+			ctx.JSON(http.StatusNotFound, handlers.Response{Message: err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, handlers.Response{Message: "Não foi possível reprovisionar o armazenamento"})
+		}
+		return
+	}
+
+	response := handlers.Response{
+		Message: "Criação do armazenamento reiniciada",
+	}
+	ctx.JSON(http.StatusAccepted, response)
+}
+
+// RequireVerifiedEmail blocks routes that write to storage until the user
+// confirms their email. It must run after the auth middleware.
+func (u *UserController) RequireVerifiedEmail(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		ctx.Abort()
+		return
+	}
+
+	verified, err := u.userUsecase.IsEmailVerified(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível verificar o email do usuário",
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !verified {
+		response := handlers.Response{
+			Message: "Confirme seu email antes de enviar arquivos",
+		}
+		ctx.AbortWithStatusJSON(http.StatusForbidden, response)
+		return
+	}
+
+	ctx.Next()
+}
+
+// RequireVerifiedEmailToWrite is RequireVerifiedEmail for routes serving a
+// whole file protocol, such as /dav: reads are let through.
+func (u *UserController) RequireVerifiedEmailToWrite(ctx *gin.Context) {
+	if handlers.IsReadMethod(ctx.Request.Method) {
+		ctx.Next()
+		return
+	}
+
+	u.RequireVerifiedEmail(ctx)
+}
+
+// RequireAdmin restricts a route to administrators. It must run after the
+// auth middleware.
+func (u *UserController) RequireAdmin(ctx *gin.Context) {
+	claims, ok := getClaims(ctx)
+	if !ok {
+		ctx.Abort()
+		return
+	}
+
+	admin, err := u.userUsecase.IsAdmin(claimInt(claims, "userId"))
+	if err != nil {
+		response := handlers.Response{
+			Message: "Não foi possível verificar as permissões do usuário",
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, response)
+		return
+	}
+
+	if !admin {
+		response := handlers.Response{
+			Message: "Acesso restrito a administradores",
+		}
+		ctx.AbortWithStatusJSON(http.StatusForbidden, response)
+		return
+	}
+
+	ctx.Next()
+}
@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/jobs"
 	"cloud_file_manager/src/models"
 	"cloud_file_manager/src/usecase"
 
@@ -17,13 +21,25 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type fakeUserRepo struct {
-	createUserFn  func(models.User) (int, error)
-	getUsersFn    func() ([]models.User, error)
-	getUserByIDFn func(int) (*models.User, error)
-	loginFn       func(dto.UserLoginDto) (*dto.UserResponseDto, error)
+	createUserFn     func(models.User) (int, error)
+	getUsersFn       func() ([]models.User, error)
+	getUserByIDFn    func(int) (*models.User, error)
+	loginFn          func(dto.UserLoginDto) (*dto.UserResponseDto, error)
+	getByEmailFn     func(string) (*models.User, error)
+	updatePassFn     func(int, string) error
+	isVerifiedFn     func(int) (bool, error)
+	markVerified     func(int) error
+	isAdminFn        func(int) (bool, error)
+	updateProfile    func(int, string, string) error
+	softDeleteFn     func(int) error
+	deleteUnverified func(int) error
+	setStorageFn     func(int, string, string) error
+	getStorageFn     func(int) (*models.StorageStatus, error)
+	getStalledFn     func(time.Time) ([]int, error)
 }
 
 func (f *fakeUserRepo) CreateUser(user models.User) (int, error) {
@@ -54,12 +70,217 @@ func (f *fakeUserRepo) Login(input dto.UserLoginDto) (*dto.UserResponseDto, erro
 	return f.loginFn(input)
 }
 
+func (f *fakeUserRepo) GetUserByEmail(email string) (*models.User, error) {
+	if f.getByEmailFn == nil {
+		panic("unexpected GetUserByEmail call")
+	}
+	return f.getByEmailFn(email)
+}
+
+func (f *fakeUserRepo) UpdatePassword(id int, password string) error {
+	if f.updatePassFn == nil {
+		panic("unexpected UpdatePassword call")
+	}
+	return f.updatePassFn(id, password)
+}
+
+func (f *fakeUserRepo) IsEmailVerified(id int) (bool, error) {
+	if f.isVerifiedFn == nil {
+		panic("unexpected IsEmailVerified call")
+	}
+	return f.isVerifiedFn(id)
+}
+
+func (f *fakeUserRepo) MarkEmailVerified(id int) error {
+	if f.markVerified == nil {
+		panic("unexpected MarkEmailVerified call")
+	}
+	return f.markVerified(id)
+}
+
+func (f *fakeUserRepo) IsAdmin(id int) (bool, error) {
+	if f.isAdminFn == nil {
+		panic("unexpected IsAdmin call")
+	}
+	return f.isAdminFn(id)
+}
+
+func (f *fakeUserRepo) UpdateProfile(id int, name string, email string) error {
+	if f.updateProfile == nil {
+		panic("unexpected UpdateProfile call")
+	}
+	return f.updateProfile(id, name, email)
+}
+
+func (f *fakeUserRepo) SoftDeleteUser(id int) error {
+	if f.softDeleteFn == nil {
+		panic("unexpected SoftDeleteUser call")
+	}
+	return f.softDeleteFn(id)
+}
+
+func (f *fakeUserRepo) DeleteUnverifiedUser(id int) error {
+	if f.deleteUnverified == nil {
+		panic("unexpected DeleteUnverifiedUser call")
+	}
+	return f.deleteUnverified(id)
+}
+
+func (f *fakeUserRepo) SetStorageStatus(id int, status string, message string) error {
+	if f.setStorageFn == nil {
+		panic("unexpected SetStorageStatus call")
+	}
+	return f.setStorageFn(id, status, message)
+}
+
+func (f *fakeUserRepo) GetStorageStatus(id int) (*models.StorageStatus, error) {
+	if f.getStorageFn == nil {
+		panic("unexpected GetStorageStatus call")
+	}
+	return f.getStorageFn(id)
+}
+
+func (f *fakeUserRepo) GetStalledStorageUsers(before time.Time) ([]int, error) {
+	if f.getStalledFn == nil {
+		panic("unexpected GetStalledStorageUsers call")
+	}
+	return f.getStalledFn(before)
+}
+
+type fakeJobQueue struct{}
+
+func (f *fakeJobQueue) Enqueue(jobType string, payload any) (int, error) {
+	panic("unexpected Enqueue call")
+}
+
+func (f *fakeJobQueue) EnqueueWith(jobType string, payload any, opts jobs.EnqueueOptions) (int, error) {
+	panic("unexpected EnqueueWith call")
+}
+
 type fakeAwsClient struct {
 	createBucketFn          func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error)
+	bucketExistsFn          func(ctx context.Context, bucket string) (bool, error)
 	listBucketsFn           func(ctx context.Context) ([]types.Bucket, error)
 	listBucketItemsFn       func(ctx context.Context, bucket string) ([]types.Object, error)
+	listBucketPageFn        func(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error)
 	getObjectFn             func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
 	putObjectPresignedURLFn func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
+	emptyBucketFn           func(ctx context.Context, bucket string) error
+	deleteBucketFn          func(ctx context.Context, bucket string) error
+	deleteObjectFn          func(ctx context.Context, bucket, key string) error
+	copyObjectFn            func(ctx context.Context, bucket, sourceKey, destinationKey string) error
+	createMultipartFn       func(ctx context.Context, bucket, key string) (string, error)
+	presignUploadPartFn     func(ctx context.Context, bucket, key, uploadId string, partNumber int32, ttl int64) (*v4.PresignedHTTPRequest, error)
+	completeMultipartFn     func(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error
+	abortMultipartFn        func(ctx context.Context, bucket, key, uploadId string) error
+	listDelimitedFn         func(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error)
+	headObjectFn            func(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error)
+	readObjectFn            func(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error)
+	writeObjectFn           func(ctx context.Context, bucket, key string, body io.Reader) (string, error)
+	listObjectsV2Fn         func(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	uploadPartFn            func(ctx context.Context, bucket, key, uploadId string, partNumber int32, body io.Reader, size int64) (string, error)
+}
+
+func (f *fakeAwsClient) ListObjectsDelimited(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error) {
+	if f.listDelimitedFn == nil {
+		panic("unexpected ListObjectsDelimited call")
+	}
+	return f.listDelimitedFn(ctx, bucket, prefix)
+}
+
+func (f *fakeAwsClient) HeadObject(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+	if f.headObjectFn == nil {
+		panic("unexpected HeadObject call")
+	}
+	return f.headObjectFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) ReadObject(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error) {
+	if f.readObjectFn == nil {
+		panic("unexpected ReadObject call")
+	}
+	return f.readObjectFn(ctx, bucket, key, byteRange)
+}
+
+func (f *fakeAwsClient) WriteObject(ctx context.Context, bucket, key string, body io.Reader) (string, error) {
+	if f.writeObjectFn == nil {
+		panic("unexpected WriteObject call")
+	}
+	return f.writeObjectFn(ctx, bucket, key, body)
+}
+
+func (f *fakeAwsClient) UploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, body io.Reader, size int64) (string, error) {
+	if f.uploadPartFn == nil {
+		panic("unexpected UploadPart call")
+	}
+	return f.uploadPartFn(ctx, bucket, key, uploadId, partNumber, body, size)
+}
+
+func (f *fakeAwsClient) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	if f.listObjectsV2Fn == nil {
+		panic("unexpected ListObjectsV2 call")
+	}
+	return f.listObjectsV2Fn(ctx, input)
+}
+
+func (f *fakeAwsClient) DeleteObject(ctx context.Context, bucket, key string) error {
+	if f.deleteObjectFn == nil {
+		panic("unexpected DeleteObject call")
+	}
+	return f.deleteObjectFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) CopyObject(ctx context.Context, bucket, sourceKey, destinationKey string) error {
+	if f.copyObjectFn == nil {
+		panic("unexpected CopyObject call")
+	}
+	return f.copyObjectFn(ctx, bucket, sourceKey, destinationKey)
+}
+
+func (f *fakeAwsClient) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	if f.createMultipartFn == nil {
+		panic("unexpected CreateMultipartUpload call")
+	}
+	return f.createMultipartFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) PresignUploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, ttl int64) (*v4.PresignedHTTPRequest, error) {
+	if f.presignUploadPartFn == nil {
+		panic("unexpected PresignUploadPart call")
+	}
+	return f.presignUploadPartFn(ctx, bucket, key, uploadId, partNumber, ttl)
+}
+
+func (f *fakeAwsClient) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error {
+	if f.completeMultipartFn == nil {
+		panic("unexpected CompleteMultipartUpload call")
+	}
+	return f.completeMultipartFn(ctx, bucket, key, uploadId, parts)
+}
+
+func (f *fakeAwsClient) AbortMultipartUpload(ctx context.Context, bucket, key, uploadId string) error {
+	if f.abortMultipartFn == nil {
+		panic("unexpected AbortMultipartUpload call")
+	}
+	return f.abortMultipartFn(ctx, bucket, key, uploadId)
+}
+
+func (f *fakeAwsClient) EmptyBucket(ctx context.Context, bucket string) error {
+	if f.emptyBucketFn == nil {
+		panic("unexpected EmptyBucket call")
+	}
+	return f.emptyBucketFn(ctx, bucket)
+}
+
+func (f *fakeAwsClient) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	panic("unexpected DeletePrefix call")
+}
+
+func (f *fakeAwsClient) DeleteBucket(ctx context.Context, bucket string) error {
+	if f.deleteBucketFn == nil {
+		panic("unexpected DeleteBucket call")
+	}
+	return f.deleteBucketFn(ctx, bucket)
 }
 
 func (f *fakeAwsClient) CreateBucket(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
@@ -69,6 +290,13 @@ func (f *fakeAwsClient) CreateBucket(ctx context.Context, bucket string) (*s3.Cr
 	return f.createBucketFn(ctx, bucket)
 }
 
+func (f *fakeAwsClient) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if f.bucketExistsFn == nil {
+		panic("unexpected BucketExists call")
+	}
+	return f.bucketExistsFn(ctx, bucket)
+}
+
 func (f *fakeAwsClient) ListBuckets(ctx context.Context) ([]types.Bucket, error) {
 	if f.listBucketsFn == nil {
 		panic("unexpected ListBuckets call")
@@ -83,6 +311,13 @@ func (f *fakeAwsClient) ListBucketItems(ctx context.Context, bucket string) ([]t
 	return f.listBucketItemsFn(ctx, bucket)
 }
 
+func (f *fakeAwsClient) ListBucketPage(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error) {
+	if f.listBucketPageFn == nil {
+		panic("unexpected ListBucketPage call")
+	}
+	return f.listBucketPageFn(ctx, bucket, prefix, token, maxKeys)
+}
+
 func (f *fakeAwsClient) GetObject(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error) {
 	if f.getObjectFn == nil {
 		panic("unexpected GetObject call")
@@ -97,8 +332,52 @@ func (f *fakeAwsClient) PutObjectPresignedUrl(ctx context.Context, bucket, key s
 	return f.putObjectPresignedURLFn(ctx, bucket, key, ttl)
 }
 
+type fakeUserTokenRepo struct {
+	createUserTokenFn  func(int, string, string, time.Time) error
+	getOwnerFn         func(string, string) (int, error)
+	consumeUserTokenFn func(string, string) (int, error)
+}
+
+func (f *fakeUserTokenRepo) CreateUserToken(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+	if f.createUserTokenFn == nil {
+		panic("unexpected CreateUserToken call")
+	}
+	return f.createUserTokenFn(userId, purpose, tokenHash, expiresAt)
+}
+
+func (f *fakeUserTokenRepo) GetUserTokenOwner(purpose string, tokenHash string) (int, error) {
+	if f.getOwnerFn == nil {
+		panic("unexpected GetUserTokenOwner call")
+	}
+	return f.getOwnerFn(purpose, tokenHash)
+}
+
+func (f *fakeUserTokenRepo) ConsumeUserToken(purpose string, tokenHash string) (int, error) {
+	if f.consumeUserTokenFn == nil {
+		panic("unexpected ConsumeUserToken call")
+	}
+	return f.consumeUserTokenFn(purpose, tokenHash)
+}
+
+func (f *fakeUserTokenRepo) ConsumeUserTokenForPassword(purpose string, tokenHash string, password string) (int, error) {
+	panic("ConsumeUserTokenForPassword not implemented")
+}
+
+type fakeMailer struct {
+	sentTo []string
+}
+
+func (f *fakeMailer) Send(to string, subject string, body string) error {
+	f.sentTo = append(f.sentTo, to)
+	return nil
+}
+
 func newUserController(repo usecase.UserRepository, aws usecase.AwsClient) UserController {
-	usecaseLayer := usecase.NewUserUseCase(repo, aws)
+	return newUserControllerWithMail(repo, aws, &fakeUserTokenRepo{}, &fakeMailer{})
+}
+
+func newUserControllerWithMail(repo usecase.UserRepository, aws usecase.AwsClient, tokens usecase.UserTokenRepository, mailer usecase.Mailer) UserController {
+	usecaseLayer := usecase.NewUserUseCase(repo, usecase.NewBucketProvisioner(repo, aws, &fakeJobQueue{}), tokens, mailer)
 	return NewUserController(usecaseLayer)
 }
 
@@ -183,10 +462,8 @@ func TestUserControllerCreateUserSuccess(t *testing.T) {
 
 	awsClient := &fakeAwsClient{
 		createBucketFn: func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
-			if bucket != "myawss3bucket-90902222345-10" {
-				t.Fatalf("bucket inesperado %s", bucket)
-			}
-			return &s3.CreateBucketOutput{}, nil
+			t.Fatalf("bucket não deve ser criado antes da verificação do email")
+			return nil, nil
 		},
 		listBucketsFn:     func(context.Context) ([]types.Bucket, error) { return nil, errors.New("unused") },
 		listBucketItemsFn: func(context.Context, string) ([]types.Object, error) { return nil, errors.New("unused") },
@@ -198,9 +475,14 @@ func TestUserControllerCreateUserSuccess(t *testing.T) {
 		},
 	}
 
-	controller := newUserController(repo, awsClient)
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(int, string, string, time.Time) error { return nil },
+	}
+	mailer := &fakeMailer{}
 
-	body, _ := json.Marshal(models.User{Name: "Ana", Email: "ana@example.com", Password: "pwd"})
+	controller := newUserControllerWithMail(repo, awsClient, tokens, mailer)
+
+	body, _ := json.Marshal(dto.CreateUserDto{Name: "Ana", Email: "ana@example.com", Password: "pwd"})
 	recorder := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(recorder)
 	ctx.Request = httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
@@ -211,6 +493,9 @@ func TestUserControllerCreateUserSuccess(t *testing.T) {
 	if recorder.Code != http.StatusCreated {
 		t.Fatalf("esperava status 201, veio %d", recorder.Code)
 	}
+	if len(mailer.sentTo) != 1 || mailer.sentTo[0] != "ana@example.com" {
+		t.Fatalf("esperava email de verificação para ana, veio %v", mailer.sentTo)
+	}
 }
 
 func TestUserControllerCreateUserBadJSON(t *testing.T) {
@@ -302,3 +587,73 @@ func TestUserControllerGetUserByIdInvalidParam(t *testing.T) {
 		t.Fatalf("esperava status 400, veio %d", recorder.Code)
 	}
 }
+
+func TestUserControllerRequireVerifiedEmailBlocksUnverified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := &fakeUserRepo{
+		isVerifiedFn: func(id int) (bool, error) {
+			if id != 4 {
+				t.Fatalf("id inesperado %d", id)
+			}
+			return false, nil
+		},
+	}
+
+	controller := newUserController(repo, &fakeAwsClient{})
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/aws/bucket/put", nil)
+	ctx.Set("claims", jwt.MapClaims{"userId": float64(4)})
+
+	controller.RequireVerifiedEmail(ctx)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("esperava status 403, veio %d", recorder.Code)
+	}
+	if !ctx.IsAborted() {
+		t.Fatalf("esperava que a requisição fosse interrompida")
+	}
+}
+
+func TestUserControllerResponsesNeverExposePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const hash = "$2a$10$abcdefghijklmnopqrstuv"
+	repo := &fakeUserRepo{
+		getUsersFn: func() ([]models.User, error) {
+			return []models.User{{ID: 1, Name: "Ana", Email: "ana@example.com", Password: hash}}, nil
+		},
+		getUserByIDFn: func(int) (*models.User, error) {
+			return &models.User{ID: 1, Name: "Ana", Email: "ana@example.com", Password: hash}, nil
+		},
+		createUserFn: func(models.User) (int, error) {
+			return 2, nil
+		},
+	}
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(int, string, string, time.Time) error { return nil },
+	}
+	controller := newUserControllerWithMail(repo, &fakeAwsClient{}, tokens, &fakeMailer{})
+
+	requests := map[string]func(*gin.Context){
+		"GetUsers":    controller.GetUsers,
+		"GetUserById": controller.GetUserById,
+		"CreateUser":  controller.CreateUser,
+	}
+
+	for name, handler := range requests {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"Leo","email":"leo@example.com","password":"segredo123"}`))
+
+		handler(ctx)
+
+		body := recorder.Body.String()
+		if strings.Contains(body, hash) || strings.Contains(body, "segredo123") || strings.Contains(strings.ToLower(body), "password") {
+			t.Fatalf("%s expõe a senha: %s", name, body)
+		}
+	}
+}
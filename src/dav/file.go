@@ -0,0 +1,289 @@
+package dav
+
+import (
+	"cloud_file_manager/src/models"
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// fileInfo also answers the ETag and content type properties, so listing a
+// folder never has to open its objects.
+type fileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	dir         bool
+	etag        string
+	contentType string
+}
+
+func objectFileInfo(object models.ObjectInfo) *fileInfo {
+	return &fileInfo{
+		name:        path.Base(object.Key),
+		size:        object.Size,
+		modTime:     object.LastModified,
+		etag:        object.ETag,
+		contentType: object.ContentType,
+	}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func (fi *fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return fi.etag, nil
+}
+
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.contentType != "" {
+		return fi.contentType, nil
+	}
+	if contentType := mime.TypeByExtension(path.Ext(fi.name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+// readFile streams an object. The download starts at the first Read, from
+// the current offset, and a Seek elsewhere drops it, so ranged GETs only
+// fetch the range.
+type readFile struct {
+	fs     *FileSystem
+	ctx    context.Context
+	key    string
+	info   *fileInfo
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if f.offset >= f.info.size {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		body, err := f.fs.storage.Read(f.ctx, f.fs.userId, f.key, f.offset)
+		if err != nil {
+			return 0, storageError(err)
+		}
+		f.body = body
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	next := offset
+	switch whence {
+	case io.SeekCurrent:
+		next += f.offset
+	case io.SeekEnd:
+		next += f.info.size
+	}
+	if next < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	if next != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = next
+
+	return next, nil
+}
+
+func (f *readFile) Close() error {
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+func (f *readFile) Readdir(count int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *readFile) Stat() (fs.FileInfo, error)               { return f.info, nil }
+func (f *readFile) Write(p []byte) (int, error)              { return 0, os.ErrInvalid }
+
+// dirFile lists a folder: its objects and sub-prefixes one level down.
+type dirFile struct {
+	fs      *FileSystem
+	ctx     context.Context
+	key     string
+	info    *fileInfo
+	entries []fs.FileInfo
+	listed  bool
+}
+
+func (f *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !f.listed {
+		if err := f.list(); err != nil {
+			return nil, err
+		}
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
+
+func (f *dirFile) list() error {
+	prefix := ""
+	if f.key != "" {
+		prefix = f.key + "/"
+	}
+
+	objects, prefixes, err := f.fs.storage.List(f.ctx, f.fs.userId, prefix)
+	if err != nil {
+		return storageError(err)
+	}
+
+	for _, common := range prefixes {
+		name := strings.TrimSuffix(strings.TrimPrefix(common, prefix), "/")
+		if name != "" {
+			f.entries = append(f.entries, &fileInfo{name: name, dir: true})
+		}
+	}
+
+	for _, object := range objects {
+		// The folder's own marker object.
+		if object.Key == prefix {
+			continue
+		}
+		f.entries = append(f.entries, objectFileInfo(object))
+	}
+
+	f.listed = true
+	return nil
+}
+
+func (f *dirFile) Close() error                                 { return nil }
+func (f *dirFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (f *dirFile) Stat() (fs.FileInfo, error)                   { return f.info, nil }
+func (f *dirFile) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+
+// writeFile pipes what is written into a streaming upload, which completes
+// on Close. The object only changes if the whole body made it.
+type writeFile struct {
+	fs   *FileSystem
+	ctx  context.Context
+	key  string
+	info *fileInfo
+
+	pipe   *io.PipeWriter
+	done   chan error
+	copied bool
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	f.start()
+	n, err := f.pipe.Write(p)
+	f.info.size += int64(n)
+	return n, err
+}
+
+// ReadFrom lets io.Copy from another file of the same bucket, as WebDAV
+// COPY does, become a server-side copy.
+func (f *writeFile) ReadFrom(r io.Reader) (int64, error) {
+	if source, ok := r.(*readFile); ok && source.fs.userId == f.fs.userId && source.offset == 0 && f.pipe == nil {
+		if err := f.fs.storage.Copy(f.ctx, f.fs.userId, source.key, f.key); err != nil {
+			return 0, storageError(err)
+		}
+		f.copied = true
+		f.info.size = source.info.size
+		f.info.etag = source.info.etag
+		source.offset = source.info.size
+		return f.info.size, nil
+	}
+
+	f.start()
+	n, err := io.Copy(f.pipe, r)
+	f.info.size += n
+	if err != nil {
+		// Fails the upload rather than storing a truncated object.
+		f.pipe.CloseWithError(err)
+	}
+	return n, err
+}
+
+func (f *writeFile) start() {
+	if f.pipe != nil {
+		return
+	}
+
+	reader, writer := io.Pipe()
+	f.pipe = writer
+	f.done = make(chan error, 1)
+
+	go func() {
+		etag, err := f.fs.storage.Write(f.ctx, f.fs.userId, f.key, reader)
+		reader.CloseWithError(err)
+		f.info.etag = etag
+		f.done <- err
+	}()
+}
+
+func (f *writeFile) Close() error {
+	if f.copied {
+		return nil
+	}
+
+	if f.pipe == nil {
+		etag, err := f.fs.storage.Write(f.ctx, f.fs.userId, f.key, strings.NewReader(""))
+		f.info.etag = etag
+		return storageError(err)
+	}
+
+	f.pipe.Close()
+	if err := <-f.done; err != nil {
+		return storageError(err)
+	}
+	f.info.modTime = time.Now()
+	return nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *writeFile) Readdir(count int) ([]fs.FileInfo, error)     { return nil, os.ErrInvalid }
+func (f *writeFile) Stat() (fs.FileInfo, error)                   { return f.info, nil }
+
+var (
+	_ webdav.File       = (*readFile)(nil)
+	_ webdav.File       = (*dirFile)(nil)
+	_ webdav.File       = (*writeFile)(nil)
+	_ webdav.FileSystem = (*FileSystem)(nil)
+)
@@ -0,0 +1,229 @@
+// Package dav exposes a user's bucket as a webdav.FileSystem, so the stock
+// x/net/webdav handler can serve it to Finder, Explorer, davfs2 and rclone.
+//
+// Buckets are flat, so folders are prefixes: a path is a folder when some
+// key lives under "path/". MKCOL stores an empty "path/" marker object so
+// new folders show up before anything is put in them.
+package dav
+
+import (
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/usecase"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// Storage is the per-user object storage. *usecase.StorageUsecase
+// implements it.
+type Storage interface {
+	List(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, []string, error)
+	ListAll(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, error)
+	HasPrefix(ctx context.Context, userId int, prefix string) (bool, error)
+	Stat(ctx context.Context, userId int, key string) (*models.ObjectInfo, error)
+	Read(ctx context.Context, userId int, key string, offset int64) (io.ReadCloser, error)
+	Write(ctx context.Context, userId int, key string, body io.Reader) (string, error)
+	Copy(ctx context.Context, userId int, sourceKey string, destinationKey string) error
+	Delete(ctx context.Context, userId int, key string) error
+}
+
+// FileSystem is the bucket of one user.
+type FileSystem struct {
+	storage Storage
+	userId  int
+}
+
+func NewFileSystem(storage Storage, userId int) *FileSystem {
+	return &FileSystem{storage: storage, userId: userId}
+}
+
+// key maps a WebDAV path to its object key: "/a/b.txt" is "a/b.txt" and
+// the root is "".
+func key(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.stat(ctx, key(name))
+}
+
+func (fs *FileSystem) stat(ctx context.Context, objectKey string) (*fileInfo, error) {
+	if objectKey == "" {
+		return &fileInfo{name: "/", dir: true}, nil
+	}
+
+	object, err := fs.storage.Stat(ctx, fs.userId, objectKey)
+	if err == nil {
+		return objectFileInfo(*object), nil
+	}
+	if !errors.Is(err, usecase.ErrObjectNotFound) {
+		return nil, storageError(err)
+	}
+
+	found, err := fs.storage.HasPrefix(ctx, fs.userId, objectKey+"/")
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
+	return &fileInfo{name: path.Base(objectKey), dir: true}, nil
+}
+
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	objectKey := key(name)
+	if objectKey == "" {
+		return os.ErrExist
+	}
+
+	if _, err := fs.stat(ctx, objectKey); err == nil {
+		return os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := fs.requireParent(ctx, objectKey); err != nil {
+		return err
+	}
+
+	_, err := fs.storage.Write(ctx, fs.userId, objectKey+"/", strings.NewReader(""))
+	return storageError(err)
+}
+
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	objectKey := key(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		// Objects are replaced whole; there is no appending to them.
+		if objectKey == "" || flag&os.O_APPEND != 0 {
+			return nil, os.ErrInvalid
+		}
+
+		if err := fs.requireParent(ctx, objectKey); err != nil {
+			return nil, err
+		}
+
+		return &writeFile{
+			fs:   fs,
+			ctx:  ctx,
+			key:  objectKey,
+			info: &fileInfo{name: path.Base(objectKey), modTime: time.Now()},
+		}, nil
+	}
+
+	info, err := fs.stat(ctx, objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.dir {
+		return &dirFile{fs: fs, ctx: ctx, key: objectKey, info: info}, nil
+	}
+
+	return &readFile{fs: fs, ctx: ctx, key: objectKey, info: info}, nil
+}
+
+// RemoveAll deletes the object at name and, for folders, everything under
+// it.
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	objectKey := key(name)
+	if objectKey == "" {
+		return os.ErrPermission
+	}
+
+	if err := fs.storage.Delete(ctx, fs.userId, objectKey); err != nil {
+		return storageError(err)
+	}
+
+	objects, err := fs.storage.ListAll(ctx, fs.userId, objectKey+"/")
+	if err != nil {
+		return storageError(err)
+	}
+
+	for _, object := range objects {
+		if err := fs.storage.Delete(ctx, fs.userId, object.Key); err != nil {
+			return storageError(err)
+		}
+	}
+
+	return nil
+}
+
+// Rename moves with server-side copies followed by deletes, object by
+// object for folders.
+func (fs *FileSystem) Rename(ctx context.Context, oldName string, newName string) error {
+	oldKey, newKey := key(oldName), key(newName)
+	if oldKey == "" || newKey == "" {
+		return os.ErrPermission
+	}
+	if strings.HasPrefix(newKey+"/", oldKey+"/") {
+		return os.ErrInvalid
+	}
+
+	info, err := fs.stat(ctx, oldKey)
+	if err != nil {
+		return err
+	}
+
+	if !info.dir {
+		if err := fs.storage.Copy(ctx, fs.userId, oldKey, newKey); err != nil {
+			return storageError(err)
+		}
+		return storageError(fs.storage.Delete(ctx, fs.userId, oldKey))
+	}
+
+	objects, err := fs.storage.ListAll(ctx, fs.userId, oldKey+"/")
+	if err != nil {
+		return storageError(err)
+	}
+
+	for _, object := range objects {
+		destination := newKey + "/" + strings.TrimPrefix(object.Key, oldKey+"/")
+		if err := fs.storage.Copy(ctx, fs.userId, object.Key, destination); err != nil {
+			return storageError(err)
+		}
+	}
+
+	for _, object := range objects {
+		if err := fs.storage.Delete(ctx, fs.userId, object.Key); err != nil {
+			return storageError(err)
+		}
+	}
+
+	return nil
+}
+
+// requireParent fails with os.ErrNotExist, which WebDAV answers with 409
+// Conflict, when the folder that would hold objectKey does not exist.
+func (fs *FileSystem) requireParent(ctx context.Context, objectKey string) error {
+	parent := path.Dir(objectKey)
+	if parent == "." {
+		return nil
+	}
+
+	info, err := fs.stat(ctx, parent)
+	if err != nil {
+		return err
+	}
+	if !info.dir {
+		return os.ErrNotExist
+	}
+
+	return nil
+}
+
+// storageError turns missing objects into os.ErrNotExist, which the WebDAV
+// handler answers with 404.
+func storageError(err error) error {
+	if errors.Is(err, usecase.ErrObjectNotFound) || errors.Is(err, usecase.ErrBucketNotFound) {
+		return os.ErrNotExist
+	}
+	return err
+}
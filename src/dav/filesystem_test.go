@@ -0,0 +1,149 @@
+package dav
+
+import (
+	"bytes"
+	"cloud_file_manager/src/storagetest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func newDavServer(storage *storagetest.Storage) *httptest.Server {
+	return httptest.NewServer(&webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: NewFileSystem(storage, 7),
+		LockSystem: webdav.NewMemLS(),
+	})
+}
+
+func davRequest(t *testing.T, server *httptest.Server, method string, path string, body string, headers map[string]string) *http.Response {
+	t.Helper()
+
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("requisição inválida: %v", err)
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s falhou: %v", method, path, err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func expectStatus(t *testing.T, response *http.Response, status int) {
+	t.Helper()
+	if response.StatusCode != status {
+		t.Fatalf("%s %s: esperava status %d, veio %d", response.Request.Method, response.Request.URL.Path, status, response.StatusCode)
+	}
+}
+
+func TestDavPutAndGetStreamObjects(t *testing.T) {
+	storage := storagetest.New(nil)
+	server := newDavServer(storage)
+	defer server.Close()
+
+	response := davRequest(t, server, http.MethodPut, "/dav/notas.txt", "olá mundo", nil)
+	expectStatus(t, response, http.StatusCreated)
+	if string(storage.Objects["notas.txt"]) != "olá mundo" {
+		t.Fatalf("o objeto deveria ter sido gravado, veio %q", storage.Objects["notas.txt"])
+	}
+	if response.Header.Get("ETag") != storage.Info("notas.txt").ETag {
+		t.Fatalf("o ETag deveria ser o do objeto, veio %q", response.Header.Get("ETag"))
+	}
+
+	response = davRequest(t, server, http.MethodGet, "/dav/notas.txt", "", map[string]string{"Range": "bytes=5-"})
+	expectStatus(t, response, http.StatusPartialContent)
+	if body, _ := io.ReadAll(response.Body); string(body) != "mundo" {
+		t.Fatalf("esperava o trecho pedido, veio %q", body)
+	}
+
+	response = davRequest(t, server, http.MethodPut, "/dav/sem-pasta/notas.txt", "x", nil)
+	expectStatus(t, response, http.StatusConflict)
+}
+
+func TestDavPropfindListsOneLevel(t *testing.T) {
+	storage := storagetest.New(map[string][]byte{
+		"a.txt":         []byte("a"),
+		"fotos/1.jpg":   []byte("1"),
+		"fotos/2/3.jpg": []byte("3"),
+	})
+	server := newDavServer(storage)
+	defer server.Close()
+
+	response := davRequest(t, server, "PROPFIND", "/dav/", "", map[string]string{"Depth": "1"})
+	expectStatus(t, response, http.StatusMultiStatus)
+
+	body, _ := io.ReadAll(response.Body)
+	for _, href := range []string{"/dav/a.txt", "/dav/fotos/"} {
+		if !strings.Contains(string(body), "<D:href>"+href+"</D:href>") {
+			t.Fatalf("a listagem deveria ter %s: %s", href, body)
+		}
+	}
+	if strings.Contains(string(body), "1.jpg") {
+		t.Fatalf("a listagem não deveria descer nas pastas: %s", body)
+	}
+}
+
+func TestDavMkcolMoveAndDeleteFolders(t *testing.T) {
+	storage := storagetest.New(nil)
+	server := newDavServer(storage)
+	defer server.Close()
+
+	expectStatus(t, davRequest(t, server, "MKCOL", "/dav/docs", "", nil), http.StatusCreated)
+	if _, ok := storage.Objects["docs/"]; !ok {
+		t.Fatalf("a pasta deveria ter o objeto marcador")
+	}
+	expectStatus(t, davRequest(t, server, "MKCOL", "/dav/docs", "", nil), http.StatusMethodNotAllowed)
+
+	davRequest(t, server, http.MethodPut, "/dav/docs/plano.md", "plano", nil)
+
+	response := davRequest(t, server, "MOVE", "/dav/docs", "", map[string]string{"Destination": server.URL + "/dav/arquivo"})
+	expectStatus(t, response, http.StatusCreated)
+	if string(storage.Objects["arquivo/plano.md"]) != "plano" || len(storage.Keys("docs/")) != 0 {
+		t.Fatalf("a pasta deveria ter sido movida, veio %v", storage.Objects)
+	}
+
+	expectStatus(t, davRequest(t, server, http.MethodDelete, "/dav/arquivo", "", nil), http.StatusNoContent)
+	if len(storage.Objects) != 0 {
+		t.Fatalf("a pasta deveria ter sido apagada, veio %v", storage.Objects)
+	}
+}
+
+func TestDavCopyIsServerSide(t *testing.T) {
+	storage := storagetest.New(map[string][]byte{"grande.bin": []byte(strings.Repeat("x", 1000))})
+	server := newDavServer(storage)
+	defer server.Close()
+
+	response := davRequest(t, server, "COPY", "/dav/grande.bin", "", map[string]string{"Destination": server.URL + "/dav/copia.bin"})
+	expectStatus(t, response, http.StatusCreated)
+
+	if !bytes.Equal(storage.Objects["copia.bin"], storage.Objects["grande.bin"]) {
+		t.Fatalf("a cópia deveria ter o mesmo conteúdo")
+	}
+	if storage.Copies != 1 || storage.Written != 0 {
+		t.Fatalf("a cópia deveria ser feita no bucket, veio %d cópias e %d bytes enviados", storage.Copies, storage.Written)
+	}
+}
+
+func TestDavLockBlocksOtherWriters(t *testing.T) {
+	storage := storagetest.New(map[string][]byte{"a.txt": []byte("a")})
+	server := newDavServer(storage)
+	defer server.Close()
+
+	lockBody := `<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	response := davRequest(t, server, "LOCK", "/dav/a.txt", lockBody, nil)
+	expectStatus(t, response, http.StatusOK)
+	token := response.Header.Get("Lock-Token")
+
+	expectStatus(t, davRequest(t, server, http.MethodPut, "/dav/a.txt", "b", nil), http.StatusLocked)
+	expectStatus(t, davRequest(t, server, http.MethodPut, "/dav/a.txt", "b", map[string]string{"If": "(" + token + ")"}), http.StatusCreated)
+}
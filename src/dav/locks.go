@@ -0,0 +1,32 @@
+package dav
+
+import (
+	"sync"
+
+	"golang.org/x/net/webdav"
+)
+
+// LockSystems keeps the WebDAV locks of each user apart, since every user
+// sees their own bucket under the same paths. Locks live in memory, so a
+// restart drops them; clients then simply lock again.
+type LockSystems struct {
+	mu      sync.Mutex
+	systems map[int]webdav.LockSystem
+}
+
+func NewLockSystems() *LockSystems {
+	return &LockSystems{systems: map[int]webdav.LockSystem{}}
+}
+
+func (ls *LockSystems) ForUser(userId int) webdav.LockSystem {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	system, ok := ls.systems[userId]
+	if !ok {
+		system = webdav.NewMemLS()
+		ls.systems[userId] = system
+	}
+
+	return system
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "cloud_file_manager/src/models"
+
+type CreateAccessKeyDto struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAccessKeyDto is the only response that carries the secret.
+type CreatedAccessKeyDto struct {
+	models.AccessKey
+	SecretAccessKey string `json:"secretAccessKey"`
+}
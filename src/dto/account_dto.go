@@ -0,0 +1,16 @@
+package dto
+
+// UpdateProfileDto only changes the fields that are present.
+type UpdateProfileDto struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+type ChangePasswordDto struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type DeleteAccountDto struct {
+	Password string `json:"password"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+import "cloud_file_manager/src/models"
+
+type CreateApiKeyDto struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// CreatedApiKeyDto is the only response that carries the full key.
+type CreatedApiKeyDto struct {
+	models.ApiKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+type ForgotPasswordDto struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDto struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailDto struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationDto struct {
+	Email string `json:"email"`
+}
+
+type RefreshTokenDto struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenPairDto struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
@@ -6,4 +6,86 @@ type BucketNameDto struct {
 
 type ObjectKeyDto struct {
 	ObectKey string `json:"key"`
-}
\ No newline at end of file
+}
+type MoveObjectDto struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type ShareObjectDto struct {
+	Key       string `json:"key"`
+	ExpiresIn int64  `json:"expiresIn"`
+}
+
+type MultipartUploadDto struct {
+	Key      string `json:"key"`
+	UploadId string `json:"uploadId"`
+}
+
+type UploadPartDto struct {
+	Key        string `json:"key"`
+	UploadId   string `json:"uploadId"`
+	PartNumber int32  `json:"partNumber"`
+}
+
+type CompletedPartDto struct {
+	PartNumber int32  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+type CompleteMultipartDto struct {
+	Key      string             `json:"key"`
+	UploadId string             `json:"uploadId"`
+	Parts    []CompletedPartDto `json:"parts"`
+}
+
+// DownloadZipDto selects what goes in a ZIP download: everything under
+// Prefix, or the listed Keys. Name is the file name offered to the client.
+type DownloadZipDto struct {
+	Prefix string   `json:"prefix"`
+	Keys   []string `json:"keys"`
+	Name   string   `json:"name"`
+}
+
+// ExtractArchiveDto asks for Key to be unpacked under Target, by default a
+// folder named after the archive next to it.
+type ExtractArchiveDto struct {
+	Key    string `json:"key"`
+	Target string `json:"target"`
+}
+
+// ImageUrlDto asks for a signed /images URL of Key, transformed to fit a
+// W by H box in Format.
+type ImageUrlDto struct {
+	Key    string `json:"key"`
+	W      int    `json:"w"`
+	H      int    `json:"h"`
+	Fit    string `json:"fit"`
+	Format string `json:"format"`
+}
+
+type SignedImageUrlDto struct {
+	URL string `json:"url"`
+}
+
+// PreviewObjectDto asks for a preview of the first MaxKB kilobytes of Key,
+// shown as Format: text, markdown, csv or json. Both are guessed from the
+// key when left out.
+type PreviewObjectDto struct {
+	Key    string `json:"key"`
+	Format string `json:"format"`
+	MaxKB  int    `json:"maxKb"`
+}
+
+// ObjectPreviewDto carries the preview in Text, HTML or Rows, depending on
+// Format. Truncated tells whether the object goes on past it.
+type ObjectPreviewDto struct {
+	Key       string     `json:"key"`
+	Format    string     `json:"format"`
+	Encoding  string     `json:"encoding"`
+	Size      int64      `json:"size"`
+	Truncated bool       `json:"truncated"`
+	Text      string     `json:"text,omitempty"`
+	HTML      string     `json:"html,omitempty"`
+	Rows      [][]string `json:"rows,omitempty"`
+}
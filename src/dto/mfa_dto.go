@@ -0,0 +1,21 @@
+package dto
+
+type MfaEnrollmentDto struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioningUri"`
+}
+
+type MfaCodeDto struct {
+	Code string `json:"code"`
+}
+
+type MfaRecoveryCodesDto struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type MfaLoginDto struct {
+	MfaToken  string `json:"mfaToken"`
+	Code      string `json:"code"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
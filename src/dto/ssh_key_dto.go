@@ -0,0 +1,8 @@
+package dto
+
+// CreateSshKeyDto takes the key as an authorized_keys line. The name
+// defaults to the key's comment.
+type CreateSshKeyDto struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"publicKey"`
+}
@@ -1,13 +1,49 @@
 package dto
 
+import "cloud_file_manager/src/models"
+
+type CreateUserDto struct {
+	Name string `json:"name"`
+	Email string `json:"email"`
+	Password string `json:"password"`
+}
+
+// PublicUserDto is the only shape in which users leave the API.
+type PublicUserDto struct {
+	ID int `json:"id"`
+	Name string `json:"name"`
+	Email string `json:"email"`
+}
+
 type UserLoginDto struct {
 	Email string `json:"email"`
 	Password string `json:"password"`
+	Scopes []string `json:"scopes"`
+	IP string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 type UserResponseDto struct {
 	ID int `json:"id"`
 	Name string `json:"name"`
 	Email string `json:"email"`
-	Token string `json:"token"`
-}
\ No newline at end of file
+	Token string `json:"token,omitempty"`
+	MfaRequired bool `json:"mfaRequired,omitempty"`
+	MfaToken string `json:"mfaToken,omitempty"`
+}
+
+func ToPublicUser(user models.User) PublicUserDto {
+	return PublicUserDto{
+		ID: user.ID,
+		Name: user.Name,
+		Email: user.Email,
+	}
+}
+
+func ToPublicUsers(users []models.User) []PublicUserDto {
+	publicUsers := make([]PublicUserDto, len(users))
+	for i, user := range users {
+		publicUsers[i] = ToPublicUser(user)
+	}
+	return publicUsers
+}
@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"cloud_file_manager/src/models"
+)
+
+const passwordHash = "$2a$10$abcdefghijklmnopqrstuv"
+
+func TestToPublicUserOmitsPassword(t *testing.T) {
+	user := models.User{ID: 1, Name: "Ana", Email: "ana@example.com", Password: passwordHash}
+
+	body, err := json.Marshal(ToPublicUsers([]models.User{user}))
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if strings.Contains(string(body), passwordHash) || strings.Contains(strings.ToLower(string(body)), "password") {
+		t.Fatalf("resposta expõe a senha: %s", body)
+	}
+}
+
+func TestUserModelNeverSerializesPassword(t *testing.T) {
+	body, err := json.Marshal(models.User{ID: 1, Password: passwordHash})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if strings.Contains(string(body), passwordHash) {
+		t.Fatalf("models.User expõe a senha: %s", body)
+	}
+}
+
+func TestResponseDtosOnlyExposeAllowedFields(t *testing.T) {
+	allowed := map[string]bool{
+		"id": true, "name": true, "email": true,
+		"token": true, "mfaRequired": true, "mfaToken": true,
+	}
+
+	for _, value := range []any{PublicUserDto{}, UserResponseDto{}} {
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if !allowed[name] {
+				t.Errorf("%s.%s expõe o campo %q", typ.Name(), typ.Field(i).Name, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package filesync
+
+import (
+	"cloud_file_manager/src/client"
+	"context"
+	"os"
+	"sync"
+)
+
+// apply runs the actions, options.Parallel at a time, recording the error
+// of each failed one in the result.
+func (e *Engine) apply(ctx context.Context, result *Result) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for range min(e.options.Parallel, len(result.Actions)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				action := &result.Actions[i]
+
+				var progress client.Progress
+				var done func(error)
+				if e.options.Observe != nil {
+					progress, done = e.options.Observe(*action)
+				}
+
+				err := e.run(ctx, *action, progress)
+				if done != nil {
+					done(err)
+				}
+
+				if err != nil {
+					mu.Lock()
+					action.Error = err.Error()
+					result.Failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range result.Actions {
+		if ctx.Err() != nil {
+			mu.Lock()
+			result.Actions[i].Error = ctx.Err().Error()
+			result.Failed++
+			mu.Unlock()
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+func (e *Engine) run(ctx context.Context, action Action, progress client.Progress) error {
+	path := e.localPath(action.Path)
+
+	switch action.Kind {
+	case Upload:
+		return e.remote.Upload(ctx, e.key(action.Path), path, progress)
+
+	case Download:
+		return e.remote.Download(ctx, e.key(action.Path), path, progress)
+
+	case DeleteLocal:
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+
+	case DeleteRemote:
+		return e.remote.Delete(ctx, e.key(action.Path))
+
+	case Conflict:
+		conflictPath := e.localPath(action.ConflictPath)
+		if err := os.Rename(path, conflictPath); err != nil {
+			return err
+		}
+
+		if err := e.remote.Download(ctx, e.key(action.Path), path, progress); err != nil {
+			// Put the local version back so the next run sees the same conflict.
+			os.Rename(conflictPath, path)
+			return err
+		}
+
+		return e.remote.Upload(ctx, e.key(action.ConflictPath), conflictPath, progress)
+	}
+
+	return nil
+}
+
+// nextState builds the state to record after a run: paths that were or
+// got in sync take their current local stat and remote ETag, paths whose
+// action failed keep their previous state and deleted paths are dropped.
+func (e *Engine) nextState(ctx context.Context, result *Result, inSync []string, known *state, remote map[string]remoteFile) (*state, error) {
+	next := &state{Prefix: e.prefix, Files: map[string]fileState{}}
+
+	touched := map[string]bool{}
+	var synced []string
+	uploaded := false
+
+	for _, action := range result.Actions {
+		touched[action.Path] = true
+
+		if action.Error != "" {
+			if previous, ok := known.Files[action.Path]; ok {
+				next.Files[action.Path] = previous
+			}
+			continue
+		}
+
+		switch action.Kind {
+		case Upload:
+			uploaded = true
+			synced = append(synced, action.Path)
+		case Download:
+			synced = append(synced, action.Path)
+		case Conflict:
+			uploaded = true
+			synced = append(synced, action.Path, action.ConflictPath)
+		}
+	}
+
+	// Untouched paths present on both sides are still in sync.
+	for path, previous := range known.Files {
+		if !touched[path] {
+			if _, ok := remote[path]; ok {
+				next.Files[path] = previous
+			}
+		}
+	}
+
+	synced = append(synced, inSync...)
+
+	// Uploads changed ETags, which only a fresh listing tells.
+	if uploaded {
+		fresh, err := e.scanRemote(ctx)
+		if err != nil {
+			return nil, err
+		}
+		remote = fresh
+	}
+
+	for _, path := range synced {
+		info, err := os.Stat(e.localPath(path))
+		if err != nil {
+			continue
+		}
+
+		object, ok := remote[path]
+		if !ok {
+			continue
+		}
+
+		next.Files[path] = fileState{Size: info.Size(), ModTime: info.ModTime().UnixNano(), ETag: object.ETag}
+	}
+
+	return next, nil
+}
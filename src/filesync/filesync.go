@@ -0,0 +1,304 @@
+// Package filesync mirrors a local directory with a prefix of the user's
+// bucket, in both directions.
+//
+// Every run compares each path on both sides with what it looked like at
+// the end of the previous run, kept in a small state database: a side that
+// differs from that state changed. A change on one side is copied to the
+// other, a deletion is propagated, and when both sides changed the local
+// copy is kept under a conflict name so neither version is lost.
+package filesync
+
+import (
+	"cloud_file_manager/src/client"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDeleteThreshold = 0.5
+	DefaultConflictSuffix  = "conflict"
+)
+
+var ErrTooManyDeletes = errors.New("a sincronização apagaria arquivos demais")
+
+type ActionKind string
+
+const (
+	Upload       ActionKind = "upload"
+	Download     ActionKind = "download"
+	DeleteLocal  ActionKind = "delete-local"
+	DeleteRemote ActionKind = "delete-remote"
+	// Conflict renames the local file to ConflictPath, downloads the remote
+	// version in its place and uploads the renamed copy.
+	Conflict ActionKind = "conflict"
+)
+
+// Action is one change a run makes. Paths are relative to the root and
+// the prefix, slash-separated.
+type Action struct {
+	Kind         ActionKind `json:"kind"`
+	Path         string     `json:"path"`
+	Size         int64      `json:"size"`
+	ConflictPath string     `json:"conflictPath,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+type Result struct {
+	Actions []Action `json:"actions"`
+	DryRun  bool     `json:"dryRun"`
+	Failed  int      `json:"failed"`
+}
+
+// Remote is the side of the bucket. *client.Client implements it.
+type Remote interface {
+	ListObjects(ctx context.Context, prefix string) ([]client.Object, error)
+	Upload(ctx context.Context, key string, path string, progress client.Progress) error
+	Download(ctx context.Context, key string, path string, progress client.Progress) error
+	Delete(ctx context.Context, key string) error
+}
+
+type Options struct {
+	// DryRun only plans: nothing is transferred, deleted or recorded.
+	DryRun bool
+	// DeleteThreshold is the largest share of the tracked files one run
+	// may delete, on both sides together, before refusing to go on. It
+	// guards against an emptied or unmounted directory wiping the bucket.
+	// Force skips the check.
+	DeleteThreshold float64
+	Force           bool
+	// ConflictSuffix names the local copies kept on conflicts, as in
+	// "report.conflict-20250102-150405.pdf".
+	ConflictSuffix string
+	// StatePath overrides where the state database is kept.
+	StatePath string
+	Parallel  int
+	// Observe, when set, is told when each action starts. It returns the
+	// progress callback for the action and a function called with its
+	// outcome.
+	Observe func(action Action) (client.Progress, func(err error))
+	Now     func() time.Time
+}
+
+type Engine struct {
+	remote    Remote
+	root      string
+	prefix    string
+	statePath string
+	options   Options
+}
+
+// New prepares the sync of root with prefix. An empty prefix syncs the
+// whole bucket.
+func New(remote Remote, root string, prefix string, options Options) *Engine {
+	if options.DeleteThreshold <= 0 {
+		options.DeleteThreshold = DefaultDeleteThreshold
+	}
+	if options.ConflictSuffix == "" {
+		options.ConflictSuffix = DefaultConflictSuffix
+	}
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+	if options.Now == nil {
+		options.Now = time.Now
+	}
+
+	root = filepath.Clean(root)
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+
+	statePath := options.StatePath
+	if statePath == "" {
+		statePath = filepath.Join(root, StateFile)
+	}
+
+	return &Engine{
+		remote:    remote,
+		root:      root,
+		prefix:    prefix,
+		statePath: filepath.Clean(statePath),
+		options:   options,
+	}
+}
+
+// Run syncs once. The result lists the planned actions, with the error of
+// those that failed; failed paths keep their previous state and are tried
+// again on the next run.
+func (e *Engine) Run(ctx context.Context) (*Result, error) {
+	known, err := loadState(e.statePath, e.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("não foi possível ler o estado da sincronização: %w", err)
+	}
+
+	local, err := e.scanLocal()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := e.scanRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, inSync := e.reconcile(local, remote, known.Files)
+	result := &Result{Actions: actions, DryRun: e.options.DryRun}
+
+	if err := e.checkDeletes(actions, len(known.Files)); err != nil {
+		return result, err
+	}
+
+	if e.options.DryRun {
+		return result, nil
+	}
+
+	e.apply(ctx, result)
+
+	next, err := e.nextState(ctx, result, inSync, known, remote)
+	if err != nil {
+		return result, err
+	}
+
+	if err := next.save(e.statePath); err != nil {
+		return result, fmt.Errorf("não foi possível salvar o estado da sincronização: %w", err)
+	}
+
+	return result, nil
+}
+
+// Watch runs a sync every interval until ctx is done, handing each outcome
+// to report. It is the loop of a sync daemon.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration, report func(*Result, error)) {
+	for {
+		result, err := e.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		report(result, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// reconcile decides what to do with every path seen on either side or in
+// the state. inSync lists the paths that need no action but whose state
+// must be (re)recorded.
+func (e *Engine) reconcile(local map[string]localFile, remote map[string]remoteFile, known map[string]fileState) ([]Action, []string) {
+	paths := map[string]struct{}{}
+	for path := range local {
+		paths[path] = struct{}{}
+	}
+	for path := range remote {
+		paths[path] = struct{}{}
+	}
+	for path := range known {
+		paths[path] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	var actions []Action
+	var inSync []string
+
+	for _, path := range sorted {
+		l, hasLocal := local[path]
+		r, hasRemote := remote[path]
+		s, hasState := known[path]
+
+		localChanged := hasLocal && (!hasState || l.Size != s.Size || l.ModTime != s.ModTime)
+		remoteChanged := hasRemote && (!hasState || r.ETag != s.ETag)
+
+		switch {
+		case hasLocal && hasRemote:
+			switch {
+			case !localChanged && !remoteChanged:
+			case localChanged && !remoteChanged:
+				actions = append(actions, Action{Kind: Upload, Path: path, Size: l.Size})
+			case !localChanged && remoteChanged:
+				actions = append(actions, Action{Kind: Download, Path: path, Size: r.Size})
+			case sameContent(e.localPath(path), l, r):
+				inSync = append(inSync, path)
+			default:
+				actions = append(actions, Action{
+					Kind:         Conflict,
+					Path:         path,
+					Size:         l.Size + r.Size,
+					ConflictPath: e.conflictPath(path),
+				})
+			}
+
+		case hasLocal:
+			// Deleted remotely, unless it is new or was edited meanwhile.
+			if localChanged {
+				actions = append(actions, Action{Kind: Upload, Path: path, Size: l.Size})
+			} else {
+				actions = append(actions, Action{Kind: DeleteLocal, Path: path})
+			}
+
+		case hasRemote:
+			if remoteChanged {
+				actions = append(actions, Action{Kind: Download, Path: path, Size: r.Size})
+			} else {
+				actions = append(actions, Action{Kind: DeleteRemote, Path: path})
+			}
+		}
+	}
+
+	return actions, inSync
+}
+
+func (e *Engine) checkDeletes(actions []Action, tracked int) error {
+	if e.options.Force {
+		return nil
+	}
+
+	deletes := 0
+	for _, action := range actions {
+		if action.Kind == DeleteLocal || action.Kind == DeleteRemote {
+			deletes++
+		}
+	}
+
+	if deletes > 1 && float64(deletes) > e.options.DeleteThreshold*float64(tracked) {
+		return fmt.Errorf("%w: %d de %d arquivos", ErrTooManyDeletes, deletes, tracked)
+	}
+
+	return nil
+}
+
+// conflictPath inserts the suffix and the time before the extension.
+func (e *Engine) conflictPath(path string) string {
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+
+	ext := ""
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name, ext = name[:i], name[i:]
+	}
+
+	stamp := e.options.Now().UTC().Format("20060102-150405")
+	return dir + name + "." + e.options.ConflictSuffix + "-" + stamp + ext
+}
+
+func (e *Engine) localPath(path string) string {
+	return filepath.Join(e.root, filepath.FromSlash(path))
+}
+
+func (e *Engine) key(path string) string {
+	return e.prefix + path
+}
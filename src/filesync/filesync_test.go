@@ -0,0 +1,246 @@
+package filesync
+
+import (
+	"cloud_file_manager/src/client"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRemote keeps the bucket in memory, with S3-like quoted MD5 ETags.
+type fakeRemote struct {
+	objects map[string]string
+	deleted []string
+}
+
+func newFakeRemote(objects map[string]string) *fakeRemote {
+	if objects == nil {
+		objects = map[string]string{}
+	}
+	return &fakeRemote{objects: objects}
+}
+
+func etag(content string) string {
+	sum := md5.Sum([]byte(content))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeRemote) ListObjects(ctx context.Context, prefix string) ([]client.Object, error) {
+	var objects []client.Object
+	for key, content := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, client.Object{Key: key, Size: int64(len(content)), ETag: etag(content)})
+		}
+	}
+	return objects, nil
+}
+
+func (f *fakeRemote) Upload(ctx context.Context, key string, path string, progress client.Progress) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f.objects[key] = string(data)
+	return nil
+}
+
+func (f *fakeRemote) Download(ctx context.Context, key string, path string, progress client.Progress) error {
+	content, ok := f.objects[key]
+	if !ok {
+		return errors.New("objeto não existe")
+	}
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func (f *fakeRemote) Delete(ctx context.Context, key string) error {
+	delete(f.objects, key)
+	f.deleted = append(f.deleted, key)
+	return nil
+}
+
+func writeFile(t *testing.T, root string, path string, content string) {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(path))
+	os.MkdirAll(filepath.Dir(full), 0o755)
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		t.Fatalf("não foi possível escrever %s: %v", path, err)
+	}
+	// Keeps consecutive edits apart on filesystems with coarse mtimes.
+	later := time.Now().Add(time.Duration(len(content)) * time.Second)
+	os.Chtimes(full, later, later)
+}
+
+func readFile(t *testing.T, root string, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		t.Fatalf("não foi possível ler %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func runSync(t *testing.T, engine *Engine) *Result {
+	t.Helper()
+	result, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if result.Failed > 0 {
+		t.Fatalf("ações falharam: %#v", result.Actions)
+	}
+	return result
+}
+
+func kinds(result *Result) map[string]ActionKind {
+	found := map[string]ActionKind{}
+	for _, action := range result.Actions {
+		found[action.Path] = action.Kind
+	}
+	return found
+}
+
+func TestSyncCopiesBothWays(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "local.txt", "daqui")
+	remote := newFakeRemote(map[string]string{"docs/remoto.txt": "de lá", "outros/x.txt": "fora"})
+
+	engine := New(remote, root, "docs", Options{})
+
+	found := kinds(runSync(t, engine))
+	if found["local.txt"] != Upload || found["remoto.txt"] != Download || len(found) != 2 {
+		t.Fatalf("ações inesperadas %v", found)
+	}
+	if remote.objects["docs/local.txt"] != "daqui" || readFile(t, root, "remoto.txt") != "de lá" {
+		t.Fatalf("os dois lados deveriam ter os dois arquivos")
+	}
+
+	if result := runSync(t, engine); len(result.Actions) != 0 {
+		t.Fatalf("a segunda sincronização não deveria ter ações, veio %#v", result.Actions)
+	}
+}
+
+func TestSyncPropagatesChangesAndDeletes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		writeFile(t, root, name, name)
+	}
+	remote := newFakeRemote(nil)
+	engine := New(remote, root, "", Options{})
+	runSync(t, engine)
+
+	writeFile(t, root, "a.txt", "a editado")
+	remote.objects["b.txt"] = "b remoto"
+	os.Remove(filepath.Join(root, "c.txt"))
+	delete(remote.objects, "d.txt")
+
+	found := kinds(runSync(t, engine))
+	expected := map[string]ActionKind{"a.txt": Upload, "b.txt": Download, "c.txt": DeleteRemote, "d.txt": DeleteLocal}
+	for path, kind := range expected {
+		if found[path] != kind {
+			t.Fatalf("%s: esperava %s, veio %s", path, kind, found[path])
+		}
+	}
+
+	if remote.objects["a.txt"] != "a editado" || readFile(t, root, "b.txt") != "b remoto" {
+		t.Fatalf("as edições deveriam ter sido copiadas")
+	}
+	if _, ok := remote.objects["c.txt"]; ok {
+		t.Fatalf("c.txt deveria ter sido apagado no bucket")
+	}
+	if _, err := os.Stat(filepath.Join(root, "d.txt")); !os.IsNotExist(err) {
+		t.Fatalf("d.txt deveria ter sido apagado localmente")
+	}
+}
+
+func TestSyncKeepsBothCopiesOnConflict(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "notas/plano.md", "v1")
+	remote := newFakeRemote(nil)
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	engine := New(remote, root, "", Options{Now: func() time.Time { return now }})
+	runSync(t, engine)
+
+	writeFile(t, root, "notas/plano.md", "versão local")
+	remote.objects["notas/plano.md"] = "versão remota"
+
+	result := runSync(t, engine)
+	if len(result.Actions) != 1 || result.Actions[0].Kind != Conflict {
+		t.Fatalf("esperava um conflito, veio %#v", result.Actions)
+	}
+
+	copyPath := "notas/plano.conflict-20250102-150405.md"
+	if result.Actions[0].ConflictPath != copyPath {
+		t.Fatalf("nome de conflito inesperado %s", result.Actions[0].ConflictPath)
+	}
+	if readFile(t, root, "notas/plano.md") != "versão remota" || readFile(t, root, copyPath) != "versão local" {
+		t.Fatalf("a pasta deveria ter as duas versões")
+	}
+	if remote.objects[copyPath] != "versão local" {
+		t.Fatalf("o bucket deveria ter a cópia local")
+	}
+
+	if result := runSync(t, engine); len(result.Actions) != 0 {
+		t.Fatalf("o conflito deveria estar resolvido, veio %#v", result.Actions)
+	}
+}
+
+func TestSyncFirstRunMatchesIdenticalFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "igual.txt", "mesmo conteúdo")
+	remote := newFakeRemote(map[string]string{"igual.txt": "mesmo conteúdo"})
+
+	if result := runSync(t, New(remote, root, "", Options{})); len(result.Actions) != 0 {
+		t.Fatalf("arquivos iguais não deveriam gerar ações, veio %#v", result.Actions)
+	}
+}
+
+func TestSyncRefusesMassDeletes(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		writeFile(t, root, name, name)
+	}
+	remote := newFakeRemote(nil)
+	runSync(t, New(remote, root, "", Options{}))
+
+	for _, name := range []string{"a", "b", "c"} {
+		os.Remove(filepath.Join(root, name))
+	}
+
+	_, err := New(remote, root, "", Options{}).Run(context.Background())
+	if !errors.Is(err, ErrTooManyDeletes) {
+		t.Fatalf("esperava ErrTooManyDeletes, veio %v", err)
+	}
+	if len(remote.deleted) != 0 {
+		t.Fatalf("nada deveria ter sido apagado, veio %v", remote.deleted)
+	}
+
+	runSync(t, New(remote, root, "", Options{Force: true}))
+	if len(remote.deleted) != 3 {
+		t.Fatalf("com Force deveria apagar os três, veio %v", remote.deleted)
+	}
+}
+
+func TestSyncDryRunChangesNothing(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "novo.txt", "novo")
+	remote := newFakeRemote(map[string]string{"remoto.txt": "remoto"})
+
+	result := runSync(t, New(remote, root, "", Options{DryRun: true}))
+	if !result.DryRun || len(result.Actions) != 2 {
+		t.Fatalf("esperava duas ações planejadas, veio %#v", result)
+	}
+
+	if _, ok := remote.objects["novo.txt"]; ok {
+		t.Fatalf("o dry-run não deveria enviar nada")
+	}
+	if _, err := os.Stat(filepath.Join(root, StateFile)); !os.IsNotExist(err) {
+		t.Fatalf("o dry-run não deveria gravar o estado")
+	}
+}
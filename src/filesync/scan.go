@@ -0,0 +1,111 @@
+package filesync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type localFile struct {
+	Size    int64
+	ModTime int64
+}
+
+type remoteFile struct {
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// scanLocal lists the regular files under root by slash-separated relative
+// path, leaving out the state database and unfinished downloads.
+func (e *Engine) scanLocal() (map[string]localFile, error) {
+	files := map[string]localFile{}
+
+	err := filepath.WalkDir(e.root, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		name := entry.Name()
+		if current == e.statePath || isPartial(name) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(e.root, current)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relative)] = localFile{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// scanRemote lists the objects under the prefix by path relative to it.
+// Folder markers and keys that would land outside the root are skipped.
+func (e *Engine) scanRemote(ctx context.Context) (map[string]remoteFile, error) {
+	objects, err := e.remote.ListObjects(ctx, e.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]remoteFile, len(objects))
+	for _, object := range objects {
+		relative := strings.TrimPrefix(object.Key, e.prefix)
+		if relative == "" || strings.HasSuffix(relative, "/") || !filepath.IsLocal(filepath.FromSlash(relative)) {
+			continue
+		}
+
+		files[relative] = remoteFile{Size: object.Size, ETag: object.ETag, LastModified: object.LastModified}
+	}
+
+	return files, nil
+}
+
+// isPartial matches the temporary files client.Download writes to.
+func isPartial(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".part")
+}
+
+// sameContent compares a local file with a remote object through the
+// ETag, which is the MD5 of the content for objects not sent in parts.
+// Multipart ETags cannot be compared, so they never match.
+func sameContent(path string, local localFile, remote remoteFile) bool {
+	etag := strings.Trim(remote.ETag, `"`)
+	if local.Size != remote.Size || etag == "" || strings.Contains(etag, "-") {
+		return false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)) == etag
+}
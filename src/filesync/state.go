@@ -0,0 +1,75 @@
+package filesync
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// StateFile is the default name of the state database, kept at the root of
+// the synced directory and never synced itself.
+const StateFile = ".cfm-sync.json"
+
+// fileState is what a path looked like on both sides the last time they
+// were in sync: the local size and mtime and the remote ETag.
+type fileState struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	ETag    string `json:"etag"`
+}
+
+type state struct {
+	Prefix string               `json:"prefix"`
+	Files  map[string]fileState `json:"files"`
+}
+
+// loadState reads the state database. A missing file, or one written for
+// another prefix, means nothing was synced yet.
+func loadState(path string, prefix string) (*state, error) {
+	empty := &state{Prefix: prefix, Files: map[string]fileState{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return empty, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded state
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, err
+	}
+
+	if loaded.Prefix != prefix || loaded.Files == nil {
+		return empty, nil
+	}
+
+	return &loaded, nil
+}
+
+// save replaces the state database atomically, so a crash mid-write keeps
+// the previous run's state.
+func (s *state) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AuthMethodSession = "session"
+	AuthMethodApiKey  = "apikey"
+
+	ApiKeyPrefix = "cfm_"
+)
+
+// ApiKeyValidator resolves an API key hash to the active key it belongs to.
+type ApiKeyValidator interface {
+	ValidateApiKey(keyHash string) (*models.ApiKey, error)
+}
+
+var apiKeyValidator ApiKeyValidator
+
+func SetApiKeyValidator(validator ApiKeyValidator) {
+	apiKeyValidator = validator
+}
+
+// Authenticate accepts either a session JWT ("Bearer <jwt>" or the bare
+// token) or a personal key ("ApiKey <key>"). Both end up as jwt.MapClaims
+// under "claims", with "userId" and "authMethod" always present.
+func Authenticate(ctx *gin.Context) {
+	header := ctx.GetHeader("Authorization")
+	if header == "" {
+		response := Response{
+			Message: "É necessário token de autorização",
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	claims, message := headerClaims(header)
+	if claims == nil {
+		response := Response{
+			Message: message,
+		}
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, response)
+		return
+	}
+
+	ctx.Set("claims", claims)
+	ctx.Next()
+}
+
+// BasicAuthenticate is Authenticate for protocol clients, such as WebDAV
+// mounts, that only speak HTTP basic auth: the password is an API key and
+// the user name is ignored. Bearer tokens are accepted too. Failures carry
+// a WWW-Authenticate challenge so clients prompt for credentials.
+func BasicAuthenticate(realm string) gin.HandlerFunc {
+	challenge := `Basic realm="` + realm + `", charset="UTF-8"`
+
+	return func(ctx *gin.Context) {
+		var claims jwt.MapClaims
+		message := "É necessário token de autorização"
+
+		if _, password, ok := ctx.Request.BasicAuth(); ok {
+			claims, message = apiKeyClaims(password)
+		} else if header := ctx.GetHeader("Authorization"); header != "" {
+			claims, message = headerClaims(header)
+		}
+
+		if claims == nil {
+			ctx.Header("WWW-Authenticate", challenge)
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, Response{Message: message})
+			return
+		}
+
+		ctx.Set("claims", claims)
+		ctx.Next()
+	}
+}
+
+func headerClaims(header string) (jwt.MapClaims, string) {
+	if key, found := strings.CutPrefix(header, "ApiKey "); found {
+		return apiKeyClaims(strings.TrimSpace(key))
+	}
+	return sessionClaims(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+}
+
+func sessionClaims(tokenString string) (jwt.MapClaims, string) {
+	claims := jwt.MapClaims{}
+	if err := parseToken(tokenString, claims); err != nil {
+		return nil, "Token inválido"
+	}
+
+	if _, pending := claims["purpose"]; pending {
+		return nil, "Token inválido"
+	}
+
+	if !isSessionActive(claims) {
+		return nil, "Sessão encerrada"
+	}
+
+	claims["authMethod"] = AuthMethodSession
+	return claims, ""
+}
+
+func apiKeyClaims(key string) (jwt.MapClaims, string) {
+	if apiKeyValidator == nil || !strings.HasPrefix(key, ApiKeyPrefix) {
+		return nil, "Chave de API inválida"
+	}
+
+	apiKey, err := apiKeyValidator.ValidateApiKey(utils.HashToken(key))
+	if err != nil {
+		fmt.Println(err)
+		return nil, "Não foi possível validar a chave de API"
+	}
+
+	if apiKey == nil {
+		return nil, "Chave de API inválida, revogada ou expirada"
+	}
+
+	return jwt.MapClaims{
+		"userId":     float64(apiKey.UserID),
+		"apiKeyId":   float64(apiKey.ID),
+		"scopes":     expandApiKeyScopes(apiKey.Scopes),
+		"authMethod": AuthMethodApiKey,
+	}, ""
+}
+
+// RequireSession restricts a route to interactive logins, e.g. so an API key
+// cannot mint more API keys.
+func RequireSession(ctx *gin.Context) {
+	if authMethod(ctx) != AuthMethodSession {
+		response := Response{
+			Message: "Esta operação exige login com usuário e senha",
+		}
+		ctx.AbortWithStatusJSON(http.StatusForbidden, response)
+		return
+	}
+
+	ctx.Next()
+}
+
+func authMethod(ctx *gin.Context) string {
+	claimsValue, exists := ctx.Get("claims")
+	if !exists {
+		return ""
+	}
+
+	claims, ok := claimsValue.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	method, _ := claims["authMethod"].(string)
+	return method
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type fakeApiKeyValidator struct {
+	keys map[string]models.ApiKey
+}
+
+func (f *fakeApiKeyValidator) ValidateApiKey(keyHash string) (*models.ApiKey, error) {
+	apiKey, ok := f.keys[keyHash]
+	if !ok {
+		return nil, nil
+	}
+	return &apiKey, nil
+}
+
+func newAuthRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	handlers = append(handlers, func(ctx *gin.Context) {
+		claims := ctx.MustGet("claims").(jwt.MapClaims)
+		ctx.JSON(http.StatusOK, gin.H{"userId": claims["userId"], "authMethod": claims["authMethod"]})
+	})
+	router.POST("/test", handlers...)
+	return router
+}
+
+func performAuth(router *gin.Engine, authorization string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/test", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAuthenticateAcceptsBearerJWT(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+	SetSessionValidator(nil)
+
+	token, _ := CreateToken("Ana", 3, 1, AllScopes)
+
+	recorder := performAuth(newAuthRouter(Authenticate), "Bearer "+token)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", recorder.Code)
+	}
+}
+
+func TestAuthenticateRejectsMfaPendingToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	token, _ := CreateMfaToken(3, AllScopes)
+
+	recorder := performAuth(newAuthRouter(Authenticate), token)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("esperava status 401, veio %d", recorder.Code)
+	}
+}
+
+func TestAuthenticateAcceptsApiKey(t *testing.T) {
+	key := ApiKeyPrefix + "abcd_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(key): {ID: 2, UserID: 9, Scopes: []string{models.ApiKeyScopeReadOnly}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	router := newAuthRouter(Authenticate)
+
+	if recorder := performAuth(router, "ApiKey "+key); recorder.Code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", recorder.Code)
+	}
+	if recorder := performAuth(router, "ApiKey "+ApiKeyPrefix+"abcd_outra"); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("esperava status 401 para chave desconhecida, veio %d", recorder.Code)
+	}
+}
+
+func TestRequireScopeMapsApiKeyScopes(t *testing.T) {
+	readKey := ApiKeyPrefix + "read_secret"
+	adminKey := ApiKeyPrefix + "admn_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(readKey):  {ID: 1, UserID: 9, Scopes: []string{models.ApiKeyScopeReadOnly}},
+		utils.HashToken(adminKey): {ID: 2, UserID: 9, Scopes: []string{models.ApiKeyScopeAdmin}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	router := newAuthRouter(Authenticate, RequireScope(ScopeFilesWrite))
+
+	if recorder := performAuth(router, "ApiKey "+readKey); recorder.Code != http.StatusForbidden {
+		t.Fatalf("chave somente leitura não pode enviar arquivos, veio %d", recorder.Code)
+	}
+	if recorder := performAuth(router, "ApiKey "+adminKey); recorder.Code != http.StatusOK {
+		t.Fatalf("chave admin deveria poder enviar arquivos, veio %d", recorder.Code)
+	}
+
+	router = newAuthRouter(Authenticate, RequireScope(ScopeAccountManage))
+	if recorder := performAuth(router, "ApiKey "+adminKey); recorder.Code != http.StatusForbidden {
+		t.Fatalf("chaves não podem gerenciar a conta, veio %d", recorder.Code)
+	}
+}
+
+func TestRequireScopeRejectsNarrowToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+	SetSessionValidator(nil)
+
+	token, _ := CreateToken("Quiosque", 3, 1, []string{ScopeFilesRead})
+
+	if recorder := performAuth(newAuthRouter(Authenticate, RequireScope(ScopeFilesRead)), token); recorder.Code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", recorder.Code)
+	}
+	if recorder := performAuth(newAuthRouter(Authenticate, RequireScope(ScopeFilesWrite)), token); recorder.Code != http.StatusForbidden {
+		t.Fatalf("esperava status 403, veio %d", recorder.Code)
+	}
+}
+
+func TestParseMfaTokenKeepsScopes(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	token, _ := CreateMfaToken(3, []string{ScopeFilesRead})
+
+	userId, scopes, err := ParseMfaToken(token)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if userId != 3 || len(scopes) != 1 || scopes[0] != ScopeFilesRead {
+		t.Fatalf("resultado inesperado %d %v", userId, scopes)
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	scopes, err := NormalizeScopes(nil)
+	if err != nil || len(scopes) != len(AllScopes) {
+		t.Fatalf("esperava todos os escopos, veio %v %v", scopes, err)
+	}
+
+	if _, err := NormalizeScopes([]string{"admin"}); err != ErrInvalidScope {
+		t.Fatalf("esperava ErrInvalidScope, veio %v", err)
+	}
+}
+
+func TestRequireSessionRejectsApiKey(t *testing.T) {
+	key := ApiKeyPrefix + "abcd_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(key): {ID: 2, UserID: 9, Scopes: []string{models.ApiKeyScopeAdmin}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	router := newAuthRouter(Authenticate, RequireSession)
+
+	if recorder := performAuth(router, "ApiKey "+key); recorder.Code != http.StatusForbidden {
+		t.Fatalf("esperava status 403, veio %d", recorder.Code)
+	}
+}
+
+func TestBasicAuthenticateTakesApiKeyAsPassword(t *testing.T) {
+	key := ApiKeyPrefix + "abcd_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(key): {ID: 2, UserID: 9, Scopes: []string{models.ApiKeyScopeUpload}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	router := newAuthRouter(BasicAuthenticate("cfm"))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/test", nil)
+	request.SetBasicAuth("qualquer", key)
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", recorder.Code)
+	}
+
+	recorder = performAuth(router, "")
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("esperava status 401, veio %d", recorder.Code)
+	}
+	if recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("a resposta 401 deveria pedir credenciais")
+	}
+}
@@ -1,61 +1,64 @@
 package handlers
 
 import (
-	"net/http"
-	"os"
+	"crypto/rand"
+	"errors"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func VerifyToken (ctx *gin.Context) {
-	tokenString := ctx.GetHeader("Authorization")
-	if tokenString == "" {
-		response := Response{
-			Message: "É necessário token de autorização",
-		}
-		ctx.JSON(http.StatusBadRequest, response)
-		ctx.AbortWithStatus(http.StatusBadRequest)
-	}
-
-	claims := jwt.MapClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
+func CreateToken(username string, userId int, sessionId int, scopes []string) (string, error) {
+	tokenString, err := signToken(jwt.MapClaims{
+		"username": username,
+		"userId": userId,
+		"sessionId": sessionId,
+		"scopes": scopes,
+		"exp": time.Now().Add(time.Hour * 24).Unix(),
 	})
 	if err != nil {
-		response := Response{
-			Message: "Não foi possível analisar o token",
-		}
-		ctx.JSON(http.StatusInternalServerError, response)
-		ctx.AbortWithStatus(http.StatusBadRequest)
+		return "", err
 	}
 
-	if !token.Valid {
-		response := Response{
-			Message: "Token inválido",
-		}
-		ctx.JSON(http.StatusUnauthorized, response)
-		ctx.AbortWithStatus(http.StatusBadRequest)
-	}
+	return tokenString, nil
+}
+
+const mfaTokenPurpose = "mfa"
+
+// MfaTokenTTL is how long the password step of a two-factor login lasts.
+const MfaTokenTTL = 5 * time.Minute
+
+var ErrInvalidMfaToken = errors.New("token de autenticação em duas etapas inválido ou expirado")
 
-	ctx.Set("claims", claims)
-	ctx.Next()
+// CreateMfaToken issues the short-lived token returned by the password step
+// of a two-factor login. It is not accepted by Authenticate.
+func CreateMfaToken(userId int, scopes []string) (string, error) {
+	return signToken(jwt.MapClaims{
+		"userId": userId,
+		"scopes": scopes,
+		"purpose": mfaTokenPurpose,
+		// Unique, so that attempts are counted for each token on its own.
+		"jti": rand.Text(),
+		"exp": time.Now().Add(MfaTokenTTL).Unix(),
+	})
 }
 
-func CreateToken(username string, password string, userId int) (string, error) {
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"username": username,
-			"userId": userId,
-			"exp": time.Now().Add(time.Hour * 24).Unix(),
-		})
-	
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
-	if err != nil {
-		return "", err
+// ParseMfaToken returns the user and the scopes requested at the password
+// step, which the final token must keep.
+func ParseMfaToken(tokenString string) (int, []string, error) {
+	claims := jwt.MapClaims{}
+	if err := parseToken(tokenString, claims); err != nil {
+		return 0, nil, ErrInvalidMfaToken
 	}
 
-	return tokenString, nil
-}
\ No newline at end of file
+	if claims["purpose"] != mfaTokenPurpose {
+		return 0, nil, ErrInvalidMfaToken
+	}
+
+	userId, ok := claims["userId"].(float64)
+	if !ok {
+		return 0, nil, ErrInvalidMfaToken
+	}
+
+	return int(userId), ClaimScopes(claims), nil
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"cloud_file_manager/src/ratelimit"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics serves the counters in the Prometheus text format. The scraper
+// must send METRICS_TOKEN as a bearer token; without one set the endpoint
+// stays closed.
+func Metrics(ctx *gin.Context) {
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	provided := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/plain; version=0.0.4")
+	ctx.Status(http.StatusOK)
+	if err := ratelimit.WriteMetrics(ctx.Writer); err != nil {
+		fmt.Println(err)
+	}
+}
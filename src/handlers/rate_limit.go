@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"cloud_file_manager/src/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const planCacheTTL = time.Minute
+
+// PlanResolver returns the name of the plan a user is subscribed to.
+type PlanResolver interface {
+	GetUserPlan(userId int) (string, error)
+}
+
+type cachedPlan struct {
+	name      string
+	expiresAt time.Time
+}
+
+// RateLimiter applies per-user token buckets sized by the user's plan.
+// API keys get a bucket of their own so a runaway script does not starve
+// the owner's interactive use.
+type RateLimiter struct {
+	buckets  *ratelimit.TokenBucketLimiter
+	plans    ratelimit.Plans
+	resolver PlanResolver
+
+	mu        sync.Mutex
+	planCache map[int]cachedPlan
+}
+
+var rateLimiter *RateLimiter
+
+// maxRouteCost is the highest cost passed to RateLimit while the routes
+// were being set up.
+var maxRouteCost int
+
+func NewRateLimiter(plans ratelimit.Plans, resolver PlanResolver) *RateLimiter {
+	return &RateLimiter{
+		buckets:   ratelimit.NewTokenBucketLimiter(),
+		plans:     plans,
+		resolver:  resolver,
+		planCache: map[int]cachedPlan{},
+	}
+}
+
+func SetRateLimiter(limiter *RateLimiter) {
+	rateLimiter = limiter
+}
+
+// ValidateRateLimits checks, once the routes are registered, that every
+// plan can pay for the most expensive of them.
+func ValidateRateLimits() error {
+	if rateLimiter == nil {
+		return nil
+	}
+
+	return rateLimiter.plans.CheckCost(maxRouteCost)
+}
+
+// RateLimit charges cost tokens to the caller's bucket and reports usage in
+// the RateLimit-* headers. It runs after Authenticate; on routes without
+// one, such as the second login step, the client IP is charged instead.
+func RateLimit(cost int) gin.HandlerFunc {
+	maxRouteCost = max(maxRouteCost, cost)
+
+	return func(ctx *gin.Context) {
+		if rateLimiter == nil {
+			ctx.Next()
+			return
+		}
+
+		claimsValue, _ := ctx.Get("claims")
+		claims, _ := claimsValue.(jwt.MapClaims)
+		userId, _ := claims["userId"].(float64)
+
+		key := fmt.Sprintf("user:%d", int(userId))
+		if apiKeyId, ok := claims["apiKeyId"].(float64); ok {
+			key = fmt.Sprintf("apikey:%d", int(apiKeyId))
+		}
+		if claims == nil {
+			key = "ip:" + ctx.ClientIP()
+		}
+
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		if !rateLimiter.charge(ctx.Writer.Header(), key, int(userId), cost, route) {
+			response := Response{
+				Message: "Limite de requisições excedido, tente novamente mais tarde",
+			}
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, response)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// ChargeRateLimit is RateLimit for handlers that authenticate requests
+// themselves, such as the S3 gateway. It reports whether the request may
+// go on; when not, Retry-After is already set on w.
+func ChargeRateLimit(w http.ResponseWriter, key string, userId int, cost int, route string) bool {
+	if rateLimiter == nil {
+		return true
+	}
+
+	return rateLimiter.charge(w.Header(), key, userId, cost, route)
+}
+
+// charge takes cost tokens from the bucket under key, sized by the user's
+// plan, and reports usage in the RateLimit-* headers.
+func (rl *RateLimiter) charge(header http.Header, key string, userId int, cost int, route string) bool {
+	plan := rl.planFor(userId)
+	decision := rl.buckets.Take(key, plan, cost)
+
+	ratelimit.RequestsTotal.Inc("limiter", "api", "plan", plan.Name, "route", route)
+
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", plan.Burst, int(math.Ceil(float64(plan.Burst)/plan.RefillPerSecond()))))
+
+	if !decision.Allowed {
+		ratelimit.HitsTotal.Inc("limiter", "api", "plan", plan.Name, "route", route)
+		header.Set("Retry-After", strconv.Itoa(max(ceilSeconds(decision.RetryAfter), 1)))
+		return false
+	}
+
+	return true
+}
+
+func (rl *RateLimiter) planFor(userId int) ratelimit.Plan {
+	if rl.resolver == nil {
+		return rl.plans.Get("")
+	}
+
+	rl.mu.Lock()
+	cached, ok := rl.planCache[userId]
+	rl.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return rl.plans.Get(cached.name)
+	}
+
+	name, err := rl.resolver.GetUserPlan(userId)
+	if err != nil {
+		fmt.Println(err)
+		return rl.plans.Get("")
+	}
+
+	rl.mu.Lock()
+	rl.planCache[userId] = cachedPlan{name: name, expiresAt: time.Now().Add(planCacheTTL)}
+	rl.mu.Unlock()
+
+	return rl.plans.Get(name)
+}
+
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
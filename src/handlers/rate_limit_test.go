@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakePlanResolver struct {
+	plans map[int]string
+}
+
+func (f *fakePlanResolver) GetUserPlan(userId int) (string, error) {
+	return f.plans[userId], nil
+}
+
+func TestRateLimitReportsUsageAndRejects(t *testing.T) {
+	plans, _ := ratelimit.ParsePlans("free:3:60,pro:100:600", "free")
+	SetRateLimiter(NewRateLimiter(plans, &fakePlanResolver{plans: map[int]string{}}))
+	defer SetRateLimiter(nil)
+
+	key := ApiKeyPrefix + "rate_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(key): {ID: 4, UserID: 9, Scopes: []string{models.ApiKeyScopeReadOnly}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	router := newAuthRouter(Authenticate, RateLimit(2))
+
+	recorder := performAuth(router, "ApiKey "+key)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", recorder.Code)
+	}
+	if recorder.Header().Get("RateLimit-Limit") != "3" || recorder.Header().Get("RateLimit-Remaining") != "1" {
+		t.Fatalf("cabeçalhos inesperados %v", recorder.Header())
+	}
+
+	recorder = performAuth(router, "ApiKey "+key)
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("esperava status 429, veio %d", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") != "1" {
+		t.Fatalf("esperava Retry-After 1, veio %q", recorder.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitUsesUserPlan(t *testing.T) {
+	plans, _ := ratelimit.ParsePlans("free:3:60,pro:100:600", "free")
+	SetRateLimiter(NewRateLimiter(plans, &fakePlanResolver{plans: map[int]string{9: "pro"}}))
+	defer SetRateLimiter(nil)
+
+	key := ApiKeyPrefix + "plan_secret"
+	SetApiKeyValidator(&fakeApiKeyValidator{keys: map[string]models.ApiKey{
+		utils.HashToken(key): {ID: 5, UserID: 9, Scopes: []string{models.ApiKeyScopeReadOnly}},
+	}})
+	defer SetApiKeyValidator(nil)
+
+	recorder := performAuth(newAuthRouter(Authenticate, RateLimit(1)), "ApiKey "+key)
+	if recorder.Header().Get("RateLimit-Limit") != "100" {
+		t.Fatalf("esperava limite do plano pro, veio %q", recorder.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitChargesClientIPWithoutAuthentication(t *testing.T) {
+	plans, _ := ratelimit.ParsePlans("free:3:60", "free")
+	SetRateLimiter(NewRateLimiter(plans, &fakePlanResolver{plans: map[int]string{}}))
+	defer SetRateLimiter(nil)
+
+	router := gin.New()
+	router.POST("/test", RateLimit(2), func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	perform := func(ip string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/test", nil)
+		request.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if code := perform("10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", code)
+	}
+	if code := perform("10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("esperava status 429, veio %d", code)
+	}
+	if code := perform("10.0.0.2"); code != http.StatusOK {
+		t.Fatalf("outro IP deveria ter o próprio limite, veio %d", code)
+	}
+}
+
+func TestValidateRateLimitsRejectsPlansBelowRouteCost(t *testing.T) {
+	plans, _ := ratelimit.ParsePlans("free:3:60,pro:100:600", "free")
+	SetRateLimiter(NewRateLimiter(plans, nil))
+	defer SetRateLimiter(nil)
+
+	RateLimit(3)
+	if err := ValidateRateLimits(); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	RateLimit(10)
+	if err := ValidateRateLimits(); err == nil {
+		t.Fatalf("esperava erro para o plano free")
+	}
+}
+
+func TestMetricsClosedWithoutToken(t *testing.T) {
+	router := gin.New()
+	router.GET("/metrics", Metrics)
+
+	perform := func(authorization string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		request.Header.Set("Authorization", authorization)
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	t.Setenv("METRICS_TOKEN", "")
+	if code := perform(""); code != http.StatusNotFound {
+		t.Fatalf("esperava status 404, veio %d", code)
+	}
+
+	t.Setenv("METRICS_TOKEN", "segredo")
+	if code := perform("Bearer errado"); code != http.StatusUnauthorized {
+		t.Fatalf("esperava status 401, veio %d", code)
+	}
+	if code := perform("Bearer segredo"); code != http.StatusOK {
+		t.Fatalf("esperava status 200, veio %d", code)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"cloud_file_manager/src/models"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ScopeFilesRead     = "files:read"
+	ScopeFilesWrite    = "files:write"
+	ScopeBucketsAdmin  = "buckets:admin"
+	ScopeUsersRead     = "users:read"
+	ScopeAccountManage = "account:manage"
+)
+
+// AllScopes is what a regular login receives when it asks for nothing
+// narrower.
+var AllScopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeBucketsAdmin, ScopeUsersRead, ScopeAccountManage}
+
+var ErrInvalidScope = errors.New("escopo inválido")
+
+// apiKeyScopes maps the coarse API key scopes to token scopes. Keys never
+// get account:manage, so they cannot manage sessions or mint other keys.
+var apiKeyScopes = map[string][]string{
+	models.ApiKeyScopeReadOnly: {ScopeFilesRead, ScopeUsersRead},
+	models.ApiKeyScopeUpload:   {ScopeFilesRead, ScopeFilesWrite, ScopeUsersRead},
+	models.ApiKeyScopeAdmin:    {ScopeFilesRead, ScopeFilesWrite, ScopeBucketsAdmin, ScopeUsersRead},
+}
+
+// NormalizeScopes validates the scopes requested at login, defaulting to all
+// of them, and returns them sorted without duplicates.
+func NormalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return slices.Clone(AllScopes), nil
+	}
+
+	for _, scope := range requested {
+		if !slices.Contains(AllScopes, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	return slices.Compact(slices.Sorted(slices.Values(requested))), nil
+}
+
+func expandApiKeyScopes(keyScopes []string) []any {
+	return scopesClaim(KeyScopes(keyScopes))
+}
+
+// KeyScopes lists the token scopes that API key scopes (read-only, upload,
+// admin) grant. S3 access keys share them.
+func KeyScopes(keyScopes []string) []string {
+	var scopes []string
+	for _, keyScope := range keyScopes {
+		for _, scope := range apiKeyScopes[keyScope] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
+
+func scopesClaim(scopes []string) []any {
+	claim := make([]any, len(scopes))
+	for i, scope := range scopes {
+		claim[i] = scope
+	}
+	return claim
+}
+
+// ClaimScopes lists the scopes granted by a token.
+func ClaimScopes(claims jwt.MapClaims) []string {
+	values, _ := claims["scopes"].([]any)
+
+	scopes := make([]string, 0, len(values))
+	for _, value := range values {
+		if scope, ok := value.(string); ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// RequireScope rejects requests whose token lacks any of the given scopes.
+// It must run after Authenticate.
+func RequireScope(required ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claimsValue, _ := ctx.Get("claims")
+		claims, _ := claimsValue.(jwt.MapClaims)
+		granted := ClaimScopes(claims)
+
+		for _, scope := range required {
+			if !slices.Contains(granted, scope) {
+				response := Response{
+					Message: "O token não tem o escopo necessário: " + scope,
+				}
+				ctx.AbortWithStatusJSON(http.StatusForbidden, response)
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireFileScopes guards routes that serve a whole file protocol under
+// one path: reads need files:read and anything else files:write.
+func RequireFileScopes(ctx *gin.Context) {
+	if IsReadMethod(ctx.Request.Method) {
+		RequireScope(ScopeFilesRead)(ctx)
+		return
+	}
+	RequireScope(ScopeFilesWrite)(ctx)
+}
+
+// IsReadMethod tells the methods of a file protocol that only read.
+func IsReadMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import "fmt"
+
+// SessionValidator reports whether the session behind a token is still active.
+type SessionValidator interface {
+	TouchSession(sessionId int) (bool, error)
+}
+
+var sessionValidator SessionValidator
+
+func SetSessionValidator(validator SessionValidator) {
+	sessionValidator = validator
+}
+
+func isSessionActive(claims map[string]any) bool {
+	if sessionValidator == nil {
+		return true
+	}
+
+	sessionId, ok := claims["sessionId"].(float64)
+	if !ok {
+		return false
+	}
+
+	active, err := sessionValidator.TouchSession(int(sessionId))
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	return active
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"cloud_file_manager/src/jwk"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("nenhuma chave de assinatura ativa")
+
+// SigningKey is one entry of the key set. Retired keys may hold only the
+// public half: they are kept to verify tokens issued before a rotation.
+// Past NotAfter, when set, a key verifies nothing.
+type SigningKey struct {
+	Kid        string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	NotAfter   time.Time
+}
+
+func (k *SigningKey) retired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// KeySet signs new tokens with the active key and verifies tokens signed by
+// any key it holds, selected through the "kid" header. HS256 tokens signed
+// with JWT_SECRET are accepted only until hs256Until.
+type KeySet struct {
+	active     *SigningKey
+	keys       map[string]*SigningKey
+	hs256Until time.Time
+}
+
+var keySet *KeySet
+
+// SetKeySet switches token signing to asymmetric keys. Without a key set
+// tokens are HS256-signed with JWT_SECRET.
+func SetKeySet(set *KeySet) {
+	keySet = set
+}
+
+// LoadKeySetFromEnv reads JWT_KEYS_DIR, JWT_ACTIVE_KID and
+// JWT_HS256_UNTIL. It returns nil when no directory is configured.
+//
+// JWT_HS256_UNTIL (RFC 3339) is the end of the move from JWT_SECRET to the
+// key set: until then HS256 tokens still verify, so enabling the key set
+// does not log everyone out. Without it they are refused right away.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+
+	set, err := LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		return nil, err
+	}
+
+	if value := os.Getenv("JWT_HS256_UNTIL"); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_HS256_UNTIL: %w", err)
+		}
+		set.AcceptHS256Until(until)
+	}
+
+	return set, nil
+}
+
+// AcceptHS256Until keeps HS256 tokens valid until the given time.
+func (ks *KeySet) AcceptHS256Until(until time.Time) {
+	ks.hs256Until = until
+}
+
+// LoadKeySet loads every *.pem file in dir, using the file name as kid.
+// Files may hold RSA or Ed25519 keys, private (PKCS#8 or PKCS#1) or public
+// (PKIX). To rotate, add the new key and point activeKid at it; give the
+// old one a "Not-After" PEM header (RFC 3339) set past the expiry of the
+// tokens it signed, and it stops verifying then.
+func LoadKeySet(dir string, activeKid string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	set := &KeySet{keys: map[string]*SigningKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		set.keys[kid] = key
+	}
+
+	if activeKid == "" && len(set.keys) == 1 {
+		for kid := range set.keys {
+			activeKid = kid
+		}
+	}
+
+	active, ok := set.keys[activeKid]
+	if !ok || active.PrivateKey == nil || active.retired(time.Now()) {
+		return nil, fmt.Errorf("%w: %q", ErrNoSigningKey, activeKid)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+func parseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("arquivo PEM inválido")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("bloco PEM não suportado: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{Kid: kid}
+	if value, ok := block.Headers["Not-After"]; ok {
+		key.NotAfter, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("Not-After: %w", err)
+		}
+	}
+
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.PrivateKey = signer
+		key.PublicKey = signer.Public()
+	} else {
+		key.PublicKey = parsed
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, jwk.ErrUnsupportedKey
+	}
+
+	return key, nil
+}
+
+// JWKS lists the public half of every key in the set that is not retired.
+func (ks *KeySet) JWKS() jwk.Set {
+	now := time.Now()
+	kids := make([]string, 0, len(ks.keys))
+	for kid, key := range ks.keys {
+		if !key.retired(now) {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids)
+
+	set := jwk.Set{Keys: []jwk.Key{}}
+	for _, kid := range kids {
+		key := ks.keys[kid]
+		publicKey, err := jwk.FromPublicKey(kid, key.Method.Alg(), key.PublicKey)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		set.Keys = append(set.Keys, publicKey)
+	}
+
+	return set
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
+	if keySet == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	}
+
+	token := jwt.NewWithClaims(keySet.active.Method, claims)
+	token.Header["kid"] = keySet.active.Kid
+	return token.SignedString(keySet.active.PrivateKey)
+}
+
+// parseToken verifies a token signed by signToken. With a key set, HS256
+// tokens are accepted only until its transition deadline, and each one
+// accepted is logged so the deadline can be set from real traffic.
+func parseToken(tokenString string, claims jwt.MapClaims) error {
+	now := time.Now()
+
+	var validMethods []string
+	if keySet == nil || now.Before(keySet.hs256Until) {
+		validMethods = append(validMethods, jwt.SigningMethodHS256.Alg())
+	}
+	if keySet != nil {
+		validMethods = append(validMethods, jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg())
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (any, error) {
+		if t.Method == jwt.SigningMethodHS256 {
+			secret := os.Getenv("JWT_SECRET")
+			if secret == "" {
+				return nil, ErrNoSigningKey
+			}
+			if keySet != nil {
+				fmt.Printf("Token HS256 aceito durante a transição para o conjunto de chaves (até %s)\n", keySet.hs256Until.Format(time.RFC3339))
+			}
+			return []byte(secret), nil
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keySet.keys[kid]
+		if !ok || key.Method != t.Method {
+			return nil, fmt.Errorf("chave desconhecida: %q", kid)
+		}
+		if key.retired(now) {
+			return nil, fmt.Errorf("chave aposentada: %q", kid)
+		}
+		return key.PublicKey, nil
+	}, jwt.WithValidMethods(validMethods))
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return jwt.ErrTokenSignatureInvalid
+	}
+
+	return nil
+}
+
+// JWKS publishes the verification keys so other services can check our
+// tokens without sharing a secret.
+func JWKS(ctx *gin.Context) {
+	set := jwk.Set{Keys: []jwk.Key{}}
+	if keySet != nil {
+		set = keySet.JWKS()
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, set)
+}
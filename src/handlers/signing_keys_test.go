@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/jwk"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePrivateKey(t *testing.T, dir string, kid string, key any) {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+}
+
+func loadTestKeySet(t *testing.T, dir string, activeKid string) {
+	t.Helper()
+
+	set, err := LoadKeySet(dir, activeKid)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	SetKeySet(set)
+	t.Cleanup(func() { SetKeySet(nil) })
+}
+
+func TestKeySetSignsWithActiveKidAndVerifiesAfterRotation(t *testing.T) {
+	os.Setenv("JWT_SECRET", "")
+	defer os.Setenv("JWT_SECRET", "secret")
+	SetSessionValidator(nil)
+
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writePrivateKey(t, dir, "2026-01", rsaKey)
+	loadTestKeySet(t, dir, "")
+
+	oldToken, err := CreateToken("Ana", 3, 1, AllScopes)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	parsed, _, _ := jwt.NewParser().ParseUnverified(oldToken, jwt.MapClaims{})
+	if parsed.Header["kid"] != "2026-01" || parsed.Method != jwt.SigningMethodRS256 {
+		t.Fatalf("cabeçalho inesperado %v", parsed.Header)
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "2026-02", edKey)
+	loadTestKeySet(t, dir, "2026-02")
+
+	newToken, _ := CreateToken("Ana", 3, 1, AllScopes)
+	parsed, _, _ = jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if parsed.Header["kid"] != "2026-02" || parsed.Method != jwt.SigningMethodEdDSA {
+		t.Fatalf("cabeçalho inesperado %v", parsed.Header)
+	}
+
+	router := newAuthRouter(Authenticate)
+	for _, token := range []string{oldToken, newToken} {
+		if recorder := performAuth(router, "Bearer "+token); recorder.Code != http.StatusOK {
+			t.Fatalf("esperava status 200, veio %d", recorder.Code)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "2026-01.pem"))
+	loadTestKeySet(t, dir, "2026-02")
+
+	if recorder := performAuth(router, "Bearer "+oldToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("chave removida não deveria verificar, veio %d", recorder.Code)
+	}
+}
+
+func TestKeySetAcceptsHS256OnlyUntilTheDeadline(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+	SetSessionValidator(nil)
+
+	legacyToken, _ := CreateToken("Ana", 3, 1, AllScopes)
+
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "atual", edKey)
+	loadTestKeySet(t, dir, "")
+
+	router := newAuthRouter(Authenticate)
+	if recorder := performAuth(router, legacyToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("sem prazo de transição o token HS256 não deveria valer, veio %d", recorder.Code)
+	}
+
+	keySet.AcceptHS256Until(time.Now().Add(time.Hour))
+	if recorder := performAuth(router, legacyToken); recorder.Code != http.StatusOK {
+		t.Fatalf("token HS256 deveria valer até o prazo, veio %d", recorder.Code)
+	}
+
+	os.Setenv("JWT_SECRET", "")
+	if recorder := performAuth(router, legacyToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("sem JWT_SECRET esperava status 401, veio %d", recorder.Code)
+	}
+	os.Setenv("JWT_SECRET", "secret")
+
+	keySet.AcceptHS256Until(time.Now().Add(-time.Minute))
+	if recorder := performAuth(router, legacyToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("depois do prazo esperava status 401, veio %d", recorder.Code)
+	}
+}
+
+func TestKeySetStopsVerifyingRetiredKeys(t *testing.T) {
+	os.Setenv("JWT_SECRET", "")
+	defer os.Setenv("JWT_SECRET", "secret")
+	SetSessionValidator(nil)
+
+	dir := t.TempDir()
+	_, oldKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "antiga", oldKey)
+	loadTestKeySet(t, dir, "")
+	oldToken, _ := CreateToken("Ana", 3, 1, AllScopes)
+
+	// Retire the old key with a Not-After header instead of deleting it.
+	der, _ := x509.MarshalPKIXPublicKey(oldKey.Public())
+	retired := pem.EncodeToMemory(&pem.Block{
+		Type:    "PUBLIC KEY",
+		Headers: map[string]string{"Not-After": time.Now().Add(-time.Minute).Format(time.RFC3339)},
+		Bytes:   der,
+	})
+	os.WriteFile(filepath.Join(dir, "antiga.pem"), retired, 0o600)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "nova", newKey)
+	loadTestKeySet(t, dir, "nova")
+
+	router := newAuthRouter(Authenticate)
+	if recorder := performAuth(router, "Bearer "+oldToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("chave aposentada não deveria verificar, veio %d", recorder.Code)
+	}
+	if _, ok := keySet.JWKS().Find("antiga"); ok {
+		t.Fatalf("chave aposentada não deveria ser publicada")
+	}
+}
+
+func TestLoadKeySetRequiresActiveKid(t *testing.T) {
+	dir := t.TempDir()
+	_, first, _ := ed25519.GenerateKey(rand.Reader)
+	_, second, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "a", first)
+	writePrivateKey(t, dir, "b", second)
+
+	if _, err := LoadKeySet(dir, ""); err == nil {
+		t.Fatalf("esperava erro sem JWT_ACTIVE_KID com várias chaves")
+	}
+}
+
+func TestJWKSPublishesPublicKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "rsa", rsaKey)
+	writePrivateKey(t, dir, "ed", edKey)
+	loadTestKeySet(t, dir, "ed")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", JWKS)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var set jwk.Set
+	if err := json.Unmarshal(recorder.Body.Bytes(), &set); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(set.Keys) != 2 {
+		t.Fatalf("esperava 2 chaves, veio %d", len(set.Keys))
+	}
+
+	key, _ := set.Find("rsa")
+	publicKey, err := key.PublicKey()
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !rsaKey.PublicKey.Equal(publicKey) {
+		t.Fatalf("chave publicada não confere")
+	}
+	if key.Alg != "RS256" {
+		t.Fatalf("alg inesperado %s", key.Alg)
+	}
+}
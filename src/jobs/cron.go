@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("expressão cron inválida")
+
+// Schedule is a parsed five-field cron expression (minute, hour, day of
+// month, month, day of week). Fields accept *, lists, ranges and steps.
+type Schedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	anyDay      bool
+	anyWeekday  bool
+}
+
+func ParseSchedule(spec string) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, ErrInvalidCron
+	}
+
+	var schedule Schedule
+	var err error
+
+	bounds := []struct {
+		target   *uint64
+		min, max int
+	}{
+		{&schedule.minutes, 0, 59},
+		{&schedule.hours, 0, 23},
+		{&schedule.daysOfMonth, 1, 31},
+		{&schedule.months, 1, 12},
+		{&schedule.daysOfWeek, 0, 6},
+	}
+
+	for i, bound := range bounds {
+		*bound.target, err = parseField(fields[i], bound.min, bound.max)
+		if err != nil {
+			return Schedule{}, err
+		}
+	}
+
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+	return schedule, nil
+}
+
+func parseField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, found := strings.Cut(part, "/"); found {
+			value, err := strconv.Atoi(after)
+			if err != nil || value <= 0 {
+				return 0, ErrInvalidCron
+			}
+			rangePart, step = before, value
+		}
+
+		start, end := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+
+			value, err := strconv.Atoi(first)
+			if err != nil {
+				return 0, ErrInvalidCron
+			}
+			start, end = value, value
+
+			if isRange {
+				end, err = strconv.Atoi(last)
+				if err != nil {
+					return 0, ErrInvalidCron
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, ErrInvalidCron
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+// Matches reports whether the schedule fires in the minute of t. As in cron,
+// when both day fields are restricted either of them may match.
+func (s Schedule) Matches(t time.Time) bool {
+	if s.minutes&(1<<uint(t.Minute())) == 0 ||
+		s.hours&(1<<uint(t.Hour())) == 0 ||
+		s.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayMatches := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	weekdayMatches := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekdayMatches
+	case s.anyWeekday:
+		return dayMatches
+	default:
+		return dayMatches || weekdayMatches
+	}
+}
+
+type Enqueuer interface {
+	EnqueueWith(jobType string, payload any, opts EnqueueOptions) (int, error)
+}
+
+type cronEntry struct {
+	name     string
+	schedule Schedule
+	jobType  string
+	payload  any
+}
+
+// Scheduler enqueues cron jobs, evaluated in UTC. Every instance runs one; the unique key
+// derived from the entry name and the minute makes sure only one job is
+// created per run across all of them.
+type Scheduler struct {
+	queue   Enqueuer
+	entries []cronEntry
+}
+
+func NewScheduler(queue Enqueuer) *Scheduler {
+	return &Scheduler{
+		queue: queue,
+	}
+}
+
+func (s *Scheduler) Add(name string, spec string, jobType string, payload any) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	s.entries = append(s.entries, cronEntry{
+		name:     name,
+		schedule: schedule,
+		jobType:  jobType,
+		payload:  payload,
+	})
+	return nil
+}
+
+// Tick enqueues every entry due in the minute of now.
+func (s *Scheduler) Tick(now time.Time) {
+	minute := now.UTC().Truncate(time.Minute)
+
+	for _, entry := range s.entries {
+		if !entry.schedule.Matches(minute) {
+			continue
+		}
+
+		_, err := s.queue.EnqueueWith(entry.jobType, entry.payload, EnqueueOptions{
+			RunAt:     minute,
+			UniqueKey: "cron:" + entry.name + ":" + minute.Format("200601021504"),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// Start ticks at the beginning of every minute until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(next.Sub(now)):
+				s.Tick(next)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeEnqueuer struct {
+	keys []string
+}
+
+func (f *fakeEnqueuer) EnqueueWith(jobType string, payload any, opts EnqueueOptions) (int, error) {
+	f.keys = append(f.keys, opts.UniqueKey)
+	return len(f.keys), nil
+}
+
+func TestParseScheduleMatches(t *testing.T) {
+	schedule, err := ParseSchedule("*/15 3-5 * * 1,3")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	// 2026-10-19 é uma segunda-feira.
+	cases := map[string]bool{
+		"2026-10-19T03:00:00Z": true,
+		"2026-10-19T04:45:00Z": true,
+		"2026-10-19T04:50:00Z": false,
+		"2026-10-19T06:00:00Z": false,
+		"2026-10-20T03:00:00Z": false,
+		"2026-10-21T05:30:00Z": true,
+	}
+
+	for value, expected := range cases {
+		moment, _ := time.Parse(time.RFC3339, value)
+		if schedule.Matches(moment) != expected {
+			t.Errorf("%s: esperava %v", value, expected)
+		}
+	}
+}
+
+func TestParseScheduleDayFieldsAreOred(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 1 * 0")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	first, _ := time.Parse(time.RFC3339, "2026-10-01T00:00:00Z")
+	sunday, _ := time.Parse(time.RFC3339, "2026-10-18T00:00:00Z")
+	monday, _ := time.Parse(time.RFC3339, "2026-10-19T00:00:00Z")
+
+	if !schedule.Matches(first) || !schedule.Matches(sunday) || schedule.Matches(monday) {
+		t.Fatalf("dia do mês e da semana deveriam valer como alternativas")
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(spec); err != ErrInvalidCron {
+			t.Errorf("%q: esperava ErrInvalidCron, veio %v", spec, err)
+		}
+	}
+}
+
+func TestSchedulerTickUsesUniqueKeyPerMinute(t *testing.T) {
+	queue := &fakeEnqueuer{}
+	scheduler := NewScheduler(queue)
+	if err := scheduler.Add("limpeza", "0 * * * *", JobPurge, nil); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	onTheHour, _ := time.Parse(time.RFC3339, "2026-10-19T10:00:42Z")
+	scheduler.Tick(onTheHour)
+	scheduler.Tick(onTheHour.Add(time.Minute))
+
+	if len(queue.keys) != 1 || queue.keys[0] != "cron:limpeza:202610191000" {
+		t.Fatalf("chaves inesperadas %v", queue.keys)
+	}
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"cloud_file_manager/src/models"
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 5
+
+	// JobPurge removes old finished jobs; see PostgresQueue.HandlePurge.
+	JobPurge = "jobs.purge"
+)
+
+// Handler runs one attempt of a job. Returning an error schedules a retry
+// with backoff until the job runs out of attempts and is dead-lettered.
+type Handler func(ctx context.Context, job models.Job) error
+
+type EnqueueOptions struct {
+	// RunAt delays the job; the zero value runs it as soon as possible.
+	RunAt       time.Time
+	MaxAttempts int
+	// UniqueKey makes enqueueing idempotent: a second job with the same key
+	// is silently dropped.
+	UniqueKey string
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error that retrying cannot fix, so the job is
+// dead-lettered right away.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// IsLastAttempt tells a handler that a failure now is final.
+func IsLastAttempt(job models.Job) bool {
+	return job.Attempts >= job.MaxAttempts
+}
+
+// Backoff grows exponentially from ten seconds and is capped at one hour.
+func Backoff(attempt int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < attempt && delay < time.Hour; i++ {
+		delay *= 2
+	}
+
+	if delay > time.Hour {
+		return time.Hour
+	}
+	return delay
+}
@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"cloud_file_manager/src/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const jobColumns = "id, job_type, payload, status, attempts, max_attempts, run_at, COALESCE(last_error, ''), created_at, finished_at"
+
+// PostgresQueue stores jobs in the jobs table. Workers claim them with
+// SELECT ... FOR UPDATE SKIP LOCKED, so any number of API instances can
+// share the queue without handing the same job out twice.
+type PostgresQueue struct {
+	connection *sql.DB
+}
+
+func NewPostgresQueue(connection *sql.DB) *PostgresQueue {
+	return &PostgresQueue{
+		connection: connection,
+	}
+}
+
+func (jq *PostgresQueue) Enqueue(jobType string, payload any) (int, error) {
+	return jq.EnqueueWith(jobType, payload, EnqueueOptions{})
+}
+
+// EnqueueWith returns 0 when a job with the same unique key already exists.
+func (jq *PostgresQueue) EnqueueWith(jobType string, payload any, opts EnqueueOptions) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+
+	var id int
+	err = jq.connection.QueryRow(
+		"INSERT INTO jobs (job_type, payload, run_at, max_attempts, unique_key) VALUES ($1, $2, $3, $4, NULLIF($5, ''))"+
+			" ON CONFLICT (unique_key) DO NOTHING RETURNING id",
+		jobType, body, opts.RunAt, opts.MaxAttempts, opts.UniqueKey,
+	).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Claim locks the next due job of one of the given types and marks it as
+// running. It returns nil when there is nothing to do.
+func (jq *PostgresQueue) Claim(jobTypes []string) (*models.Job, error) {
+	row := jq.connection.QueryRow(
+		"UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()"+
+			" WHERE id = (SELECT id FROM jobs WHERE status = 'queued' AND run_at <= NOW() AND job_type = ANY($1)"+
+			" ORDER BY run_at, id FOR UPDATE SKIP LOCKED LIMIT 1)"+
+			" RETURNING "+jobColumns,
+		pq.Array(jobTypes),
+	)
+
+	job, err := scanJob(row)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (jq *PostgresQueue) Complete(id int) error {
+	_, err := jq.connection.Exec(
+		"UPDATE jobs SET status = 'done', last_error = NULL, locked_at = NULL, finished_at = NOW(), updated_at = NOW() WHERE id = $1",
+		id,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (jq *PostgresQueue) Retry(id int, message string, runAt time.Time) error {
+	_, err := jq.connection.Exec(
+		"UPDATE jobs SET status = 'queued', last_error = $2, run_at = $3, locked_at = NULL, updated_at = NOW() WHERE id = $1",
+		id, message, runAt,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// Bury moves a job to the dead letter state, where it stays until an admin
+// retries it.
+func (jq *PostgresQueue) Bury(id int, message string) error {
+	_, err := jq.connection.Exec(
+		"UPDATE jobs SET status = 'dead', last_error = $2, locked_at = NULL, finished_at = NOW(), updated_at = NOW() WHERE id = $1",
+		id, message,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// RequeueStale hands back jobs whose worker died while running them.
+func (jq *PostgresQueue) RequeueStale(olderThan time.Duration) (int64, error) {
+	result, err := jq.connection.Exec(
+		"UPDATE jobs SET status = 'queued', run_at = NOW(), locked_at = NULL, updated_at = NOW()"+
+			" WHERE status = 'running' AND locked_at <= NOW() - $1 * INTERVAL '1 second'",
+		olderThan.Seconds(),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (jq *PostgresQueue) ListJobs(status string, limit int) ([]models.Job, error) {
+	rows, err := jq.connection.Query(
+		"SELECT "+jobColumns+" FROM jobs WHERE ($1 = '' OR status = $1) ORDER BY id DESC LIMIT $2",
+		status, limit,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (jq *PostgresQueue) GetJob(id int) (*models.Job, error) {
+	job, err := scanJob(jq.connection.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = $1", id))
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetJobByUniqueKey returns the job holding the unique key, or nil.
+func (jq *PostgresQueue) GetJobByUniqueKey(uniqueKey string) (*models.Job, error) {
+	job, err := scanJob(jq.connection.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE unique_key = $1", uniqueKey))
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// RetryJob puts a dead job back in the queue with a fresh set of attempts.
+func (jq *PostgresQueue) RetryJob(id int) (bool, error) {
+	result, err := jq.connection.Exec(
+		"UPDATE jobs SET status = 'queued', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()"+
+			" WHERE id = $1 AND status = 'dead'",
+		id,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// HandlePurge removes finished jobs older than a week. It is scheduled as a
+// cron job itself.
+func (jq *PostgresQueue) HandlePurge(ctx context.Context, job models.Job) error {
+	_, err := jq.connection.ExecContext(
+		ctx,
+		"DELETE FROM jobs WHERE status = 'done' AND finished_at <= NOW() - INTERVAL '7 days'",
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*models.Job, error) {
+	var job models.Job
+	var payload []byte
+
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	job.Payload = payload
+	return &job, nil
+}
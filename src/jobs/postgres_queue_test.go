@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var jobRowColumns = []string{"id", "job_type", "payload", "status", "attempts", "max_attempts", "run_at", "last_error", "created_at", "finished_at"}
+
+func TestPostgresQueueClaimSkipsLockedJobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FOR UPDATE SKIP LOCKED LIMIT 1").
+		WillReturnRows(sqlmock.NewRows(jobRowColumns).
+			AddRow(4, "storage.provision", []byte(`{"userId":7}`), "running", 1, 5, now, "", now, nil))
+
+	job, err := NewPostgresQueue(db).Claim([]string{"storage.provision"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if job == nil || job.ID != 4 || job.Attempts != 1 || string(job.Payload) != `{"userId":7}` {
+		t.Fatalf("job inesperado %#v", job)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestPostgresQueueEnqueueIgnoresDuplicateKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("ON CONFLICT \\(unique_key\\) DO NOTHING RETURNING id").
+		WithArgs("jobs.purge", []byte("null"), sqlmock.AnyArg(), DefaultMaxAttempts, "cron:limpeza:202610191000").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	id, err := NewPostgresQueue(db).EnqueueWith(JobPurge, nil, EnqueueOptions{UniqueKey: "cron:limpeza:202610191000"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if id != 0 {
+		t.Fatalf("esperava id 0 para job duplicado, veio %d", id)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"cloud_file_manager/src/models"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is the part of the queue a worker needs. PostgresQueue implements it.
+type Store interface {
+	Claim(jobTypes []string) (*models.Job, error)
+	Complete(id int) error
+	Retry(id int, message string, runAt time.Time) error
+	Bury(id int, message string) error
+	RequeueStale(olderThan time.Duration) (int64, error)
+}
+
+type WorkerConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	// StaleAfter is how long a job may stay running before it is assumed
+	// that its worker died and the job is handed out again.
+	StaleAfter time.Duration
+}
+
+// WorkerConfigFromEnv reads JOB_WORKERS and JOB_POLL_INTERVAL (a duration
+// such as "2s"), falling back to four workers polling every second.
+func WorkerConfigFromEnv() WorkerConfig {
+	config := WorkerConfig{
+		Workers:      4,
+		PollInterval: time.Second,
+		StaleAfter:   30 * time.Minute,
+	}
+
+	if workers, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && workers > 0 {
+		config.Workers = workers
+	}
+	if interval, err := time.ParseDuration(os.Getenv("JOB_POLL_INTERVAL")); err == nil && interval > 0 {
+		config.PollInterval = interval
+	}
+
+	return config
+}
+
+type Worker struct {
+	store    Store
+	config   WorkerConfig
+	handlers map[string]Handler
+	now      func() time.Time
+}
+
+func NewWorker(store Store, config WorkerConfig) *Worker {
+	return &Worker{
+		store:    store,
+		config:   config,
+		handlers: map[string]Handler{},
+		now:      time.Now,
+	}
+}
+
+// Register must be called before Start.
+func (w *Worker) Register(jobType string, handler Handler) {
+	w.handlers[jobType] = handler
+}
+
+// Start launches the worker pool and a janitor that requeues stale jobs.
+// Everything stops when ctx is cancelled; the returned WaitGroup lets the
+// caller wait for running jobs to finish.
+func (w *Worker) Start(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	for i := 0; i < w.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.janitor(ctx)
+	}()
+
+	return &wg
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		worked, err := w.RunNext(ctx)
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		if worked && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.config.PollInterval):
+		}
+	}
+}
+
+func (w *Worker) janitor(ctx context.Context) {
+	ticker := time.NewTicker(w.config.StaleAfter / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			requeued, err := w.store.RequeueStale(w.config.StaleAfter)
+			if err != nil {
+				fmt.Println(err)
+			} else if requeued > 0 {
+				fmt.Printf("%d jobs travados voltaram para a fila\n", requeued)
+			}
+		}
+	}
+}
+
+// RunNext claims and runs a single job. It reports whether there was one.
+func (w *Worker) RunNext(ctx context.Context) (bool, error) {
+	jobTypes := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+
+	job, err := w.store.Claim(jobTypes)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	err = w.run(ctx, *job)
+	if err == nil {
+		return true, w.store.Complete(job.ID)
+	}
+
+	fmt.Printf("job %d (%s) falhou na tentativa %d: %v\n", job.ID, job.Type, job.Attempts, err)
+
+	if IsPermanent(err) || IsLastAttempt(*job) {
+		return true, w.store.Bury(job.ID, err.Error())
+	}
+
+	return true, w.store.Retry(job.ID, err.Error(), w.now().Add(Backoff(job.Attempts)))
+}
+
+func (w *Worker) run(ctx context.Context, job models.Job) (err error) {
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("tipo de job desconhecido %q", job.Type))
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	return handler(ctx, job)
+}
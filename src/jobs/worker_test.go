@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"cloud_file_manager/src/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	jobs      []models.Job
+	completed []int
+	retried   map[int]time.Time
+	buried    map[int]string
+}
+
+func newFakeStore(jobs ...models.Job) *fakeStore {
+	return &fakeStore{
+		jobs:    jobs,
+		retried: map[int]time.Time{},
+		buried:  map[int]string{},
+	}
+}
+
+func (f *fakeStore) Claim(jobTypes []string) (*models.Job, error) {
+	if len(f.jobs) == 0 {
+		return nil, nil
+	}
+
+	job := f.jobs[0]
+	f.jobs = f.jobs[1:]
+	job.Attempts++
+	return &job, nil
+}
+
+func (f *fakeStore) Complete(id int) error {
+	f.completed = append(f.completed, id)
+	return nil
+}
+
+func (f *fakeStore) Retry(id int, message string, runAt time.Time) error {
+	f.retried[id] = runAt
+	return nil
+}
+
+func (f *fakeStore) Bury(id int, message string) error {
+	f.buried[id] = message
+	return nil
+}
+
+func (f *fakeStore) RequeueStale(time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func TestWorkerRetriesWithBackoffThenBuries(t *testing.T) {
+	store := newFakeStore(
+		models.Job{ID: 1, Type: "teste", Attempts: 0, MaxAttempts: 3},
+		models.Job{ID: 2, Type: "teste", Attempts: 2, MaxAttempts: 3},
+	)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	worker := NewWorker(store, WorkerConfig{})
+	worker.now = func() time.Time { return now }
+	worker.Register("teste", func(context.Context, models.Job) error {
+		return errors.New("falhou")
+	})
+
+	for i := 0; i < 2; i++ {
+		if worked, err := worker.RunNext(context.Background()); !worked || err != nil {
+			t.Fatalf("esperava processar um job, veio %v/%v", worked, err)
+		}
+	}
+
+	if store.retried[1] != now.Add(10*time.Second) {
+		t.Errorf("esperava nova tentativa em 10s, veio %v", store.retried[1])
+	}
+	if store.buried[2] != "falhou" {
+		t.Errorf("esperava job 2 na fila de mortos, veio %q", store.buried[2])
+	}
+}
+
+func TestWorkerBuriesPermanentErrorsAndPanics(t *testing.T) {
+	store := newFakeStore(
+		models.Job{ID: 1, Type: "permanente", MaxAttempts: 5},
+		models.Job{ID: 2, Type: "panico", MaxAttempts: 5},
+		models.Job{ID: 3, Type: "ok", MaxAttempts: 5},
+	)
+
+	worker := NewWorker(store, WorkerConfig{})
+	worker.Register("permanente", func(context.Context, models.Job) error {
+		return Permanent(errors.New("payload inválido"))
+	})
+	worker.Register("panico", func(context.Context, models.Job) error {
+		panic("quebrou")
+	})
+	worker.Register("ok", func(context.Context, models.Job) error {
+		return nil
+	})
+
+	for {
+		worked, err := worker.RunNext(context.Background())
+		if err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+		if !worked {
+			break
+		}
+	}
+
+	if _, ok := store.buried[1]; !ok {
+		t.Errorf("erro permanente deveria ir direto para a fila de mortos")
+	}
+	if _, ok := store.retried[2]; !ok {
+		t.Errorf("panic deveria ser tratado como falha comum")
+	}
+	if len(store.completed) != 1 || store.completed[0] != 3 {
+		t.Errorf("esperava concluir o job 3, veio %v", store.completed)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	if Backoff(1) != 10*time.Second || Backoff(3) != 40*time.Second {
+		t.Fatalf("backoff inesperado %v/%v", Backoff(1), Backoff(3))
+	}
+	if Backoff(50) != time.Hour {
+		t.Fatalf("esperava limite de uma hora, veio %v", Backoff(50))
+	}
+}
@@ -0,0 +1,138 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Key is a public JSON Web Key (RFC 7517). Only the members needed for
+// signature verification are modelled.
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+var ErrUnsupportedKey = errors.New("jwk: tipo de chave não suportado")
+
+// Find returns the key with the given kid, or the only key of the set when
+// the token carries no kid.
+func (s Set) Find(kid string) (Key, bool) {
+	if kid == "" && len(s.Keys) == 1 {
+		return s.Keys[0], true
+	}
+
+	for _, key := range s.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curva %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curva %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, k.Kty)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// FromPublicKey encodes an RSA, EC or Ed25519 public key as a signing JWK.
+func FromPublicKey(kid string, alg string, publicKey crypto.PublicKey) (Key, error) {
+	key := Key{Kid: kid, Use: "sig", Alg: alg}
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = encodeInt(pub.N.Bytes())
+		key.E = encodeInt(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		key.Kty = "EC"
+		key.Crv = pub.Curve.Params().Name
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key.X = encodeInt(pub.X.FillBytes(make([]byte, size)))
+		key.Y = encodeInt(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = encodeInt(pub)
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+
+	return key, nil
+}
+
+func encodeInt(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer writes messages to a file, or to the standard log when no path
+// is set, instead of delivering them.
+type LogMailer struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{
+		path: path,
+	}
+}
+
+func (lm *LogMailer) Send(to string, subject string, body string) error {
+	entry := fmt.Sprintf("[%s] Para: %s\nAssunto: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+
+	if lm.path == "" {
+		log.Print(entry)
+		return nil
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	file, err := os.OpenFile(lm.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(entry)
+	return err
+}
@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"os"
+)
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// NewMailerFromEnv picks the mailer configured by MAILER ("smtp" or "log").
+// The log mailer is the default so local development never sends real mail.
+func NewMailerFromEnv() Mailer {
+	if os.Getenv("MAILER") == "smtp" {
+		return NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USER"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	}
+
+	return NewLogMailer(os.Getenv("MAIL_LOG_FILE"))
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(host string, port string, username string, password string, from string) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (sm *SMTPMailer) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if sm.username != "" {
+		auth = smtp.PlainAuth("", sm.username, sm.password, sm.host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + sm.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"utf-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	err := smtp.SendMail(sm.host+":"+sm.port, auth, sm.from, []string{to}, []byte(message))
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoadEmbeddedMigrationsAreSequential(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Fatalf("esperava versão %d, veio %d (%s)", i+1, migration.Version, migration.Name)
+		}
+	}
+}
+
+// Up migrations may be run again over a schema they already built, e.g.
+// one made by hand before migrations existed.
+func TestUpMigrationsCanBeRerun(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	unguarded := regexp.MustCompile(`(?i)CREATE (UNIQUE )?(TABLE|INDEX) |ADD COLUMN `)
+	guarded := regexp.MustCompile(`(?i)(CREATE (UNIQUE )?(TABLE|INDEX)|ADD COLUMN) IF NOT EXISTS `)
+	for _, migration := range migrations {
+		if len(unguarded.FindAllString(migration.Up, -1)) != len(guarded.FindAllString(migration.Up, -1)) &&
+			!strings.Contains(migration.Up, "DO $$") {
+			t.Errorf("%04d_%s: CREATE e ADD COLUMN precisam de IF NOT EXISTS", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestLoadRequiresBothDirections(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0001_create_users.up.sql": {Data: []byte("CREATE TABLE users ();")},
+	}
+
+	if _, err := load(fsys); err == nil {
+		t.Fatalf("esperava erro para migration sem down")
+	}
+}
+
+func TestMigratorUpAppliesOnlyPendingMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	migrator := &Migrator{
+		connection: db,
+		migrations: []Migration{
+			{Version: 1, Name: "create_users", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"},
+			{Version: 2, Name: "create_sessions", Up: "CREATE TABLE sessions ();", Down: "DROP TABLE sessions;"},
+		},
+	}
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE sessions ();")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "create_sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Fatalf("esperava aplicar apenas a versão 2, veio %v", applied)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
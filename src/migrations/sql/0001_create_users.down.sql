@@ -0,0 +1,3 @@
+-- The users table may predate migrations and hold accounts that were never
+-- created by them, so rolling back never drops it.
+SELECT 1;
@@ -0,0 +1,9 @@
+-- Databases created before migrations existed already have this table.
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    user_name TEXT NOT NULL,
+    user_email TEXT NOT NULL,
+    user_password TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_user_email_key ON users (user_email);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_purpose_idx ON user_tokens (user_id, purpose);
+
+-- Accounts that existed before verification was introduced keep working.
+-- Only when the column is new, so running this again verifies no one.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'email_verified_at') THEN
+        ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+        UPDATE users SET email_verified_at = NOW();
+    END IF;
+END $$;
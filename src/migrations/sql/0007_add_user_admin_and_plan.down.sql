@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN plan;
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,3 @@
+DROP TABLE login_lockouts;
+
+DROP TABLE rate_limit_hits;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rate_limit_hits (
+    id BIGSERIAL PRIMARY KEY,
+    limiter_key TEXT NOT NULL,
+    hit_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_hits_key_idx ON rate_limit_hits (limiter_key, hit_at);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    email TEXT PRIMARY KEY,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
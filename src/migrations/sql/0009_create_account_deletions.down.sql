@@ -0,0 +1,12 @@
+DROP TABLE account_deletions;
+
+-- Without deleted_at, deleted accounts would look live again, and a new
+-- signup may have taken their email. A colon cannot appear in a valid
+-- address, so the prefix frees the email for the full index and keeps the
+-- deleted account from being logged into.
+UPDATE users SET user_email = 'deleted+' || id || ':' || user_email WHERE deleted_at IS NOT NULL;
+
+DROP INDEX users_user_email_key;
+CREATE UNIQUE INDEX users_user_email_key ON users (user_email);
+
+ALTER TABLE users DROP COLUMN deleted_at;
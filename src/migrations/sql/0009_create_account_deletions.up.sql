@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- A deleted account frees its email for a new signup. Hand-made tables may
+-- carry the uniqueness as a constraint instead of an index.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_user_email_key;
+DROP INDEX IF EXISTS users_user_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_user_email_key ON users (user_email) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id),
+    status TEXT NOT NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
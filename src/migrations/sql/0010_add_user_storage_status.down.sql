@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN storage_updated_at;
+ALTER TABLE users DROP COLUMN storage_error;
+ALTER TABLE users DROP COLUMN storage_status;
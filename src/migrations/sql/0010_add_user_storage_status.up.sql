@@ -0,0 +1,13 @@
+-- Verified accounts got their bucket before provisioning was tracked. Only
+-- when the columns are new, so running this again changes no status.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'storage_status') THEN
+        ALTER TABLE users ADD COLUMN storage_status TEXT NOT NULL DEFAULT 'pending';
+        ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_updated_at TIMESTAMPTZ;
+        UPDATE users SET storage_status = 'ready', storage_updated_at = NOW() WHERE email_verified_at IS NOT NULL;
+    END IF;
+END $$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_error TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_updated_at TIMESTAMPTZ;
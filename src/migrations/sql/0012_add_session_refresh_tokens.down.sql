@@ -0,0 +1,3 @@
+ALTER TABLE sessions DROP COLUMN scopes;
+ALTER TABLE sessions DROP COLUMN refresh_expires_at;
+ALTER TABLE sessions DROP COLUMN refresh_token_hash;
@@ -0,0 +1 @@
+DROP TABLE s3_access_keys;
@@ -0,0 +1,15 @@
+-- The secret is kept as issued: SigV4 signatures can only be checked by
+-- recomputing them with it.
+CREATE TABLE IF NOT EXISTS s3_access_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key_name TEXT NOT NULL,
+    access_key_id TEXT NOT NULL UNIQUE,
+    secret_key TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS s3_access_keys_user_id_idx ON s3_access_keys (user_id);
@@ -0,0 +1 @@
+DROP TABLE ssh_keys;
@@ -0,0 +1,12 @@
+-- Public keys users log in to the SFTP server with, as authorized_keys
+-- lines. Logins look them up by their SHA256 fingerprint.
+CREATE TABLE IF NOT EXISTS ssh_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key_name TEXT NOT NULL,
+    public_key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, fingerprint)
+);
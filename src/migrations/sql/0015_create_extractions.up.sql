@@ -0,0 +1,18 @@
+-- Archives being extracted into a bucket by the background jobs, with
+-- their progress. The totals are only known up front for ZIP archives.
+CREATE TABLE IF NOT EXISTS extractions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    archive_key TEXT NOT NULL,
+    target_prefix TEXT NOT NULL,
+    status TEXT NOT NULL,
+    files_done INT NOT NULL DEFAULT 0,
+    files_total INT,
+    bytes_done BIGINT NOT NULL DEFAULT 0,
+    bytes_total BIGINT,
+    skipped INT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
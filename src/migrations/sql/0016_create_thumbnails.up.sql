@@ -0,0 +1,17 @@
+-- Catalog of the thumbnails generated for image objects. They are stored
+-- in the thumbnail bucket under derivative_key; source_etag is the version
+-- of the object they were made from.
+CREATE TABLE IF NOT EXISTS thumbnails (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    object_key TEXT NOT NULL,
+    size INT NOT NULL,
+    source_etag TEXT NOT NULL,
+    derivative_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    bytes BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, object_key, size)
+);
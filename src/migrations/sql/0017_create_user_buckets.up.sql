@@ -0,0 +1,8 @@
+-- Buckets users created through POST /aws/bucket, besides the one
+-- provisioned at signup. Deleting the account deletes these and no others.
+CREATE TABLE IF NOT EXISTS user_buckets (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    bucket_name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// AccessKey is an S3 access key pair for the gateway. Its scopes are the
+// API key ones (read-only, upload, admin).
+type AccessKey struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"userId"`
+	Name        string     `json:"name"`
+	AccessKeyID string     `json:"accessKeyId"`
+	Scopes      []string   `json:"scopes"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	AccountDeletionPending = "pending"
+	AccountDeletionDone    = "done"
+	AccountDeletionFailed  = "failed"
+)
+
+type AccountDeletion struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
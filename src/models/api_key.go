@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	ApiKeyScopeReadOnly = "read-only"
+	ApiKeyScopeUpload   = "upload"
+	ApiKeyScopeAdmin    = "admin"
+)
+
+type ApiKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	ExtractionPending = "pending"
+	ExtractionRunning = "running"
+	ExtractionDone    = "done"
+	ExtractionFailed  = "failed"
+)
+
+// Extraction is an archive of a user's bucket being unpacked under
+// TargetPrefix. Skipped counts the entries left out, such as links or
+// paths escaping the target.
+type Extraction struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"userId"`
+	ArchiveKey   string     `json:"archiveKey"`
+	TargetPrefix string     `json:"targetPrefix"`
+	Status       string     `json:"status"`
+	FilesDone    int        `json:"filesDone"`
+	FilesTotal   *int       `json:"filesTotal,omitempty"`
+	BytesDone    int64      `json:"bytesDone"`
+	BytesTotal   *int64     `json:"bytesTotal,omitempty"`
+	Skipped      int        `json:"skipped"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	FinishedAt   *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ArchiveFile is an entry of an archive, as its directory describes it.
+type ArchiveFile struct {
+	Name           string    `json:"name"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressedSize"`
+	Modified       time.Time `json:"modified"`
+	IsDir          bool      `json:"isDir"`
+}
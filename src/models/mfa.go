@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type UserMfa struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+}
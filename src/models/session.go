@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	CreatedAt time.Time `json:"createdAt"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Current   bool      `json:"current"`
+	Scopes    []string  `json:"-"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SshKey is a public key its owner can log in to the SFTP server with.
+type SshKey struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"userId"`
+	Name        string     `json:"name"`
+	PublicKey   string     `json:"publicKey"`
+	Fingerprint string     `json:"fingerprint"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"io"
+	"time"
+)
+
+// Storage statuses follow the bucket provisioning saga of a user: pending
+// until the email is verified and the job starts, then ready or failed.
+const (
+	StoragePending      = "pending"
+	StorageProvisioning = "provisioning"
+	StorageReady        = "ready"
+	StorageFailed       = "failed"
+)
+
+type StorageStatus struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ObjectInfo describes an object of a user's bucket. Keys are relative to
+// the bucket; "folders" are keys ending in "/".
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"lastModified"`
+	ContentType  string    `json:"contentType,omitempty"`
+}
+
+// ObjectStream is an open object body. ContentRange is set for ranged
+// reads, as in "bytes 0-99/1234".
+type ObjectStream struct {
+	Body         io.ReadCloser
+	Info         ObjectInfo
+	ContentRange string
+}
+
+// ListQuery pages through a listing the way ListObjectsV2 does.
+type ListQuery struct {
+	Prefix            string
+	Delimiter         string
+	ContinuationToken string
+	StartAfter        string
+	MaxKeys           int
+}
+
+type ObjectPage struct {
+	Objects               []ObjectInfo
+	Prefixes              []string
+	Truncated             bool
+	NextContinuationToken string
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Thumbnail is a resized copy of an image object, fitting in a Size by
+// Size box.
+type Thumbnail struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"userId"`
+	ObjectKey     string    `json:"objectKey"`
+	Size          int       `json:"size"`
+	SourceETag    string    `json:"sourceEtag"`
+	DerivativeKey string    `json:"-"`
+	ContentType   string    `json:"contentType"`
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	Bytes         int64     `json:"bytes"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
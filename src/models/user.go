@@ -1,9 +1,12 @@
 package models
 
+// User is the persistence model. Handlers never serialize it directly: API
+// responses go through dto.PublicUserDto, and the password hash is excluded
+// from JSON as a second line of defence.
 type User struct {
 	ID int `json:"id"`
 	Name string `json:"name"`
 	Email string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"-"`
 }
 
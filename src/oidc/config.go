@@ -0,0 +1,36 @@
+package oidc
+
+import (
+	"os"
+	"strings"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// ConfigFromEnv reads the provider settings. The second value is false when
+// no issuer is configured, meaning OIDC login is disabled.
+func ConfigFromEnv() (Config, bool) {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return Config{}, false
+	}
+
+	scopes := []string{"openid", "email", "profile"}
+	if value := os.Getenv("OIDC_SCOPES"); value != "" {
+		scopes = strings.Fields(value)
+	}
+
+	return Config{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       scopes,
+	}, true
+}
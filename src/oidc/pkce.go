@@ -0,0 +1,47 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+func randomString(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// NewPendingLogin creates the state, nonce and PKCE verifier of a new
+// authorization request.
+func NewPendingLogin() (PendingLogin, error) {
+	state, err := randomString(24)
+	if err != nil {
+		return PendingLogin{}, err
+	}
+
+	nonce, err := randomString(24)
+	if err != nil {
+		return PendingLogin{}, err
+	}
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return PendingLogin{}, err
+	}
+
+	return PendingLogin{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}, nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge from a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
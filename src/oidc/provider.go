@@ -0,0 +1,242 @@
+package oidc
+
+import (
+	"cloud_file_manager/src/jwk"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidIDToken   = errors.New("oidc: id token inválido")
+	ErrEmailNotVerified = errors.New("oidc: o provedor não confirmou o email")
+)
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Identity holds the verified claims of an ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider talks to one OpenID Connect provider. Discovery runs lazily on
+// first use, so the API starts even when the provider is unreachable.
+type Provider struct {
+	config     Config
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      jwk.Set
+}
+
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Provider{
+		config:     config,
+		httpClient: httpClient,
+	}
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var document discoveryDocument
+	err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &document)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(document.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer %q não corresponde ao configurado", document.Issuer)
+	}
+
+	p.discovery = &document
+	return p.discovery, nil
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, login PendingLogin) (string, error) {
+	document, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", login.State)
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", CodeChallenge(login.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(document.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return document.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the verified
+// identity from the ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, login PendingLogin) (*Identity, error) {
+	document, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", login.CodeVerifier)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, document.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint respondeu %d", response.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return nil, err
+	}
+
+	if tokens.IDToken == "" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return p.VerifyIDToken(ctx, tokens.IDToken, login.Nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken string, nonce string) (*Identity, error) {
+	document, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, document.JwksURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(document.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce não confere", ErrInvalidIDToken)
+	}
+
+	// Some providers send email_verified as the string "true".
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+
+	return &Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: verified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// publicKey looks the kid up in the cached key set and refetches the set
+// once when it is missing, which is how provider key rotation shows up.
+func (p *Provider) publicKey(ctx context.Context, jwksURI string, kid string) (any, error) {
+	p.mu.Lock()
+	key, found := p.keys.Find(kid)
+	p.mu.Unlock()
+
+	if !found {
+		var keys jwk.Set
+		if err := p.getJSON(ctx, jwksURI, &keys); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.keys = keys
+		key, found = p.keys.Find(kid)
+		p.mu.Unlock()
+	}
+
+	if !found {
+		return nil, fmt.Errorf("oidc: chave %q não encontrada", kid)
+	}
+
+	return key.PublicKey()
+}
+
+func (p *Provider) getJSON(ctx context.Context, address string, target any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s respondeu %d", address, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(target)
+}
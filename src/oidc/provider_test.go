@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that enforces PKCE for a single authorization code.
+type mockProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+	audience  string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("falha ao gerar chave: %v", err)
+	}
+
+	mock := &mockProvider{key: key, audience: "client-id"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mock.server.URL,
+			"authorization_endpoint": mock.server.URL + "/authorize",
+			"token_endpoint":         mock.server.URL + "/token",
+			"jwks_uri":               mock.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		clientID, _, _ := r.BasicAuth()
+		if r.Form.Get("code") != "good-code" || clientID != "client-id" ||
+			CodeChallenge(r.Form.Get("code_verifier")) != mock.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            mock.server.URL,
+			"sub":            "user-123",
+			"aud":            mock.audience,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          mock.nonce,
+			"email":          "Ana@Example.com",
+			"email_verified": true,
+			"name":           "Ana",
+		})
+		token.Header["kid"] = "test-key"
+		signed, _ := token.SignedString(key)
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+
+	mock.server = httptest.NewServer(mux)
+	t.Cleanup(mock.server.Close)
+	return mock
+}
+
+func (m *mockProvider) authorize(t *testing.T, provider *Provider, login PendingLogin) {
+	address, err := provider.AuthCodeURL(context.Background(), login)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	parsed, _ := url.Parse(address)
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("state") != login.State {
+		t.Fatalf("url de autorização inesperada %s", address)
+	}
+
+	m.challenge = query.Get("code_challenge")
+	m.nonce = query.Get("nonce")
+}
+
+func newTestProvider(mock *mockProvider) *Provider {
+	return NewProvider(Config{
+		Issuer:       mock.server.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8000/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	}, mock.server.Client())
+}
+
+func TestProviderAuthorizationCodeFlow(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newTestProvider(mock)
+
+	login, _ := NewPendingLogin()
+	mock.authorize(t, provider, login)
+
+	identity, err := provider.Exchange(context.Background(), "good-code", login)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if identity.Subject != "user-123" || identity.Email != "ana@example.com" || !identity.EmailVerified {
+		t.Fatalf("identidade inesperada %#v", identity)
+	}
+}
+
+func TestProviderRejectsWrongNonce(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newTestProvider(mock)
+
+	login, _ := NewPendingLogin()
+	mock.authorize(t, provider, login)
+	mock.nonce = "outro-nonce"
+
+	_, err := provider.Exchange(context.Background(), "good-code", login)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("esperava ErrInvalidIDToken, veio %v", err)
+	}
+}
+
+func TestProviderRejectsWrongAudience(t *testing.T) {
+	mock := newMockProvider(t)
+	mock.audience = "outro-cliente"
+	provider := newTestProvider(mock)
+
+	login, _ := NewPendingLogin()
+	mock.authorize(t, provider, login)
+
+	_, err := provider.Exchange(context.Background(), "good-code", login)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("esperava ErrInvalidIDToken, veio %v", err)
+	}
+}
+
+func TestProviderRejectsWrongVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newTestProvider(mock)
+
+	login, _ := NewPendingLogin()
+	mock.authorize(t, provider, login)
+	login.CodeVerifier = "adulterado"
+
+	if _, err := provider.Exchange(context.Background(), "good-code", login); err == nil {
+		t.Fatalf("esperava erro com verificador PKCE errado")
+	}
+}
+
+func TestMemoryStateStoreTakeIsSingleUse(t *testing.T) {
+	store := NewMemoryStateStore()
+	store.Save(PendingLogin{State: "abc", ExpiresAt: time.Now().Add(time.Minute)})
+
+	if _, ok, _ := store.Take("abc"); !ok {
+		t.Fatalf("esperava encontrar o state")
+	}
+	if _, ok, _ := store.Take("abc"); ok {
+		t.Fatalf("o state não pode ser reutilizado")
+	}
+}
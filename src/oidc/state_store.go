@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"sync"
+	"time"
+)
+
+type PendingLogin struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// StateStore keeps pending logins between the redirect to the provider and
+// the callback. Take must remove the entry so a state is used only once.
+type StateStore interface {
+	Save(login PendingLogin) error
+	Take(state string) (PendingLogin, bool, error)
+}
+
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	pending map[string]PendingLogin
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		pending: map[string]PendingLogin{},
+	}
+}
+
+func (ms *MemoryStateStore) Save(login PendingLogin) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	for state, entry := range ms.pending {
+		if now.After(entry.ExpiresAt) {
+			delete(ms.pending, state)
+		}
+	}
+
+	ms.pending[login.State] = login
+	return nil
+}
+
+func (ms *MemoryStateStore) Take(state string) (PendingLogin, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	login, ok := ms.pending[state]
+	if !ok {
+		return PendingLogin{}, false, nil
+	}
+
+	delete(ms.pending, state)
+
+	if time.Now().After(login.ExpiresAt) {
+		return PendingLogin{}, false, nil
+	}
+
+	return login, true, nil
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps attempts in process memory. Limits are per instance, so
+// use the Postgres store when running more than one.
+type MemoryStore struct {
+	mu    sync.Mutex
+	hits  map[string][]time.Time
+	now   func() time.Time
+	calls int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hits: map[string][]time.Time{},
+		now:  time.Now,
+	}
+}
+
+func (ms *MemoryStore) Hit(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	hits := prune(ms.hits[key], now.Add(-window))
+
+	if len(hits) >= limit {
+		ms.hits[key] = hits
+		return false, hits[0].Add(window).Sub(now), nil
+	}
+
+	ms.hits[key] = append(hits, now)
+
+	// Keys that stopped being hit would otherwise stay forever.
+	ms.calls++
+	if ms.calls%1000 == 0 {
+		ms.sweep(now, window)
+	}
+
+	return true, 0, nil
+}
+
+func (ms *MemoryStore) Blocked(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	hits := prune(ms.hits[key], now.Add(-window))
+
+	if len(hits) >= limit {
+		return true, hits[0].Add(window).Sub(now), nil
+	}
+
+	return false, 0, nil
+}
+
+func (ms *MemoryStore) Reset(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.hits, key)
+	return nil
+}
+
+func (ms *MemoryStore) sweep(now time.Time, window time.Duration) {
+	for key, hits := range ms.hits {
+		if len(hits) == 0 || !hits[len(hits)-1].After(now.Add(-window)) {
+			delete(ms.hits, key)
+		}
+	}
+}
+
+func prune(hits []time.Time, since time.Time) []time.Time {
+	for i, hit := range hits {
+		if hit.After(since) {
+			return hits[i:]
+		}
+	}
+	return hits[:0]
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreSlidingWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		allowed, _, _ := store.Hit("ip:1", 3, time.Minute)
+		if !allowed {
+			t.Fatalf("tentativa %d deveria passar", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	allowed, retryAfter, _ := store.Hit("ip:1", 3, time.Minute)
+	if allowed {
+		t.Fatalf("quarta tentativa deveria ser recusada")
+	}
+	if retryAfter != 30*time.Second {
+		t.Fatalf("esperava 30s de espera, veio %s", retryAfter)
+	}
+
+	if allowed, _, _ := store.Hit("ip:2", 3, time.Minute); !allowed {
+		t.Fatalf("chaves diferentes não deveriam compartilhar a janela")
+	}
+
+	now = now.Add(30 * time.Second)
+	if allowed, _, _ := store.Hit("ip:1", 3, time.Minute); !allowed {
+		t.Fatalf("tentativa deveria passar quando a mais antiga sai da janela")
+	}
+}
+
+func TestMemoryStoreReset(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.Hit("account:ana", 1, time.Hour)
+	if allowed, _, _ := store.Hit("account:ana", 1, time.Hour); allowed {
+		t.Fatalf("segunda tentativa deveria ser recusada")
+	}
+
+	store.Reset("account:ana")
+	if allowed, _, _ := store.Hit("account:ana", 1, time.Hour); !allowed {
+		t.Fatalf("tentativa deveria passar após o reset")
+	}
+}
+
+func TestMemoryStoreBlockedDoesNotRecord(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if blocked, _, _ := store.Blocked("s3:1", 2, time.Minute); blocked {
+			t.Fatalf("consultar não deveria contar como tentativa")
+		}
+	}
+
+	store.Hit("s3:1", 2, time.Minute)
+	now = now.Add(20 * time.Second)
+	store.Hit("s3:1", 2, time.Minute)
+
+	blocked, retryAfter, _ := store.Blocked("s3:1", 2, time.Minute)
+	if !blocked || retryAfter != 40*time.Second {
+		t.Fatalf("esperava bloqueio de 40s, veio %v %s", blocked, retryAfter)
+	}
+}
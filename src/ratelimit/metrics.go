@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Counter is a Prometheus-style counter with labels, rendered in the text
+// exposition format by WriteMetrics.
+type Counter struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	values map[string]uint64
+}
+
+var (
+	RequestsTotal = newCounter("cfm_rate_limit_requests_total", "Requests checked by a rate limiter.")
+	HitsTotal     = newCounter("cfm_rate_limit_hits_total", "Requests refused by a rate limiter.")
+)
+
+func newCounter(name string, help string) *Counter {
+	return &Counter{name: name, help: help, values: map[string]uint64{}}
+}
+
+// Inc takes label names and values in pairs.
+func (c *Counter) Inc(labels ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[formatLabels(labels)]++
+}
+
+func (c *Counter) Value(labels ...string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[formatLabels(labels)]
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+		return err
+	}
+
+	series := make([]string, 0, len(c.values))
+	for labels := range c.values {
+		series = append(series, labels)
+	}
+	sort.Strings(series)
+
+	for _, labels := range series {
+		if _, err := fmt.Fprintf(w, "%s%s %d\n", c.name, labels, c.values[labels]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteMetrics renders every rate limit counter.
+func WriteMetrics(w io.Writer) error {
+	for _, counter := range []*Counter{RequestsTotal, HitsTotal} {
+		if err := counter.write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan is a request quota: a burst of Burst tokens refilled at PerMinute
+// tokens per minute. Each route costs one or more tokens.
+type Plan struct {
+	Name      string
+	Burst     int
+	PerMinute int
+}
+
+func (p Plan) RefillPerSecond() float64 {
+	return float64(p.PerMinute) / time.Minute.Seconds()
+}
+
+// Plans holds the configured plans and the one used for users without an
+// explicit plan or with an unknown one.
+type Plans struct {
+	byName      map[string]Plan
+	defaultPlan Plan
+}
+
+var DefaultPlan = Plan{Name: "free", Burst: 60, PerMinute: 60}
+
+// ParsePlans reads a comma separated list of name:burst:perMinute entries,
+// e.g. "free:60:60,pro:600:600".
+func ParsePlans(spec string, defaultName string) (Plans, error) {
+	plans := Plans{byName: map[string]Plan{}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 3 {
+			return Plans{}, fmt.Errorf("plano inválido: %q", entry)
+		}
+
+		burst, err := strconv.Atoi(fields[1])
+		if err != nil || burst <= 0 {
+			return Plans{}, fmt.Errorf("plano inválido: %q", entry)
+		}
+
+		perMinute, err := strconv.Atoi(fields[2])
+		if err != nil || perMinute <= 0 {
+			return Plans{}, fmt.Errorf("plano inválido: %q", entry)
+		}
+
+		plan := Plan{Name: fields[0], Burst: burst, PerMinute: perMinute}
+		plans.byName[plan.Name] = plan
+		if len(plans.byName) == 1 {
+			plans.defaultPlan = plan
+		}
+	}
+
+	if defaultName != "" {
+		plan, ok := plans.byName[defaultName]
+		if !ok {
+			return Plans{}, fmt.Errorf("plano padrão desconhecido: %q", defaultName)
+		}
+		plans.defaultPlan = plan
+	}
+
+	return plans, nil
+}
+
+// PlansFromEnv reads RATE_LIMIT_PLANS and RATE_LIMIT_DEFAULT_PLAN, falling
+// back to DefaultPlan.
+func PlansFromEnv() (Plans, error) {
+	spec := os.Getenv("RATE_LIMIT_PLANS")
+	if spec == "" {
+		return Plans{byName: map[string]Plan{DefaultPlan.Name: DefaultPlan}, defaultPlan: DefaultPlan}, nil
+	}
+
+	return ParsePlans(spec, os.Getenv("RATE_LIMIT_DEFAULT_PLAN"))
+}
+
+func (p Plans) Get(name string) Plan {
+	if plan, ok := p.byName[name]; ok {
+		return plan
+	}
+
+	return p.defaultPlan
+}
+
+// CheckCost fails when some plan's burst is smaller than cost, as its users
+// could never afford a route that expensive.
+func (p Plans) CheckCost(cost int) error {
+	for _, plan := range p.byName {
+		if plan.Burst < cost {
+			return fmt.Errorf("o plano %q permite rajadas de %d, menos que o custo %d de uma rota", plan.Name, plan.Burst, cost)
+		}
+	}
+
+	return nil
+}
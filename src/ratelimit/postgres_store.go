@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps attempts in the rate_limit_hits table so every API
+// instance shares the same windows. Each key is serialized with a
+// transaction-scoped advisory lock.
+type PostgresStore struct {
+	connection *sql.DB
+}
+
+func NewPostgresStore(connection *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		connection: connection,
+	}
+}
+
+func (ps *PostgresStore) Hit(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	tx, err := ps.connection.Begin()
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", key)
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM rate_limit_hits WHERE limiter_key = $1 AND hit_at <= NOW() - $2 * INTERVAL '1 second'",
+		key, window.Seconds(),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	var count int
+	var retryAfterSeconds float64
+	err = tx.QueryRow(
+		"SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(hit_at) + $2 * INTERVAL '1 second' - NOW()), 0) "+
+			"FROM rate_limit_hits WHERE limiter_key = $1",
+		key, window.Seconds(),
+	).Scan(&count, &retryAfterSeconds)
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	if count >= limit {
+		return false, time.Duration(retryAfterSeconds * float64(time.Second)), nil
+	}
+
+	_, err = tx.Exec("INSERT INTO rate_limit_hits (limiter_key) VALUES ($1)", key)
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	return true, 0, nil
+}
+
+func (ps *PostgresStore) Blocked(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	var count int
+	var retryAfterSeconds float64
+	err := ps.connection.QueryRow(
+		"SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MIN(hit_at) + $2 * INTERVAL '1 second' - NOW()), 0) "+
+			"FROM rate_limit_hits WHERE limiter_key = $1 AND hit_at > NOW() - $2 * INTERVAL '1 second'",
+		key, window.Seconds(),
+	).Scan(&count, &retryAfterSeconds)
+	if err != nil {
+		fmt.Println(err)
+		return false, 0, err
+	}
+
+	if count >= limit {
+		return true, time.Duration(retryAfterSeconds * float64(time.Second)), nil
+	}
+
+	return false, 0, nil
+}
+
+func (ps *PostgresStore) Reset(key string) error {
+	_, err := ps.connection.Exec("DELETE FROM rate_limit_hits WHERE limiter_key = $1", key)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
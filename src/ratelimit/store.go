@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"os"
+	"time"
+)
+
+// Store keeps sliding-window attempt logs. Implementations must be safe for
+// concurrent use; the Postgres one is shared by every instance of the API.
+type Store interface {
+	// Hit records an attempt under key unless limit attempts already happened
+	// in the trailing window. When refused it returns how long until the
+	// oldest attempt leaves the window.
+	Hit(key string, limit int, window time.Duration) (bool, time.Duration, error)
+	// Blocked tells, without recording an attempt, whether Hit would refuse
+	// key now, and for how long.
+	Blocked(key string, limit int, window time.Duration) (bool, time.Duration, error)
+	Reset(key string) error
+}
+
+// Rule is a limit of attempts per sliding window.
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Allow is a shortcut for Hit with the rule's limit and window.
+func (r Rule) Allow(store Store, key string) (bool, time.Duration, error) {
+	return store.Hit(key, r.Limit, r.Window)
+}
+
+// Blocked is a shortcut for Store.Blocked with the rule's limit and window.
+func (r Rule) Blocked(store Store, key string) (bool, time.Duration, error) {
+	return store.Blocked(key, r.Limit, r.Window)
+}
+
+// NewStoreFromEnv returns the Postgres store when RATE_LIMIT_STORE=postgres
+// and the in-memory one otherwise.
+func NewStoreFromEnv(connection *sql.DB) Store {
+	if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+		return NewPostgresStore(connection)
+	}
+
+	return NewMemoryStore()
+}
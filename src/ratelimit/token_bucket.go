@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Decision is the outcome of taking tokens from a bucket, with the values
+// reported in the RateLimit-* headers.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// TokenBucketLimiter keeps one in-memory bucket per key. Buckets refill
+// continuously at the plan's rate up to its burst size.
+type TokenBucketLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	calls   int
+}
+
+func NewTokenBucketLimiter() *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (tl *TokenBucketLimiter) Take(key string, plan Plan, cost int) Decision {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	now := tl.now()
+	burst := float64(plan.Burst)
+	rate := plan.RefillPerSecond()
+
+	current, ok := tl.buckets[key]
+	if !ok {
+		current = &bucket{tokens: burst, updated: now}
+		tl.buckets[key] = current
+	}
+
+	current.tokens = math.Min(burst, current.tokens+now.Sub(current.updated).Seconds()*rate)
+	current.updated = now
+
+	decision := Decision{Limit: plan.Burst}
+	if current.tokens >= float64(cost) {
+		current.tokens -= float64(cost)
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = secondsToDuration((float64(cost) - current.tokens) / rate)
+	}
+
+	decision.Remaining = int(math.Floor(current.tokens))
+	decision.Reset = secondsToDuration((burst - current.tokens) / rate)
+
+	tl.calls++
+	if tl.calls%1000 == 0 {
+		tl.sweep(now)
+	}
+
+	return decision
+}
+
+// sweep drops buckets idle long enough to be full again, which is the same
+// as not having one.
+func (tl *TokenBucketLimiter) sweep(now time.Time) {
+	for key, current := range tl.buckets {
+		if now.Sub(current.updated) > time.Hour {
+			delete(tl.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
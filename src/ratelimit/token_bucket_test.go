@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefillsAtPlanRate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewTokenBucketLimiter()
+	limiter.now = func() time.Time { return now }
+
+	plan := Plan{Name: "free", Burst: 10, PerMinute: 60}
+
+	decision := limiter.Take("user:1", plan, 8)
+	if !decision.Allowed || decision.Remaining != 2 || decision.Limit != 10 {
+		t.Fatalf("decisão inesperada %#v", decision)
+	}
+	if decision.Reset != 8*time.Second {
+		t.Fatalf("esperava reset em 8s, veio %s", decision.Reset)
+	}
+
+	decision = limiter.Take("user:1", plan, 5)
+	if decision.Allowed {
+		t.Fatalf("não deveria haver fichas suficientes")
+	}
+	if decision.RetryAfter != 3*time.Second {
+		t.Fatalf("esperava 3s de espera, veio %s", decision.RetryAfter)
+	}
+
+	now = now.Add(3 * time.Second)
+	if decision := limiter.Take("user:1", plan, 5); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("decisão inesperada após recarga %#v", decision)
+	}
+}
+
+func TestParsePlans(t *testing.T) {
+	plans, err := ParsePlans("free:60:60, pro:600:1200", "pro")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if plan := plans.Get("free"); plan.Burst != 60 {
+		t.Fatalf("plano inesperado %#v", plan)
+	}
+	if plan := plans.Get("desconhecido"); plan.Name != "pro" {
+		t.Fatalf("esperava plano padrão pro, veio %#v", plan)
+	}
+
+	if _, err := ParsePlans("free:60", ""); err == nil {
+		t.Fatalf("esperava erro para plano incompleto")
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	HitsTotal.Inc("limiter", "api", "plan", "free", "route", `GET /aws/"bucket"`)
+
+	var out strings.Builder
+	if err := WriteMetrics(&out); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	expected := `cfm_rate_limit_hits_total{limiter="api",plan="free",route="GET /aws/\"bucket\""} 1`
+	if !strings.Contains(out.String(), expected) {
+		t.Fatalf("métrica não encontrada em:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "# TYPE cfm_rate_limit_requests_total counter") {
+		t.Fatalf("cabeçalho TYPE ausente em:\n%s", out.String())
+	}
+}
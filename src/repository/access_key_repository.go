@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AccessKeyRepository struct {
+	connection *sql.DB
+}
+
+func NewAccessKeyRepository(connection *sql.DB) *AccessKeyRepository {
+	return &AccessKeyRepository{
+		connection: connection,
+	}
+}
+
+func (ar *AccessKeyRepository) CreateAccessKey(accessKey models.AccessKey, secretKey string) (int, time.Time, error) {
+	var id int
+	var createdAt time.Time
+	err := ar.connection.QueryRow(
+		"INSERT INTO s3_access_keys (user_id, key_name, access_key_id, secret_key, scopes)"+
+			" VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		accessKey.UserID, accessKey.Name, accessKey.AccessKeyID, secretKey, pq.Array(accessKey.Scopes),
+	).Scan(&id, &createdAt)
+	if err != nil {
+		fmt.Println(err)
+		return 0, time.Time{}, err
+	}
+
+	return id, createdAt, nil
+}
+
+func (ar *AccessKeyRepository) GetAccessKeysByUser(userId int) ([]models.AccessKey, error) {
+	rows, err := ar.connection.Query(
+		"SELECT id, user_id, key_name, access_key_id, scopes, last_used_at, created_at FROM s3_access_keys"+
+			" WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return []models.AccessKey{}, err
+	}
+	defer rows.Close()
+
+	accessKeys := []models.AccessKey{}
+	for rows.Next() {
+		var accessKey models.AccessKey
+		err = rows.Scan(
+			&accessKey.ID,
+			&accessKey.UserID,
+			&accessKey.Name,
+			&accessKey.AccessKeyID,
+			pq.Array(&accessKey.Scopes),
+			&accessKey.LastUsedAt,
+			&accessKey.CreatedAt,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return []models.AccessKey{}, err
+		}
+
+		accessKeys = append(accessKeys, accessKey)
+	}
+
+	return accessKeys, rows.Err()
+}
+
+func (ar *AccessKeyRepository) RevokeAccessKey(userId int, accessKeyId int) (bool, error) {
+	result, err := ar.connection.Exec(
+		"UPDATE s3_access_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		accessKeyId, userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// UseAccessKey finds an active key by its id, records its use and returns
+// it with its secret. It returns nil when the key is unknown or revoked.
+func (ar *AccessKeyRepository) UseAccessKey(accessKeyId string) (*models.AccessKey, string, error) {
+	var accessKey models.AccessKey
+	var secretKey string
+	err := ar.connection.QueryRow(
+		"UPDATE s3_access_keys SET last_used_at = NOW()"+
+			" WHERE access_key_id = $1 AND revoked_at IS NULL"+
+			" RETURNING id, user_id, key_name, access_key_id, secret_key, scopes, last_used_at, created_at",
+		accessKeyId,
+	).Scan(
+		&accessKey.ID,
+		&accessKey.UserID,
+		&accessKey.Name,
+		&accessKey.AccessKeyID,
+		&secretKey,
+		pq.Array(&accessKey.Scopes),
+		&accessKey.LastUsedAt,
+		&accessKey.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		}
+
+		fmt.Println(err)
+		return nil, "", err
+	}
+
+	return &accessKey, secretKey, nil
+}
+
+func (ar *AccessKeyRepository) RevokeAllAccessKeys(userId int) error {
+	_, err := ar.connection.Exec(
+		"UPDATE s3_access_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+)
+
+// AccountDeletionRepository tracks the background jobs that tear down
+// deleted accounts.
+type AccountDeletionRepository struct {
+	connection *sql.DB
+}
+
+func NewAccountDeletionRepository(connection *sql.DB) *AccountDeletionRepository {
+	return &AccountDeletionRepository{
+		connection: connection,
+	}
+}
+
+func (ar *AccountDeletionRepository) CreateAccountDeletion(userId int) (*models.AccountDeletion, error) {
+	deletion := models.AccountDeletion{UserID: userId, Status: models.AccountDeletionPending}
+
+	err := ar.connection.QueryRow(
+		"INSERT INTO account_deletions (user_id, status) VALUES ($1, $2) RETURNING id, created_at",
+		userId, models.AccountDeletionPending,
+	).Scan(&deletion.ID, &deletion.CreatedAt)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+func (ar *AccountDeletionRepository) GetAccountDeletion(id int) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	var deletionError sql.NullString
+
+	err := ar.connection.QueryRow(
+		"SELECT id, user_id, status, error, created_at, finished_at FROM account_deletions WHERE id = $1",
+		id,
+	).Scan(
+		&deletion.ID,
+		&deletion.UserID,
+		&deletion.Status,
+		&deletionError,
+		&deletion.CreatedAt,
+		&deletion.FinishedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	deletion.Error = deletionError.String
+	return &deletion, nil
+}
+
+// FinishAccountDeletion records the outcome; an empty message means success.
+func (ar *AccountDeletionRepository) FinishAccountDeletion(id int, message string) error {
+	status := models.AccountDeletionDone
+	if message != "" {
+		status = models.AccountDeletionFailed
+	}
+
+	_, err := ar.connection.Exec(
+		"UPDATE account_deletions SET status = $2, error = NULLIF($3, ''), finished_at = NOW() WHERE id = $1",
+		id, status, message,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
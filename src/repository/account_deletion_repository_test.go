@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAccountDeletionRepositoryFinishWithError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewAccountDeletionRepository(db)
+
+	mock.ExpectExec("UPDATE account_deletions SET status = \\$2, error = NULLIF\\(\\$3, ''\\), finished_at = NOW\\(\\) WHERE id = \\$1").
+		WithArgs(4, "failed", "access denied").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.FinishAccountDeletion(4, "access denied"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ApiKeyRepository struct {
+	connection *sql.DB
+}
+
+func NewApiKeyRepository(connection *sql.DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		connection: connection,
+	}
+}
+
+func (ar *ApiKeyRepository) CreateApiKey(apiKey models.ApiKey, keyHash string) (int, time.Time, error) {
+	var id int
+	var createdAt time.Time
+	err := ar.connection.QueryRow(
+		"INSERT INTO api_keys (user_id, key_name, key_prefix, key_hash, scopes, expires_at)"+
+			" VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		apiKey.UserID, apiKey.Name, apiKey.Prefix, keyHash, pq.Array(apiKey.Scopes), apiKey.ExpiresAt,
+	).Scan(&id, &createdAt)
+	if err != nil {
+		fmt.Println(err)
+		return 0, time.Time{}, err
+	}
+
+	return id, createdAt, nil
+}
+
+func (ar *ApiKeyRepository) GetApiKeysByUser(userId int) ([]models.ApiKey, error) {
+	rows, err := ar.connection.Query(
+		"SELECT id, user_id, key_name, key_prefix, scopes, expires_at, last_used_at, created_at FROM api_keys"+
+			" WHERE user_id = $1 AND revoked_at IS NULL ORDER BY created_at DESC",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return []models.ApiKey{}, err
+	}
+	defer rows.Close()
+
+	apiKeys := []models.ApiKey{}
+	for rows.Next() {
+		var apiKey models.ApiKey
+		err = rows.Scan(
+			&apiKey.ID,
+			&apiKey.UserID,
+			&apiKey.Name,
+			&apiKey.Prefix,
+			pq.Array(&apiKey.Scopes),
+			&apiKey.ExpiresAt,
+			&apiKey.LastUsedAt,
+			&apiKey.CreatedAt,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return []models.ApiKey{}, err
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+func (ar *ApiKeyRepository) RevokeApiKey(userId int, apiKeyId int) (bool, error) {
+	result, err := ar.connection.Exec(
+		"UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		apiKeyId, userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ValidateApiKey finds an active key by hash and records its use. It
+// returns nil when the key is unknown, revoked or expired.
+func (ar *ApiKeyRepository) ValidateApiKey(keyHash string) (*models.ApiKey, error) {
+	var apiKey models.ApiKey
+	err := ar.connection.QueryRow(
+		"UPDATE api_keys SET last_used_at = NOW()"+
+			" WHERE key_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()"+
+			" RETURNING id, user_id, key_name, key_prefix, scopes, expires_at, last_used_at, created_at",
+		keyHash,
+	).Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.Prefix,
+		pq.Array(&apiKey.Scopes),
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (ar *ApiKeyRepository) RevokeAllApiKeys(userId int) error {
+	_, err := ar.connection.Exec(
+		"UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
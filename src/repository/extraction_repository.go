@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+)
+
+// ExtractionRepository tracks the archives the background jobs extract,
+// and how far along they are.
+type ExtractionRepository struct {
+	connection *sql.DB
+}
+
+func NewExtractionRepository(connection *sql.DB) *ExtractionRepository {
+	return &ExtractionRepository{
+		connection: connection,
+	}
+}
+
+func (er *ExtractionRepository) CreateExtraction(userId int, archiveKey string, targetPrefix string) (*models.Extraction, error) {
+	extraction := models.Extraction{
+		UserID:       userId,
+		ArchiveKey:   archiveKey,
+		TargetPrefix: targetPrefix,
+		Status:       models.ExtractionPending,
+	}
+
+	err := er.connection.QueryRow(
+		"INSERT INTO extractions (user_id, archive_key, target_prefix, status) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at",
+		userId, archiveKey, targetPrefix, models.ExtractionPending,
+	).Scan(&extraction.ID, &extraction.CreatedAt, &extraction.UpdatedAt)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &extraction, nil
+}
+
+func (er *ExtractionRepository) GetExtraction(id int) (*models.Extraction, error) {
+	var extraction models.Extraction
+	var filesTotal sql.NullInt64
+	var bytesTotal sql.NullInt64
+	var extractionError sql.NullString
+
+	err := er.connection.QueryRow(
+		`SELECT id, user_id, archive_key, target_prefix, status, files_done, files_total, bytes_done, bytes_total, skipped, error, created_at, updated_at, finished_at
+		FROM extractions WHERE id = $1`,
+		id,
+	).Scan(
+		&extraction.ID,
+		&extraction.UserID,
+		&extraction.ArchiveKey,
+		&extraction.TargetPrefix,
+		&extraction.Status,
+		&extraction.FilesDone,
+		&filesTotal,
+		&extraction.BytesDone,
+		&bytesTotal,
+		&extraction.Skipped,
+		&extractionError,
+		&extraction.CreatedAt,
+		&extraction.UpdatedAt,
+		&extraction.FinishedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if filesTotal.Valid {
+		total := int(filesTotal.Int64)
+		extraction.FilesTotal = &total
+	}
+	if bytesTotal.Valid {
+		extraction.BytesTotal = &bytesTotal.Int64
+	}
+	extraction.Error = extractionError.String
+	return &extraction, nil
+}
+
+// UpdateExtractionProgress stores the status and counters of a running
+// extraction.
+func (er *ExtractionRepository) UpdateExtractionProgress(extraction models.Extraction) error {
+	_, err := er.connection.Exec(
+		`UPDATE extractions SET status = $2, files_done = $3, files_total = $4, bytes_done = $5, bytes_total = $6, skipped = $7, updated_at = NOW()
+		WHERE id = $1`,
+		extraction.ID, extraction.Status, extraction.FilesDone, extraction.FilesTotal,
+		extraction.BytesDone, extraction.BytesTotal, extraction.Skipped,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// FinishExtraction records the outcome with the final counters; an empty
+// message means success.
+func (er *ExtractionRepository) FinishExtraction(extraction models.Extraction, message string) error {
+	status := models.ExtractionDone
+	if message != "" {
+		status = models.ExtractionFailed
+	}
+
+	_, err := er.connection.Exec(
+		`UPDATE extractions SET status = $2, files_done = $3, bytes_done = $4, skipped = $5, error = NULLIF($6, ''), updated_at = NOW(), finished_at = NOW()
+		WHERE id = $1`,
+		extraction.ID, status, extraction.FilesDone, extraction.BytesDone, extraction.Skipped, message,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestExtractionRepositoryUpdateProgressWithoutTotals(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewExtractionRepository(db)
+
+	mock.ExpectExec("UPDATE extractions SET status = \\$2, files_done = \\$3, files_total = \\$4, bytes_done = \\$5, bytes_total = \\$6, skipped = \\$7, updated_at = NOW\\(\\)").
+		WithArgs(3, models.ExtractionRunning, 12, nil, int64(4096), nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	extraction := models.Extraction{ID: 3, Status: models.ExtractionRunning, FilesDone: 12, BytesDone: 4096, Skipped: 1}
+	if err := repo.UpdateExtractionProgress(extraction); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// IdentityRepository links users to accounts at external identity providers.
+type IdentityRepository struct {
+	connection *sql.DB
+}
+
+func NewIdentityRepository(connection *sql.DB) *IdentityRepository {
+	return &IdentityRepository{
+		connection: connection,
+	}
+}
+
+// GetUserIdByIdentity returns 0 when the provider account is not linked yet.
+func (ir *IdentityRepository) GetUserIdByIdentity(issuer string, subject string) (int, error) {
+	var userId int
+	err := ir.connection.QueryRow(
+		"SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2",
+		issuer, subject,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (ir *IdentityRepository) LinkIdentity(userId int, issuer string, subject string) error {
+	_, err := ir.connection.Exec(
+		"INSERT INTO user_identities (user_id, issuer, subject) VALUES ($1, $2, $3)"+
+			" ON CONFLICT (issuer, subject) DO NOTHING",
+		userId, issuer, subject,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LoginLockoutRepository tracks consecutive failed logins per email. Keying
+// by email instead of user id locks unknown addresses the same way, so the
+// lockout does not reveal which emails are registered.
+type LoginLockoutRepository struct {
+	connection *sql.DB
+}
+
+func NewLoginLockoutRepository(connection *sql.DB) *LoginLockoutRepository {
+	return &LoginLockoutRepository{
+		connection: connection,
+	}
+}
+
+// GetLockedUntil returns nil when the account is not currently locked.
+func (lr *LoginLockoutRepository) GetLockedUntil(email string) (*time.Time, error) {
+	var lockedUntil time.Time
+
+	err := lr.connection.QueryRow(
+		"SELECT locked_until FROM login_lockouts WHERE email = $1 AND locked_until > NOW()",
+		email,
+	).Scan(&lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &lockedUntil, nil
+}
+
+// RecordLoginFailure returns the number of consecutive failures. A streak
+// idle for a day starts over.
+func (lr *LoginLockoutRepository) RecordLoginFailure(email string) (int, error) {
+	var failures int
+
+	err := lr.connection.QueryRow(
+		"INSERT INTO login_lockouts (email, failed_attempts, updated_at) VALUES ($1, 1, NOW())"+
+			" ON CONFLICT (email) DO UPDATE SET"+
+			" failed_attempts = CASE WHEN login_lockouts.updated_at < NOW() - INTERVAL '24 hours'"+
+			" THEN 1 ELSE login_lockouts.failed_attempts + 1 END,"+
+			" updated_at = NOW()"+
+			" RETURNING failed_attempts",
+		email,
+	).Scan(&failures)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (lr *LoginLockoutRepository) LockAccount(email string, until time.Time) error {
+	_, err := lr.connection.Exec(
+		"UPDATE login_lockouts SET locked_until = $2 WHERE email = $1",
+		email, until,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// ResetLoginFailures clears the streak and any lock, after a successful
+// login or when an admin unlocks the account.
+func (lr *LoginLockoutRepository) ResetLoginFailures(email string) error {
+	_, err := lr.connection.Exec("DELETE FROM login_lockouts WHERE email = $1", email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLoginLockoutRepositoryRecordLoginFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewLoginLockoutRepository(db)
+
+	mock.ExpectQuery("INSERT INTO login_lockouts \\(email, failed_attempts, updated_at\\) VALUES \\(\\$1, 1, NOW\\(\\)\\) ON CONFLICT \\(email\\) DO UPDATE").
+		WithArgs("ana@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"failed_attempts"}).AddRow(3))
+
+	failures, err := repo.RecordLoginFailure("ana@example.com")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if failures != 3 {
+		t.Fatalf("esperava 3 falhas, veio %d", failures)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestLoginLockoutRepositoryGetLockedUntilNotLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewLoginLockoutRepository(db)
+
+	mock.ExpectQuery("SELECT locked_until FROM login_lockouts WHERE email = \\$1 AND locked_until > NOW\\(\\)").
+		WithArgs("ana@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}))
+
+	lockedUntil, err := repo.GetLockedUntil("ana@example.com")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if lockedUntil != nil {
+		t.Fatalf("não esperava bloqueio, veio %v", lockedUntil)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestLoginLockoutRepositoryLockAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewLoginLockoutRepository(db)
+
+	until := time.Now().Add(time.Minute)
+	mock.ExpectExec("UPDATE login_lockouts SET locked_until = \\$2 WHERE email = \\$1").
+		WithArgs("ana@example.com", until).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.LockAccount("ana@example.com", until); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
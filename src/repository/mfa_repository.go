@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/secretbox"
+	"database/sql"
+	"fmt"
+)
+
+// MfaRepository keeps TOTP secrets sealed with the server key.
+type MfaRepository struct {
+	connection *sql.DB
+	box        *secretbox.Box
+}
+
+func NewMfaRepository(connection *sql.DB, box *secretbox.Box) *MfaRepository {
+	return &MfaRepository{
+		connection: connection,
+		box:        box,
+	}
+}
+
+func (mr *MfaRepository) GetMfa(userId int) (*models.UserMfa, error) {
+	var mfa models.UserMfa
+	var lastUsedStep sql.NullInt64
+
+	err := mr.connection.QueryRow(
+		"SELECT user_id, secret, enabled_at, last_used_step FROM user_mfa WHERE user_id = $1",
+		userId,
+	).Scan(
+		&mfa.UserID,
+		&mfa.Secret,
+		&mfa.EnabledAt,
+		&lastUsedStep,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	mfa.LastUsedStep = lastUsedStep.Int64
+
+	if !secretbox.IsSealed(mfa.Secret) {
+		mr.sealLegacySecret(userId, mfa.Secret)
+		return &mfa, nil
+	}
+
+	mfa.Secret, err = mr.box.Open(mfa.Secret)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+// sealLegacySecret encrypts a secret stored in plaintext before secrets
+// were sealed. Failing leaves it as it was, to be sealed on the next read.
+func (mr *MfaRepository) sealLegacySecret(userId int, secret string) {
+	sealed, err := mr.box.Seal(secret)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	_, err = mr.connection.Exec(
+		"UPDATE user_mfa SET secret = $2 WHERE user_id = $1 AND secret = $3",
+		userId, sealed, secret,
+	)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// SaveMfaSecret starts (or restarts) an enrollment. Enabled factors are left
+// untouched, so an attacker with a stolen session cannot swap the secret.
+func (mr *MfaRepository) SaveMfaSecret(userId int, secret string) (bool, error) {
+	sealed, err := mr.box.Seal(secret)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := mr.connection.Exec(
+		"INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)"+
+			" ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = NULL"+
+			" WHERE user_mfa.enabled_at IS NULL",
+		userId, sealed,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// EnableMfa turns the factor on and replaces the recovery codes.
+func (mr *MfaRepository) EnableMfa(userId int, step int64, recoveryCodeHashes []string) error {
+	tx, err := mr.connection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE user_mfa SET enabled_at = NOW(), last_used_step = $2 WHERE user_id = $1",
+		userId, step,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.Exec(
+			"INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userId, hash,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MarkStepUsed records the TOTP step of an accepted code and fails when that
+// step (or a later one) was already used, blocking replays.
+func (mr *MfaRepository) MarkStepUsed(userId int, step int64) (bool, error) {
+	result, err := mr.connection.Exec(
+		"UPDATE user_mfa SET last_used_step = $2"+
+			" WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)",
+		userId, step,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (mr *MfaRepository) ConsumeRecoveryCode(userId int, codeHash string) (bool, error) {
+	result, err := mr.connection.Exec(
+		"UPDATE mfa_recovery_codes SET used_at = NOW()"+
+			" WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL",
+		userId, codeHash,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func isMfaEnabled(connection *sql.DB, userId int) (bool, error) {
+	var enabled bool
+	err := connection.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM user_mfa WHERE user_id = $1 AND enabled_at IS NOT NULL)",
+		userId,
+	).Scan(&enabled)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return enabled, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"bytes"
+	"cloud_file_manager/src/secretbox"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// sealedArg matches a value sealed by secretbox, remembering it.
+type sealedArg struct {
+	value string
+}
+
+func (s *sealedArg) Match(v driver.Value) bool {
+	value, ok := v.(string)
+	s.value = value
+	return ok && secretbox.IsSealed(value)
+}
+
+func newTestBox(t *testing.T) *secretbox.Box {
+	box, err := secretbox.New(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	return box
+}
+
+func TestMfaRepositoryStoresSecretsSealed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewMfaRepository(db, newTestBox(t))
+
+	stored := &sealedArg{}
+	mock.ExpectExec("INSERT INTO user_mfa \\(user_id, secret\\)").
+		WithArgs(3, stored).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := repo.SaveMfaSecret(3, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step FROM user_mfa WHERE user_id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_used_step"}).AddRow(3, stored.value, nil, nil))
+
+	mfa, err := repo.GetMfa(3)
+	if err != nil || mfa.Secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("esperava o segredo aberto, veio %#v, %v", mfa, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestMfaRepositorySealsPlaintextSecretsOnRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewMfaRepository(db, newTestBox(t))
+
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step FROM user_mfa WHERE user_id = \\$1").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_used_step"}).AddRow(3, "JBSWY3DPEHPK3PXP", nil, nil))
+	mock.ExpectExec("UPDATE user_mfa SET secret = \\$2 WHERE user_id = \\$1 AND secret = \\$3").
+		WithArgs(3, &sealedArg{}, "JBSWY3DPEHPK3PXP").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mfa, err := repo.GetMfa(3)
+	if err != nil || mfa.Secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("esperava o segredo, veio %#v, %v", mfa, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type SessionRepository struct {
+	connection *sql.DB
+}
+
+func NewSessionRepository(connection *sql.DB) *SessionRepository {
+	return &SessionRepository{
+		connection: connection,
+	}
+}
+
+func (sr *SessionRepository) GetSessionsByUser(userId int) ([]models.Session, error) {
+	query := "SELECT id, user_id, ip_address, user_agent, created_at, last_seen_at FROM sessions" +
+		" WHERE user_id = $1 AND revoked_at IS NULL ORDER BY last_seen_at DESC"
+	rows, err := sr.connection.Query(query, userId)
+	if err != nil {
+		fmt.Println(err)
+		return []models.Session{}, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		err = rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.IP,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastSeen,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return []models.Session{}, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (sr *SessionRepository) RevokeSession(userId int, sessionId int) (bool, error) {
+	result, err := sr.connection.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionId, userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// TouchSession updates last_seen_at and reports whether the session is still active.
+func (sr *SessionRepository) TouchSession(sessionId int) (bool, error) {
+	result, err := sr.connection.Exec(
+		"UPDATE sessions SET last_seen_at = NOW() WHERE id = $1 AND revoked_at IS NULL",
+		sessionId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func insertSession(connection *sql.DB, userId int, ip string, userAgent string) (int, error) {
+	var id int
+	err := connection.QueryRow(
+		"INSERT INTO sessions (user_id, ip_address, user_agent) VALUES ($1, $2, $3) RETURNING id",
+		userId, ip, userAgent,
+	).Scan(&id)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (sr *SessionRepository) RevokeAllSessions(userId int) error {
+	_, err := sr.connection.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (sr *SessionRepository) CreateSession(userId int, ip string, userAgent string) (int, error) {
+	return insertSession(sr.connection, userId, ip, userAgent)
+}
+
+// RevokeOtherSessions ends every session of the user except the current one.
+func (sr *SessionRepository) RevokeOtherSessions(userId int, currentSessionId int) error {
+	_, err := sr.connection.Exec(
+		"UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL",
+		userId, currentSessionId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// SetRefreshToken attaches a refresh token to an open session, replacing the
+// previous one. The scopes are those of the access tokens it will mint.
+func (sr *SessionRepository) SetRefreshToken(sessionId int, tokenHash string, scopes []string, expiresAt time.Time) (bool, error) {
+	result, err := sr.connection.Exec(
+		"UPDATE sessions SET refresh_token_hash = $2, refresh_expires_at = $3, scopes = $4"+
+			" WHERE id = $1 AND revoked_at IS NULL",
+		sessionId, tokenHash, expiresAt, pq.Array(scopes),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// RotateRefreshToken swaps a valid refresh token for a new one in a single
+// statement, so a token can only ever be used once. It returns nil when the
+// token is unknown, expired or its session was revoked.
+func (sr *SessionRepository) RotateRefreshToken(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	var session models.Session
+
+	err := sr.connection.QueryRow(
+		"UPDATE sessions SET refresh_token_hash = $2, refresh_expires_at = $3, last_seen_at = NOW()"+
+			" WHERE refresh_token_hash = $1 AND refresh_expires_at > NOW() AND revoked_at IS NULL"+
+			" RETURNING id, user_id, scopes",
+		tokenHash, newTokenHash, expiresAt,
+	).Scan(&session.ID, &session.UserID, pq.Array(&session.Scopes))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &session, nil
+}
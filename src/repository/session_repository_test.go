@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSessionRepositoryGetSessionsByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT id, user_id, ip_address, user_agent, created_at, last_seen_at FROM sessions WHERE user_id = \\$1 AND revoked_at IS NULL").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "ip_address", "user_agent", "created_at", "last_seen_at"}).
+			AddRow(1, 3, "10.0.0.1", "Firefox", now, now).
+			AddRow(2, 3, "10.0.0.2", "curl/8.0", now, now))
+
+	sessions, err := repo.GetSessionsByUser(3)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(sessions) != 2 || sessions[1].UserAgent != "curl/8.0" {
+		t.Fatalf("sessões inesperadas: %#v", sessions)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestSessionRepositoryRevokeSessionNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	mock.ExpectExec("UPDATE sessions SET revoked_at = NOW\\(\\) WHERE id = \\$1 AND user_id = \\$2 AND revoked_at IS NULL").
+		WithArgs(9, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	revoked, err := repo.RevokeSession(3, 9)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if revoked {
+		t.Fatalf("não esperava revogar sessão de outro usuário")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestSessionRepositoryTouchSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	mock.ExpectExec("UPDATE sessions SET last_seen_at = NOW\\(\\) WHERE id = \\$1 AND revoked_at IS NULL").
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	active, err := repo.TouchSession(4)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !active {
+		t.Fatalf("esperava sessão ativa")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestSessionRepositoryRotateRefreshTokenUnknown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSessionRepository(db)
+
+	mock.ExpectQuery("UPDATE sessions SET refresh_token_hash = \\$2").
+		WithArgs("antigo", "novo", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scopes"}))
+
+	session, err := repo.RotateRefreshToken("antigo", "novo", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if session != nil {
+		t.Fatalf("não esperava sessão para token desconhecido")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
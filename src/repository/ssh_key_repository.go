@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SshKeyRepository struct {
+	connection *sql.DB
+}
+
+func NewSshKeyRepository(connection *sql.DB) *SshKeyRepository {
+	return &SshKeyRepository{
+		connection: connection,
+	}
+}
+
+// CreateSshKey stores the key unless the user already has it, in which
+// case it returns id 0.
+func (sr *SshKeyRepository) CreateSshKey(sshKey models.SshKey) (int, time.Time, error) {
+	var id int
+	var createdAt time.Time
+	err := sr.connection.QueryRow(
+		"INSERT INTO ssh_keys (user_id, key_name, public_key, fingerprint) VALUES ($1, $2, $3, $4)"+
+			" ON CONFLICT (user_id, fingerprint) DO NOTHING RETURNING id, created_at",
+		sshKey.UserID, sshKey.Name, sshKey.PublicKey, sshKey.Fingerprint,
+	).Scan(&id, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, time.Time{}, nil
+		}
+
+		fmt.Println(err)
+		return 0, time.Time{}, err
+	}
+
+	return id, createdAt, nil
+}
+
+func (sr *SshKeyRepository) GetSshKeysByUser(userId int) ([]models.SshKey, error) {
+	rows, err := sr.connection.Query(
+		"SELECT id, user_id, key_name, public_key, fingerprint, last_used_at, created_at FROM ssh_keys"+
+			" WHERE user_id = $1 ORDER BY created_at DESC",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return []models.SshKey{}, err
+	}
+	defer rows.Close()
+
+	sshKeys := []models.SshKey{}
+	for rows.Next() {
+		var sshKey models.SshKey
+		err = rows.Scan(
+			&sshKey.ID,
+			&sshKey.UserID,
+			&sshKey.Name,
+			&sshKey.PublicKey,
+			&sshKey.Fingerprint,
+			&sshKey.LastUsedAt,
+			&sshKey.CreatedAt,
+		)
+		if err != nil {
+			fmt.Println(err)
+			return []models.SshKey{}, err
+		}
+
+		sshKeys = append(sshKeys, sshKey)
+	}
+
+	return sshKeys, rows.Err()
+}
+
+func (sr *SshKeyRepository) DeleteSshKey(userId int, sshKeyId int) (bool, error) {
+	result, err := sr.connection.Exec("DELETE FROM ssh_keys WHERE id = $1 AND user_id = $2", sshKeyId, userId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteAllSshKeys removes every key of the user, ending their SFTP access.
+func (sr *SshKeyRepository) DeleteAllSshKeys(userId int) error {
+	_, err := sr.connection.Exec("DELETE FROM ssh_keys WHERE user_id = $1", userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// UseSshKey finds the user with the given email who owns the key, records
+// its use and returns the user id, or 0 when there is no such key. Deleted
+// accounts never match.
+func (sr *SshKeyRepository) UseSshKey(email string, fingerprint string) (int, error) {
+	var userId int
+	err := sr.connection.QueryRow(
+		"UPDATE ssh_keys k SET last_used_at = NOW() FROM users u"+
+			" WHERE u.id = k.user_id AND LOWER(u.user_email) = LOWER($1) AND u.deleted_at IS NULL AND k.fingerprint = $2"+
+			" RETURNING k.user_id",
+		email, fingerprint,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSshKeyRepositoryUseSshKeyIgnoresDeletedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSshKeyRepository(db)
+
+	mock.ExpectQuery("UPDATE ssh_keys k SET last_used_at = NOW\\(\\) FROM users u WHERE u.id = k.user_id AND LOWER\\(u.user_email\\) = LOWER\\(\\$1\\) AND u.deleted_at IS NULL AND k.fingerprint = \\$2 RETURNING k.user_id").
+		WithArgs("ana@example.com", "SHA256:abc").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	userId, err := repo.UseSshKey("ana@example.com", "SHA256:abc")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if userId != 0 {
+		t.Fatalf("não esperava encontrar usuário, veio %d", userId)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestSshKeyRepositoryDeleteAllSshKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSshKeyRepository(db)
+
+	mock.ExpectExec("DELETE FROM ssh_keys WHERE user_id = \\$1").
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := repo.DeleteAllSshKeys(7); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
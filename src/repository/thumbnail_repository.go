@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"cloud_file_manager/src/models"
+	"database/sql"
+	"fmt"
+)
+
+// ThumbnailRepository is the catalog of the thumbnails generated for the
+// image objects of each user.
+type ThumbnailRepository struct {
+	connection *sql.DB
+}
+
+func NewThumbnailRepository(connection *sql.DB) *ThumbnailRepository {
+	return &ThumbnailRepository{
+		connection: connection,
+	}
+}
+
+// SaveThumbnail records a thumbnail, replacing the one of an older
+// version of the object.
+func (tr *ThumbnailRepository) SaveThumbnail(thumbnail models.Thumbnail) error {
+	_, err := tr.connection.Exec(
+		`INSERT INTO thumbnails (user_id, object_key, size, source_etag, derivative_key, content_type, width, height, bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, object_key, size) DO UPDATE SET
+			source_etag = EXCLUDED.source_etag, derivative_key = EXCLUDED.derivative_key, content_type = EXCLUDED.content_type,
+			width = EXCLUDED.width, height = EXCLUDED.height, bytes = EXCLUDED.bytes, created_at = NOW()`,
+		thumbnail.UserID, thumbnail.ObjectKey, thumbnail.Size, thumbnail.SourceETag, thumbnail.DerivativeKey,
+		thumbnail.ContentType, thumbnail.Width, thumbnail.Height, thumbnail.Bytes,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (tr *ThumbnailRepository) GetThumbnail(userId int, objectKey string, size int) (*models.Thumbnail, error) {
+	var thumbnail models.Thumbnail
+
+	err := tr.connection.QueryRow(
+		`SELECT id, user_id, object_key, size, source_etag, derivative_key, content_type, width, height, bytes, created_at
+		FROM thumbnails WHERE user_id = $1 AND object_key = $2 AND size = $3`,
+		userId, objectKey, size,
+	).Scan(
+		&thumbnail.ID,
+		&thumbnail.UserID,
+		&thumbnail.ObjectKey,
+		&thumbnail.Size,
+		&thumbnail.SourceETag,
+		&thumbnail.DerivativeKey,
+		&thumbnail.ContentType,
+		&thumbnail.Width,
+		&thumbnail.Height,
+		&thumbnail.Bytes,
+		&thumbnail.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &thumbnail, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// UserBucketRepository records the buckets users create on their own, so
+// they can be found again without guessing from bucket names.
+type UserBucketRepository struct {
+	connection *sql.DB
+}
+
+func NewUserBucketRepository(connection *sql.DB) *UserBucketRepository {
+	return &UserBucketRepository{
+		connection: connection,
+	}
+}
+
+func (br *UserBucketRepository) AddUserBucket(userId int, bucketName string) error {
+	_, err := br.connection.Exec(
+		"INSERT INTO user_buckets (user_id, bucket_name) VALUES ($1, $2) ON CONFLICT (bucket_name) DO NOTHING",
+		userId, bucketName,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (br *UserBucketRepository) GetUserBuckets(userId int) ([]string, error) {
+	rows, err := br.connection.Query("SELECT bucket_name FROM user_buckets WHERE user_id = $1 ORDER BY id", userId)
+	if err != nil {
+		fmt.Println(err)
+		return []string{}, err
+	}
+	defer rows.Close()
+
+	buckets := []string{}
+	for rows.Next() {
+		var bucket string
+		err = rows.Scan(&bucket)
+		if err != nil {
+			fmt.Println(err)
+			return []string{}, err
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUserBucketRepositoryGetUserBuckets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserBucketRepository(db)
+
+	mock.ExpectQuery("SELECT bucket_name FROM user_buckets WHERE user_id = \\$1 ORDER BY id").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"bucket_name"}).AddRow("fotos-7").AddRow("base-7"))
+
+	buckets, err := repo.GetUserBuckets(7)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !reflect.DeepEqual(buckets, []string{"fotos-7", "base-7"}) {
+		t.Fatalf("buckets inesperados %v", buckets)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"cloud_file_manager/src/models"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,7 +23,7 @@ func NewUserRepository(connection *sql.DB) *UserRepository {
 
 func (pr *UserRepository) GetUsers() ([]models.User, error) {
 
-	query := "SELECT id, user_name, user_email, user_password FROM users"
+	query := "SELECT id, user_name, user_email FROM users WHERE deleted_at IS NULL"
 	rows, err := pr.connection.Query(query)
 	if err != nil {
 		fmt.Println(err)
@@ -37,7 +38,6 @@ func (pr *UserRepository) GetUsers() ([]models.User, error) {
 			&userObj.ID,
 			&userObj.Name,
 			&userObj.Email,
-			&userObj.Password,
 		)
 
 		if err != nil {
@@ -82,7 +82,7 @@ func (ur *UserRepository) GetUserById(id int) (*models.User, error) {
 
 	var user models.User
 
-	query, err := ur.connection.Prepare("SELECT * FROM users WHERE id = $1")
+	query, err := ur.connection.Prepare("SELECT id, user_name, user_email, user_password FROM users WHERE id = $1 AND deleted_at IS NULL")
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -109,7 +109,7 @@ func (ur *UserRepository) GetUserById(id int) (*models.User, error) {
 func (ur *UserRepository) Login(userDto dto.UserLoginDto) (*dto.UserResponseDto, error) {
 	var user models.User
 
-	query, err := ur.connection.Prepare("SELECT id, user_name, user_email, user_password FROM users WHERE user_email = $1")
+	query, err := ur.connection.Prepare("SELECT id, user_name, user_email, user_password FROM users WHERE user_email = $1 AND deleted_at IS NULL")
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -139,7 +139,32 @@ func (ur *UserRepository) Login(userDto dto.UserLoginDto) (*dto.UserResponseDto,
 	userResponse.Name = user.Name
 	userResponse.Email = user.Email
 
-	token, err := handlers.CreateToken(user.Name, user.Password, user.ID)
+	mfaEnabled, err := isMfaEnabled(ur.connection, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// With two-factor enabled the password alone only earns a short-lived
+	// token to be exchanged, together with a TOTP code, at /login/mfa.
+	if mfaEnabled {
+		mfaToken, err := handlers.CreateMfaToken(user.ID, userDto.Scopes)
+		if err != nil {
+			return nil, err
+		}
+
+		userResponse.MfaRequired = true
+		userResponse.MfaToken = mfaToken
+
+		query.Close()
+		return &userResponse, nil
+	}
+
+	sessionId, err := insertSession(ur.connection, user.ID, userDto.IP, userDto.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := handlers.CreateToken(user.Name, user.ID, sessionId, userDto.Scopes)
 	if err != nil {
 		return nil, err
 	}
@@ -157,3 +182,232 @@ func validatePassword(password string, savedPassword string) (bool, error) {
 	}
 	return true, nil
 }
+
+// GetUserByEmail ignores case, as OIDC providers and users do not agree on
+// how addresses are written.
+func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+
+	err := ur.connection.QueryRow(
+		"SELECT id, user_name, user_email, user_password FROM users WHERE LOWER(user_email) = LOWER($1) AND deleted_at IS NULL",
+		email,
+	).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (ur *UserRepository) UpdatePassword(userId int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	_, err = ur.connection.Exec("UPDATE users SET user_password = $1 WHERE id = $2", hashedPassword, userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) IsEmailVerified(userId int) (bool, error) {
+	var verified bool
+
+	err := ur.connection.QueryRow(
+		"SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1",
+		userId,
+	).Scan(&verified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		fmt.Println(err)
+		return false, err
+	}
+
+	return verified, nil
+}
+
+func (ur *UserRepository) MarkEmailVerified(userId int) error {
+	_, err := ur.connection.Exec(
+		"UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND email_verified_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) IsAdmin(userId int) (bool, error) {
+	var admin bool
+
+	err := ur.connection.QueryRow(
+		"SELECT is_admin FROM users WHERE id = $1",
+		userId,
+	).Scan(&admin)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		fmt.Println(err)
+		return false, err
+	}
+
+	return admin, nil
+}
+
+func (ur *UserRepository) GetUserPlan(userId int) (string, error) {
+	var plan string
+
+	err := ur.connection.QueryRow(
+		"SELECT plan FROM users WHERE id = $1",
+		userId,
+	).Scan(&plan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+
+		fmt.Println(err)
+		return "", err
+	}
+
+	return plan, nil
+}
+
+// UpdateProfile changes name and email. A new email address has to be
+// verified again.
+func (ur *UserRepository) UpdateProfile(userId int, name string, email string) error {
+	_, err := ur.connection.Exec(
+		"UPDATE users SET user_name = $2, user_email = $3,"+
+			" email_verified_at = CASE WHEN user_email = $3 THEN email_verified_at ELSE NULL END"+
+			" WHERE id = $1 AND deleted_at IS NULL",
+		userId, name, email,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) SoftDeleteUser(userId int) error {
+	_, err := ur.connection.Exec(
+		"UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUnverifiedUser undoes a signup that could not be completed. Accounts
+// that were already verified are never touched.
+func (ur *UserRepository) DeleteUnverifiedUser(userId int) error {
+	tx, err := ur.connection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM user_tokens WHERE user_id = $1", userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM users WHERE id = $1 AND email_verified_at IS NULL",
+		userId,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ur *UserRepository) SetStorageStatus(userId int, status string, message string) error {
+	_, err := ur.connection.Exec(
+		"UPDATE users SET storage_status = $2, storage_error = NULLIF($3, ''), storage_updated_at = NOW() WHERE id = $1",
+		userId, status, message,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) GetStorageStatus(userId int) (*models.StorageStatus, error) {
+	var status models.StorageStatus
+	var message sql.NullString
+
+	err := ur.connection.QueryRow(
+		"SELECT storage_status, storage_error, storage_updated_at FROM users WHERE id = $1 AND deleted_at IS NULL",
+		userId,
+	).Scan(&status.Status, &message, &status.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		fmt.Println(err)
+		return nil, err
+	}
+
+	status.Error = message.String
+	return &status, nil
+}
+
+// GetStalledStorageUsers finds verified users whose bucket provisioning has
+// not moved since updatedBefore, e.g. because the job was lost.
+func (ur *UserRepository) GetStalledStorageUsers(updatedBefore time.Time) ([]int, error) {
+	rows, err := ur.connection.Query(
+		"SELECT id FROM users WHERE storage_status IN ('pending', 'provisioning')"+
+			" AND email_verified_at IS NOT NULL AND deleted_at IS NULL AND storage_updated_at <= $1",
+		updatedBefore,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIds []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+
+	return userIds, rows.Err()
+}
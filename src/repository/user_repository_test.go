@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"os"
 	"testing"
+	"time"
 
 	"cloud_file_manager/src/dto"
 	"cloud_file_manager/src/models"
@@ -21,11 +22,11 @@ func TestUserRepositoryGetUsers(t *testing.T) {
 
 	repo := NewUserRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "user_name", "user_email", "user_password"}).
-		AddRow(1, "Ana", "ana@example.com", "hash1").
-		AddRow(2, "João", "joao@example.com", "hash2")
+	rows := sqlmock.NewRows([]string{"id", "user_name", "user_email"}).
+		AddRow(1, "Ana", "ana@example.com").
+		AddRow(2, "João", "joao@example.com")
 
-	mock.ExpectQuery("SELECT id, user_name, user_email, user_password FROM users").
+	mock.ExpectQuery("SELECT id, user_name, user_email FROM users WHERE deleted_at IS NULL").
 		WillReturnRows(rows)
 
 	users, err := repo.GetUsers()
@@ -112,7 +113,7 @@ func TestUserRepositoryGetUserById(t *testing.T) {
 
 	repo := NewUserRepository(db)
 
-	mock.ExpectPrepare("SELECT \\* FROM users WHERE id = \\$1").
+	mock.ExpectPrepare("SELECT id, user_name, user_email, user_password FROM users WHERE id = \\$1 AND deleted_at IS NULL").
 		ExpectQuery().
 		WithArgs(7).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "user_email", "user_password"}).
@@ -140,7 +141,7 @@ func TestUserRepositoryGetUserByIdNotFound(t *testing.T) {
 
 	repo := NewUserRepository(db)
 
-	mock.ExpectPrepare("SELECT \\* FROM users WHERE id = \\$1").
+	mock.ExpectPrepare("SELECT id, user_name, user_email, user_password FROM users WHERE id = \\$1 AND deleted_at IS NULL").
 		ExpectQuery().
 		WithArgs(99).
 		WillReturnError(sql.ErrNoRows)
@@ -178,11 +179,21 @@ func TestUserRepositoryLogin(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "user_email", "user_password"}).
 			AddRow(5, "Ana", "ana@example.com", string(hashed)))
 
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_mfa WHERE user_id = \\$1 AND enabled_at IS NOT NULL\\)").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	mock.ExpectQuery("INSERT INTO sessions \\(user_id, ip_address, user_agent\\) VALUES \\(\\$1, \\$2, \\$3\\) RETURNING id").
+		WithArgs(5, "10.0.0.1", "curl/8.0").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+
 	os.Setenv("JWT_SECRET", "secret")
 
 	response, err := repo.Login(dto.UserLoginDto{
-		Email:    "ana@example.com",
-		Password: "secret",
+		Email:     "ana@example.com",
+		Password:  "secret",
+		IP:        "10.0.0.1",
+		UserAgent: "curl/8.0",
 	})
 	if err != nil {
 		t.Fatalf("não esperava erro, veio %v", err)
@@ -228,3 +239,126 @@ func TestUserRepositoryLoginInvalidPassword(t *testing.T) {
 		t.Errorf("expectativas não cumpridas: %v", err)
 	}
 }
+
+func TestUserRepositoryLoginWithMfaSkipsSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatalf("falha ao gerar hash: %v", err)
+	}
+
+	mock.ExpectPrepare("SELECT id, user_name, user_email, user_password FROM users WHERE user_email = \\$1").
+		ExpectQuery().
+		WithArgs("ana@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "user_email", "user_password"}).
+			AddRow(5, "Ana", "ana@example.com", string(hashed)))
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_mfa WHERE user_id = \\$1 AND enabled_at IS NOT NULL\\)").
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	os.Setenv("JWT_SECRET", "secret")
+
+	response, err := repo.Login(dto.UserLoginDto{
+		Email:    "ana@example.com",
+		Password: "secret",
+	})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if response == nil || !response.MfaRequired || response.MfaToken == "" || response.Token != "" {
+		t.Fatalf("esperava apenas token de duas etapas, veio %#v", response)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserRepositoryDeleteUnverifiedUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_tokens WHERE user_id = \\$1").
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM users WHERE id = \\$1 AND email_verified_at IS NULL").
+		WithArgs(9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.DeleteUnverifiedUser(9); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserRepositoryGetStorageStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	updatedAt := time.Now()
+	mock.ExpectQuery("SELECT storage_status, storage_error, storage_updated_at FROM users WHERE id = \\$1").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"storage_status", "storage_error", "storage_updated_at"}).
+			AddRow(models.StorageFailed, "nome em uso", updatedAt))
+
+	status, err := repo.GetStorageStatus(4)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if status == nil || status.Status != models.StorageFailed || status.Error != "nome em uso" {
+		t.Fatalf("status inesperado %#v", status)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserRepositoryGetUserByEmailIgnoresCase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	mock.ExpectQuery("SELECT id, user_name, user_email, user_password FROM users WHERE LOWER\\(user_email\\) = LOWER\\(\\$1\\) AND deleted_at IS NULL").
+		WithArgs("user@x.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_name", "user_email", "user_password"}).AddRow(4, "User", "User@x.com", "hash"))
+
+	user, err := repo.GetUserByEmail("user@x.com")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if user == nil || user.ID != 4 {
+		t.Fatalf("esperava o usuário 4, veio %#v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
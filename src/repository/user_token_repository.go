@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserTokenRepository stores single-use tokens mailed to users (password
+// resets and the like). Only the hash of each token is persisted.
+type UserTokenRepository struct {
+	connection *sql.DB
+}
+
+func NewUserTokenRepository(connection *sql.DB) *UserTokenRepository {
+	return &UserTokenRepository{
+		connection: connection,
+	}
+}
+
+// CreateUserToken stores a new token and discards any pending token with the
+// same purpose, so only the most recent link works.
+func (tr *UserTokenRepository) CreateUserToken(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+	tx, err := tr.connection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userId, purpose,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		userId, purpose, tokenHash, expiresAt,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUserTokenOwner returns the owner of a valid token without spending
+// it, or 0 when the token is unknown, expired or was already used.
+func (tr *UserTokenRepository) GetUserTokenOwner(purpose string, tokenHash string) (int, error) {
+	var userId int
+	err := tr.connection.QueryRow(
+		"SELECT user_id FROM user_tokens"+
+			" WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()",
+		tokenHash, purpose,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+// ConsumeUserToken marks a valid token as used and returns its owner. It
+// returns 0 when the token is unknown, expired or was already used.
+func (tr *UserTokenRepository) ConsumeUserToken(purpose string, tokenHash string) (int, error) {
+	var userId int
+	err := tr.connection.QueryRow(
+		"UPDATE user_tokens SET used_at = NOW()"+
+			" WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()"+
+			" RETURNING user_id",
+		tokenHash, purpose,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+// ConsumeUserTokenForPassword consumes a token and stores the new password
+// of its owner in one transaction, so a failed update leaves the token
+// usable. It returns 0 when the token is not valid.
+func (tr *UserTokenRepository) ConsumeUserTokenForPassword(purpose string, tokenHash string, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := tr.connection.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userId int
+	err = tx.QueryRow(
+		"UPDATE user_tokens SET used_at = NOW()"+
+			" WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()"+
+			" RETURNING user_id",
+		tokenHash, purpose,
+	).Scan(&userId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		fmt.Println(err)
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE users SET user_password = $1 WHERE id = $2", hashedPassword, userId)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	return userId, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUserTokenRepositoryCreateUserToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserTokenRepository(db)
+	expiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM user_tokens WHERE user_id = \\$1 AND purpose = \\$2 AND used_at IS NULL").
+		WithArgs(3, "password_reset").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_tokens \\(user_id, purpose, token_hash, expires_at\\) VALUES \\(\\$1, \\$2, \\$3, \\$4\\)").
+		WithArgs(3, "password_reset", "hash", expiresAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := repo.CreateUserToken(3, "password_reset", "hash", expiresAt); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserTokenRepositoryConsumeUserTokenAlreadyUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserTokenRepository(db)
+
+	mock.ExpectQuery("UPDATE user_tokens SET used_at = NOW\\(\\) WHERE token_hash = \\$1 AND purpose = \\$2 AND used_at IS NULL AND expires_at > NOW\\(\\) RETURNING user_id").
+		WithArgs("hash", "password_reset").
+		WillReturnError(sql.ErrNoRows)
+
+	userId, err := repo.ConsumeUserToken("password_reset", "hash")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if userId != 0 {
+		t.Fatalf("esperava token inválido, veio usuário %d", userId)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserTokenRepositoryConsumeUserTokenForPasswordKeepsTokenOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserTokenRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE user_tokens SET used_at = NOW\\(\\) WHERE token_hash = \\$1 AND purpose = \\$2 AND used_at IS NULL AND expires_at > NOW\\(\\) RETURNING user_id").
+		WithArgs("hash", "password_reset").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(8))
+	mock.ExpectExec("UPDATE users SET user_password = \\$1 WHERE id = \\$2").
+		WithArgs(sqlmock.AnyArg(), 8).
+		WillReturnError(errors.New("conexão perdida"))
+	mock.ExpectRollback()
+
+	if _, err := repo.ConsumeUserTokenForPassword("password_reset", "hash", "novasenha123"); err == nil {
+		t.Fatalf("esperava erro")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
+
+func TestUserTokenRepositoryGetUserTokenOwnerDoesNotSpendTheToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("não foi possível criar mock do banco: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserTokenRepository(db)
+
+	mock.ExpectQuery("SELECT user_id FROM user_tokens WHERE token_hash = \\$1 AND purpose = \\$2 AND used_at IS NULL AND expires_at > NOW\\(\\)").
+		WithArgs("hash", "email_verification").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(8))
+
+	userId, err := repo.GetUserTokenOwner("email_verification", "hash")
+	if err != nil || userId != 8 {
+		t.Fatalf("esperava o usuário 8, veio %d, %v", userId, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("expectativas não cumpridas: %v", err)
+	}
+}
@@ -12,6 +12,20 @@ func SetupRoutes (
 	UserController controllers.UserController, 
 	LoginController controllers.LoginController,
 	AwsController controllers.AwsController,
+	SessionController controllers.SessionController,
+	AuthController controllers.AuthController,
+	MfaController controllers.MfaController,
+	OidcController *controllers.OidcController,
+	ApiKeyController controllers.ApiKeyController,
+	AccountController controllers.AccountController,
+	JobController controllers.JobController,
+	DavController controllers.DavController,
+	AccessKeyController controllers.AccessKeyController,
+	S3Controller controllers.S3Controller,
+	SshKeyController controllers.SshKeyController,
+	ArchiveController controllers.ArchiveController,
+	ThumbnailController controllers.ThumbnailController,
+	ImageController controllers.ImageController,
 ) {
 
 	// PING
@@ -21,21 +35,105 @@ func SetupRoutes (
 			})
 	})
 
+	// Public keys for verifying our tokens
+	server.GET("/.well-known/jwks.json", handlers.JWKS)
+
+	// Prometheus metrics
+	server.GET("/metrics", handlers.Metrics)
+
 	// User routes
 	users := server.Group("/users")
-	users.GET("", UserController.GetUsers)
-	users.GET("/:id", handlers.VerifyToken, UserController.GetUserById)
+	usersRead := handlers.RequireScope(handlers.ScopeUsersRead)
+	users.GET("", handlers.Authenticate, handlers.RateLimit(5), usersRead, UserController.GetUsers)
+	users.GET("/:id", handlers.Authenticate, handlers.RateLimit(1), usersRead, UserController.GetUserById)
 	users.POST("", UserController.CreateUser)
 
 	// Login routes
 	login := server.Group("/login")
 	login.POST("", LoginController.Login)
+	login.POST("/mfa", handlers.RateLimit(1), MfaController.Login)
+
+	// Auth routes
+	auth := server.Group("/auth")
+	auth.POST("/forgot", AuthController.ForgotPassword)
+	auth.POST("/reset", AuthController.ResetPassword)
+	auth.POST("/verify", UserController.VerifyEmail)
+	auth.POST("/verify/resend", UserController.ResendVerification)
+	auth.POST("/refresh-token", handlers.Authenticate, handlers.RateLimit(1), handlers.RequireSession, AuthController.IssueRefreshToken)
+	auth.POST("/refresh", AuthController.Refresh)
+	auth.POST("/logout", handlers.Authenticate, handlers.RequireSession, SessionController.Logout)
+
+	// OIDC routes are only available when a provider is configured
+	if OidcController != nil {
+		auth.GET("/oidc/login", OidcController.Login)
+		auth.GET("/oidc/callback", OidcController.Callback)
+	}
+
+	// Account routes, only reachable with an interactive, full-scope login
+	me := server.Group("/me", handlers.Authenticate, handlers.RateLimit(1), handlers.RequireSession, handlers.RequireScope(handlers.ScopeAccountManage))
+	me.GET("/sessions", SessionController.GetSessions)
+	me.DELETE("/sessions/:id", SessionController.DeleteSession)
+	me.POST("/mfa/enroll", MfaController.Enroll)
+	me.POST("/mfa/confirm", MfaController.Confirm)
+	me.POST("/api-keys", ApiKeyController.CreateApiKey)
+	me.GET("/api-keys", ApiKeyController.GetApiKeys)
+	me.DELETE("/api-keys/:id", ApiKeyController.RevokeApiKey)
+	me.POST("/s3-keys", AccessKeyController.CreateAccessKey)
+	me.GET("/s3-keys", AccessKeyController.GetAccessKeys)
+	me.DELETE("/s3-keys/:id", AccessKeyController.RevokeAccessKey)
+	me.POST("/ssh-keys", SshKeyController.CreateSshKey)
+	me.GET("/ssh-keys", SshKeyController.GetSshKeys)
+	me.DELETE("/ssh-keys/:id", SshKeyController.DeleteSshKey)
+	me.GET("/storage", UserController.GetStorageStatus)
+	me.POST("/storage/retry", UserController.RetryStorage)
+	me.PATCH("", AccountController.UpdateProfile)
+	me.POST("/password", AccountController.ChangePassword)
+	me.DELETE("", AccountController.DeleteAccount)
+
+	// Admin routes
+	admin := server.Group("/admin", handlers.Authenticate, handlers.RequireSession, handlers.RequireScope(handlers.ScopeAccountManage), UserController.RequireAdmin)
+	admin.POST("/users/:id/unlock", LoginController.UnlockAccount)
+	admin.GET("/account-deletions/:id", AccountController.GetAccountDeletion)
+	admin.GET("/jobs", JobController.ListJobs)
+	admin.GET("/jobs/:id", JobController.GetJob)
+	admin.POST("/jobs/:id/retry", JobController.RetryJob)
+
+	// Aws routes, weighted by how much each call costs us at AWS
+	filesRead := handlers.RequireScope(handlers.ScopeFilesRead)
+	filesWrite := handlers.RequireScope(handlers.ScopeFilesWrite)
+	bucketsAdmin := handlers.RequireScope(handlers.ScopeBucketsAdmin)
+
+	aws := server.Group("/aws", handlers.Authenticate)
+	aws.POST("/bucket", handlers.RateLimit(10), bucketsAdmin, UserController.RequireVerifiedEmail, AwsController.CreateBucket)
+	aws.GET("/bucket", handlers.RateLimit(5), bucketsAdmin, AwsController.ListBuckets)
+	aws.GET("/bucket/items", handlers.RateLimit(2), filesRead, AwsController.ListBucketItems)
+	aws.POST("/bucket/object", handlers.RateLimit(1), filesRead, AwsController.GetObject)
+	aws.POST("/bucket/put", handlers.RateLimit(1), filesWrite, UserController.RequireVerifiedEmail, AwsController.PutObject)
+	aws.POST("/bucket/delete", handlers.RateLimit(1), filesWrite, AwsController.DeleteObject)
+	aws.POST("/bucket/move", handlers.RateLimit(2), filesWrite, AwsController.MoveObject)
+	aws.POST("/bucket/share", handlers.RateLimit(1), filesRead, AwsController.ShareObject)
+	aws.POST("/bucket/preview", handlers.RateLimit(1), filesRead, AwsController.PreviewObject)
+	aws.POST("/bucket/multipart", handlers.RateLimit(1), filesWrite, UserController.RequireVerifiedEmail, AwsController.StartMultipartUpload)
+	aws.POST("/bucket/multipart/part", handlers.RateLimit(1), filesWrite, UserController.RequireVerifiedEmail, AwsController.PresignUploadPart)
+	aws.POST("/bucket/multipart/complete", handlers.RateLimit(1), filesWrite, UserController.RequireVerifiedEmail, AwsController.CompleteMultipartUpload)
+	aws.POST("/bucket/multipart/abort", handlers.RateLimit(1), filesWrite, AwsController.AbortMultipartUpload)
+	aws.POST("/bucket/zip", handlers.RateLimit(5), filesRead, ArchiveController.DownloadZip)
+	aws.POST("/bucket/archive/contents", handlers.RateLimit(2), filesRead, ArchiveController.ListArchive)
+	aws.POST("/bucket/extract", handlers.RateLimit(5), filesWrite, UserController.RequireVerifiedEmail, ArchiveController.ExtractArchive)
+	aws.GET("/bucket/extract/:id", handlers.RateLimit(1), filesRead, ArchiveController.GetExtraction)
+	aws.GET("/bucket/thumbnail", handlers.RateLimit(1), filesRead, ThumbnailController.GetThumbnail)
+	aws.POST("/bucket/image-url", handlers.RateLimit(1), filesRead, ImageController.SignImageUrl)
+
+	// Transformed images, reachable by anyone holding a URL from /aws/bucket/image-url
+	server.GET("/images/*key", ImageController.VerifyImageUrl, handlers.RateLimit(2), ImageController.ServeImage)
+
+	// WebDAV share of the bucket, for mounting it as a network drive
+	dav := server.Group(controllers.DavPrefix, handlers.BasicAuthenticate("cloud-file-manager"), handlers.RateLimit(1), handlers.RequireFileScopes, UserController.RequireVerifiedEmailToWrite)
+	for _, method := range controllers.DavMethods {
+		dav.Handle(method, "/*path", DavController.Serve)
+	}
 
-	// Aws routes
-	aws := server.Group("/aws")
-	aws.POST("/bucket", handlers.VerifyToken, AwsController.CreateBucket)
-	aws.GET("/bucket", handlers.VerifyToken, AwsController.ListBuckets)
-	aws.GET("/bucket/items", handlers.VerifyToken, AwsController.ListBucketItems)
-	aws.POST("/bucket/object", handlers.VerifyToken, AwsController.GetObject)
-	aws.POST("/bucket/put", handlers.VerifyToken, AwsController.PutObject)
+	// S3-compatible endpoint, signed with the access keys from /me/s3-keys
+	server.Any(controllers.S3Prefix, S3Controller.Serve)
+	server.Any(controllers.S3Prefix+"/*path", S3Controller.Serve)
 }
\ No newline at end of file
@@ -0,0 +1,95 @@
+package s3gateway
+
+import (
+	"cloud_file_manager/src/usecase"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// s3Error is an error answered the S3 way, with its code in an XML body.
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestId string   `xml:"RequestId"`
+}
+
+func errAccessDenied(message string) *s3Error {
+	return &s3Error{Status: http.StatusForbidden, Code: "AccessDenied", Message: message}
+}
+
+func errInvalidArgument(message string) *s3Error {
+	return &s3Error{Status: http.StatusBadRequest, Code: "InvalidArgument", Message: message}
+}
+
+func errMalformedAuthorization() *s3Error {
+	return &s3Error{Status: http.StatusBadRequest, Code: "AuthorizationHeaderMalformed", Message: "Cabeçalho de autorização malformado"}
+}
+
+func errContentSHA256Mismatch() *s3Error {
+	return &s3Error{Status: http.StatusBadRequest, Code: "XAmzContentSHA256Mismatch", Message: "O SHA-256 do corpo não confere com x-amz-content-sha256"}
+}
+
+func errIncompleteBody() *s3Error {
+	return &s3Error{Status: http.StatusBadRequest, Code: "IncompleteBody", Message: "O corpo da requisição está incompleto ou malformado"}
+}
+
+func errNotImplemented() *s3Error {
+	return &s3Error{Status: http.StatusNotImplemented, Code: "NotImplemented", Message: "Operação não suportada por este gateway"}
+}
+
+func errSlowDown() *s3Error {
+	return &s3Error{Status: http.StatusServiceUnavailable, Code: "SlowDown", Message: "Muitas requisições, tente novamente mais tarde"}
+}
+
+func errNoSuchBucket() *s3Error {
+	return &s3Error{Status: http.StatusNotFound, Code: "NoSuchBucket", Message: "O bucket não existe"}
+}
+
+// toS3Error maps the storage errors to the S3 ones. Errors S3 itself
+// returned keep their code and status; nothing else of them, such as the
+// real bucket name, reaches the client.
+func toS3Error(err error) *s3Error {
+	var gatewayErr *s3Error
+	if errors.As(err, &gatewayErr) {
+		return gatewayErr
+	}
+
+	switch {
+	case errors.Is(err, usecase.ErrObjectNotFound):
+		return &s3Error{Status: http.StatusNotFound, Code: "NoSuchKey", Message: "A chave não existe"}
+	case errors.Is(err, usecase.ErrUploadNotFound):
+		return &s3Error{Status: http.StatusNotFound, Code: "NoSuchUpload", Message: "O upload não existe ou já foi concluído"}
+	case errors.Is(err, usecase.ErrInvalidRange):
+		return &s3Error{Status: http.StatusRequestedRangeNotSatisfiable, Code: "InvalidRange", Message: err.Error()}
+	case errors.Is(err, usecase.ErrBucketNotFound):
+		return errNoSuchBucket()
+	case errors.Is(err, usecase.ErrInvalidPart), errors.Is(err, usecase.ErrNoParts), errors.Is(err, usecase.ErrInvalidPageSize):
+		return errInvalidArgument(err.Error())
+	}
+
+	var apiErr smithy.APIError
+	var responseErr *smithyhttp.ResponseError
+	if errors.As(err, &apiErr) && errors.As(err, &responseErr) && responseErr.HTTPStatusCode() < http.StatusInternalServerError {
+		return &s3Error{Status: responseErr.HTTPStatusCode(), Code: apiErr.ErrorCode(), Message: apiErr.ErrorMessage()}
+	}
+
+	fmt.Println(err)
+	return &s3Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: "Erro interno. Tente novamente."}
+}
@@ -0,0 +1,640 @@
+// Package s3gateway serves a user's bucket over a small subset of the S3
+// API, for tools like rclone and the aws CLI. Requests are signed with
+// SigV4 using access keys issued by the app, and every operation is mapped
+// onto the caller's real bucket, so the AWS credentials never leave the
+// server.
+package s3gateway
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BucketName is the only bucket the gateway shows. It stands for the
+// caller's own bucket, whatever its real name.
+const BucketName = "files"
+
+// maxXMLBody bounds the XML bodies we parse, such as the list of parts of
+// a multipart upload or the keys of a batch delete.
+const maxXMLBody = 1 << 20
+
+// maxDeleteKeys is how many keys a batch delete may carry, as in S3.
+const maxDeleteKeys = 1000
+
+// signatureFailures bounds the wrong signatures one access key may send
+// before requests with it are refused without checking.
+var signatureFailures = ratelimit.Rule{Limit: 10, Window: 15 * time.Minute}
+
+// Storage is what the gateway needs from the storage layer. It is
+// implemented by *usecase.StorageUsecase.
+type Storage interface {
+	Stat(ctx context.Context, userId int, key string) (*models.ObjectInfo, error)
+	ReadRange(ctx context.Context, userId int, key string, byteRange string) (*models.ObjectStream, error)
+	Write(ctx context.Context, userId int, key string, body io.Reader) (string, error)
+	Copy(ctx context.Context, userId int, sourceKey string, destinationKey string) error
+	Delete(ctx context.Context, userId int, key string) error
+	ListPage(ctx context.Context, userId int, query models.ListQuery) (*models.ObjectPage, error)
+	StartMultipart(ctx context.Context, userId int, key string) (string, error)
+	UploadPart(ctx context.Context, userId int, key string, uploadId string, partNumber int32, body io.Reader, size int64) (string, error)
+	CompleteMultipart(ctx context.Context, userId int, key string, uploadId string, parts []dto.CompletedPartDto) (*models.ObjectInfo, error)
+	AbortMultipart(ctx context.Context, userId int, key string, uploadId string) error
+}
+
+// KeyResolver finds an active access key and its secret, returning nil
+// when there is none. It is implemented by *usecase.AccessKeyUsecase.
+type KeyResolver interface {
+	ResolveAccessKey(accessKeyId string) (*models.AccessKey, string, error)
+}
+
+// EmailVerifier tells whether a user confirmed their email, which writes
+// require. It is implemented by *usecase.UserUsecase.
+type EmailVerifier interface {
+	IsEmailVerified(userId int) (bool, error)
+}
+
+// Gateway is an http.Handler answering S3 requests under prefix.
+type Gateway struct {
+	storage  Storage
+	keys     KeyResolver
+	verifier EmailVerifier
+	failures ratelimit.Store
+	prefix   string
+	now      func() time.Time
+}
+
+func NewGateway(storage Storage, keys KeyResolver, verifier EmailVerifier, failures ratelimit.Store, prefix string) *Gateway {
+	return &Gateway{
+		storage:  storage,
+		keys:     keys,
+		verifier: verifier,
+		failures: failures,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		now:      time.Now,
+	}
+}
+
+// request is an authenticated S3 request.
+type request struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	requestId   string
+	accessKey   *models.AccessKey
+	sig         *signature
+	signingKey  []byte
+	payloadHash string
+	bucket      string
+	key         string
+	query       url.Values
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &request{w: w, r: r, requestId: newRequestId(), query: r.URL.Query()}
+	w.Header().Set("x-amz-request-id", req.requestId)
+	w.Header().Set("Server", "cloud-file-manager")
+
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, g.prefix), "/")
+	req.bucket, req.key, _ = strings.Cut(path, "/")
+
+	if err := g.authenticate(req); err != nil {
+		req.fail(err)
+		return
+	}
+
+	// Access keys get a rate limit bucket of their own, as API keys do.
+	key := "accesskey:" + strconv.Itoa(req.accessKey.ID)
+	if !handlers.ChargeRateLimit(w, key, req.accessKey.UserID, 1, r.Method+" "+g.prefix) {
+		req.fail(errSlowDown())
+		return
+	}
+
+	required := handlers.ScopeFilesWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		required = handlers.ScopeFilesRead
+	}
+	if !slices.Contains(handlers.KeyScopes(req.accessKey.Scopes), required) {
+		req.fail(errAccessDenied("A chave de acesso não tem o escopo necessário: " + required))
+		return
+	}
+
+	if required == handlers.ScopeFilesWrite {
+		verified, err := g.verifier.IsEmailVerified(req.accessKey.UserID)
+		if err != nil {
+			req.fail(err)
+			return
+		}
+		if !verified {
+			req.fail(errAccessDenied("Confirme seu email antes de enviar arquivos"))
+			return
+		}
+	}
+
+	if req.bucket == "" {
+		g.serveService(req)
+		return
+	}
+	if req.bucket != BucketName {
+		req.fail(errNoSuchBucket())
+		return
+	}
+	if req.key == "" {
+		g.serveBucket(req)
+		return
+	}
+	g.serveObject(req)
+}
+
+// authenticate checks the signature of the request against the secret of
+// the access key it names.
+func (g *Gateway) authenticate(req *request) error {
+	sig, err := parseSignature(req.r)
+	if err != nil {
+		return err
+	}
+
+	accessKey, secret, err := g.keys.ResolveAccessKey(sig.accessKeyId)
+	if err != nil {
+		return err
+	}
+	if accessKey == nil {
+		return &s3Error{Status: http.StatusForbidden, Code: "InvalidAccessKeyId", Message: "A chave de acesso não existe ou foi revogada"}
+	}
+
+	failureKey := "s3:signature:" + sig.accessKeyId
+	blocked, retryAfter, err := signatureFailures.Blocked(g.failures, failureKey)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		ratelimit.HitsTotal.Inc("limiter", "s3", "reason", "signature")
+		req.w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter.Seconds()), 1)))
+		return errSlowDown()
+	}
+
+	if err := sig.checkTime(g.now()); err != nil {
+		return err
+	}
+
+	payloadHash := req.r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		if !sig.presigned {
+			return errInvalidArgument("O cabeçalho x-amz-content-sha256 é obrigatório")
+		}
+		payloadHash = unsignedPayload
+	}
+
+	signingKey, err := sig.verify(req.r, secret, payloadHash)
+	if err != nil {
+		if _, _, hitErr := signatureFailures.Allow(g.failures, failureKey); hitErr != nil {
+			return hitErr
+		}
+		return err
+	}
+
+	req.accessKey = accessKey
+	req.sig = sig
+	req.signingKey = signingKey
+	req.payloadHash = payloadHash
+	return nil
+}
+
+func (g *Gateway) serveService(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.fail(errNotImplemented())
+		return
+	}
+
+	req.writeXML(http.StatusOK, listAllMyBucketsResult{
+		Xmlns:   s3Namespace,
+		Owner:   req.owner(),
+		Buckets: []bucketEntry{{Name: BucketName, CreationDate: s3Time(req.accessKey.CreatedAt)}},
+	})
+}
+
+func (g *Gateway) serveBucket(req *request) {
+	switch req.r.Method {
+	case http.MethodHead:
+		req.w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		switch {
+		case req.query.Has("location"):
+			req.writeXML(http.StatusOK, locationConstraint{Xmlns: s3Namespace})
+		case req.query.Has("uploads"), req.query.Has("versions"), req.query.Has("acl"), req.query.Has("policy"):
+			req.fail(errNotImplemented())
+		default:
+			g.listObjects(req)
+		}
+	case http.MethodPost:
+		if !req.query.Has("delete") {
+			req.fail(errNotImplemented())
+			return
+		}
+		g.deleteObjects(req)
+	case http.MethodPut:
+		// The bucket always exists; creating it again is a no-op, as for
+		// the owner of an S3 bucket.
+		req.w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		req.fail(errAccessDenied("O bucket não pode ser apagado por aqui"))
+	default:
+		req.fail(errNotImplemented())
+	}
+}
+
+func (g *Gateway) serveObject(req *request) {
+	switch req.r.Method {
+	case http.MethodGet:
+		g.getObject(req, true)
+	case http.MethodHead:
+		g.getObject(req, false)
+	case http.MethodPut:
+		switch {
+		case req.query.Has("partNumber") && req.query.Has("uploadId"):
+			g.uploadPart(req)
+		case req.r.Header.Get("X-Amz-Copy-Source") != "":
+			g.copyObject(req)
+		default:
+			g.putObject(req)
+		}
+	case http.MethodPost:
+		switch {
+		case req.query.Has("uploads"):
+			g.startMultipart(req)
+		case req.query.Has("uploadId"):
+			g.completeMultipart(req)
+		default:
+			req.fail(errNotImplemented())
+		}
+	case http.MethodDelete:
+		if req.query.Has("uploadId") {
+			if err := g.storage.AbortMultipart(req.r.Context(), req.accessKey.UserID, req.key, req.query.Get("uploadId")); err != nil {
+				req.fail(err)
+				return
+			}
+			req.w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := g.storage.Delete(req.r.Context(), req.accessKey.UserID, req.key); err != nil {
+			req.fail(err)
+			return
+		}
+		req.w.WriteHeader(http.StatusNoContent)
+	default:
+		req.fail(errNotImplemented())
+	}
+}
+
+// listObjects answers ListObjectsV2 and, for older clients, ListObjects,
+// whose marker works like StartAfter.
+func (g *Gateway) listObjects(req *request) {
+	v2 := req.query.Get("list-type") == "2"
+	encodingType := req.query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		req.fail(errInvalidArgument("encoding-type inválido"))
+		return
+	}
+
+	query := models.ListQuery{
+		Prefix:    req.query.Get("prefix"),
+		Delimiter: req.query.Get("delimiter"),
+	}
+	if value := req.query.Get("max-keys"); value != "" {
+		maxKeys, err := strconv.Atoi(value)
+		if err != nil {
+			req.fail(errInvalidArgument("max-keys inválido"))
+			return
+		}
+		query.MaxKeys = min(maxKeys, 1000)
+	}
+	if v2 {
+		query.ContinuationToken = req.query.Get("continuation-token")
+		query.StartAfter = req.query.Get("start-after")
+	} else {
+		query.StartAfter = req.query.Get("marker")
+	}
+
+	page, err := g.storage.ListPage(req.r.Context(), req.accessKey.UserID, query)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	encode := func(value string) string {
+		if encodingType == "url" {
+			return uriEncode(value, false)
+		}
+		return value
+	}
+
+	result := listBucketResult{
+		Xmlns:        s3Namespace,
+		Name:         BucketName,
+		Prefix:       encode(query.Prefix),
+		Delimiter:    encode(query.Delimiter),
+		MaxKeys:      query.MaxKeys,
+		EncodingType: encodingType,
+		IsTruncated:  page.Truncated,
+	}
+	if result.MaxKeys == 0 {
+		result.MaxKeys = 1000
+	}
+
+	for _, object := range page.Objects {
+		result.Contents = append(result.Contents, objectEntry{
+			Key:          encode(object.Key),
+			LastModified: s3Time(object.LastModified),
+			ETag:         object.ETag,
+			Size:         object.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, prefix := range page.Prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(prefix)})
+	}
+
+	if v2 {
+		keyCount := len(page.Objects) + len(page.Prefixes)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.ContinuationToken
+		result.NextContinuationToken = page.NextContinuationToken
+		result.StartAfter = encode(query.StartAfter)
+	} else {
+		marker := encode(query.StartAfter)
+		result.Marker = &marker
+		if page.Truncated {
+			result.NextMarker = encode(lastListed(page))
+		}
+	}
+
+	req.writeXML(http.StatusOK, result)
+}
+
+// lastListed is the greatest key or prefix of a page, where the next page
+// of a ListObjects starts.
+func lastListed(page *models.ObjectPage) string {
+	last := ""
+	if len(page.Objects) > 0 {
+		last = page.Objects[len(page.Objects)-1].Key
+	}
+	if len(page.Prefixes) > 0 {
+		last = max(last, page.Prefixes[len(page.Prefixes)-1])
+	}
+	return last
+}
+
+func (g *Gateway) getObject(req *request, withBody bool) {
+	ctx := req.r.Context()
+	userId := req.accessKey.UserID
+
+	if !withBody {
+		info, err := g.storage.Stat(ctx, userId, req.key)
+		if err != nil {
+			req.fail(err)
+			return
+		}
+		setObjectHeaders(req.w, info)
+		req.w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		req.w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	stream, err := g.storage.ReadRange(ctx, userId, req.key, req.r.Header.Get("Range"))
+	if err != nil {
+		req.fail(err)
+		return
+	}
+	defer stream.Body.Close()
+
+	setObjectHeaders(req.w, &stream.Info)
+	req.w.Header().Set("Content-Length", strconv.FormatInt(stream.Info.Size, 10))
+
+	status := http.StatusOK
+	if stream.ContentRange != "" {
+		req.w.Header().Set("Content-Range", stream.ContentRange)
+		status = http.StatusPartialContent
+	}
+	req.w.WriteHeader(status)
+
+	io.Copy(req.w, stream.Body)
+}
+
+func setObjectHeaders(w http.ResponseWriter, info *models.ObjectInfo) {
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", info.ETag)
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+func (g *Gateway) putObject(req *request) {
+	body, err := payloadReader(req.r, req.sig, req.signingKey, req.payloadHash)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	etag, err := g.storage.Write(req.r.Context(), req.accessKey.UserID, req.key, body)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	req.w.Header().Set("ETag", etag)
+	req.w.WriteHeader(http.StatusOK)
+}
+
+// copyObject copies inside the bucket; the source must be in it too.
+func (g *Gateway) copyObject(req *request) {
+	source, err := url.PathUnescape(req.r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		req.fail(errInvalidArgument("x-amz-copy-source inválido"))
+		return
+	}
+	source, _, _ = strings.Cut(strings.TrimPrefix(source, "/"), "?")
+
+	sourceBucket, sourceKey, _ := strings.Cut(source, "/")
+	if sourceBucket != BucketName {
+		req.fail(errNoSuchBucket())
+		return
+	}
+	if sourceKey == "" {
+		req.fail(errInvalidArgument("x-amz-copy-source inválido"))
+		return
+	}
+
+	ctx := req.r.Context()
+	userId := req.accessKey.UserID
+	if err := g.storage.Copy(ctx, userId, sourceKey, req.key); err != nil {
+		req.fail(err)
+		return
+	}
+
+	info, err := g.storage.Stat(ctx, userId, req.key)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	req.writeXML(http.StatusOK, copyObjectResult{
+		Xmlns:        s3Namespace,
+		LastModified: s3Time(info.LastModified),
+		ETag:         info.ETag,
+	})
+}
+
+func (g *Gateway) startMultipart(req *request) {
+	uploadId, err := g.storage.StartMultipart(req.r.Context(), req.accessKey.UserID, req.key)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	req.writeXML(http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   BucketName,
+		Key:      req.key,
+		UploadId: uploadId,
+	})
+}
+
+func (g *Gateway) uploadPart(req *request) {
+	partNumber, err := strconv.ParseInt(req.query.Get("partNumber"), 10, 32)
+	if err != nil {
+		req.fail(errInvalidArgument("partNumber inválido"))
+		return
+	}
+
+	size := decodedLength(req.r, req.payloadHash)
+	if size < 0 {
+		req.fail(&s3Error{Status: http.StatusLengthRequired, Code: "MissingContentLength", Message: "O tamanho da parte é obrigatório"})
+		return
+	}
+
+	body, err := payloadReader(req.r, req.sig, req.signingKey, req.payloadHash)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	etag, err := g.storage.UploadPart(req.r.Context(), req.accessKey.UserID, req.key, req.query.Get("uploadId"), int32(partNumber), body, size)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	req.w.Header().Set("ETag", etag)
+	req.w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) completeMultipart(req *request) {
+	body, err := payloadReader(req.r, req.sig, req.signingKey, req.payloadHash)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	var input completeMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(body, maxXMLBody)).Decode(&input); err != nil {
+		req.fail(&s3Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: "O XML enviado é inválido"})
+		return
+	}
+
+	parts := make([]dto.CompletedPartDto, len(input.Parts))
+	for i, part := range input.Parts {
+		parts[i] = dto.CompletedPartDto{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+
+	info, err := g.storage.CompleteMultipart(req.r.Context(), req.accessKey.UserID, req.key, req.query.Get("uploadId"), parts)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	req.writeXML(http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: req.r.URL.Path,
+		Bucket:   BucketName,
+		Key:      req.key,
+		ETag:     info.ETag,
+	})
+}
+
+// deleteObjects answers DeleteObjects. Keys that fail are reported one by
+// one instead of failing the request.
+func (g *Gateway) deleteObjects(req *request) {
+	body, err := payloadReader(req.r, req.sig, req.signingKey, req.payloadHash)
+	if err != nil {
+		req.fail(err)
+		return
+	}
+
+	var input deleteRequest
+	if err := xml.NewDecoder(io.LimitReader(body, maxXMLBody)).Decode(&input); err != nil || len(input.Objects) > maxDeleteKeys {
+		req.fail(&s3Error{Status: http.StatusBadRequest, Code: "MalformedXML", Message: "O XML enviado é inválido"})
+		return
+	}
+
+	result := deleteResult{Xmlns: s3Namespace}
+	for _, object := range input.Objects {
+		if err := g.storage.Delete(req.r.Context(), req.accessKey.UserID, object.Key); err != nil {
+			s3Err := toS3Error(err)
+			result.Errors = append(result.Errors, deleteErrorEntry{Key: object.Key, Code: s3Err.Code, Message: s3Err.Message})
+			continue
+		}
+		if !input.Quiet {
+			result.Deleted = append(result.Deleted, deletedEntry{Key: object.Key})
+		}
+	}
+
+	req.writeXML(http.StatusOK, result)
+}
+
+func (req *request) owner() owner {
+	id := strconv.Itoa(req.accessKey.UserID)
+	return owner{ID: id, DisplayName: id}
+}
+
+func (req *request) writeXML(status int, value any) {
+	req.w.Header().Set("Content-Type", "application/xml")
+	req.w.WriteHeader(status)
+	io.WriteString(req.w, xml.Header)
+	xml.NewEncoder(req.w).Encode(value)
+}
+
+func (req *request) fail(err error) {
+	s3Err := toS3Error(err)
+
+	if req.r.Method == http.MethodHead {
+		req.w.WriteHeader(s3Err.Status)
+		return
+	}
+
+	req.writeXML(s3Err.Status, errorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  req.r.URL.Path,
+		RequestId: req.requestId,
+	})
+}
+
+func newRequestId() string {
+	buffer := make([]byte, 8)
+	rand.Read(buffer)
+	return strings.ToUpper(hex.EncodeToString(buffer))
+}
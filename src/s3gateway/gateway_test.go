@@ -0,0 +1,419 @@
+package s3gateway
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/storagetest"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	testKeyId  = "CFMKTESTTESTTESTTEST"
+	testSecret = "segredo-de-teste"
+)
+
+type fakeKeys struct {
+	scopes []string
+}
+
+func (f *fakeKeys) ResolveAccessKey(accessKeyId string) (*models.AccessKey, string, error) {
+	if accessKeyId != testKeyId {
+		return nil, "", nil
+	}
+	return &models.AccessKey{ID: 1, UserID: 7, AccessKeyID: testKeyId, Scopes: f.scopes}, testSecret, nil
+}
+
+type fakeVerifier struct {
+	unverified bool
+}
+
+func (f *fakeVerifier) IsEmailVerified(userId int) (bool, error) {
+	return !f.unverified, nil
+}
+
+func newGatewayServer(t *testing.T, storage *storagetest.Storage, scopes ...string) (*httptest.Server, *s3.Client) {
+	t.Helper()
+	return newGatewayServerFor(t, storage, &fakeVerifier{}, scopes...)
+}
+
+func newGatewayServerFor(t *testing.T, storage *storagetest.Storage, verifier *fakeVerifier, scopes ...string) (*httptest.Server, *s3.Client) {
+	t.Helper()
+
+	if len(scopes) == 0 {
+		scopes = []string{models.ApiKeyScopeUpload}
+	}
+	server := httptest.NewServer(NewGateway(storage, &fakeKeys{scopes: scopes}, verifier, ratelimit.NewMemoryStore(), "/s3"))
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL + "/s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(testKeyId, testSecret, ""),
+	})
+	return server, client
+}
+
+func expectS3Code(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != code {
+		t.Fatalf("esperava o erro %s, veio %v", code, err)
+	}
+}
+
+func TestGatewayPutGetAndListWithSdk(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	_, client := newGatewayServer(t, storage)
+	ctx := context.Background()
+
+	for key, content := range map[string]string{"notas.txt": "olá mundo", "fotos/1.jpg": "1", "fotos/2.jpg": "2"} {
+		output, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(BucketName), Key: aws.String(key), Body: strings.NewReader(content)})
+		if err != nil {
+			t.Fatalf("o envio de %s deveria funcionar: %v", key, err)
+		}
+		if aws.ToString(output.ETag) != storagetest.ETag([]byte(content)) {
+			t.Fatalf("o ETag deveria ser o do objeto, veio %q", aws.ToString(output.ETag))
+		}
+	}
+	if string(storage.Objects["notas.txt"]) != "olá mundo" {
+		t.Fatalf("o objeto deveria ter sido gravado, veio %q", storage.Objects["notas.txt"])
+	}
+
+	object, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(BucketName), Key: aws.String("notas.txt"), Range: aws.String("bytes=5-9")})
+	if err != nil {
+		t.Fatalf("a leitura deveria funcionar: %v", err)
+	}
+	body, _ := io.ReadAll(object.Body)
+	if string(body) != "mundo" || aws.ToString(object.ContentRange) != "bytes 5-9/10" {
+		t.Fatalf("esperava o trecho pedido, veio %q (%s)", body, aws.ToString(object.ContentRange))
+	}
+
+	list, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName), Delimiter: aws.String("/")})
+	if err != nil {
+		t.Fatalf("a listagem deveria funcionar: %v", err)
+	}
+	if len(list.Contents) != 1 || aws.ToString(list.Contents[0].Key) != "notas.txt" || len(list.CommonPrefixes) != 1 || aws.ToString(list.CommonPrefixes[0].Prefix) != "fotos/" {
+		t.Fatalf("a listagem deveria ter notas.txt e fotos/, veio %+v", list)
+	}
+
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(BucketName), Key: aws.String("nada.txt")})
+	var notFound *types.NotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("esperava NotFound, veio %v", err)
+	}
+
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(BucketName), Key: aws.String("notas.txt")}); err != nil {
+		t.Fatalf("a remoção deveria funcionar: %v", err)
+	}
+	if _, ok := storage.Objects["notas.txt"]; ok {
+		t.Fatalf("o objeto deveria ter sido apagado")
+	}
+
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("outro"), Key: aws.String("a")})
+	expectS3Code(t, err, "NoSuchBucket")
+}
+
+func TestGatewayMultipartUploadWithSdk(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	_, client := newGatewayServer(t, storage)
+	ctx := context.Background()
+
+	started, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(BucketName), Key: aws.String("grande.bin")})
+	if err != nil {
+		t.Fatalf("o início do upload deveria funcionar: %v", err)
+	}
+
+	var completed []types.CompletedPart
+	for i, content := range []string{strings.Repeat("a", 1000), "fim"} {
+		part, err := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(BucketName),
+			Key:        aws.String("grande.bin"),
+			UploadId:   started.UploadId,
+			PartNumber: aws.Int32(int32(i + 1)),
+			Body:       strings.NewReader(content),
+		})
+		if err != nil {
+			t.Fatalf("o envio da parte %d deveria funcionar: %v", i+1, err)
+		}
+		completed = append(completed, types.CompletedPart{PartNumber: aws.Int32(int32(i + 1)), ETag: part.ETag})
+	}
+
+	_, err = client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(BucketName),
+		Key:             aws.String("grande.bin"),
+		UploadId:        started.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		t.Fatalf("a conclusão deveria funcionar: %v", err)
+	}
+	if string(storage.Objects["grande.bin"]) != strings.Repeat("a", 1000)+"fim" {
+		t.Fatalf("o objeto deveria juntar as partes, veio %d bytes", len(storage.Objects["grande.bin"]))
+	}
+
+	_, err = client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{Bucket: aws.String(BucketName), Key: aws.String("grande.bin"), UploadId: started.UploadId})
+	expectS3Code(t, err, "NoSuchUpload")
+}
+
+func TestGatewayRejectsBadCredentialsAndScopes(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	server, _ := newGatewayServer(t, storage, models.ApiKeyScopeReadOnly)
+	ctx := context.Background()
+
+	wrongSecret := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL + "/s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(testKeyId, "outro-segredo", ""),
+	})
+	_, err := wrongSecret.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)})
+	expectS3Code(t, err, "SignatureDoesNotMatch")
+
+	unknownKey := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL + "/s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("CFMKDESCONHECIDA0000", testSecret, ""),
+	})
+	_, err = unknownKey.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)})
+	expectS3Code(t, err, "InvalidAccessKeyId")
+
+	readOnly := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL + "/s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(testKeyId, testSecret, ""),
+	})
+	if _, err := readOnly.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)}); err != nil {
+		t.Fatalf("uma chave de leitura deveria listar: %v", err)
+	}
+	_, err = readOnly.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(BucketName), Key: aws.String("a.txt"), Body: strings.NewReader("a")})
+	expectS3Code(t, err, "AccessDenied")
+
+	response, err := http.Get(server.URL + "/s3/files/a.txt")
+	if err != nil {
+		t.Fatalf("a requisição falhou: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("uma requisição sem assinatura deveria ser negada, veio %d", response.StatusCode)
+	}
+}
+
+func TestGatewayRefusesWritesUntilEmailIsVerified(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	storage.Objects["a.txt"] = []byte("a")
+	verifier := &fakeVerifier{unverified: true}
+	_, client := newGatewayServerFor(t, storage, verifier)
+	ctx := context.Background()
+
+	if _, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(BucketName), Key: aws.String("a.txt")}); err != nil {
+		t.Fatalf("a leitura deveria funcionar: %v", err)
+	}
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(BucketName), Key: aws.String("b.txt"), Body: strings.NewReader("b")})
+	expectS3Code(t, err, "AccessDenied")
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(BucketName), Key: aws.String("a.txt")})
+	expectS3Code(t, err, "AccessDenied")
+
+	verifier.unverified = false
+	if _, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(BucketName), Key: aws.String("b.txt"), Body: strings.NewReader("b")}); err != nil {
+		t.Fatalf("o envio deveria funcionar com o email confirmado: %v", err)
+	}
+}
+
+func TestGatewayThrottlesWrongSignaturesAndRateLimits(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	server, _ := newGatewayServer(t, storage)
+	ctx := context.Background()
+
+	newClient := func(secret string) *s3.Client {
+		return s3.New(s3.Options{
+			Region:           "us-east-1",
+			BaseEndpoint:     aws.String(server.URL + "/s3"),
+			UsePathStyle:     true,
+			RetryMaxAttempts: 1,
+			Credentials:      credentials.NewStaticCredentialsProvider(testKeyId, secret, ""),
+		})
+	}
+
+	wrongSecret := newClient("outro-segredo")
+	for i := 0; i < signatureFailures.Limit; i++ {
+		_, err := wrongSecret.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)})
+		expectS3Code(t, err, "SignatureDoesNotMatch")
+	}
+
+	// Once over the limit, not even the right secret is checked.
+	_, err := newClient(testSecret).ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)})
+	expectS3Code(t, err, "SlowDown")
+
+	plans, _ := ratelimit.ParsePlans("free:2:60", "free")
+	handlers.SetRateLimiter(handlers.NewRateLimiter(plans, nil))
+	defer handlers.SetRateLimiter(nil)
+
+	_, client := newGatewayServer(t, storage)
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)}); err != nil {
+			t.Fatalf("a listagem deveria funcionar: %v", err)
+		}
+	}
+	_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String(BucketName)}, func(o *s3.Options) {
+		o.RetryMaxAttempts = 1
+	})
+	expectS3Code(t, err, "SlowDown")
+}
+
+func TestGatewayAcceptsPresignedUrlsUntilTheyExpire(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	storage.Objects["a.txt"] = []byte("conteúdo")
+	server, client := newGatewayServer(t, storage)
+
+	presigned, err := s3.NewPresignClient(client).PresignGetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(BucketName),
+		Key:    aws.String("a.txt"),
+	}, s3.WithPresignExpires(time.Minute))
+	if err != nil {
+		t.Fatalf("a assinatura do link deveria funcionar: %v", err)
+	}
+
+	response, err := http.Get(presigned.URL)
+	if err != nil {
+		t.Fatalf("a requisição falhou: %v", err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != "conteúdo" {
+		t.Fatalf("o link assinado deveria servir o objeto, veio %d %q", response.StatusCode, body)
+	}
+
+	server.Config.Handler.(*Gateway).now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	response, err = http.Get(presigned.URL)
+	if err != nil {
+		t.Fatalf("a requisição falhou: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("um link expirado deveria ser negado, veio %d", response.StatusCode)
+	}
+}
+
+func TestChunkedPayloadRejectsTamperedChunks(t *testing.T) {
+	sig := &signature{amzDate: "20250102T030405Z", scope: "20250102/us-east-1/s3/aws4_request", value: "seed"}
+	key := signingKey(testSecret, "20250102", "us-east-1")
+
+	chunkSignature := func(previous string, data string) string {
+		sum := sha256Hex(data)
+		return hex.EncodeToString(hmacSHA256(key, "AWS4-HMAC-SHA256-PAYLOAD\n"+sig.amzDate+"\n"+sig.scope+"\n"+previous+"\n"+emptySHA256+"\n"+sum))
+	}
+	first := chunkSignature("seed", "olá")
+	last := chunkSignature(first, "")
+	body := fmt.Sprintf("%x;chunk-signature=%s\r\nolá\r\n0;chunk-signature=%s\r\n\r\n", len("olá"), first, last)
+
+	request := httptest.NewRequest(http.MethodPut, "/s3/files/a.txt", strings.NewReader(body))
+	reader, err := payloadReader(request, sig, key, streamingSigned)
+	if err != nil {
+		t.Fatalf("o corpo deveria ser aceito: %v", err)
+	}
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "olá" {
+		t.Fatalf("esperava o conteúdo dos blocos, veio %q, %v", data, err)
+	}
+
+	tampered := strings.Replace(body, "olá", "olé", 1)
+	request = httptest.NewRequest(http.MethodPut, "/s3/files/a.txt", strings.NewReader(tampered))
+	reader, _ = payloadReader(request, sig, key, streamingSigned)
+	_, err = io.ReadAll(reader)
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) || s3Err.Code != "SignatureDoesNotMatch" {
+		t.Fatalf("um bloco alterado deveria ser rejeitado, veio %v", err)
+	}
+}
+
+// cutBody yields data and then fails the way a request body does when the
+// client disconnects before its Content-Length.
+func cutBody(data string) io.Reader {
+	return io.MultiReader(strings.NewReader(data), iotest.ErrReader(io.ErrUnexpectedEOF))
+}
+
+// signedPut signs a PUT of a.txt for payloadHash and returns the request
+// with the seed signature the chunks chain from.
+func signedPut(t *testing.T, payloadHash string, body io.Reader, length int64) (*http.Request, string) {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPut, "/s3/files/a.txt", body)
+	request.ContentLength = length
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	err := v4.NewSigner().SignHTTP(context.Background(), aws.Credentials{AccessKeyID: testKeyId, SecretAccessKey: testSecret}, request, payloadHash, "s3", "us-east-1", time.Now())
+	if err != nil {
+		t.Fatalf("a assinatura falhou: %v", err)
+	}
+	_, seed, _ := strings.Cut(request.Header.Get("Authorization"), "Signature=")
+	return request, seed
+}
+
+func TestGatewayRejectsTruncatedBodies(t *testing.T) {
+	storage := storagetest.New(nil)
+	storage.UserID = 7
+	gateway := NewGateway(storage, &fakeKeys{scopes: []string{models.ApiKeyScopeUpload}}, &fakeVerifier{}, ratelimit.NewMemoryStore(), "/s3")
+
+	expectIncomplete := func(request *http.Request) {
+		t.Helper()
+
+		recorder := httptest.NewRecorder()
+		gateway.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "<Code>IncompleteBody</Code>") {
+			t.Fatalf("um corpo incompleto deveria ser rejeitado, veio %d %s", recorder.Code, recorder.Body.String())
+		}
+		if len(storage.Objects) != 0 {
+			t.Fatalf("nada deveria ser gravado, veio %v", storage.Keys(""))
+		}
+	}
+
+	content := "conteúdo inteiro"
+	request, _ := signedPut(t, sha256Hex(content), cutBody(content[:5]), int64(len(content)))
+	expectIncomplete(request)
+
+	date := time.Now().UTC().Format("20060102")
+	key := signingKey(testSecret, date, "us-east-1")
+	request, seed := signedPut(t, streamingSigned, nil, 0)
+	scope := date + "/us-east-1/s3/aws4_request"
+	sum := sha256Hex(content)
+	chunkSig := hex.EncodeToString(hmacSHA256(key, "AWS4-HMAC-SHA256-PAYLOAD\n"+request.Header.Get("X-Amz-Date")+"\n"+scope+"\n"+seed+"\n"+emptySHA256+"\n"+sum))
+	chunk := fmt.Sprintf("%x;chunk-signature=%s\r\n%s", len(content), chunkSig, content[:5])
+	request.Body = io.NopCloser(cutBody(chunk))
+	request.ContentLength = int64(len(chunk) + 100)
+	request.Header.Set("X-Amz-Decoded-Content-Length", strconv.Itoa(len(content)))
+	expectIncomplete(request)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
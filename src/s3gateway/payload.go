@@ -0,0 +1,297 @@
+package s3gateway
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// payloadReader returns the body of the request as the object bytes,
+// checking them against what the signature covers: the SHA-256 of the
+// whole body, or the signature of every chunk of an aws-chunked body.
+// Checks that can only happen at the end surface as a read error, so a
+// bad body fails the upload instead of being stored.
+func payloadReader(r *http.Request, sig *signature, key []byte, payloadHash string) (io.Reader, error) {
+	switch {
+	case payloadHash == unsignedPayload:
+		return r.Body, nil
+
+	case sha256Pattern.MatchString(payloadHash):
+		return &hashedReader{reader: r.Body, hash: sha256.New(), expected: payloadHash}, nil
+
+	case payloadHash == streamingSigned, payloadHash == streamingSignedTrailer, payloadHash == streamingUnsignedTrailer:
+		reader := &chunkedReader{
+			reader:   bufio.NewReader(r.Body),
+			signed:   payloadHash != streamingUnsignedTrailer,
+			trailer:  payloadHash != streamingSigned,
+			key:      key,
+			sig:      sig,
+			previous: sig.value,
+		}
+		if reader.trailer {
+			reader.checksumName, reader.checksum = trailerChecksum(r.Header.Get("X-Amz-Trailer"))
+		}
+		return reader, nil
+	}
+
+	return nil, errInvalidArgument("x-amz-content-sha256 inválido")
+}
+
+// decodedLength is the size of the object inside the body.
+func decodedLength(r *http.Request, payloadHash string) int64 {
+	if strings.HasPrefix(payloadHash, "STREAMING-") {
+		length, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return -1
+		}
+		return length
+	}
+	return r.ContentLength
+}
+
+// hashedReader fails at the end of the body if its SHA-256 is not the
+// declared one. A body that ends any other way was cut short and its
+// hash was never checked.
+type hashedReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func (hr *hashedReader) Read(p []byte) (int, error) {
+	n, err := hr.reader.Read(p)
+	hr.hash.Write(p[:n])
+
+	if err == io.EOF && hex.EncodeToString(hr.hash.Sum(nil)) != hr.expected {
+		return n, errContentSHA256Mismatch()
+	}
+	if err != nil && err != io.EOF {
+		return n, errIncompleteBody()
+	}
+	return n, err
+}
+
+// chunkedReader decodes aws-chunked bodies:
+//
+//	<hex size>[;chunk-signature=<signature>]\r\n<data>\r\n ... 0[;...]\r\n[trailers]\r\n
+//
+// Signed chunks chain their signatures from the request's. The data of a
+// chunk is handed on as it arrives and its signature is checked once the
+// chunk ends.
+type chunkedReader struct {
+	reader  *bufio.Reader
+	signed  bool
+	trailer bool
+	key     []byte
+	sig     *signature
+
+	previous  string
+	remaining int64
+	chunkSig  string
+	chunkHash hash.Hash
+	done      bool
+
+	checksumName string
+	checksum     hash.Hash
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.done {
+		return 0, io.EOF
+	}
+
+	if cr.remaining == 0 {
+		if err := cr.nextChunk(); err != nil {
+			return 0, err
+		}
+		if cr.done {
+			return 0, io.EOF
+		}
+	}
+
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+
+	n, err := cr.reader.Read(p)
+	cr.remaining -= int64(n)
+	if cr.chunkHash != nil {
+		cr.chunkHash.Write(p[:n])
+	}
+	if cr.checksum != nil {
+		cr.checksum.Write(p[:n])
+	}
+
+	// The body may only end after the last chunk; ending inside one leaves
+	// its signature unchecked.
+	if err != nil {
+		return n, errIncompleteBody()
+	}
+
+	if cr.remaining == 0 {
+		if err := cr.endChunk(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (cr *chunkedReader) nextChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return errIncompleteBody()
+	}
+
+	if cr.signed {
+		signatureValue, found := strings.CutPrefix(extension, "chunk-signature=")
+		if !found {
+			return errIncompleteBody()
+		}
+		cr.chunkSig = signatureValue
+		cr.chunkHash = sha256.New()
+	}
+
+	cr.remaining = size
+	if size > 0 {
+		return nil
+	}
+
+	// The last chunk is empty; it is signed like the others.
+	if cr.signed {
+		if err := cr.checkChunk(); err != nil {
+			return err
+		}
+	}
+	if cr.trailer {
+		if err := cr.readTrailers(); err != nil {
+			return err
+		}
+	} else if _, err := cr.readLine(); err != nil {
+		return err
+	}
+
+	cr.done = true
+	return nil
+}
+
+func (cr *chunkedReader) endChunk() error {
+	if line, err := cr.readLine(); err != nil || line != "" {
+		return errIncompleteBody()
+	}
+
+	if cr.signed {
+		return cr.checkChunk()
+	}
+	return nil
+}
+
+func (cr *chunkedReader) checkChunk() error {
+	stringToSign := "AWS4-HMAC-SHA256-PAYLOAD\n" + cr.sig.amzDate + "\n" + cr.sig.scope + "\n" +
+		cr.previous + "\n" + emptySHA256 + "\n" + hex.EncodeToString(cr.chunkHash.Sum(nil))
+
+	expected := hex.EncodeToString(hmacSHA256(cr.key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(cr.chunkSig)) {
+		return &s3Error{Status: http.StatusForbidden, Code: "SignatureDoesNotMatch", Message: "A assinatura de um bloco do corpo não confere"}
+	}
+
+	cr.previous = expected
+	return nil
+}
+
+// readTrailers reads the trailing headers up to the blank line ending the
+// body, checking the declared checksum and, for signed bodies, the
+// trailer signature.
+func (cr *chunkedReader) readTrailers() error {
+	var canonical strings.Builder
+	trailerSig := ""
+
+	for {
+		line, err := cr.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return errIncompleteBody()
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		if name == "x-amz-trailer-signature" {
+			trailerSig = value
+			continue
+		}
+		canonical.WriteString(name + ":" + value + "\n")
+
+		if name == cr.checksumName && cr.checksum != nil {
+			if base64.StdEncoding.EncodeToString(cr.checksum.Sum(nil)) != value {
+				return &s3Error{Status: http.StatusBadRequest, Code: "BadDigest", Message: "O checksum enviado não confere com o conteúdo"}
+			}
+		}
+	}
+
+	if !cr.signed {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256-TRAILER\n" + cr.sig.amzDate + "\n" + cr.sig.scope + "\n" +
+		cr.previous + "\n" + hex.EncodeToString(sum[:])
+
+	expected := hex.EncodeToString(hmacSHA256(cr.key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(trailerSig)) {
+		return &s3Error{Status: http.StatusForbidden, Code: "SignatureDoesNotMatch", Message: "A assinatura do trailer não confere"}
+	}
+
+	return nil
+}
+
+func (cr *chunkedReader) readLine() (string, error) {
+	line, err := cr.reader.ReadString('\n')
+	if err != nil {
+		return "", errIncompleteBody()
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+// trailerChecksum prepares the checksum named in X-Amz-Trailer. Kinds we
+// do not know are passed on unchecked.
+func trailerChecksum(trailer string) (string, hash.Hash) {
+	name := strings.ToLower(strings.TrimSpace(trailer))
+
+	switch name {
+	case "x-amz-checksum-crc32":
+		return name, crc32.NewIEEE()
+	case "x-amz-checksum-crc32c":
+		return name, crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "x-amz-checksum-sha1":
+		return name, sha1.New()
+	case "x-amz-checksum-sha256":
+		return name, sha256.New()
+	}
+
+	return name, nil
+}
@@ -0,0 +1,298 @@
+package s3gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingSigned          = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingSignedTrailer   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// maxClockSkew is how far the signing time of a request may be from
+	// ours, as S3 allows.
+	maxClockSkew = 15 * time.Minute
+	// Presigned URLs cannot be valid for longer than a week.
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// signature is a parsed SigV4 signature, from the Authorization header or
+// from the query of a presigned URL.
+type signature struct {
+	accessKeyId   string
+	scope         string
+	date          string
+	region        string
+	signedHeaders []string
+	value         string
+	amzDate       string
+	signedAt      time.Time
+	presigned     bool
+	expires       time.Duration
+}
+
+// parseSignature reads the signature of the request. It fails with an
+// s3Error describing what is wrong.
+func parseSignature(r *http.Request) (*signature, error) {
+	query := r.URL.Query()
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		return parseAuthorization(header, r.Header.Get("X-Amz-Date"))
+	}
+
+	if query.Get("X-Amz-Algorithm") != "" {
+		return parsePresigned(query)
+	}
+
+	return nil, errAccessDenied("A requisição não está assinada")
+}
+
+func parseAuthorization(header string, amzDate string) (*signature, error) {
+	rest, found := strings.CutPrefix(header, signingAlgorithm+" ")
+	if !found {
+		return nil, errInvalidArgument("Apenas assinaturas " + signingAlgorithm + " são aceitas")
+	}
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errMalformedAuthorization()
+		}
+		fields[name] = value
+	}
+
+	sig := &signature{value: fields["Signature"], amzDate: amzDate}
+	if err := sig.setCredential(fields["Credential"]); err != nil {
+		return nil, err
+	}
+	if fields["SignedHeaders"] == "" || sig.value == "" {
+		return nil, errMalformedAuthorization()
+	}
+	sig.signedHeaders = strings.Split(fields["SignedHeaders"], ";")
+
+	if err := sig.setDate(); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+func parsePresigned(query url.Values) (*signature, error) {
+	if query.Get("X-Amz-Algorithm") != signingAlgorithm {
+		return nil, errInvalidArgument("Apenas assinaturas " + signingAlgorithm + " são aceitas")
+	}
+
+	sig := &signature{
+		value:     query.Get("X-Amz-Signature"),
+		amzDate:   query.Get("X-Amz-Date"),
+		presigned: true,
+	}
+	if err := sig.setCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	if query.Get("X-Amz-SignedHeaders") == "" || sig.value == "" {
+		return nil, errMalformedAuthorization()
+	}
+	sig.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+
+	seconds, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxPresignExpiry {
+		return nil, errInvalidArgument("X-Amz-Expires deve ser entre 1 segundo e 7 dias")
+	}
+	sig.expires = time.Duration(seconds) * time.Second
+
+	if err := sig.setDate(); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// setCredential splits "<key id>/<date>/<region>/s3/aws4_request".
+func (sig *signature) setCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return errMalformedAuthorization()
+	}
+
+	sig.accessKeyId = parts[0]
+	sig.date = parts[1]
+	sig.region = parts[2]
+	sig.scope = strings.Join(parts[1:], "/")
+	return nil
+}
+
+func (sig *signature) setDate() error {
+	signedAt, err := time.Parse(amzDateFormat, sig.amzDate)
+	if err != nil || !strings.HasPrefix(sig.amzDate, sig.date) {
+		return errAccessDenied("X-Amz-Date ausente ou diferente da data da credencial")
+	}
+	sig.signedAt = signedAt
+	return nil
+}
+
+// checkTime rejects requests signed too far from now and expired
+// presigned URLs.
+func (sig *signature) checkTime(now time.Time) error {
+	if sig.presigned {
+		if now.Before(sig.signedAt.Add(-maxClockSkew)) || now.After(sig.signedAt.Add(sig.expires)) {
+			return errAccessDenied("O link assinado expirou")
+		}
+		return nil
+	}
+
+	if now.Sub(sig.signedAt).Abs() > maxClockSkew {
+		return &s3Error{Status: http.StatusForbidden, Code: "RequestTimeTooSkewed", Message: "A hora da requisição está muito distante da hora do servidor"}
+	}
+	return nil
+}
+
+// verify checks the signature against the request and the secret of the
+// key. payloadHash is what the client declared for the body.
+func (sig *signature) verify(r *http.Request, secret string, payloadHash string) ([]byte, error) {
+	if !sig.presigned && !slices.Contains(sig.signedHeaders, "host") {
+		return nil, errAccessDenied("O cabeçalho host precisa ser assinado")
+	}
+
+	key := signingKey(secret, sig.date, sig.region)
+	expected := hex.EncodeToString(hmacSHA256(key, sig.stringToSign(canonicalRequest(r, sig, payloadHash))))
+
+	if !hmac.Equal([]byte(expected), []byte(sig.value)) {
+		return nil, &s3Error{
+			Status:  http.StatusForbidden,
+			Code:    "SignatureDoesNotMatch",
+			Message: "A assinatura calculada não confere com a enviada. Verifique a chave e o método de assinatura.",
+		}
+	}
+
+	return key, nil
+}
+
+func (sig *signature) stringToSign(canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return signingAlgorithm + "\n" + sig.amzDate + "\n" + sig.scope + "\n" + hex.EncodeToString(sum[:])
+}
+
+func canonicalRequest(r *http.Request, sig *signature, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range sig.signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(canonicalHeaderValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.RawQuery, sig.presigned),
+		headers.String(),
+		strings.Join(sig.signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func canonicalHeaderValue(r *http.Request, name string) string {
+	var values []string
+	switch name {
+	case "host":
+		values = []string{r.Host}
+	case "content-length":
+		values = r.Header.Values(name)
+		if len(values) == 0 && r.ContentLength >= 0 {
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	case "transfer-encoding":
+		values = r.TransferEncoding
+	default:
+		values = r.Header.Values(name)
+	}
+
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// canonicalQuery sorts the parameters by their encoded names and values.
+// A presigned URL's own signature is not part of what it signs.
+func canonicalQuery(rawQuery string, presigned bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	var pairs [][2]string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, _ = url.QueryUnescape(name)
+		value, _ = url.QueryUnescape(value)
+		if presigned && name == "X-Amz-Signature" {
+			continue
+		}
+		pairs = append(pairs, [2]string{uriEncode(name, true), uriEncode(value, true)})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters, the way
+// SigV4 expects; slashes are kept in paths.
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+func signingKey(secret string, date string, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+// Package secretbox encrypts secrets the server has to read back, such as
+// TOTP seeds, with a server key so that a database leak does not expose
+// them.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sealedPrefix marks values written by Seal, telling them apart from the
+// plaintext stored before encryption was introduced.
+const sealedPrefix = "v1:"
+
+var ErrInvalidSealed = errors.New("segredo cifrado inválido")
+
+// Box seals values with AES-256-GCM.
+type Box struct {
+	aead cipher.AEAD
+}
+
+func New(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("a chave deve ter 32 bytes, veio %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// FromEnv reads the base64-encoded 32-byte key in MFA_ENCRYPTION_KEY,
+// e.g. from `openssl rand -base64 32`.
+func FromEnv() (*Box, error) {
+	value := os.Getenv("MFA_ENCRYPTION_KEY")
+	if value == "" {
+		return nil, errors.New("MFA_ENCRYPTION_KEY não configurada")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY: %w", err)
+	}
+
+	return New(key)
+}
+
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Open(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", ErrInvalidSealed
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", ErrInvalidSealed
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidSealed
+	}
+
+	return string(plaintext), nil
+}
+
+// IsSealed tells values written by Seal from plaintext.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
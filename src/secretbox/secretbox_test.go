@@ -0,0 +1,41 @@
+package secretbox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBoxSealsAndOpens(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("o segredo não deveria aparecer no valor cifrado: %s", sealed)
+	}
+
+	opened, err := box.Open(sealed)
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("esperava o segredo original, veio %q, %v", opened, err)
+	}
+
+	other, _ := New(bytes.Repeat([]byte{2}, 32))
+	if _, err := other.Open(sealed); err != ErrInvalidSealed {
+		t.Fatalf("outra chave não deveria abrir o segredo, veio %v", err)
+	}
+	if _, err := box.Open("JBSWY3DPEHPK3PXP"); err != ErrInvalidSealed {
+		t.Fatalf("texto puro não deveria abrir, veio %v", err)
+	}
+}
+
+func TestNewRequires32ByteKey(t *testing.T) {
+	if _, err := New([]byte("curta")); err == nil {
+		t.Fatalf("esperava erro com chave curta")
+	}
+}
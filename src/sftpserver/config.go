@@ -0,0 +1,66 @@
+package sftpserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type Config struct {
+	Addr        string
+	HostKeyFile string
+}
+
+// ConfigFromEnv reads the server settings. The second value is false when
+// SFTP_ADDR is not set, meaning the SFTP server is disabled.
+func ConfigFromEnv() (Config, bool) {
+	addr := os.Getenv("SFTP_ADDR")
+	if addr == "" {
+		return Config{}, false
+	}
+
+	hostKeyFile := os.Getenv("SFTP_HOST_KEY_FILE")
+	if hostKeyFile == "" {
+		hostKeyFile = "sftp_host_ed25519_key"
+	}
+
+	return Config{Addr: addr, HostKeyFile: hostKeyFile}, true
+}
+
+// LoadHostKey reads the server's private key, creating an ed25519 one on
+// the first start. The key has to stay the same across restarts, or
+// clients that trusted the server refuse to connect.
+func LoadHostKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = generateHostKey(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKey(data)
+}
+
+func generateHostKey(file string) ([]byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "cloud-file-manager sftp")
+	if err != nil {
+		return nil, err
+	}
+
+	data := pem.EncodeToMemory(block)
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
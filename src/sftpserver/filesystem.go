@@ -0,0 +1,217 @@
+package sftpserver
+
+import (
+	"cloud_file_manager/src/dav"
+	"cloud_file_manager/src/usecase"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+var (
+	errIsFolder     = errors.New("o caminho é uma pasta")
+	errNotFolder    = errors.New("o caminho não é uma pasta")
+	errFolderInUse  = errors.New("a pasta não está vazia")
+	errRandomWrite  = errors.New("os arquivos só podem ser gravados em sequência, do início ao fim")
+	errMissingParts = errors.New("o envio terminou com partes faltando")
+)
+
+// fileSystem answers the SFTP requests of one user. Paths and folders
+// work as over WebDAV, through dav.FileSystem; contents are streamed
+// straight from and to the storage. Users who have not confirmed their
+// email get a read-only one.
+type fileSystem struct {
+	ctx      context.Context
+	storage  dav.Storage
+	userId   int
+	readOnly bool
+	names    *dav.FileSystem
+}
+
+func newFileSystem(ctx context.Context, storage dav.Storage, userId int, readOnly bool) *fileSystem {
+	return &fileSystem{
+		ctx:      ctx,
+		storage:  storage,
+		userId:   userId,
+		readOnly: readOnly,
+		names:    dav.NewFileSystem(storage, userId),
+	}
+}
+
+func (fs *fileSystem) handlers() sftp.Handlers {
+	return sftp.Handlers{FileGet: fs, FilePut: fs, FileCmd: fs, FileList: fs}
+}
+
+// objectKey maps an SFTP path to its key: "/a/b.txt" is "a/b.txt" and
+// the root is "". Cleaning the path first keeps ".." inside the bucket.
+func objectKey(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func (fs *fileSystem) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	info, err := fs.names.Stat(fs.ctx, r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errIsFolder
+	}
+
+	return &streamReader{fs: fs, key: objectKey(r.Filepath), size: info.Size()}, nil
+}
+
+func (fs *fileSystem) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	key := objectKey(r.Filepath)
+	if key == "" || fs.readOnly {
+		return nil, os.ErrPermission
+	}
+
+	// Objects are replaced whole; there is no appending to them.
+	if r.Pflags().Append {
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+
+	parent, err := fs.names.Stat(fs.ctx, path.Dir("/"+key))
+	if err != nil {
+		return nil, err
+	}
+	if !parent.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	if info, err := fs.names.Stat(fs.ctx, key); err == nil && info.IsDir() {
+		return nil, errIsFolder
+	}
+
+	return newUpload(fs, key), nil
+}
+
+func (fs *fileSystem) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		// Objects have no permissions or owners, and their times are set
+		// by the storage.
+		return nil
+	}
+
+	if fs.readOnly {
+		return os.ErrPermission
+	}
+
+	switch r.Method {
+	case "Mkdir":
+		return fs.names.Mkdir(fs.ctx, r.Filepath, 0)
+	case "Rename":
+		if _, err := fs.names.Stat(fs.ctx, r.Target); err == nil {
+			return os.ErrExist
+		}
+		return fs.names.Rename(fs.ctx, r.Filepath, r.Target)
+	case "Remove":
+		info, err := fs.names.Stat(fs.ctx, r.Filepath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return errIsFolder
+		}
+		return storageError(fs.storage.Delete(fs.ctx, fs.userId, objectKey(r.Filepath)))
+	case "Rmdir":
+		return fs.removeFolder(objectKey(r.Filepath))
+	}
+
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// PosixRename replaces the target, where a plain SFTP rename refuses to.
+func (fs *fileSystem) PosixRename(r *sftp.Request) error {
+	if fs.readOnly {
+		return os.ErrPermission
+	}
+	return fs.names.Rename(fs.ctx, r.Filepath, r.Target)
+}
+
+// removeFolder deletes an empty folder, that is its marker object.
+func (fs *fileSystem) removeFolder(key string) error {
+	if key == "" {
+		return os.ErrPermission
+	}
+
+	info, err := fs.names.Stat(fs.ctx, key)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errNotFolder
+	}
+
+	objects, prefixes, err := fs.storage.List(fs.ctx, fs.userId, key+"/")
+	if err != nil {
+		return storageError(err)
+	}
+	if len(prefixes) > 0 || len(objects) > 1 || (len(objects) == 1 && objects[0].Key != key+"/") {
+		return errFolderInUse
+	}
+
+	return storageError(fs.storage.Delete(fs.ctx, fs.userId, key+"/"))
+}
+
+func (fs *fileSystem) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		folder, err := fs.names.OpenFile(fs.ctx, r.Filepath, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer folder.Close()
+
+		info, err := folder.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, errNotFolder
+		}
+
+		entries, err := folder.Readdir(0)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt(entries), nil
+	case "Stat":
+		info, err := fs.names.Stat(fs.ctx, r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(entries []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(entries, l[offset:])
+	if n < len(entries) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// storageError turns missing objects into os.ErrNotExist, which SFTP
+// answers with "no such file".
+func storageError(err error) error {
+	if errors.Is(err, usecase.ErrObjectNotFound) || errors.Is(err, usecase.ErrBucketNotFound) {
+		return os.ErrNotExist
+	}
+	return err
+}
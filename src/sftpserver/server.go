@@ -0,0 +1,142 @@
+// Package sftpserver is an embedded SFTP server. Users log in with their
+// email and either their password or one of their registered SSH keys, and
+// are jailed to their own bucket: "/" is the root of the bucket, folders
+// are prefixes as over WebDAV, and file contents are streamed through the
+// storage layer.
+package sftpserver
+
+import (
+	"cloud_file_manager/src/dav"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Authenticator logs SSH users in, returning their user id, and tells
+// whether they confirmed their email, without which they may only read.
+// *usecase.SftpAuthUsecase implements it.
+type Authenticator interface {
+	PasswordLogin(ip string, email string, password string) (int, error)
+	PublicKeyLogin(email string, publicKey ssh.PublicKey) (int, error)
+	IsEmailVerified(userId int) (bool, error)
+}
+
+type Server struct {
+	storage dav.Storage
+	auth    Authenticator
+	config  *ssh.ServerConfig
+}
+
+func NewServer(storage dav.Storage, auth Authenticator, hostKey ssh.Signer) *Server {
+	config := &ssh.ServerConfig{
+		MaxAuthTries:  3,
+		ServerVersion: "SSH-2.0-cloud-file-manager",
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+			return permissions(auth.PasswordLogin(ip, conn.User(), string(password)))
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, publicKey ssh.PublicKey) (*ssh.Permissions, error) {
+			return permissions(auth.PublicKeyLogin(conn.User(), publicKey))
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	return &Server{storage: storage, auth: auth, config: config}
+}
+
+// permissions carries the id of the logged in user to the connection.
+func permissions(userId int, err error) (*ssh.Permissions, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.Permissions{Extensions: map[string]string{"userId": strconv.Itoa(userId)}}, nil
+}
+
+// Serve accepts connections until the listener is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	// Failed handshakes and logins are routine on a public port and are
+	// not logged; the login guard already counts wrong passwords.
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	userId, _ := strconv.Atoi(serverConn.Permissions.Extensions["userId"])
+	verified, err := s.auth.IsEmailVerified(userId)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "apenas sessões SFTP são aceitas")
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go s.handleSession(ctx, channel, channelRequests, userId, !verified)
+	}
+}
+
+// handleSession waits for the sftp subsystem request; shells, commands
+// and port forwarding are refused.
+func (s *Server) handleSession(ctx context.Context, channel ssh.Channel, requests <-chan *ssh.Request, userId int, readOnly bool) {
+	defer channel.Close()
+
+	for request := range requests {
+		if request.Type != "subsystem" || subsystemName(request.Payload) != "sftp" {
+			request.Reply(false, nil)
+			continue
+		}
+		request.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		server := sftp.NewRequestServer(channel, newFileSystem(ctx, s.storage, userId, readOnly).handlers())
+		if err := server.Serve(); err != nil && err != io.EOF {
+			fmt.Println(err)
+		}
+		server.Close()
+		return
+	}
+}
+
+// subsystemName reads the SSH string the subsystem request carries.
+func subsystemName(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	length := binary.BigEndian.Uint32(payload)
+	if uint64(len(payload)-4) < uint64(length) {
+		return ""
+	}
+	return string(payload[4 : 4+length])
+}
@@ -0,0 +1,224 @@
+package sftpserver
+
+import (
+	"bytes"
+	"cloud_file_manager/src/storagetest"
+	"cloud_file_manager/src/usecase"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type fakeAuthenticator struct {
+	publicKey  ssh.PublicKey
+	unverified bool
+}
+
+func (f *fakeAuthenticator) IsEmailVerified(userId int) (bool, error) {
+	return !f.unverified, nil
+}
+
+func (f *fakeAuthenticator) PasswordLogin(ip string, email string, password string) (int, error) {
+	if email == "ana@example.com" && password == "senha-certa" {
+		return 7, nil
+	}
+	return 0, usecase.ErrInvalidCredentials
+}
+
+func (f *fakeAuthenticator) PublicKeyLogin(email string, publicKey ssh.PublicKey) (int, error) {
+	if email == "ana@example.com" && bytes.Equal(publicKey.Marshal(), f.publicKey.Marshal()) {
+		return 7, nil
+	}
+	return 0, usecase.ErrInvalidCredentials
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("não foi possível criar a chave: %v", err)
+	}
+	return signer
+}
+
+// startServer serves storage on a local port and returns its address and
+// the key the test user logs in with.
+func startServer(t *testing.T, storage *storagetest.Storage) (string, ssh.Signer) {
+	t.Helper()
+	return startServerFor(t, storage, &fakeAuthenticator{})
+}
+
+func startServerFor(t *testing.T, storage *storagetest.Storage, auth *fakeAuthenticator) (string, ssh.Signer) {
+	t.Helper()
+
+	userKey := newSigner(t)
+	auth.publicKey = userKey.PublicKey()
+	server := NewServer(storage, auth, newSigner(t))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("não foi possível escutar: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+
+	return listener.Addr().String(), userKey
+}
+
+func dial(t *testing.T, addr string, auth ssh.AuthMethod) (*sftp.Client, error) {
+	t.Helper()
+
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "ana@example.com",
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, nil
+}
+
+func TestSftpAuthenticatesWithPasswordOrKey(t *testing.T) {
+	storage := storagetest.New(nil)
+	addr, userKey := startServer(t, storage)
+
+	if _, err := dial(t, addr, ssh.Password("senha-errada")); err == nil {
+		t.Fatalf("a senha errada não deveria entrar")
+	}
+	if _, err := dial(t, addr, ssh.PublicKeys(newSigner(t))); err == nil {
+		t.Fatalf("uma chave não cadastrada não deveria entrar")
+	}
+
+	if _, err := dial(t, addr, ssh.Password("senha-certa")); err != nil {
+		t.Fatalf("a senha certa deveria entrar: %v", err)
+	}
+	if _, err := dial(t, addr, ssh.PublicKeys(userKey)); err != nil {
+		t.Fatalf("a chave cadastrada deveria entrar: %v", err)
+	}
+}
+
+func TestSftpStreamsLargeFilesBothWays(t *testing.T) {
+	storage := storagetest.New(nil)
+	addr, userKey := startServer(t, storage)
+	client, err := dial(t, addr, ssh.PublicKeys(userKey))
+	if err != nil {
+		t.Fatalf("não foi possível entrar: %v", err)
+	}
+
+	content := make([]byte, 5<<20+123)
+	rand.Read(content)
+
+	file, err := client.Create("/grande.bin")
+	if err != nil {
+		t.Fatalf("não foi possível criar o arquivo: %v", err)
+	}
+	// ReadFrom sends many writes concurrently, as sftp clients do.
+	if _, err := file.ReadFrom(bytes.NewReader(content)); err != nil {
+		t.Fatalf("o envio deveria funcionar: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("o fechamento deveria gravar o objeto: %v", err)
+	}
+	if !bytes.Equal(storage.Objects["grande.bin"], content) {
+		t.Fatalf("o objeto gravado difere do enviado (%d bytes)", len(storage.Objects["grande.bin"]))
+	}
+
+	file, err = client.Open("/grande.bin")
+	if err != nil {
+		t.Fatalf("não foi possível abrir o arquivo: %v", err)
+	}
+	defer file.Close()
+
+	var downloaded bytes.Buffer
+	if _, err := file.WriteTo(&downloaded); err != nil {
+		t.Fatalf("a leitura deveria funcionar: %v", err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), content) {
+		t.Fatalf("o conteúdo lido difere do objeto")
+	}
+	if storage.Reads != 1 {
+		t.Fatalf("a leitura deveria usar um único download, usou %d", storage.Reads)
+	}
+}
+
+func TestSftpFoldersStayInsideTheBucket(t *testing.T) {
+	storage := storagetest.New(map[string][]byte{"a.txt": []byte("a")})
+	addr, _ := startServer(t, storage)
+	client, err := dial(t, addr, ssh.Password("senha-certa"))
+	if err != nil {
+		t.Fatalf("não foi possível entrar: %v", err)
+	}
+
+	if err := client.Mkdir("/docs"); err != nil {
+		t.Fatalf("a pasta deveria ser criada: %v", err)
+	}
+	if err := client.Rename("/a.txt", "/docs/a.txt"); err != nil {
+		t.Fatalf("o arquivo deveria ser movido: %v", err)
+	}
+	if _, ok := storage.Objects["docs/a.txt"]; !ok {
+		t.Fatalf("o arquivo deveria estar na pasta, veio %v", storage.Objects)
+	}
+
+	entries, err := client.ReadDir("/../..")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "docs" || !entries[0].IsDir() {
+		t.Fatalf("a raiz deveria ser o bucket, com a pasta docs, veio %v, %v", entries, err)
+	}
+
+	if err := client.RemoveDirectory("/docs"); err == nil {
+		t.Fatalf("uma pasta com arquivos não deveria ser removida")
+	}
+	if err := client.Remove("/docs/a.txt"); err != nil {
+		t.Fatalf("o arquivo deveria ser removido: %v", err)
+	}
+	if err := client.RemoveDirectory("/docs"); err != nil {
+		t.Fatalf("a pasta vazia deveria ser removida: %v", err)
+	}
+	if len(storage.Objects) != 0 {
+		t.Fatalf("o bucket deveria estar vazio, veio %v", storage.Objects)
+	}
+
+	if _, err := client.Stat("/nada.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("esperava arquivo inexistente, veio %v", err)
+	}
+}
+
+func TestSftpIsReadOnlyUntilEmailIsVerified(t *testing.T) {
+	storage := storagetest.New(map[string][]byte{"a.txt": []byte("a")})
+	addr, _ := startServerFor(t, storage, &fakeAuthenticator{unverified: true})
+	client, err := dial(t, addr, ssh.Password("senha-certa"))
+	if err != nil {
+		t.Fatalf("não foi possível entrar: %v", err)
+	}
+
+	if _, err := client.Stat("/a.txt"); err != nil {
+		t.Fatalf("a leitura deveria funcionar: %v", err)
+	}
+	if _, err := client.Create("/b.txt"); err == nil {
+		t.Fatalf("o envio não deveria ser aceito sem o email confirmado")
+	}
+	if err := client.Remove("/a.txt"); err == nil {
+		t.Fatalf("a remoção não deveria ser aceita sem o email confirmado")
+	}
+	if err := client.Mkdir("/docs"); err == nil {
+		t.Fatalf("a criação de pastas não deveria ser aceita sem o email confirmado")
+	}
+	if len(storage.Objects) != 1 {
+		t.Fatalf("o bucket não deveria mudar, veio %v", storage.Objects)
+	}
+}
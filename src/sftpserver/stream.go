@@ -0,0 +1,245 @@
+package sftpserver
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+)
+
+const (
+	// readWindow is how much of a download is kept to answer reads that
+	// arrive late. SFTP clients keep up to 2 MiB of reads in flight and
+	// the server runs them concurrently, so they reach us out of order.
+	readWindow = 4 << 20
+	readChunk  = 32 << 10
+
+	// maxPendingWrite bounds the early writes an upload holds in memory
+	// while it waits for the ones before them.
+	maxPendingWrite = 8 << 20
+)
+
+// streamReader answers ReadAt from a single sequential download of the
+// object. Only a read far from the current position restarts the download
+// there.
+type streamReader struct {
+	mu     sync.Mutex
+	fs     *fileSystem
+	key    string
+	size   int64
+	body   io.ReadCloser
+	base   int64
+	window []byte
+}
+
+func (sr *streamReader) ReadAt(p []byte, offset int64) (int, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if offset >= sr.size {
+		return 0, io.EOF
+	}
+	end := min(offset+int64(len(p)), sr.size)
+
+	if sr.body == nil || offset < sr.base || offset > sr.base+int64(len(sr.window))+readWindow {
+		start := offset
+		if sr.body == nil && offset < readWindow {
+			// The first reads of a download race each other too.
+			start = 0
+		}
+		if err := sr.open(start); err != nil {
+			return 0, err
+		}
+	}
+
+	for sr.base+int64(len(sr.window)) < end {
+		if err := sr.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.window[offset-sr.base:end-sr.base])
+	sr.trim()
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (sr *streamReader) open(offset int64) error {
+	if sr.body != nil {
+		sr.body.Close()
+		sr.body = nil
+	}
+
+	body, err := sr.fs.storage.Read(sr.fs.ctx, sr.fs.userId, sr.key, offset)
+	if err != nil {
+		return storageError(err)
+	}
+
+	sr.body = body
+	sr.base = offset
+	sr.window = sr.window[:0]
+	return nil
+}
+
+// fill appends the next bytes of the download to the window.
+func (sr *streamReader) fill() error {
+	sr.window = slices.Grow(sr.window, readChunk)
+	n, err := sr.body.Read(sr.window[len(sr.window):cap(sr.window)])
+	sr.window = sr.window[:len(sr.window)+n]
+
+	if n > 0 {
+		return nil
+	}
+	if err == io.EOF {
+		// The object is shorter than when it was opened.
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// trim forgets the oldest bytes. It waits for a quarter more than the
+// window, so they are not moved on every read.
+func (sr *streamReader) trim() {
+	if len(sr.window) <= readWindow+readWindow/4 {
+		return
+	}
+
+	drop := len(sr.window) - readWindow
+	sr.window = append(sr.window[:0], sr.window[drop:]...)
+	sr.base += int64(drop)
+}
+
+func (sr *streamReader) Close() error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if sr.body != nil {
+		return sr.body.Close()
+	}
+	return nil
+}
+
+// upload streams the writes of an open file into the object, which is
+// stored when the file is closed. Writes that arrive ahead of their turn
+// wait in memory; rewriting data already sent is not possible.
+type upload struct {
+	mu          sync.Mutex
+	pipe        *io.PipeWriter
+	done        chan error
+	offset      int64
+	pending     map[int64][]byte
+	pendingSize int
+	err         error
+	closed      bool
+}
+
+func newUpload(fs *fileSystem, key string) *upload {
+	reader, writer := io.Pipe()
+	u := &upload{pipe: writer, done: make(chan error, 1), pending: map[int64][]byte{}}
+
+	go func() {
+		_, err := fs.storage.Write(fs.ctx, fs.userId, key, reader)
+		reader.CloseWithError(err)
+		u.done <- err
+	}()
+
+	return u
+}
+
+func (u *upload) WriteAt(p []byte, offset int64) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.err != nil {
+		return 0, u.err
+	}
+
+	if offset < u.offset {
+		u.fail(errRandomWrite)
+		return 0, u.err
+	}
+
+	if offset > u.offset {
+		if _, ok := u.pending[offset]; ok || u.pendingSize+len(p) > maxPendingWrite {
+			u.fail(errRandomWrite)
+			return 0, u.err
+		}
+		u.pending[offset] = bytes.Clone(p)
+		u.pendingSize += len(p)
+		return len(p), nil
+	}
+
+	if err := u.write(p); err != nil {
+		return 0, err
+	}
+
+	for {
+		data, ok := u.pending[u.offset]
+		if !ok {
+			break
+		}
+		delete(u.pending, u.offset)
+		u.pendingSize -= len(data)
+
+		if err := u.write(data); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (u *upload) write(p []byte) error {
+	if _, err := u.pipe.Write(p); err != nil {
+		u.err = err
+		return err
+	}
+	u.offset += int64(len(p))
+	return nil
+}
+
+// fail abandons the upload, so the object is left as it was.
+func (u *upload) fail(err error) {
+	u.err = err
+	u.pipe.CloseWithError(err)
+}
+
+// TransferError is called when the connection drops with the file still
+// open. The upload is abandoned instead of storing a truncated object.
+func (u *upload) TransferError(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.err == nil {
+		u.fail(err)
+	}
+}
+
+func (u *upload) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.closed {
+		return u.err
+	}
+	u.closed = true
+
+	if u.err == nil && len(u.pending) > 0 {
+		u.fail(errMissingParts)
+	}
+	if u.err == nil {
+		u.pipe.Close()
+	}
+
+	err := <-u.done
+	if u.err != nil {
+		return u.err
+	}
+	if err != nil {
+		u.err = storageError(err)
+	}
+	return u.err
+}
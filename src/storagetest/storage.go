@@ -0,0 +1,279 @@
+// Package storagetest provides an in-memory bucket for testing the WebDAV,
+// S3 and SFTP front ends without AWS.
+package storagetest
+
+import (
+	"bytes"
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/usecase"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LastModified is the modification time reported for every object.
+var LastModified = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// Storage keeps one user's bucket in memory. It counts the downloads
+// opened, the server-side copies and the bytes streamed in, so tests can
+// tell streamed or copied transfers from round trips.
+type Storage struct {
+	// UserID, when not zero, is the only user the storage answers to; a
+	// call for anyone else panics.
+	UserID  int
+	Objects map[string][]byte
+	Reads   int
+	Copies  int
+	Written int
+
+	mu      sync.Mutex
+	uploads map[string]map[int32][]byte
+}
+
+func New(objects map[string][]byte) *Storage {
+	if objects == nil {
+		objects = map[string][]byte{}
+	}
+	return &Storage{Objects: objects, uploads: map[string]map[int32][]byte{}}
+}
+
+// ETag is the quoted MD5 S3 gives objects uploaded in one piece.
+func ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (s *Storage) Info(key string) models.ObjectInfo {
+	return models.ObjectInfo{
+		Key:          key,
+		Size:         int64(len(s.Objects[key])),
+		ETag:         ETag(s.Objects[key]),
+		LastModified: LastModified,
+	}
+}
+
+// Keys returns the keys under prefix in order.
+func (s *Storage) Keys(prefix string) []string {
+	var keys []string
+	for key := range s.Objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Storage) checkUser(userId int) {
+	if s.UserID != 0 && userId != s.UserID {
+		panic(fmt.Sprintf("unexpected user %d", userId))
+	}
+}
+
+func (s *Storage) List(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, []string, error) {
+	page, err := s.ListPage(ctx, userId, models.ListQuery{Prefix: prefix, Delimiter: "/"})
+	if err != nil {
+		return nil, nil, err
+	}
+	return page.Objects, page.Prefixes, nil
+}
+
+func (s *Storage) ListAll(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, error) {
+	page, err := s.ListPage(ctx, userId, models.ListQuery{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	return page.Objects, nil
+}
+
+func (s *Storage) ListPage(ctx context.Context, userId int, query models.ListQuery) (*models.ObjectPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	page := &models.ObjectPage{}
+	seen := map[string]bool{}
+	for _, key := range s.Keys(query.Prefix) {
+		if key <= query.StartAfter {
+			continue
+		}
+		if query.Delimiter != "" {
+			if i := strings.Index(strings.TrimPrefix(key, query.Prefix), query.Delimiter); i >= 0 {
+				common := key[:len(query.Prefix)+i+len(query.Delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					page.Prefixes = append(page.Prefixes, common)
+				}
+				continue
+			}
+		}
+		page.Objects = append(page.Objects, s.Info(key))
+	}
+	return page, nil
+}
+
+func (s *Storage) HasPrefix(ctx context.Context, userId int, prefix string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+	return len(s.Keys(prefix)) > 0, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, userId int, key string) (*models.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	if _, ok := s.Objects[key]; !ok {
+		return nil, usecase.ErrObjectNotFound
+	}
+	info := s.Info(key)
+	return &info, nil
+}
+
+func (s *Storage) Read(ctx context.Context, userId int, key string, offset int64) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	data, ok := s.Objects[key]
+	if !ok {
+		return nil, usecase.ErrObjectNotFound
+	}
+	s.Reads++
+	return io.NopCloser(bytes.NewReader(data[offset:])), nil
+}
+
+func (s *Storage) ReadRange(ctx context.Context, userId int, key string, byteRange string) (*models.ObjectStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	data, ok := s.Objects[key]
+	if !ok {
+		return nil, usecase.ErrObjectNotFound
+	}
+
+	stream := &models.ObjectStream{Info: s.Info(key)}
+	if byteRange != "" {
+		var start, end int
+		if _, err := fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end); err != nil || end >= len(data) {
+			return nil, usecase.ErrInvalidRange
+		}
+		stream.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end, len(data))
+		data = data[start : end+1]
+	}
+	s.Reads++
+	stream.Body = io.NopCloser(bytes.NewReader(data))
+	stream.Info.Size = int64(len(data))
+	return stream, nil
+}
+
+func (s *Storage) Write(ctx context.Context, userId int, key string, body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+	s.Objects[key] = data
+	s.Written += len(data)
+	return ETag(data), nil
+}
+
+func (s *Storage) Copy(ctx context.Context, userId int, sourceKey string, destinationKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	data, ok := s.Objects[sourceKey]
+	if !ok {
+		return usecase.ErrObjectNotFound
+	}
+	s.Objects[destinationKey] = data
+	s.Copies++
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, userId int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+	delete(s.Objects, key)
+	return nil
+}
+
+func (s *Storage) StartMultipart(ctx context.Context, userId int, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	uploadId := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+	s.uploads[uploadId] = map[int32][]byte{}
+	return uploadId, nil
+}
+
+func (s *Storage) UploadPart(ctx context.Context, userId int, key string, uploadId string, partNumber int32, body io.Reader, size int64) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) != size {
+		return "", fmt.Errorf("parte com %d bytes, esperava %d", len(data), size)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	parts, ok := s.uploads[uploadId]
+	if !ok {
+		return "", usecase.ErrUploadNotFound
+	}
+	parts[partNumber] = data
+	return ETag(data), nil
+}
+
+func (s *Storage) CompleteMultipart(ctx context.Context, userId int, key string, uploadId string, parts []dto.CompletedPartDto) (*models.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	uploaded, ok := s.uploads[uploadId]
+	if !ok {
+		return nil, usecase.ErrUploadNotFound
+	}
+
+	var data []byte
+	for _, part := range parts {
+		if ETag(uploaded[part.PartNumber]) != part.ETag {
+			return nil, usecase.ErrInvalidPart
+		}
+		data = append(data, uploaded[part.PartNumber]...)
+	}
+	s.Objects[key] = data
+	delete(s.uploads, uploadId)
+	info := s.Info(key)
+	return &info, nil
+}
+
+func (s *Storage) AbortMultipart(ctx context.Context, userId int, key string, uploadId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkUser(userId)
+
+	if _, ok := s.uploads[uploadId]; !ok {
+		return usecase.ErrUploadNotFound
+	}
+	delete(s.uploads, uploadId)
+	return nil
+}
@@ -0,0 +1,56 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret and expected values from RFC 6238, appendix B, truncated to 6 digits.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCodeRFCVectors(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range cases {
+		code, err := GenerateCode(rfcSecret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+		if code != expected {
+			t.Errorf("em %d esperava %s, veio %s", unix, expected, code)
+		}
+	}
+}
+
+func TestValidateAcceptsSkewAndRejectsOldCodes(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	previous, _ := GenerateCode(rfcSecret, Step(now)-1)
+	step, ok := Validate(rfcSecret, previous, now)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("esperava aceitar código do período anterior")
+	}
+
+	old, _ := GenerateCode(rfcSecret, Step(now)-3)
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Fatalf("não deveria aceitar código antigo")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Cloud File Manager", "ana@example.com", "ABC")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Cloud%20File%20Manager:ana@example.com?") {
+		t.Fatalf("uri inesperada %s", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Cloud+File+Manager") {
+		t.Fatalf("uri sem parâmetros esperados %s", uri)
+	}
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AccessKeyIdPrefix starts every gateway access key id, which like AWS
+// ones are 20 upper-case characters.
+const AccessKeyIdPrefix = "CFMK"
+
+var ErrInvalidAccessKey = errors.New("informe um nome e ao menos um escopo válido (read-only, upload, admin)")
+
+type AccessKeyUsecase struct {
+	repository AccessKeyRepository
+}
+
+func NewAccessKeyUsecase(repo AccessKeyRepository) AccessKeyUsecase {
+	return AccessKeyUsecase{
+		repository: repo,
+	}
+}
+
+// CreateAccessKey issues an S3 key pair for the gateway. The secret is
+// returned once, here.
+func (au *AccessKeyUsecase) CreateAccessKey(userId int, input dto.CreateAccessKeyDto) (*dto.CreatedAccessKeyDto, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(input.Scopes) == 0 {
+		return nil, ErrInvalidAccessKey
+	}
+
+	allowed := []string{models.ApiKeyScopeReadOnly, models.ApiKeyScopeUpload, models.ApiKeyScopeAdmin}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, ErrInvalidAccessKey
+		}
+	}
+
+	buffer := make([]byte, 10)
+	if _, err := rand.Read(buffer); err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateToken(30)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey := models.AccessKey{
+		UserID:      userId,
+		Name:        name,
+		AccessKeyID: AccessKeyIdPrefix + base32.StdEncoding.EncodeToString(buffer),
+		Scopes:      slices.Compact(slices.Sorted(slices.Values(input.Scopes))),
+	}
+
+	accessKey.ID, accessKey.CreatedAt, err = au.repository.CreateAccessKey(accessKey, secret)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &dto.CreatedAccessKeyDto{
+		AccessKey:       accessKey,
+		SecretAccessKey: secret,
+	}, nil
+}
+
+func (au *AccessKeyUsecase) GetAccessKeys(userId int) ([]models.AccessKey, error) {
+	accessKeys, err := au.repository.GetAccessKeysByUser(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return accessKeys, nil
+}
+
+func (au *AccessKeyUsecase) RevokeAccessKey(userId int, accessKeyId int) (bool, error) {
+	revoked, err := au.repository.RevokeAccessKey(userId, accessKeyId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// ResolveAccessKey returns the active key with the given id and its
+// secret, or nil when there is none.
+func (au *AccessKeyUsecase) ResolveAccessKey(accessKeyId string) (*models.AccessKey, string, error) {
+	if !strings.HasPrefix(accessKeyId, AccessKeyIdPrefix) {
+		return nil, "", nil
+	}
+
+	accessKey, secret, err := au.repository.UseAccessKey(accessKeyId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, "", err
+	}
+
+	return accessKey, secret, nil
+}
@@ -0,0 +1,352 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWrongPassword  = errors.New("senha atual incorreta")
+	ErrEmailTaken     = errors.New("email já cadastrado")
+	ErrInvalidProfile = errors.New("nome e email precisam ser válidos")
+	ErrUserNotFound   = errors.New("usuário não encontrado")
+)
+
+const JobDeleteAccount = "account.delete"
+
+type accountDeletionPayload struct {
+	DeletionID int `json:"deletionId"`
+	UserID     int `json:"userId"`
+}
+
+type AccountUsecase struct {
+	userRepository            UserRepository
+	sessionRepository         SessionRepository
+	apiKeyRepository          ApiKeyRepository
+	accessKeyRepository       AccessKeyRepository
+	sshKeyRepository          SshKeyRepository
+	tokenRepository           UserTokenRepository
+	mailer                    Mailer
+	awsService                AwsClient
+	bucketRepository          UserBucketRepository
+	thumbnailBucket           string
+	accountDeletionRepository AccountDeletionRepository
+	jobQueue                  JobQueue
+}
+
+func NewAccountUsecase(
+	userRepo UserRepository,
+	sessionRepo SessionRepository,
+	apiKeyRepo ApiKeyRepository,
+	accessKeyRepo AccessKeyRepository,
+	sshKeyRepo SshKeyRepository,
+	tokenRepo UserTokenRepository,
+	mailer Mailer,
+	awsService AwsClient,
+	bucketRepo UserBucketRepository,
+	thumbnailBucket string,
+	deletionRepo AccountDeletionRepository,
+	jobQueue JobQueue,
+) AccountUsecase {
+	return AccountUsecase{
+		userRepository:            userRepo,
+		sessionRepository:         sessionRepo,
+		apiKeyRepository:          apiKeyRepo,
+		accessKeyRepository:       accessKeyRepo,
+		sshKeyRepository:          sshKeyRepo,
+		tokenRepository:           tokenRepo,
+		mailer:                    mailer,
+		awsService:                awsService,
+		bucketRepository:          bucketRepo,
+		thumbnailBucket:           thumbnailBucket,
+		accountDeletionRepository: deletionRepo,
+		jobQueue:                  jobQueue,
+	}
+}
+
+// UpdateProfile changes name and/or email. A new email goes back to
+// unverified and gets a fresh verification link.
+func (au *AccountUsecase) UpdateProfile(userId int, input dto.UpdateProfileDto) (*dto.PublicUserDto, error) {
+	user, err := au.userRepository.GetUserById(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	name := user.Name
+	if input.Name != nil {
+		name = strings.TrimSpace(*input.Name)
+	}
+
+	email := user.Email
+	if input.Email != nil {
+		email = strings.TrimSpace(*input.Email)
+	}
+
+	if name == "" || !strings.Contains(email, "@") {
+		return nil, ErrInvalidProfile
+	}
+
+	emailChanged := !strings.EqualFold(email, user.Email)
+	if emailChanged {
+		existing, err := au.userRepository.GetUserByEmail(email)
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+
+		if existing != nil {
+			return nil, ErrEmailTaken
+		}
+	}
+
+	err = au.userRepository.UpdateProfile(userId, name, email)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	user.Name = name
+	user.Email = email
+	if emailChanged {
+		err = sendVerificationEmail(au.tokenRepository, au.mailer, *user)
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
+		}
+	}
+
+	publicUser := dto.ToPublicUser(*user)
+	return &publicUser, nil
+}
+
+// ChangePassword requires the current password and signs out every other
+// session, keeping the one that made the change.
+func (au *AccountUsecase) ChangePassword(userId int, currentSessionId int, input dto.ChangePasswordDto) error {
+	if len(input.NewPassword) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	err := au.checkPassword(userId, input.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	err = au.userRepository.UpdatePassword(userId, input.NewPassword)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return au.sessionRepository.RevokeOtherSessions(userId, currentSessionId)
+}
+
+// DeleteAccount checks the password, cuts off access right away and queues
+// the deletion. Progress is tracked by the returned AccountDeletion.
+func (au *AccountUsecase) DeleteAccount(userId int, password string) (*models.AccountDeletion, error) {
+	err := au.checkPassword(userId, password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = au.revokeAccess(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	deletion, err := au.accountDeletionRepository.CreateAccountDeletion(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	_, err = au.jobQueue.Enqueue(JobDeleteAccount, accountDeletionPayload{
+		DeletionID: deletion.ID,
+		UserID:     userId,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// HandleDeletionJob makes one attempt at deleting the account. The deletion
+// stays pending while the job still has retries left.
+func (au *AccountUsecase) HandleDeletionJob(ctx context.Context, job models.Job) error {
+	var payload accountDeletionPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	deletion := models.AccountDeletion{ID: payload.DeletionID, UserID: payload.UserID}
+	if jobs.IsLastAttempt(job) {
+		return au.RunAccountDeletion(deletion)
+	}
+
+	err := au.deleteAccountData(deletion.UserID)
+	if err != nil {
+		return err
+	}
+
+	return au.finishAccountDeletion(deletion.ID, nil)
+}
+
+// RunAccountDeletion empties and deletes the user's buckets, removes their
+// thumbnails, ends any remaining access and soft-deletes the user,
+// recording the outcome on the deletion job.
+func (au *AccountUsecase) RunAccountDeletion(deletion models.AccountDeletion) error {
+	err := au.deleteAccountData(deletion.UserID)
+	return au.finishAccountDeletion(deletion.ID, err)
+}
+
+func (au *AccountUsecase) finishAccountDeletion(id int, err error) error {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+
+	finishErr := au.accountDeletionRepository.FinishAccountDeletion(id, message)
+	if finishErr != nil {
+		fmt.Println(finishErr)
+	}
+
+	return err
+}
+
+func (au *AccountUsecase) GetAccountDeletion(id int) (*models.AccountDeletion, error) {
+	return au.accountDeletionRepository.GetAccountDeletion(id)
+}
+
+func (au *AccountUsecase) deleteAccountData(userId int) error {
+	ctx := context.Background()
+
+	buckets, err := au.userBucketNames(ctx, userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	for _, bucket := range buckets {
+		err = au.awsService.EmptyBucket(ctx, bucket)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		err = au.awsService.DeleteBucket(ctx, bucket)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+	}
+
+	// Thumbnails and transformed images live under the user's id in a
+	// bucket shared by everyone.
+	if au.thumbnailBucket != "" {
+		err = au.awsService.DeletePrefix(ctx, au.thumbnailBucket, strconv.Itoa(userId)+"/")
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+	}
+
+	// Access was revoked when the deletion was requested; do it again in
+	// case the user signed in while the buckets were being emptied.
+	err = au.revokeAccess(userId)
+	if err != nil {
+		return err
+	}
+
+	return au.userRepository.SoftDeleteUser(userId)
+}
+
+func (au *AccountUsecase) revokeAccess(userId int) error {
+	err := au.sessionRepository.RevokeAllSessions(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	err = au.apiKeyRepository.RevokeAllApiKeys(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	err = au.accessKeyRepository.RevokeAllAccessKeys(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	err = au.sshKeyRepository.DeleteAllSshKeys(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (au *AccountUsecase) checkPassword(userId int, password string) error {
+	user, err := au.userRepository.GetUserById(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if user == nil {
+		return ErrWrongPassword
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
+
+// userBucketNames lists the buckets of the user that still exist: the one
+// provisioned at signup and those recorded when created through
+// POST /aws/bucket. Nothing else is ever taken as the user's.
+func (au *AccountUsecase) userBucketNames(ctx context.Context, userId int) ([]string, error) {
+	created, err := au.bucketRepository.GetUserBuckets(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := map[string]bool{userBucketName(userId): true}
+	for _, name := range created {
+		owned[name] = true
+	}
+
+	buckets, err := au.awsService.ListBuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, bucket := range buckets {
+		if bucket.Name != nil && owned[*bucket.Name] {
+			names = append(names, *bucket.Name)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,257 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeAccountDeletionRepo struct {
+	finished map[int]string
+}
+
+func (f *fakeAccountDeletionRepo) CreateAccountDeletion(userId int) (*models.AccountDeletion, error) {
+	return &models.AccountDeletion{ID: 1, UserID: userId, Status: models.AccountDeletionPending}, nil
+}
+
+func (f *fakeAccountDeletionRepo) GetAccountDeletion(int) (*models.AccountDeletion, error) {
+	panic("GetAccountDeletion not implemented")
+}
+
+func (f *fakeAccountDeletionRepo) FinishAccountDeletion(id int, message string) error {
+	f.finished[id] = message
+	return nil
+}
+
+type fakeUserBucketRepo struct {
+	buckets map[int][]string
+}
+
+func (f *fakeUserBucketRepo) AddUserBucket(userId int, bucketName string) error {
+	if f.buckets == nil {
+		f.buckets = map[int][]string{}
+	}
+	f.buckets[userId] = append(f.buckets[userId], bucketName)
+	return nil
+}
+
+func (f *fakeUserBucketRepo) GetUserBuckets(userId int) ([]string, error) {
+	return f.buckets[userId], nil
+}
+
+func userWithPassword(t *testing.T, password string) *models.User {
+	t.Helper()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("falha ao gerar hash: %v", err)
+	}
+	return &models.User{ID: 7, Name: "Ana", Email: "ana@example.com", Password: string(hashed)}
+}
+
+func TestAccountUsecaseUpdateProfileChangedEmailSendsVerification(t *testing.T) {
+	var updated string
+	repo := &fakeUserRepo{
+		getUserByIDFn: func(int) (*models.User, error) {
+			return &models.User{ID: 7, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+		getByEmailFn: func(string) (*models.User, error) {
+			return nil, nil
+		},
+		updateProfile: func(id int, name string, email string) error {
+			updated = name + "|" + email
+			return nil
+		},
+	}
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(int, string, string, time.Time) error { return nil },
+	}
+	mailer := &fakeMailer{}
+
+	usecase := NewAccountUsecase(repo, &fakeSessionRepo{}, &fakeApiKeyRepo{}, &fakeAccessKeyRepo{}, &fakeSshKeyRepo{}, tokens, mailer, &fakeAwsClient{}, &fakeUserBucketRepo{}, "", &fakeAccountDeletionRepo{}, &fakeJobQueue{})
+
+	email := " nova@example.com "
+	user, err := usecase.UpdateProfile(7, dto.UpdateProfileDto{Email: &email})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if updated != "Ana|nova@example.com" || user.Email != "nova@example.com" {
+		t.Fatalf("atualização inesperada %q %#v", updated, user)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("esperava email de verificação para o novo endereço")
+	}
+}
+
+func TestAccountUsecaseUpdateProfileEmailTaken(t *testing.T) {
+	repo := &fakeUserRepo{
+		getUserByIDFn: func(int) (*models.User, error) {
+			return &models.User{ID: 7, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+		getByEmailFn: func(string) (*models.User, error) {
+			return &models.User{ID: 8}, nil
+		},
+	}
+
+	usecase := NewAccountUsecase(repo, &fakeSessionRepo{}, &fakeApiKeyRepo{}, &fakeAccessKeyRepo{}, &fakeSshKeyRepo{}, &fakeUserTokenRepo{}, &fakeMailer{}, &fakeAwsClient{}, &fakeUserBucketRepo{}, "", &fakeAccountDeletionRepo{}, &fakeJobQueue{})
+
+	email := "leo@example.com"
+	_, err := usecase.UpdateProfile(7, dto.UpdateProfileDto{Email: &email})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("esperava ErrEmailTaken, veio %v", err)
+	}
+}
+
+func TestAccountUsecaseChangePasswordRequiresCurrent(t *testing.T) {
+	user := userWithPassword(t, "senha-antiga")
+	var keptSession int
+	repo := &fakeUserRepo{
+		getUserByIDFn: func(int) (*models.User, error) { return user, nil },
+		updatePassFn:  func(int, string) error { return nil },
+	}
+	sessions := &fakeSessionRepo{
+		revokeOthersFn: func(userId int, currentSessionId int) error {
+			keptSession = currentSessionId
+			return nil
+		},
+	}
+
+	usecase := NewAccountUsecase(repo, sessions, &fakeApiKeyRepo{}, &fakeAccessKeyRepo{}, &fakeSshKeyRepo{}, &fakeUserTokenRepo{}, &fakeMailer{}, &fakeAwsClient{}, &fakeUserBucketRepo{}, "", &fakeAccountDeletionRepo{}, &fakeJobQueue{})
+
+	err := usecase.ChangePassword(7, 3, dto.ChangePasswordDto{CurrentPassword: "errada", NewPassword: "senha-nova"})
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("esperava ErrWrongPassword, veio %v", err)
+	}
+
+	err = usecase.ChangePassword(7, 3, dto.ChangePasswordDto{CurrentPassword: "senha-antiga", NewPassword: "senha-nova"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if keptSession != 3 {
+		t.Fatalf("esperava manter a sessão 3, veio %d", keptSession)
+	}
+}
+
+func TestAccountUsecaseRunAccountDeletion(t *testing.T) {
+	user := userWithPassword(t, "senha-certa")
+	var softDeleted bool
+	repo := &fakeUserRepo{
+		getUserByIDFn: func(int) (*models.User, error) { return user, nil },
+		softDeleteFn: func(id int) error {
+			softDeleted = id == 7
+			return nil
+		},
+	}
+	sessions := &fakeSessionRepo{revokeAllFn: func(int) error { return nil }}
+	apiKeys := &fakeApiKeyRepo{revokeAllFn: func(int) error { return nil }}
+	accessKeys := &fakeAccessKeyRepo{revokeAllFn: func(int) error { return nil }}
+	var sshKeysDeleted []int
+	sshKeys := &fakeSshKeyRepo{deleteAllFn: func(id int) error {
+		sshKeysDeleted = append(sshKeysDeleted, id)
+		return nil
+	}}
+
+	var emptied, deleted []string
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{
+				{Name: aws.String("myawss3bucket-90902222345-7")},
+				{Name: aws.String("fotos-7")},
+				{Name: aws.String("fotos-17")},
+				// Not created through the API, so not the user's.
+				{Name: aws.String("alheio-7")},
+			}, nil
+		},
+		emptyBucketFn: func(_ context.Context, bucket string) error {
+			emptied = append(emptied, bucket)
+			return nil
+		},
+		deleteBucketFn: func(_ context.Context, bucket string) error {
+			deleted = append(deleted, bucket)
+			return nil
+		},
+		deletePrefixFn: func(_ context.Context, bucket, prefix string) error {
+			deleted = append(deleted, bucket+"/"+prefix)
+			return nil
+		},
+	}
+	deletions := &fakeAccountDeletionRepo{finished: map[int]string{}}
+
+	queue := &fakeJobQueue{}
+
+	usecase := NewAccountUsecase(repo, sessions, apiKeys, accessKeys, sshKeys, &fakeUserTokenRepo{}, &fakeMailer{}, client, &fakeUserBucketRepo{buckets: map[int][]string{7: {"fotos-7"}}}, "miniaturas", deletions, queue)
+
+	if _, err := usecase.DeleteAccount(7, "errada"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("esperava ErrWrongPassword, veio %v", err)
+	}
+
+	deletion, err := usecase.DeleteAccount(7, "senha-certa")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if len(queue.jobs) != 1 || queue.jobs[0].Type != JobDeleteAccount {
+		t.Fatalf("esperava job de exclusão na fila, veio %v", queue.jobs)
+	}
+
+	if err := usecase.HandleDeletionJob(context.Background(), queue.jobs[0]); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(emptied) != 2 || len(deleted) != 3 || deleted[1] != "fotos-7" || deleted[2] != "miniaturas/7/" {
+		t.Fatalf("buckets inesperados: esvaziados %v, apagados %v", emptied, deleted)
+	}
+	if !softDeleted {
+		t.Fatalf("esperava exclusão lógica do usuário")
+	}
+	// Once when the deletion is requested and again when it runs.
+	if len(sshKeysDeleted) != 2 || sshKeysDeleted[0] != 7 {
+		t.Fatalf("esperava as chaves SSH removidas, veio %v", sshKeysDeleted)
+	}
+	if message, ok := deletions.finished[deletion.ID]; !ok || message != "" {
+		t.Fatalf("esperava exclusão concluída com sucesso, veio %q", message)
+	}
+}
+
+func TestAccountUsecaseDeletionJobRecordsFailureOnLastAttempt(t *testing.T) {
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String("fotos-7")}}, nil
+		},
+		emptyBucketFn: func(context.Context, string) error {
+			return errors.New("access denied")
+		},
+	}
+	deletions := &fakeAccountDeletionRepo{finished: map[int]string{}}
+
+	usecase := NewAccountUsecase(&fakeUserRepo{}, &fakeSessionRepo{}, &fakeApiKeyRepo{}, &fakeAccessKeyRepo{}, &fakeSshKeyRepo{}, &fakeUserTokenRepo{}, &fakeMailer{}, client, &fakeUserBucketRepo{buckets: map[int][]string{7: {"fotos-7"}}}, "", deletions, &fakeJobQueue{})
+
+	job := models.Job{
+		Type:        JobDeleteAccount,
+		Payload:     []byte(`{"deletionId":2,"userId":7}`),
+		Attempts:    1,
+		MaxAttempts: 3,
+	}
+
+	if err := usecase.HandleDeletionJob(context.Background(), job); err == nil {
+		t.Fatalf("esperava erro do AWS")
+	}
+	if _, ok := deletions.finished[2]; ok {
+		t.Fatalf("a exclusão deveria continuar pendente enquanto houver tentativas")
+	}
+
+	job.Attempts = 3
+	if err := usecase.HandleDeletionJob(context.Background(), job); err == nil {
+		t.Fatalf("esperava erro do AWS")
+	}
+	if deletions.finished[2] != "access denied" {
+		t.Fatalf("esperava falha registrada, veio %q", deletions.finished[2])
+	}
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultApiKeyDays = 90
+	maxApiKeyDays     = 365
+)
+
+var ErrInvalidApiKey = errors.New("informe um nome, ao menos um escopo válido (read-only, upload, admin) e validade de até 365 dias")
+
+type ApiKeyUsecase struct {
+	repository ApiKeyRepository
+}
+
+func NewApiKeyUsecase(repo ApiKeyRepository) ApiKeyUsecase {
+	return ApiKeyUsecase{
+		repository: repo,
+	}
+}
+
+// CreateApiKey generates a key of the form cfm_<prefix>_<secret>. Only its
+// hash is stored; the full value is returned once, here.
+func (au *ApiKeyUsecase) CreateApiKey(userId int, input dto.CreateApiKeyDto) (*dto.CreatedApiKeyDto, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" || len(input.Scopes) == 0 {
+		return nil, ErrInvalidApiKey
+	}
+
+	allowed := []string{models.ApiKeyScopeReadOnly, models.ApiKeyScopeUpload, models.ApiKeyScopeAdmin}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, ErrInvalidApiKey
+		}
+	}
+
+	days := input.ExpiresInDays
+	if days == 0 {
+		days = defaultApiKeyDays
+	}
+	if days < 0 || days > maxApiKeyDays {
+		return nil, ErrInvalidApiKey
+	}
+
+	prefix, err := randomApiKeyPrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := handlers.ApiKeyPrefix + prefix + "_" + secret
+
+	apiKey := models.ApiKey{
+		UserID:    userId,
+		Name:      name,
+		Prefix:    handlers.ApiKeyPrefix + prefix,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(input.Scopes))),
+		ExpiresAt: time.Now().Add(time.Duration(days) * 24 * time.Hour),
+	}
+
+	apiKey.ID, apiKey.CreatedAt, err = au.repository.CreateApiKey(apiKey, utils.HashToken(key))
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return &dto.CreatedApiKeyDto{
+		ApiKey: apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (au *ApiKeyUsecase) GetApiKeys(userId int) ([]models.ApiKey, error) {
+	apiKeys, err := au.repository.GetApiKeysByUser(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+func (au *ApiKeyUsecase) RevokeApiKey(userId int, apiKeyId int) (bool, error) {
+	revoked, err := au.repository.RevokeApiKey(userId, apiKeyId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func randomApiKeyPrefix() (string, error) {
+	buffer := make([]byte, 5)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(base32.StdEncoding.EncodeToString(buffer)), nil
+}
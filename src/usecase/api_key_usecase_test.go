@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+)
+
+type fakeApiKeyRepo struct {
+	createApiKeyFn func(models.ApiKey, string) (int, time.Time, error)
+	revokeAllFn    func(int) error
+}
+
+func (f *fakeApiKeyRepo) CreateApiKey(apiKey models.ApiKey, keyHash string) (int, time.Time, error) {
+	if f.createApiKeyFn == nil {
+		panic("CreateApiKey not implemented")
+	}
+	return f.createApiKeyFn(apiKey, keyHash)
+}
+
+func (f *fakeApiKeyRepo) GetApiKeysByUser(int) ([]models.ApiKey, error) {
+	panic("GetApiKeysByUser not implemented")
+}
+
+func (f *fakeApiKeyRepo) RevokeApiKey(int, int) (bool, error) {
+	panic("RevokeApiKey not implemented")
+}
+
+func (f *fakeApiKeyRepo) RevokeAllApiKeys(userId int) error {
+	if f.revokeAllFn == nil {
+		panic("RevokeAllApiKeys not implemented")
+	}
+	return f.revokeAllFn(userId)
+}
+
+func TestApiKeyUsecaseCreateApiKeyStoresHash(t *testing.T) {
+	var stored models.ApiKey
+	var storedHash string
+	repo := &fakeApiKeyRepo{
+		createApiKeyFn: func(apiKey models.ApiKey, keyHash string) (int, time.Time, error) {
+			stored = apiKey
+			storedHash = keyHash
+			return 1, time.Now(), nil
+		},
+	}
+
+	usecase := NewApiKeyUsecase(repo)
+
+	created, err := usecase.CreateApiKey(4, dto.CreateApiKeyDto{Name: "ci", Scopes: []string{"upload", "read-only", "upload"}})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if storedHash != utils.HashToken(created.Key) || strings.Contains(storedHash, created.Key) {
+		t.Fatalf("a chave deve ser salva apenas como hash")
+	}
+	if !strings.HasPrefix(created.Key, stored.Prefix+"_") {
+		t.Fatalf("prefixo %s não corresponde à chave %s", stored.Prefix, created.Key)
+	}
+	if len(stored.Scopes) != 2 {
+		t.Fatalf("escopos duplicados não deveriam ser salvos: %v", stored.Scopes)
+	}
+	if days := time.Until(stored.ExpiresAt).Hours() / 24; days < 89 || days > 90 {
+		t.Fatalf("esperava validade padrão de 90 dias, veio %.1f", days)
+	}
+}
+
+func TestApiKeyUsecaseCreateApiKeyRejectsUnknownScope(t *testing.T) {
+	usecase := NewApiKeyUsecase(&fakeApiKeyRepo{})
+
+	_, err := usecase.CreateApiKey(4, dto.CreateApiKeyDto{Name: "ci", Scopes: []string{"root"}})
+	if !errors.Is(err, ErrInvalidApiKey) {
+		t.Fatalf("esperava ErrInvalidApiKey, veio %v", err)
+	}
+}
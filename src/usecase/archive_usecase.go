@@ -0,0 +1,272 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// zipPrefetch is how many of the next objects are opened while the
+	// current one is written, and zipReadAhead how much of each is read
+	// ahead. Together they bound the memory of a download.
+	zipPrefetch  = 4
+	zipReadAhead = 1 << 20
+
+	// maxZipKeys bounds a download, whether the keys are listed or found
+	// under a prefix.
+	maxZipKeys = 1000
+
+	// zipErrorManifest lists, inside the archive, the keys left out of it.
+	zipErrorManifest = "_erros.txt"
+)
+
+var (
+	ErrInvalidZipRequest = errors.New("informe um prefixo ou uma lista de até 1000 chaves, não os dois")
+	ErrEmptyZip          = errors.New("nenhum arquivo encontrado para compactar")
+	ErrZipTooLarge       = errors.New("a pasta tem mais de 1000 arquivos, baixe suas subpastas separadamente")
+)
+
+// ArchiveEntry is an object going into an archive under Name. Names
+// ending in "/" are folders.
+type ArchiveEntry struct {
+	Key  string
+	Name string
+}
+
+type ArchiveUsecase struct {
+	storage StorageUsecase
+}
+
+func NewArchiveUsecase(storage StorageUsecase) ArchiveUsecase {
+	return ArchiveUsecase{
+		storage: storage,
+	}
+}
+
+// ZipEntries resolves what a download holds. It runs before the response
+// starts, so a bad request can still be answered with an error.
+func (au *ArchiveUsecase) ZipEntries(ctx context.Context, userId int, input dto.DownloadZipDto) ([]ArchiveEntry, error) {
+	// An empty prefix would be the whole bucket.
+	if len(input.Keys) > maxZipKeys || (len(input.Keys) > 0) == (input.Prefix != "") {
+		return nil, ErrInvalidZipRequest
+	}
+
+	if len(input.Keys) > 0 {
+		entries := make([]ArchiveEntry, 0, len(input.Keys))
+		seen := map[string]bool{}
+		for _, key := range input.Keys {
+			name := zipEntryName(key)
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			entries = append(entries, ArchiveEntry{Key: key, Name: name})
+		}
+		if len(entries) == 0 {
+			return nil, ErrInvalidZipRequest
+		}
+		return entries, nil
+	}
+
+	page, err := au.storage.ListPage(ctx, userId, models.ListQuery{Prefix: input.Prefix, MaxKeys: maxZipKeys})
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Truncated {
+		return nil, ErrZipTooLarge
+	}
+
+	// Names start at the folder of the prefix: "fotos/" holds "a.jpg" and
+	// "fotos/2024" holds "2024/a.jpg".
+	base := input.Prefix[:strings.LastIndex(input.Prefix, "/")+1]
+
+	var entries []ArchiveEntry
+	for _, object := range page.Objects {
+		name := zipEntryName(strings.TrimPrefix(object.Key, base))
+		if name == "" {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{Key: object.Key, Name: name})
+	}
+	if len(entries) == 0 {
+		return nil, ErrEmptyZip
+	}
+
+	return entries, nil
+}
+
+// zipEntryName cleans a key into a relative name, so that no entry can
+// be extracted outside its folder. Folders keep their trailing "/".
+func zipEntryName(key string) string {
+	name := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if name != "" && strings.HasSuffix(key, "/") {
+		name += "/"
+	}
+	return name
+}
+
+// ZipFileName is the name offered for the download of input.
+func ZipFileName(input dto.DownloadZipDto) string {
+	name := strings.TrimSuffix(input.Name, ".zip")
+	if name == "" && input.Prefix != "" {
+		name = path.Base(strings.TrimSuffix(input.Prefix, "/"))
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "arquivos"
+	}
+	return name + ".zip"
+}
+
+// WriteZip streams the entries into w as a ZIP archive. Files are stored
+// without compression and their contents are never held whole: while one
+// is copied, the next few are opened with their first bytes read ahead.
+// Keys that cannot be read are listed in zipErrorManifest instead; an
+// error returned here leaves w with a broken archive.
+func (au *ArchiveUsecase) WriteZip(ctx context.Context, userId int, entries []ArchiveEntry, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	fetches := au.prefetch(ctx, userId, entries)
+	defer func() {
+		cancel()
+		for fetch := range fetches {
+			<-fetch.ready
+			fetch.close()
+		}
+	}()
+
+	archive := zip.NewWriter(w)
+	var failures []string
+
+	for fetch := range fetches {
+		<-fetch.ready
+		if fetch.err != nil {
+			failures = append(failures, fetch.entry.Key+": "+zipFailure(fetch.err))
+			continue
+		}
+
+		err := fetch.writeTo(archive)
+		fetch.close()
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(failures) > 0 {
+		manifest, err := archive.Create(zipErrorManifest)
+		if err != nil {
+			return err
+		}
+		text := "Alguns arquivos não puderam ser incluídos:\n" + strings.Join(failures, "\n") + "\n"
+		if _, err := io.WriteString(manifest, text); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func zipFailure(err error) string {
+	if errors.Is(err, ErrObjectNotFound) {
+		return "arquivo não encontrado"
+	}
+	fmt.Println(err)
+	return "não foi possível ler o arquivo"
+}
+
+// zipFetch is an object being opened for an archive. Its fields are set
+// once ready is closed.
+type zipFetch struct {
+	entry  ArchiveEntry
+	ready  chan struct{}
+	stream *zipStream
+	err    error
+}
+
+type zipStream struct {
+	head   []byte
+	body   io.ReadCloser
+	header zip.FileHeader
+}
+
+// prefetch opens the entries in order, at most zipPrefetch ahead of the
+// one being written. The channel is closed after the last entry, or early
+// when ctx is done.
+func (au *ArchiveUsecase) prefetch(ctx context.Context, userId int, entries []ArchiveEntry) <-chan *zipFetch {
+	fetches := make(chan *zipFetch, zipPrefetch)
+
+	go func() {
+		defer close(fetches)
+		for _, entry := range entries {
+			fetch := &zipFetch{entry: entry, ready: make(chan struct{})}
+			select {
+			case fetches <- fetch:
+			case <-ctx.Done():
+				return
+			}
+			go fetch.open(ctx, &au.storage, userId)
+		}
+	}()
+
+	return fetches
+}
+
+func (f *zipFetch) open(ctx context.Context, storage *StorageUsecase, userId int) {
+	defer close(f.ready)
+
+	header := zip.FileHeader{Name: f.entry.Name, Method: zip.Store}
+	if strings.HasSuffix(f.entry.Name, "/") {
+		f.stream = &zipStream{header: header}
+		return
+	}
+
+	stream, err := storage.ReadRange(ctx, userId, f.entry.Key, "")
+	if err != nil {
+		f.err = err
+		return
+	}
+
+	head := make([]byte, min(max(stream.Info.Size, 0), zipReadAhead))
+	n, err := io.ReadFull(stream.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		stream.Body.Close()
+		f.err = err
+		return
+	}
+
+	header.Modified = stream.Info.LastModified
+	f.stream = &zipStream{head: head[:n], body: stream.Body, header: header}
+}
+
+func (f *zipFetch) writeTo(archive *zip.Writer) error {
+	header := f.stream.header
+	writer, err := archive.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	if f.stream.body == nil {
+		return nil
+	}
+
+	_, err = io.Copy(writer, io.MultiReader(bytes.NewReader(f.stream.head), f.stream.body))
+	return err
+}
+
+func (f *zipFetch) close() {
+	if f.stream != nil && f.stream.body != nil {
+		f.stream.body.Close()
+		f.stream.body = nil
+	}
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"cloud_file_manager/src/dto"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type countingBody struct {
+	io.Reader
+	close func()
+}
+
+func (b *countingBody) Close() error {
+	b.close()
+	return nil
+}
+
+func TestArchiveUsecaseZipsPrefixAndListsMissingKeys(t *testing.T) {
+	contents := map[string]string{"fotos/": ""}
+	for i := range 20 {
+		contents[fmt.Sprintf("fotos/%02d.txt", i)] = strings.Repeat(fmt.Sprint(i), 1000*i)
+	}
+
+	var mu sync.Mutex
+	open, maxOpen := 0, 0
+	client := &fakeAwsClient{
+		listObjectsV2Fn: func(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+			objects := []types.Object{{Key: aws.String("fotos/")}}
+			for i := range 20 {
+				objects = append(objects, types.Object{Key: aws.String(fmt.Sprintf("fotos/%02d.txt", i))})
+			}
+			// Listed, but gone by the time it is read.
+			objects = append(objects, types.Object{Key: aws.String("fotos/sumiu.txt")})
+			return &s3.ListObjectsV2Output{Contents: objects}, nil
+		},
+		readObjectFn: func(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error) {
+			content, ok := contents[key]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+
+			mu.Lock()
+			open++
+			maxOpen = max(maxOpen, open)
+			mu.Unlock()
+
+			body := &countingBody{Reader: strings.NewReader(content), close: func() {
+				mu.Lock()
+				open--
+				mu.Unlock()
+			}}
+			return &s3.GetObjectOutput{Body: body, ContentLength: aws.Int64(int64(len(content)))}, nil
+		},
+	}
+
+	archives := NewArchiveUsecase(NewStorageUsecase(client, nil))
+
+	entries, err := archives.ZipEntries(context.Background(), 7, dto.DownloadZipDto{Prefix: "fotos"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := archives.WriteZip(context.Background(), 7, entries, &buffer); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if open != 0 {
+		t.Fatalf("todos os objetos deveriam ser fechados, %d ficaram abertos", open)
+	}
+	if maxOpen > zipPrefetch+1 {
+		t.Fatalf("no máximo %d objetos deveriam ficar abertos, foram %d", zipPrefetch+1, maxOpen)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("o arquivo ZIP deveria ser válido: %v", err)
+	}
+
+	found := map[string]string{}
+	for _, file := range reader.File {
+		body, err := file.Open()
+		if err != nil {
+			t.Fatalf("não foi possível abrir %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		found[file.Name] = string(data)
+	}
+
+	if _, ok := found["fotos/"]; !ok {
+		t.Fatalf("esperava a pasta fotos/ no arquivo, veio %v", reader.File)
+	}
+	for i := range 20 {
+		name := fmt.Sprintf("fotos/%02d.txt", i)
+		if found[name] != contents[name] {
+			t.Fatalf("conteúdo inesperado em %s", name)
+		}
+	}
+	if !strings.Contains(found[zipErrorManifest], "fotos/sumiu.txt: arquivo não encontrado") {
+		t.Fatalf("o manifesto deveria listar a chave que sumiu, veio %q", found[zipErrorManifest])
+	}
+}
+
+func TestArchiveUsecaseValidatesRequest(t *testing.T) {
+	client := &fakeAwsClient{}
+	archives := NewArchiveUsecase(NewStorageUsecase(client, nil))
+
+	tooMany := make([]string, maxZipKeys+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprint(i)
+	}
+
+	for _, input := range []dto.DownloadZipDto{
+		{},
+		{Prefix: "fotos/", Keys: []string{"a.txt"}},
+		{Keys: tooMany},
+		{Keys: []string{"/", ".."}},
+	} {
+		if _, err := archives.ZipEntries(context.Background(), 7, input); !errors.Is(err, ErrInvalidZipRequest) {
+			t.Fatalf("esperava ErrInvalidZipRequest, veio %v", err)
+		}
+	}
+
+	entries, err := archives.ZipEntries(context.Background(), 7, dto.DownloadZipDto{Keys: []string{"../../etc/senha", "a.txt", "a.txt"}})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "etc/senha" || entries[1].Name != "a.txt" {
+		t.Fatalf("entradas inesperadas %#v", entries)
+	}
+
+	if name := ZipFileName(dto.DownloadZipDto{Prefix: "fotos/2024/"}); name != "2024.zip" {
+		t.Fatalf("esperava 2024.zip, veio %s", name)
+	}
+}
+
+func TestArchiveUsecaseRefusesPrefixesOverTheKeyLimit(t *testing.T) {
+	client := &fakeAwsClient{
+		listObjectsV2Fn: func(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+			if aws.ToInt32(input.MaxKeys) != maxZipKeys {
+				t.Fatalf("a listagem deveria parar em %d chaves, pediu %d", maxZipKeys, aws.ToInt32(input.MaxKeys))
+			}
+			objects := make([]types.Object, maxZipKeys)
+			for i := range objects {
+				objects[i] = types.Object{Key: aws.String(fmt.Sprintf("fotos/%04d.jpg", i))}
+			}
+			return &s3.ListObjectsV2Output{Contents: objects, IsTruncated: aws.Bool(true)}, nil
+		},
+	}
+	archives := NewArchiveUsecase(NewStorageUsecase(client, nil))
+
+	if _, err := archives.ZipEntries(context.Background(), 7, dto.DownloadZipDto{Prefix: "fotos/"}); !errors.Is(err, ErrZipTooLarge) {
+		t.Fatalf("esperava ErrZipTooLarge, veio %v", err)
+	}
+}
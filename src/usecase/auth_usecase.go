@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	passwordResetPurpose = "password_reset"
+	passwordResetTTL     = time.Hour
+	refreshTokenTTL      = 30 * 24 * time.Hour
+	minPasswordLength    = 8
+)
+
+var (
+	ErrInvalidToken = errors.New("token inválido ou expirado")
+	ErrWeakPassword = errors.New("a senha precisa ter pelo menos 8 caracteres")
+)
+
+type AuthUsecase struct {
+	userRepository    UserRepository
+	tokenRepository   UserTokenRepository
+	sessionRepository SessionRepository
+	mailer            Mailer
+}
+
+func NewAuthUsecase(userRepo UserRepository, tokenRepo UserTokenRepository, sessionRepo SessionRepository, mailer Mailer) AuthUsecase {
+	return AuthUsecase{
+		userRepository:    userRepo,
+		tokenRepository:   tokenRepo,
+		sessionRepository: sessionRepo,
+		mailer:            mailer,
+	}
+}
+
+// ForgotPassword mails a reset link when the email is registered and silently
+// does nothing otherwise, so callers cannot tell the two cases apart.
+func (au *AuthUsecase) ForgotPassword(email string) error {
+	user, err := au.userRepository.GetUserByEmail(email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	token, err := issueUserToken(au.tokenRepository, user.ID, passwordResetPurpose, passwordResetTTL)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	link := os.Getenv("APP_URL") + "/reset-password?token=" + token
+	body := "Olá, " + user.Name + ".\n\n" +
+		"Recebemos um pedido para redefinir sua senha. Use o link abaixo em até 1 hora:\n\n" +
+		link + "\n\n" +
+		"Se você não fez esse pedido, ignore este email."
+
+	return au.mailer.Send(user.Email, "Redefinição de senha", body)
+}
+
+// ResetPassword consumes a reset token, stores the new password and ends
+// every open session of the user. The token is only spent once the new
+// password is stored.
+func (au *AuthUsecase) ResetPassword(token string, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	userId, err := au.tokenRepository.ConsumeUserTokenForPassword(passwordResetPurpose, utils.HashToken(token), password)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if userId == 0 {
+		return ErrInvalidToken
+	}
+
+	return au.sessionRepository.RevokeAllSessions(userId)
+}
+
+// IssueRefreshToken lets long-lived clients such as the CLI renew the access
+// token of their session without storing the password. The token carries
+// the scopes of the session's current access token.
+func (au *AuthUsecase) IssueRefreshToken(sessionId int, scopes []string) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := au.sessionRepository.SetRefreshToken(sessionId, utils.HashToken(token), scopes, time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		fmt.Println(err)
+		return "", err
+	}
+
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new
+// refresh token. Revoking the session invalidates both.
+func (au *AuthUsecase) Refresh(refreshToken string) (*dto.TokenPairDto, error) {
+	newRefreshToken, err := utils.GenerateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := au.sessionRepository.RotateRefreshToken(
+		utils.HashToken(refreshToken),
+		utils.HashToken(newRefreshToken),
+		time.Now().Add(refreshTokenTTL),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if session == nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := au.userRepository.GetUserById(session.UserID)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := handlers.CreateToken(user.Name, user.ID, session.ID, session.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TokenPairDto{
+		Token:        token,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
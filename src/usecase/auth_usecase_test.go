@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/utils"
+)
+
+type fakeUserTokenRepo struct {
+	createUserTokenFn  func(int, string, string, time.Time) error
+	getOwnerFn         func(string, string) (int, error)
+	consumeUserTokenFn func(string, string) (int, error)
+	consumeForPassFn   func(string, string, string) (int, error)
+}
+
+func (f *fakeUserTokenRepo) CreateUserToken(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+	if f.createUserTokenFn == nil {
+		panic("CreateUserToken not implemented")
+	}
+	return f.createUserTokenFn(userId, purpose, tokenHash, expiresAt)
+}
+
+func (f *fakeUserTokenRepo) GetUserTokenOwner(purpose string, tokenHash string) (int, error) {
+	if f.getOwnerFn == nil {
+		panic("GetUserTokenOwner not implemented")
+	}
+	return f.getOwnerFn(purpose, tokenHash)
+}
+
+func (f *fakeUserTokenRepo) ConsumeUserToken(purpose string, tokenHash string) (int, error) {
+	if f.consumeUserTokenFn == nil {
+		panic("ConsumeUserToken not implemented")
+	}
+	return f.consumeUserTokenFn(purpose, tokenHash)
+}
+
+func (f *fakeUserTokenRepo) ConsumeUserTokenForPassword(purpose string, tokenHash string, password string) (int, error) {
+	if f.consumeForPassFn == nil {
+		panic("ConsumeUserTokenForPassword not implemented")
+	}
+	return f.consumeForPassFn(purpose, tokenHash, password)
+}
+
+type fakeMailer struct {
+	sent []string
+}
+
+func (f *fakeMailer) Send(to string, subject string, body string) error {
+	f.sent = append(f.sent, to+"\n"+body)
+	return nil
+}
+
+func TestAuthUsecaseForgotPasswordUnknownEmail(t *testing.T) {
+	repo := &fakeUserRepo{
+		getByEmailFn: func(string) (*models.User, error) {
+			return nil, nil
+		},
+	}
+	mailer := &fakeMailer{}
+
+	usecase := NewAuthUsecase(repo, &fakeUserTokenRepo{}, &fakeSessionRepo{}, mailer)
+
+	if err := usecase.ForgotPassword("ninguem@example.com"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("não deveria enviar email para endereço desconhecido")
+	}
+}
+
+func TestAuthUsecaseForgotPasswordStoresHashedToken(t *testing.T) {
+	repo := &fakeUserRepo{
+		getByEmailFn: func(email string) (*models.User, error) {
+			return &models.User{ID: 8, Name: "Ana", Email: email}, nil
+		},
+	}
+
+	var storedHash string
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+			if userId != 8 || purpose != passwordResetPurpose {
+				t.Fatalf("token inesperado para %d/%s", userId, purpose)
+			}
+			if time.Until(expiresAt) > passwordResetTTL {
+				t.Fatalf("expiração longa demais: %v", expiresAt)
+			}
+			storedHash = tokenHash
+			return nil
+		},
+	}
+	mailer := &fakeMailer{}
+
+	usecase := NewAuthUsecase(repo, tokens, &fakeSessionRepo{}, mailer)
+
+	if err := usecase.ForgotPassword("ana@example.com"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("esperava 1 email, veio %d", len(mailer.sent))
+	}
+
+	token := mailer.sent[0][strings.Index(mailer.sent[0], "token=")+len("token="):]
+	token = strings.Fields(token)[0]
+	if utils.HashToken(token) != storedHash {
+		t.Fatalf("o hash salvo não corresponde ao token enviado")
+	}
+	if strings.Contains(mailer.sent[0], storedHash) {
+		t.Fatalf("o email não deve conter o hash do token")
+	}
+}
+
+func TestAuthUsecaseResetPasswordInvalidToken(t *testing.T) {
+	tokens := &fakeUserTokenRepo{
+		consumeForPassFn: func(string, string, string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	usecase := NewAuthUsecase(&fakeUserRepo{}, tokens, &fakeSessionRepo{}, &fakeMailer{})
+
+	err := usecase.ResetPassword("expirado", "novasenha123")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("esperava ErrInvalidToken, veio %v", err)
+	}
+}
+
+func TestAuthUsecaseResetPasswordRevokesSessions(t *testing.T) {
+	var updated, revoked int
+	tokens := &fakeUserTokenRepo{
+		consumeForPassFn: func(purpose string, tokenHash string, password string) (int, error) {
+			if tokenHash != utils.HashToken("abc") || password != "novasenha123" {
+				t.Fatalf("hash inesperado %s", tokenHash)
+			}
+			updated = 8
+			return 8, nil
+		},
+	}
+	repo := &fakeUserRepo{}
+	sessions := &fakeSessionRepo{
+		revokeAllFn: func(userId int) error {
+			revoked = userId
+			return nil
+		},
+	}
+
+	usecase := NewAuthUsecase(repo, tokens, sessions, &fakeMailer{})
+
+	if err := usecase.ResetPassword("abc", "novasenha123"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if updated != 8 || revoked != 8 {
+		t.Fatalf("esperava atualizar senha e sessões do usuário 8, veio %d/%d", updated, revoked)
+	}
+}
+
+func TestAuthUsecaseResetPasswordWeakPassword(t *testing.T) {
+	usecase := NewAuthUsecase(&fakeUserRepo{}, &fakeUserTokenRepo{}, &fakeSessionRepo{}, &fakeMailer{})
+
+	err := usecase.ResetPassword("abc", "curta")
+	if !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("esperava ErrWeakPassword, veio %v", err)
+	}
+}
+
+func TestAuthUsecaseIssueRefreshTokenStoresHash(t *testing.T) {
+	var storedHash string
+	sessions := &fakeSessionRepo{
+		setRefreshFn: func(sessionId int, tokenHash string, scopes []string, expiresAt time.Time) (bool, error) {
+			if sessionId != 3 || len(scopes) != 1 || scopes[0] != "files:read" {
+				t.Fatalf("sessão inesperada %d/%v", sessionId, scopes)
+			}
+			storedHash = tokenHash
+			return true, nil
+		},
+	}
+
+	usecase := NewAuthUsecase(&fakeUserRepo{}, &fakeUserTokenRepo{}, sessions, &fakeMailer{})
+
+	token, err := usecase.IssueRefreshToken(3, []string{"files:read"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if token == "" || storedHash != utils.HashToken(token) {
+		t.Fatalf("o banco deveria guardar apenas o hash do refresh token")
+	}
+}
+
+func TestAuthUsecaseRefreshRotatesToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	var newHash string
+	sessions := &fakeSessionRepo{
+		rotateRefreshFn: func(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+			if tokenHash != utils.HashToken("antigo") {
+				t.Fatalf("hash inesperado %s", tokenHash)
+			}
+			newHash = newTokenHash
+			return &models.Session{ID: 3, UserID: 8, Scopes: []string{"files:read"}}, nil
+		},
+	}
+	repo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana"}, nil
+		},
+	}
+
+	usecase := NewAuthUsecase(repo, &fakeUserTokenRepo{}, sessions, &fakeMailer{})
+
+	tokens, err := usecase.Refresh("antigo")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if tokens.Token == "" || newHash != utils.HashToken(tokens.RefreshToken) {
+		t.Fatalf("esperava um novo par de tokens, veio %+v", tokens)
+	}
+}
+
+func TestAuthUsecaseRefreshInvalidToken(t *testing.T) {
+	sessions := &fakeSessionRepo{
+		rotateRefreshFn: func(string, string, time.Time) (*models.Session, error) {
+			return nil, nil
+		},
+	}
+
+	usecase := NewAuthUsecase(&fakeUserRepo{}, &fakeUserTokenRepo{}, sessions, &fakeMailer{})
+
+	if _, err := usecase.Refresh("revogado"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("esperava ErrInvalidToken, veio %v", err)
+	}
+}
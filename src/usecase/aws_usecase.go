@@ -1,23 +1,55 @@
 package usecase
 
 import (
+	"cloud_file_manager/src/dto"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
-	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
 
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+const (
+	defaultShareTTL = time.Hour
+	// SigV4 presigned URLs cannot outlive a week.
+	maxShareTTL    = 7 * 24 * time.Hour
+	maxUploadParts = 10000
+	// presignedUploadTTL is how long, in seconds, an upload URL is valid.
+	presignedUploadTTL = 60
+	// thumbnailUploadDelay is how long after its URL expires a presigned
+	// upload gets its thumbnails, as by then it is likely done.
+	thumbnailUploadDelay = time.Minute
+	// Largest page ListObjectsV2 returns.
+	maxPageSize = 1000
+)
+
+var (
+	ErrBucketNotFound  = errors.New("o usuário não possui bucket")
+	ErrInvalidShareTTL = errors.New("a validade do link deve ser entre 1 segundo e 7 dias")
+	ErrInvalidPart     = errors.New("o número da parte deve ser entre 1 e 10000")
+	ErrNoParts         = errors.New("é necessário informar as partes enviadas")
+	ErrInvalidPageSize = errors.New("o limite deve ser entre 1 e 1000")
+)
+
 type AwsUsecase struct {
-	AwsService AwsClient
+	AwsService       AwsClient
+	bucketRepository UserBucketRepository
+	thumbnails       ThumbnailQueue
 }
 
-func NewAwsUsecase(awsService AwsClient) AwsUsecase {
+func NewAwsUsecase(awsService AwsClient, bucketRepo UserBucketRepository, thumbnails ThumbnailQueue) AwsUsecase {
 	return AwsUsecase{
-		AwsService: awsService,
+		AwsService:       awsService,
+		bucketRepository: bucketRepo,
+		thumbnails:       thumbnails,
 	}
 }
 
@@ -32,6 +64,12 @@ func (au *AwsUsecase) CreateBucket(userId int, name string) (*s3.CreateBucketOut
 		return nil, err
 	}
 
+	// Recorded so that deleting the account deletes the bucket too.
+	err = au.bucketRepository.AddUserBucket(userId, bucketName)
+	if err != nil {
+		return nil, err
+	}
+
 	return output, nil
 }
 
@@ -51,71 +89,305 @@ func (au *AwsUsecase) ListBuckets() ([]types.Bucket, error) {
 func (au *AwsUsecase) ListBucketItems(userId int) ([]types.Object, error) {
 	ctx := context.Background()
 
-	buckets, err := au.AwsService.ListBuckets(ctx)
+	bucketName, err := au.userBucket(ctx, userId)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
-	var suffix string
-	suffix = "-" + strconv.Itoa(userId)
 
-	var bucketName string
+	output, err := au.AwsService.ListBucketItems(ctx, bucketName)
 
-	for _, element := range buckets {
-		if strings.HasSuffix(*element.Name, suffix) {
-			bucketName = *element.Name
-		}
+	return output, err
+}
+
+// ListBucketPage lists the user's objects under prefix one page at a time.
+// A zero limit means the largest page; cursor is the one returned by the
+// previous page.
+func (au *AwsUsecase) ListBucketPage(userId int, prefix string, cursor string, limit int32) ([]types.Object, string, error) {
+	if limit == 0 {
+		limit = maxPageSize
 	}
 
-	output, err := au.AwsService.ListBucketItems(ctx, bucketName)
+	if limit < 0 || limit > maxPageSize {
+		return nil, "", ErrInvalidPageSize
+	}
 
-	return output, err
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return au.AwsService.ListBucketPage(ctx, bucketName, prefix, cursor, limit)
 }
 
 func (au *AwsUsecase) GetObject(userId int, objectKey string) (*v4.PresignedHTTPRequest, error) {
 	ctx := context.Background()
 
-	buckets, err := au.AwsService.ListBuckets(ctx)
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := au.AwsService.GetObject(ctx, bucketName, objectKey, 60)
+
+	return output, err
+}
+
+func (au *AwsUsecase) PutObject(userId int, objectKey string) (*v4.PresignedHTTPRequest, error) {
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := au.AwsService.PutObjectPresignedUrl(ctx, bucketName, objectKey, presignedUploadTTL)
 	if err != nil {
+		return nil, err
+	}
+
+	// The upload goes straight to S3, which tells us nothing when it ends.
+	runAt := time.Now().Add(presignedUploadTTL*time.Second + thumbnailUploadDelay)
+	if err := au.thumbnails.EnqueueThumbnailsAt(userId, objectKey, runAt); err != nil {
 		fmt.Println(err)
+	}
+
+	return output, nil
+}
+
+func (au *AwsUsecase) DeleteObject(userId int, objectKey string) error {
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return au.AwsService.DeleteObject(ctx, bucketName, objectKey)
+}
+
+// MoveObject renames an object. S3 has no rename, so the object is copied
+// and the source removed only once the copy succeeded.
+func (au *AwsUsecase) MoveObject(userId int, sourceKey string, destinationKey string) error {
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := au.AwsService.CopyObject(ctx, bucketName, sourceKey, destinationKey); err != nil {
+		return err
+	}
+	queueThumbnails(au.thumbnails, userId, destinationKey)
+
+	return au.AwsService.DeleteObject(ctx, bucketName, sourceKey)
+}
+
+// ShareObject returns a download link meant to be handed to someone else,
+// valid for expiresIn seconds (an hour when zero).
+func (au *AwsUsecase) ShareObject(userId int, objectKey string, expiresIn int64) (*v4.PresignedHTTPRequest, error) {
+	ttl := time.Duration(expiresIn) * time.Second
+	if expiresIn == 0 {
+		ttl = defaultShareTTL
+	}
+
+	if ttl < time.Second || ttl > maxShareTTL {
+		return nil, ErrInvalidShareTTL
+	}
+
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
 		return nil, err
 	}
-	var suffix string
-	suffix = "-" + strconv.Itoa(userId)
 
-	var bucketName string
+	return au.AwsService.GetObject(ctx, bucketName, objectKey, int64(ttl/time.Second))
+}
 
-	for _, element := range buckets {
-		if strings.HasSuffix(*element.Name, suffix) {
-			bucketName = *element.Name
+// PreviewObject reads no more than the first input.MaxKB kilobytes of an
+// object and returns them as text, sanitized Markdown HTML, CSV rows or
+// JSON.
+func (au *AwsUsecase) PreviewObject(userId int, input dto.PreviewObjectDto) (*dto.ObjectPreviewDto, error) {
+	format := input.Format
+	if format == "" {
+		format = previewFormat(input.Key)
+	}
+	if format != PreviewText && format != PreviewMarkdown && format != PreviewCSV && format != PreviewJSON {
+		return nil, ErrInvalidPreviewFormat
+	}
+
+	maxKB := input.MaxKB
+	if maxKB == 0 {
+		maxKB = defaultPreviewKB
+	}
+	if maxKB < 0 || maxKB > maxPreviewKB {
+		return nil, ErrInvalidPreviewSize
+	}
+
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := au.AwsService.HeadObject(ctx, bucketName, input.Key)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	preview := &dto.ObjectPreviewDto{
+		Key:      input.Key,
+		Format:   format,
+		Encoding: "utf-8",
+		Size:     aws.ToInt64(head.ContentLength),
+	}
+	// An empty object has no range to read.
+	if preview.Size == 0 {
+		return preview, nil
+	}
+
+	limit := int64(maxKB) << 10
+	preview.Truncated = preview.Size > limit
+
+	output, err := au.AwsService.ReadObject(ctx, bucketName, input.Key, fmt.Sprintf("bytes=0-%d", min(preview.Size, limit)-1))
+	if err != nil {
+		return nil, storageError(err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(output.Body, limit))
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	text, encoding, err := decodeText(data, preview.Truncated)
+	if err != nil {
+		return nil, err
+	}
+	preview.Encoding = encoding
+
+	switch format {
+	case PreviewMarkdown:
+		preview.HTML, err = renderMarkdown(text)
+		if err != nil {
+			fmt.Println(err)
+			return nil, err
 		}
+	case PreviewCSV:
+		preview.Rows = csvRows(input.Key, text, preview.Truncated)
+	case PreviewJSON:
+		preview.Text = indentJSON(text, preview.Truncated)
+	default:
+		preview.Text = text
 	}
 
-	output, err := au.AwsService.GetObject(ctx, bucketName, objectKey, 60)
+	return preview, nil
+}
 
-	return output, err
+func (au *AwsUsecase) StartMultipartUpload(userId int, objectKey string) (string, error) {
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	return au.AwsService.CreateMultipartUpload(ctx, bucketName, objectKey)
 }
 
-func (au *AwsUsecase) PutObject(userId int, objectKey string) (*v4.PresignedHTTPRequest, error) {
+func (au *AwsUsecase) PresignUploadPart(userId int, objectKey string, uploadId string, partNumber int32) (*v4.PresignedHTTPRequest, error) {
+	if partNumber < 1 || partNumber > maxUploadParts {
+		return nil, ErrInvalidPart
+	}
+
 	ctx := context.Background()
 
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return au.AwsService.PresignUploadPart(ctx, bucketName, objectKey, uploadId, partNumber, 60)
+}
+
+func (au *AwsUsecase) CompleteMultipartUpload(userId int, objectKey string, uploadId string, parts []dto.CompletedPartDto) error {
+	completed, err := completedParts(parts)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	err = au.AwsService.CompleteMultipartUpload(ctx, bucketName, objectKey, uploadId, completed)
+	if err != nil {
+		return err
+	}
+
+	queueThumbnails(au.thumbnails, userId, objectKey)
+	return nil
+}
+
+func (au *AwsUsecase) AbortMultipartUpload(userId int, objectKey string, uploadId string) error {
+	ctx := context.Background()
+
+	bucketName, err := au.userBucket(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return au.AwsService.AbortMultipartUpload(ctx, bucketName, objectKey, uploadId)
+}
+
+func completedParts(parts []dto.CompletedPartDto) ([]types.CompletedPart, error) {
+	if len(parts) == 0 {
+		return nil, ErrNoParts
+	}
+
+	completed := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		if part.PartNumber < 1 || part.PartNumber > maxUploadParts {
+			return nil, ErrInvalidPart
+		}
+		completed[i] = types.CompletedPart{
+			PartNumber: aws.Int32(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		}
+	}
+
+	// S3 rejects the completion unless the parts are in ascending order.
+	sort.Slice(completed, func(i, j int) bool {
+		return *completed[i].PartNumber < *completed[j].PartNumber
+	})
+
+	return completed, nil
+}
+
+// userBucket returns the bucket provisioned for the user, once it exists.
+// Buckets the user created through POST /aws/bucket are never picked.
+func (au *AwsUsecase) userBucket(ctx context.Context, userId int) (string, error) {
 	buckets, err := au.AwsService.ListBuckets(ctx)
 	if err != nil {
 		fmt.Println(err)
-		return nil, err
+		return "", err
 	}
-	var suffix string
-	suffix = "-" + strconv.Itoa(userId)
 
-	var bucketName string
+	bucketName := userBucketName(userId)
 
 	for _, element := range buckets {
-		if strings.HasSuffix(*element.Name, suffix) {
-			bucketName = *element.Name
+		if aws.ToString(element.Name) == bucketName {
+			return bucketName, nil
 		}
 	}
 
-	output, err := au.AwsService.PutObjectPresignedUrl(ctx, bucketName, objectKey, 60)
-
-	return output, err
+	return "", ErrBucketNotFound
 }
@@ -2,8 +2,13 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"cloud_file_manager/src/dto"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
@@ -11,6 +16,21 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+type fakeThumbnailQueue struct {
+	enqueued []string
+	runAt    []time.Time
+}
+
+func (f *fakeThumbnailQueue) EnqueueThumbnails(userId int, key string) error {
+	return f.EnqueueThumbnailsAt(userId, key, time.Time{})
+}
+
+func (f *fakeThumbnailQueue) EnqueueThumbnailsAt(userId int, key string, runAt time.Time) error {
+	f.enqueued = append(f.enqueued, key)
+	f.runAt = append(f.runAt, runAt)
+	return nil
+}
+
 func TestAwsUsecaseCreateBucket(t *testing.T) {
 	var captured string
 	client := &fakeAwsClient{
@@ -20,7 +40,8 @@ func TestAwsUsecaseCreateBucket(t *testing.T) {
 		},
 	}
 
-	usecase := NewAwsUsecase(client)
+	buckets := &fakeUserBucketRepo{}
+	usecase := NewAwsUsecase(client, buckets, &fakeThumbnailQueue{})
 
 	if _, err := usecase.CreateBucket(12, "base"); err != nil {
 		t.Fatalf("não esperava erro, veio %v", err)
@@ -29,6 +50,9 @@ func TestAwsUsecaseCreateBucket(t *testing.T) {
 	if captured != "base-12" {
 		t.Fatalf("esperava bucket base-12, veio %s", captured)
 	}
+	if len(buckets.buckets[12]) != 1 || buckets.buckets[12][0] != "base-12" {
+		t.Fatalf("esperava o bucket registrado, veio %v", buckets.buckets)
+	}
 }
 
 func TestAwsUsecaseListBuckets(t *testing.T) {
@@ -43,7 +67,7 @@ func TestAwsUsecaseListBuckets(t *testing.T) {
 		},
 	}
 
-	usecase := NewAwsUsecase(client)
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
 
 	buckets, err := usecase.ListBuckets()
 	if err != nil {
@@ -59,19 +83,19 @@ func TestAwsUsecaseListBucketItems(t *testing.T) {
 	client := &fakeAwsClient{
 		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
 			return []types.Bucket{
-				{Name: aws.String("files-10")},
-				{Name: aws.String("files-77")},
+				{Name: aws.String(userBucketName(10))},
+				{Name: aws.String(userBucketName(77))},
 			}, nil
 		},
 		listBucketItemsFn: func(ctx context.Context, bucket string) ([]types.Object, error) {
-			if bucket != "files-77" {
+			if bucket != userBucketName(77) {
 				t.Fatalf("bucket inesperado %s", bucket)
 			}
 			return []types.Object{{Key: aws.String("doc.txt")}}, nil
 		},
 	}
 
-	usecase := NewAwsUsecase(client)
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
 
 	items, err := usecase.ListBucketItems(77)
 	if err != nil {
@@ -86,12 +110,12 @@ func TestAwsUsecaseGetObject(t *testing.T) {
 	client := &fakeAwsClient{
 		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
 			return []types.Bucket{
-				{Name: aws.String("files-9")},
-				{Name: aws.String("files-22")},
+				{Name: aws.String(userBucketName(9))},
+				{Name: aws.String(userBucketName(22))},
 			}, nil
 		},
 		getObjectFn: func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error) {
-			if bucket != "files-22" {
+			if bucket != userBucketName(22) {
 				t.Fatalf("bucket inesperado %s", bucket)
 			}
 			if key != "photo.png" {
@@ -104,7 +128,7 @@ func TestAwsUsecaseGetObject(t *testing.T) {
 		},
 	}
 
-	usecase := NewAwsUsecase(client)
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
 
 	if _, err := usecase.GetObject(22, "photo.png"); err != nil {
 		t.Fatalf("não esperava erro, veio %v", err)
@@ -115,11 +139,11 @@ func TestAwsUsecasePutObject(t *testing.T) {
 	client := &fakeAwsClient{
 		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
 			return []types.Bucket{
-				{Name: aws.String("files-22")},
+				{Name: aws.String(userBucketName(22))},
 			}, nil
 		},
 		putObjectPresignedURLFn: func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error) {
-			if bucket != "files-22" {
+			if bucket != userBucketName(22) {
 				t.Fatalf("bucket inesperado %s", bucket)
 			}
 			if key != "upload.bin" {
@@ -132,9 +156,229 @@ func TestAwsUsecasePutObject(t *testing.T) {
 		},
 	}
 
-	usecase := NewAwsUsecase(client)
+	thumbnails := &fakeThumbnailQueue{}
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, thumbnails)
 
 	if _, err := usecase.PutObject(22, "upload.bin"); err != nil {
 		t.Fatalf("não esperava erro, veio %v", err)
 	}
+
+	// The upload goes straight to S3; its thumbnails wait for the URL to expire.
+	if len(thumbnails.runAt) != 1 || time.Until(thumbnails.runAt[0]) <= 60*time.Second {
+		t.Fatalf("as miniaturas deveriam esperar o fim do upload, veio %v", thumbnails.runAt)
+	}
+}
+
+func TestAwsUsecaseMoveObjectCopiesThenDeletes(t *testing.T) {
+	var calls []string
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(5))}}, nil
+		},
+		copyObjectFn: func(ctx context.Context, bucket, sourceKey, destinationKey string) error {
+			calls = append(calls, "copy "+bucket+" "+sourceKey+" "+destinationKey)
+			return nil
+		},
+		deleteObjectFn: func(ctx context.Context, bucket, key string) error {
+			calls = append(calls, "delete "+bucket+" "+key)
+			return nil
+		},
+	}
+
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
+
+	if err := usecase.MoveObject(5, "a.txt", "docs/a.txt"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	expected := []string{"copy " + userBucketName(5) + " a.txt docs/a.txt", "delete " + userBucketName(5) + " a.txt"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("esperava %v, veio %v", expected, calls)
+	}
+}
+
+func TestAwsUsecaseDeleteObjectWithoutBucket(t *testing.T) {
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(51))}}, nil
+		},
+	}
+
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
+
+	if err := usecase.DeleteObject(5, "a.txt"); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("esperava ErrBucketNotFound, veio %v", err)
+	}
+}
+
+func TestAwsUsecaseShareObjectTTL(t *testing.T) {
+	var captured int64
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(5))}}, nil
+		},
+		getObjectFn: func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error) {
+			captured = ttl
+			return &v4.PresignedHTTPRequest{}, nil
+		},
+	}
+
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
+
+	if _, err := usecase.ShareObject(5, "a.txt", 0); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if captured != 3600 {
+		t.Fatalf("esperava validade padrão de uma hora, veio %d", captured)
+	}
+
+	if _, err := usecase.ShareObject(5, "a.txt", 8*24*3600); !errors.Is(err, ErrInvalidShareTTL) {
+		t.Fatalf("esperava ErrInvalidShareTTL, veio %v", err)
+	}
+}
+
+func TestAwsUsecaseCompleteMultipartSortsParts(t *testing.T) {
+	var captured []types.CompletedPart
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(5))}}, nil
+		},
+		completeMultipartFn: func(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error {
+			if uploadId != "up-1" {
+				t.Fatalf("upload inesperado %s", uploadId)
+			}
+			captured = parts
+			return nil
+		},
+	}
+
+	thumbnails := &fakeThumbnailQueue{}
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, thumbnails)
+
+	parts := []dto.CompletedPartDto{{PartNumber: 2, ETag: "b"}, {PartNumber: 1, ETag: "a"}}
+	if err := usecase.CompleteMultipartUpload(5, "big.bin", "up-1", parts); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if len(captured) != 2 || *captured[0].PartNumber != 1 || *captured[0].ETag != "a" {
+		t.Fatalf("esperava partes em ordem crescente, veio %#v", captured)
+	}
+
+	if err := usecase.CompleteMultipartUpload(5, "big.bin", "up-1", nil); !errors.Is(err, ErrNoParts) {
+		t.Fatalf("esperava ErrNoParts, veio %v", err)
+	}
+
+	if len(thumbnails.enqueued) != 1 || thumbnails.enqueued[0] != "big.bin" {
+		t.Fatalf("o upload concluído deveria ir para as miniaturas, veio %v", thumbnails.enqueued)
+	}
+}
+
+func TestAwsUsecaseListBucketPage(t *testing.T) {
+	client := &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(5))}}, nil
+		},
+		listBucketPageFn: func(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error) {
+			if bucket != userBucketName(5) || prefix != "docs/" || token != "c1" {
+				t.Fatalf("página inesperada %s/%s/%s", bucket, prefix, token)
+			}
+			if maxKeys != 1000 {
+				t.Fatalf("esperava o limite padrão, veio %d", maxKeys)
+			}
+			return []types.Object{{Key: aws.String("docs/a.txt")}}, "c2", nil
+		},
+	}
+
+	usecase := NewAwsUsecase(client, &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
+
+	items, next, err := usecase.ListBucketPage(5, "docs/", "c1", 0)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(items) != 1 || next != "c2" {
+		t.Fatalf("esperava um item e o próximo cursor, veio %#v/%s", items, next)
+	}
+
+	if _, _, err := usecase.ListBucketPage(5, "", "", 5000); !errors.Is(err, ErrInvalidPageSize) {
+		t.Fatalf("esperava ErrInvalidPageSize, veio %v", err)
+	}
+}
+
+func TestAwsUsecasePreviewObject(t *testing.T) {
+	bucket := &memoryBucket{objects: map[string][]byte{
+		"leia.md":     []byte("# Título\n\n<script>alert(1)</script>\n\n[clique](javascript:alert(1)) e **negrito**\n"),
+		"vendas.csv":  []byte("produto;preço\ncafé;10\nchá;\"8\"\n"),
+		"dados.json":  []byte(`{"a":[1,2]}`),
+		"latin1.txt":  {'c', 'a', 'f', 0xE9},
+		"longo.txt":   []byte(strings.Repeat("a", 1023) + "é" + strings.Repeat("b", 2000)),
+		"vazio.txt":   {},
+		"foto.bin":    {0x89, 'P', 'N', 'G', 0, 0},
+		"tabela.csv":  []byte(strings.Repeat("x,y\n", 400)),
+		"utf16le.txt": {0xFF, 0xFE, 'o', 0, 'l', 0, 0xE1, 0},
+	}}
+	usecase := NewAwsUsecase(bucket.client(), &fakeUserBucketRepo{}, &fakeThumbnailQueue{})
+
+	preview, err := usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "leia.md"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if preview.Format != PreviewMarkdown || !strings.Contains(preview.HTML, "<h1>Título</h1>") || !strings.Contains(preview.HTML, "<strong>negrito</strong>") {
+		t.Fatalf("HTML inesperado %s", preview.HTML)
+	}
+	if strings.Contains(preview.HTML, "<script") || strings.Contains(preview.HTML, "javascript:") {
+		t.Fatalf("o HTML deveria ser sanitizado, veio %s", preview.HTML)
+	}
+
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "vendas.csv"})
+	if !reflect.DeepEqual(preview.Rows, [][]string{{"produto", "preço"}, {"café", "10"}, {"chá", "8"}}) {
+		t.Fatalf("linhas inesperadas %#v", preview.Rows)
+	}
+
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "tabela.csv", MaxKB: 1})
+	if !preview.Truncated || len(preview.Rows) != 100 {
+		t.Fatalf("esperava as primeiras 100 linhas, veio %d", len(preview.Rows))
+	}
+
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "dados.json"})
+	if preview.Text != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Fatalf("JSON inesperado %q", preview.Text)
+	}
+
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "latin1.txt"})
+	if preview.Text != "café" || preview.Encoding != "windows-1252" {
+		t.Fatalf("texto inesperado %q em %s", preview.Text, preview.Encoding)
+	}
+
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "utf16le.txt"})
+	if preview.Text != "olá" || preview.Encoding != "utf-16" {
+		t.Fatalf("texto inesperado %q em %s", preview.Text, preview.Encoding)
+	}
+
+	// Only the first kilobyte is read, and the character it cuts is dropped.
+	bucket.reads = nil
+	preview, _ = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "longo.txt", MaxKB: 1})
+	if !reflect.DeepEqual(bucket.reads, []string{"bytes=0-1023"}) {
+		t.Fatalf("leituras inesperadas %v", bucket.reads)
+	}
+	if !preview.Truncated || preview.Size != 3025 || preview.Text != strings.Repeat("a", 1023) || preview.Encoding != "utf-8" {
+		t.Fatalf("prévia inesperada %#v", preview)
+	}
+
+	preview, err = usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "vazio.txt"})
+	if err != nil || preview.Size != 0 || preview.Text != "" {
+		t.Fatalf("esperava uma prévia vazia, veio %#v %v", preview, err)
+	}
+
+	if _, err := usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "foto.bin"}); !errors.Is(err, ErrNotText) {
+		t.Fatalf("esperava ErrNotText, veio %v", err)
+	}
+	if _, err := usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "sumiu.txt"}); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("esperava ErrObjectNotFound, veio %v", err)
+	}
+	if _, err := usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "leia.md", Format: "pdf"}); !errors.Is(err, ErrInvalidPreviewFormat) {
+		t.Fatalf("esperava ErrInvalidPreviewFormat, veio %v", err)
+	}
+	if _, err := usecase.PreviewObject(7, dto.PreviewObjectDto{Key: "leia.md", MaxKB: 4096}); !errors.Is(err, ErrInvalidPreviewSize) {
+		t.Fatalf("esperava ErrInvalidPreviewSize, veio %v", err)
+	}
 }
@@ -2,8 +2,12 @@ package usecase
 
 import (
 	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/jobs"
 	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/oidc"
 	"context"
+	"io"
+	"time"
 
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -15,12 +19,170 @@ type UserRepository interface {
 	GetUsers() ([]models.User, error)
 	GetUserById(int) (*models.User, error)
 	Login(dto.UserLoginDto) (*dto.UserResponseDto, error)
+	GetUserByEmail(string) (*models.User, error)
+	UpdatePassword(userId int, password string) error
+	IsEmailVerified(userId int) (bool, error)
+	MarkEmailVerified(userId int) error
+	IsAdmin(userId int) (bool, error)
+	UpdateProfile(userId int, name string, email string) error
+	SoftDeleteUser(userId int) error
+	DeleteUnverifiedUser(userId int) error
+	SetStorageStatus(userId int, status string, message string) error
+	GetStorageStatus(userId int) (*models.StorageStatus, error)
+	GetStalledStorageUsers(updatedBefore time.Time) ([]int, error)
+}
+
+type SessionRepository interface {
+	GetSessionsByUser(int) ([]models.Session, error)
+	RevokeSession(userId int, sessionId int) (bool, error)
+	RevokeAllSessions(userId int) error
+	RevokeOtherSessions(userId int, currentSessionId int) error
+	CreateSession(userId int, ip string, userAgent string) (int, error)
+	SetRefreshToken(sessionId int, tokenHash string, scopes []string, expiresAt time.Time) (bool, error)
+	RotateRefreshToken(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error)
+}
+
+type MfaRepository interface {
+	GetMfa(userId int) (*models.UserMfa, error)
+	SaveMfaSecret(userId int, secret string) (bool, error)
+	EnableMfa(userId int, step int64, recoveryCodeHashes []string) error
+	MarkStepUsed(userId int, step int64) (bool, error)
+	ConsumeRecoveryCode(userId int, codeHash string) (bool, error)
+}
+
+type UserTokenRepository interface {
+	CreateUserToken(userId int, purpose string, tokenHash string, expiresAt time.Time) error
+	GetUserTokenOwner(purpose string, tokenHash string) (int, error)
+	ConsumeUserToken(purpose string, tokenHash string) (int, error)
+	ConsumeUserTokenForPassword(purpose string, tokenHash string, password string) (int, error)
+}
+
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+type IdentityRepository interface {
+	GetUserIdByIdentity(issuer string, subject string) (int, error)
+	LinkIdentity(userId int, issuer string, subject string) error
+}
+
+type OidcProvider interface {
+	AuthCodeURL(ctx context.Context, login oidc.PendingLogin) (string, error)
+	Exchange(ctx context.Context, code string, login oidc.PendingLogin) (*oidc.Identity, error)
+}
+
+type OidcStateStore interface {
+	Save(login oidc.PendingLogin) error
+	Take(state string) (oidc.PendingLogin, bool, error)
+}
+
+type ApiKeyRepository interface {
+	CreateApiKey(apiKey models.ApiKey, keyHash string) (int, time.Time, error)
+	GetApiKeysByUser(userId int) ([]models.ApiKey, error)
+	RevokeApiKey(userId int, apiKeyId int) (bool, error)
+	RevokeAllApiKeys(userId int) error
+}
+
+type AccessKeyRepository interface {
+	CreateAccessKey(accessKey models.AccessKey, secretKey string) (int, time.Time, error)
+	GetAccessKeysByUser(userId int) ([]models.AccessKey, error)
+	RevokeAccessKey(userId int, accessKeyId int) (bool, error)
+	UseAccessKey(accessKeyId string) (*models.AccessKey, string, error)
+	RevokeAllAccessKeys(userId int) error
+}
+
+type SshKeyRepository interface {
+	CreateSshKey(sshKey models.SshKey) (int, time.Time, error)
+	GetSshKeysByUser(userId int) ([]models.SshKey, error)
+	DeleteSshKey(userId int, sshKeyId int) (bool, error)
+	DeleteAllSshKeys(userId int) error
+	UseSshKey(email string, fingerprint string) (int, error)
+}
+
+type AccountDeletionRepository interface {
+	CreateAccountDeletion(userId int) (*models.AccountDeletion, error)
+	GetAccountDeletion(id int) (*models.AccountDeletion, error)
+	FinishAccountDeletion(id int, message string) error
+}
+
+type UserBucketRepository interface {
+	AddUserBucket(userId int, bucketName string) error
+	GetUserBuckets(userId int) ([]string, error)
+}
+
+type ExtractionRepository interface {
+	CreateExtraction(userId int, archiveKey string, targetPrefix string) (*models.Extraction, error)
+	GetExtraction(id int) (*models.Extraction, error)
+	UpdateExtractionProgress(extraction models.Extraction) error
+	FinishExtraction(extraction models.Extraction, message string) error
+}
+
+type ThumbnailRepository interface {
+	SaveThumbnail(thumbnail models.Thumbnail) error
+	GetThumbnail(userId int, objectKey string, size int) (*models.Thumbnail, error)
+}
+
+// ThumbnailQueue schedules the thumbnails of an object whose upload just
+// finished, or for runAt when the upload goes straight to S3.
+type ThumbnailQueue interface {
+	EnqueueThumbnails(userId int, key string) error
+	EnqueueThumbnailsAt(userId int, key string, runAt time.Time) error
+}
+
+type LoginLockoutRepository interface {
+	GetLockedUntil(email string) (*time.Time, error)
+	RecordLoginFailure(email string) (int, error)
+	LockAccount(email string, until time.Time) error
+	ResetLoginFailures(email string) error
+}
+
+// JobQueue schedules work on the background workers.
+type JobQueue interface {
+	Enqueue(jobType string, payload any) (int, error)
+	EnqueueWith(jobType string, payload any, opts jobs.EnqueueOptions) (int, error)
+}
+
+// ThumbnailJobQueue also finds the job of an object version, to tell a
+// thumbnail still being made from one that failed.
+type ThumbnailJobQueue interface {
+	JobQueue
+	GetJobByUniqueKey(uniqueKey string) (*models.Job, error)
+	RetryJob(id int) (bool, error)
+}
+
+type JobRepository interface {
+	ListJobs(status string, limit int) ([]models.Job, error)
+	GetJob(id int) (*models.Job, error)
+	RetryJob(id int) (bool, error)
+}
+
+// StorageProvisioner creates a user's bucket outside the request that
+// triggered it. Progress is reported through the user's storage status.
+type StorageProvisioner interface {
+	Enqueue(userId int) error
 }
 
 type AwsClient interface {
 	CreateBucket(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error)
+	BucketExists(ctx context.Context, bucket string) (bool, error)
 	ListBuckets(ctx context.Context) ([]types.Bucket, error)
 	ListBucketItems(ctx context.Context, bucket string) ([]types.Object, error)
+	ListBucketPage(ctx context.Context, bucket, prefix, continuationToken string, maxKeys int32) ([]types.Object, string, error)
 	GetObject(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
 	PutObjectPresignedUrl(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
+	EmptyBucket(ctx context.Context, bucket string) error
+	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	DeleteBucket(ctx context.Context, bucket string) error
+	DeleteObject(ctx context.Context, bucket, key string) error
+	CopyObject(ctx context.Context, bucket, sourceKey, destinationKey string) error
+	CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error)
+	PresignUploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, ttl int64) (*v4.PresignedHTTPRequest, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadId string) error
+	ListObjectsDelimited(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error)
+	HeadObject(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error)
+	ReadObject(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error)
+	WriteObject(ctx context.Context, bucket, key string, body io.Reader) (string, error)
+	UploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, body io.Reader, size int64) (string, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
 }
@@ -0,0 +1,618 @@
+package usecase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const JobExtractArchive = "archive.extract"
+
+const (
+	// progressInterval spaces the progress written while an archive is
+	// extracted.
+	progressInterval = 2 * time.Second
+	// minRatioCheckBytes spares small ZIP entries the compression ratio
+	// check, since a short run of repeated text compresses past any ratio.
+	minRatioCheckBytes = 1 << 20
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("só arquivos .zip, .tar.gz, .tgz e .tar podem ser extraídos")
+	ErrNotZipArchive      = errors.New("só é possível listar o conteúdo de arquivos .zip")
+	ErrInvalidArchive     = errors.New("o arquivo está corrompido ou não é do formato indicado pela extensão")
+	ErrArchiveTooLarge    = errors.New("o conteúdo do arquivo passa do limite de extração")
+	ErrTooManyEntries     = errors.New("o arquivo tem mais entradas do que o limite de extração")
+	ErrQuotaExceeded      = errors.New("a extração passaria da cota de armazenamento")
+)
+
+// ExtractLimits bound what one extraction may write. MaxRatio is checked
+// against the size of the archive, and per entry for ZIP archives.
+type ExtractLimits struct {
+	MaxEntries int
+	MaxBytes   int64
+	MaxRatio   int64
+	// Quota caps the bytes a bucket may hold once extracted into; zero is
+	// no cap.
+	Quota int64
+}
+
+// ExtractLimitsFromEnv reads STORAGE_QUOTA_BYTES and EXTRACT_MAX_BYTES,
+// falling back to no quota and 10 GiB per archive.
+func ExtractLimitsFromEnv() ExtractLimits {
+	limits := ExtractLimits{
+		MaxEntries: 10000,
+		MaxBytes:   10 << 30,
+		MaxRatio:   100,
+	}
+
+	if quota, err := strconv.ParseInt(os.Getenv("STORAGE_QUOTA_BYTES"), 10, 64); err == nil && quota > 0 {
+		limits.Quota = quota
+	}
+	if maxBytes, err := strconv.ParseInt(os.Getenv("EXTRACT_MAX_BYTES"), 10, 64); err == nil && maxBytes > 0 {
+		limits.MaxBytes = maxBytes
+	}
+
+	return limits
+}
+
+type extractionPayload struct {
+	ExtractionID int `json:"extractionId"`
+	UserID       int `json:"userId"`
+}
+
+type ExtractUsecase struct {
+	storage              StorageUsecase
+	extractionRepository ExtractionRepository
+	jobQueue             JobQueue
+	limits               ExtractLimits
+}
+
+func NewExtractUsecase(storage StorageUsecase, extractionRepo ExtractionRepository, jobQueue JobQueue, limits ExtractLimits) ExtractUsecase {
+	return ExtractUsecase{
+		storage:              storage,
+		extractionRepository: extractionRepo,
+		jobQueue:             jobQueue,
+		limits:               limits,
+	}
+}
+
+// archiveFormat tells the format of an archive from its extension.
+func archiveFormat(key string) string {
+	lower := strings.ToLower(key)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// ListArchive reads the central directory of a ZIP archive through ranged
+// reads, without downloading the entries.
+func (eu *ExtractUsecase) ListArchive(ctx context.Context, userId int, key string) ([]models.ArchiveFile, error) {
+	if archiveFormat(key) != "zip" {
+		return nil, ErrNotZipArchive
+	}
+
+	archive, _, err := eu.openZip(ctx, userId, key)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]models.ArchiveFile, 0, len(archive.File))
+	for _, file := range archive.File {
+		files = append(files, models.ArchiveFile{
+			Name:           file.Name,
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			Modified:       file.Modified,
+			IsDir:          file.FileInfo().IsDir(),
+		})
+	}
+
+	return files, nil
+}
+
+func (eu *ExtractUsecase) openZip(ctx context.Context, userId int, key string) (*zip.Reader, *rangeReaderAt, error) {
+	info, err := eu.storage.Stat(ctx, userId, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := newRangeReaderAt(ctx, &eu.storage, userId, key, info.Size)
+	archive, err := zip.NewReader(reader, info.Size)
+	if err != nil {
+		if errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrAlgorithm) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, ErrInvalidArchive
+		}
+		return nil, nil, err
+	}
+
+	return archive, reader, nil
+}
+
+// StartExtraction queues the extraction of an archive of the bucket.
+func (eu *ExtractUsecase) StartExtraction(ctx context.Context, userId int, input dto.ExtractArchiveDto) (*models.Extraction, error) {
+	if archiveFormat(input.Key) == "" {
+		return nil, ErrUnsupportedArchive
+	}
+
+	if _, err := eu.storage.Stat(ctx, userId, input.Key); err != nil {
+		return nil, err
+	}
+
+	extraction, err := eu.extractionRepository.CreateExtraction(userId, input.Key, extractTarget(input))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = eu.jobQueue.Enqueue(JobExtractArchive, extractionPayload{
+		ExtractionID: extraction.ID,
+		UserID:       userId,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return extraction, nil
+}
+
+// extractTarget cleans the target prefix; "/" is the root of the bucket.
+// Without one, "fotos/ferias.zip" goes to "fotos/ferias/".
+func extractTarget(input dto.ExtractArchiveDto) string {
+	target := input.Target
+	if target == "" {
+		target = input.Key
+		for _, extension := range []string{".zip", ".tar.gz", ".tgz", ".tar"} {
+			if strings.HasSuffix(strings.ToLower(target), extension) {
+				target = target[:len(target)-len(extension)]
+				break
+			}
+		}
+	}
+
+	target = strings.TrimPrefix(path.Clean("/"+target), "/")
+	if target == "" {
+		return ""
+	}
+	return target + "/"
+}
+
+// GetExtraction returns the extraction when it belongs to the user.
+func (eu *ExtractUsecase) GetExtraction(userId int, id int) (*models.Extraction, error) {
+	extraction, err := eu.extractionRepository.GetExtraction(id)
+	if err != nil {
+		return nil, err
+	}
+	if extraction == nil || extraction.UserID != userId {
+		return nil, nil
+	}
+
+	return extraction, nil
+}
+
+// HandleExtractionJob makes one attempt at an extraction. Each attempt
+// starts over; entries already written are simply written again, and
+// count against the quota only once. Errors no retry can fix fail the
+// extraction right away.
+func (eu *ExtractUsecase) HandleExtractionJob(ctx context.Context, job models.Job) error {
+	var payload extractionPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	extraction, err := eu.extractionRepository.GetExtraction(payload.ExtractionID)
+	if err != nil {
+		return err
+	}
+	if extraction == nil || extraction.Status == models.ExtractionDone || extraction.Status == models.ExtractionFailed {
+		return nil
+	}
+
+	run := &extractionRun{
+		usecase:    eu,
+		ctx:        ctx,
+		extraction: *extraction,
+	}
+	err = run.extract()
+	if err == nil {
+		return eu.extractionRepository.FinishExtraction(run.extraction, "")
+	}
+
+	message, permanent := extractionFailure(err)
+	if !permanent && !jobs.IsLastAttempt(job) {
+		return err
+	}
+
+	if finishErr := eu.extractionRepository.FinishExtraction(run.extraction, message); finishErr != nil {
+		fmt.Println(finishErr)
+	}
+
+	if permanent {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// extractionFailure tells whether err fails the extraction for good, and
+// the message the user sees for it.
+func extractionFailure(err error) (string, bool) {
+	for _, failure := range []error{
+		ErrObjectNotFound, ErrInvalidArchive, ErrArchiveTooLarge, ErrTooManyEntries, ErrQuotaExceeded, ErrUnsupportedArchive,
+	} {
+		if errors.Is(err, failure) {
+			return failure.Error(), true
+		}
+	}
+
+	var corrupt flate.CorruptInputError
+	if errors.As(err, &corrupt) {
+		return ErrInvalidArchive.Error(), true
+	}
+	for _, failure := range []error{zip.ErrFormat, zip.ErrChecksum, gzip.ErrHeader, gzip.ErrChecksum, tar.ErrHeader} {
+		if errors.Is(err, failure) {
+			return ErrInvalidArchive.Error(), true
+		}
+	}
+
+	return "não foi possível extrair o arquivo", false
+}
+
+// extractionRun is one attempt at an extraction. Its counters are what the
+// repository reports as progress.
+type extractionRun struct {
+	usecase    *ExtractUsecase
+	ctx        context.Context
+	extraction models.Extraction
+	budget     extractBudget
+	reportedAt time.Time
+}
+
+// extractBudget counts the bytes written against the limits.
+type extractBudget struct {
+	maxBytes   int64
+	quotaBytes int64
+	written    int64
+	// replaced holds the sizes of the objects already under the target,
+	// such as those a failed attempt wrote. An entry overwriting one of
+	// them gives its size back to the quota.
+	replaced map[string]int64
+}
+
+func (b *extractBudget) replace(key string) {
+	b.quotaBytes += b.replaced[key]
+	delete(b.replaced, key)
+}
+
+func (b *extractBudget) take(n int64) error {
+	b.written += n
+	if b.written > b.maxBytes {
+		return ErrArchiveTooLarge
+	}
+	if b.quotaBytes >= 0 && b.written > b.quotaBytes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (r *extractionRun) extract() error {
+	eu := r.usecase
+	key := r.extraction.ArchiveKey
+
+	info, err := eu.storage.Stat(r.ctx, r.extraction.UserID, key)
+	if err != nil {
+		return err
+	}
+
+	r.budget = extractBudget{
+		maxBytes:   min(eu.limits.MaxBytes, eu.limits.MaxRatio*max(info.Size, 1)),
+		quotaBytes: -1,
+	}
+	if eu.limits.Quota > 0 {
+		objects, err := eu.storage.ListAll(r.ctx, r.extraction.UserID, "")
+		if err != nil {
+			return err
+		}
+
+		var usage int64
+		r.budget.replaced = map[string]int64{}
+		for _, object := range objects {
+			usage += object.Size
+			if strings.HasPrefix(object.Key, r.extraction.TargetPrefix) {
+				r.budget.replaced[object.Key] = object.Size
+			}
+		}
+		r.budget.quotaBytes = max(eu.limits.Quota-usage, 0)
+	}
+
+	r.extraction.Status = models.ExtractionRunning
+	r.extraction.FilesDone, r.extraction.BytesDone, r.extraction.Skipped = 0, 0, 0
+	r.extraction.FilesTotal, r.extraction.BytesTotal = nil, nil
+
+	switch archiveFormat(key) {
+	case "zip":
+		return r.extractZip()
+	case "tar.gz", "tar":
+		return r.extractTar(info.Size)
+	}
+	return ErrUnsupportedArchive
+}
+
+func (r *extractionRun) extractZip() error {
+	eu := r.usecase
+	archive, reader, err := eu.openZip(r.ctx, r.extraction.UserID, r.extraction.ArchiveKey)
+	if err != nil {
+		return err
+	}
+
+	if len(archive.File) > eu.limits.MaxEntries {
+		return ErrTooManyEntries
+	}
+
+	// The directory declares the sizes; a bomb is refused before anything
+	// is written, and the entries are held to what they declare.
+	var files int
+	var total, freed int64
+	for _, file := range archive.File {
+		size := int64(min(file.UncompressedSize64, 1<<62))
+		total += size
+		if total > r.budget.maxBytes {
+			return ErrArchiveTooLarge
+		}
+		if size > minRatioCheckBytes && size/max(int64(file.CompressedSize64), 1) > eu.limits.MaxRatio {
+			return ErrArchiveTooLarge
+		}
+		if !file.FileInfo().IsDir() {
+			files++
+		}
+		if name, ok := safeEntryName(file.Name); ok {
+			freed += r.budget.replaced[r.extraction.TargetPrefix+name]
+		}
+	}
+	if r.budget.quotaBytes >= 0 && total-freed > r.budget.quotaBytes {
+		return ErrQuotaExceeded
+	}
+	r.extraction.FilesTotal = &files
+	r.extraction.BytesTotal = &total
+	r.report(true)
+
+	for _, file := range archive.File {
+		name, ok := safeEntryName(file.Name)
+		if !ok || file.Flags&0x1 != 0 || file.Mode()&os.ModeSymlink != 0 {
+			r.extraction.Skipped++
+			continue
+		}
+
+		if strings.HasSuffix(name, "/") {
+			if err := r.write(name, strings.NewReader("")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var decompress func(io.Reader) io.ReadCloser
+		switch file.Method {
+		case zip.Store:
+			decompress = io.NopCloser
+		case zip.Deflate:
+			decompress = flate.NewReader
+		default:
+			r.extraction.Skipped++
+			continue
+		}
+
+		offset, err := file.DataOffset()
+		if err != nil {
+			return err
+		}
+		body, err := reader.section(offset, int64(file.CompressedSize64))
+		if err != nil {
+			return err
+		}
+
+		content := decompress(body)
+		err = r.write(name, &zipEntryReader{
+			reader:   content,
+			expected: file.UncompressedSize64,
+			crc:      file.CRC32,
+			hash:     crc32.NewIEEE(),
+		})
+		content.Close()
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// zipEntryReader fails an entry whose content does not match the size and
+// checksum of the directory, before it is stored.
+type zipEntryReader struct {
+	reader   io.Reader
+	expected uint64
+	read     uint64
+	crc      uint32
+	hash     hash.Hash32
+}
+
+func (z *zipEntryReader) Read(p []byte) (int, error) {
+	n, err := z.reader.Read(p)
+	z.read += uint64(n)
+	z.hash.Write(p[:n])
+
+	if z.read > z.expected {
+		return 0, ErrInvalidArchive
+	}
+	if err == io.EOF && (z.read != z.expected || z.hash.Sum32() != z.crc) {
+		return 0, ErrInvalidArchive
+	}
+	if err == io.ErrUnexpectedEOF {
+		return n, ErrInvalidArchive
+	}
+	return n, err
+}
+
+func (r *extractionRun) extractTar(size int64) error {
+	eu := r.usecase
+	stream, err := eu.storage.ReadRange(r.ctx, r.extraction.UserID, r.extraction.ArchiveKey, "")
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+
+	var content io.Reader = stream.Body
+	if archiveFormat(r.extraction.ArchiveKey) == "tar.gz" {
+		gzipReader, err := gzip.NewReader(stream.Body)
+		if err != nil {
+			return ErrInvalidArchive
+		}
+		defer gzipReader.Close()
+		content = gzipReader
+	}
+
+	archive := tar.NewReader(content)
+	entries := 0
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrInvalidArchive
+			}
+			return err
+		}
+
+		entries++
+		if entries > eu.limits.MaxEntries {
+			return ErrTooManyEntries
+		}
+
+		name, ok := safeEntryName(header.Name)
+		switch {
+		case !ok:
+			r.extraction.Skipped++
+		case header.Typeflag == tar.TypeDir:
+			if !strings.HasSuffix(name, "/") {
+				name += "/"
+			}
+			err = r.write(name, strings.NewReader(""))
+		case header.Typeflag == tar.TypeReg && !strings.HasSuffix(name, "/"):
+			err = r.write(name, &tarEntryReader{reader: archive})
+		default:
+			// Links, devices and the like have no object to become.
+			r.extraction.Skipped++
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// tarEntryReader fails an entry the archive ends inside of, before it is
+// stored.
+type tarEntryReader struct {
+	reader io.Reader
+}
+
+func (t *tarEntryReader) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, ErrInvalidArchive
+	}
+	return n, err
+}
+
+// safeEntryName turns an entry name into a key under the target. Names
+// that are absolute or climb out with ".." are refused, so that no entry
+// lands outside the target.
+func safeEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+
+	clean := path.Clean(name)
+	if clean == "." {
+		return "", false
+	}
+	if strings.HasSuffix(name, "/") {
+		clean += "/"
+	}
+	return clean, true
+}
+
+// write stores one entry under the target, counting it against the
+// budget as it streams.
+func (r *extractionRun) write(name string, content io.Reader) error {
+	key := r.extraction.TargetPrefix + name
+	r.budget.replace(key)
+
+	counted := &budgetReader{reader: content, run: r}
+	_, err := r.usecase.storage.Write(r.ctx, r.extraction.UserID, key, counted)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(name, "/") {
+		r.extraction.FilesDone++
+	}
+	r.report(false)
+	return nil
+}
+
+type budgetReader struct {
+	reader io.Reader
+	run    *extractionRun
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.run.extraction.BytesDone += int64(n)
+	if budgetErr := b.run.budget.take(int64(n)); budgetErr != nil {
+		return 0, budgetErr
+	}
+	return n, err
+}
+
+// report stores the progress, at most once per progressInterval unless
+// forced.
+func (r *extractionRun) report(force bool) {
+	if !force && time.Since(r.reportedAt) < progressInterval {
+		return
+	}
+	r.reportedAt = time.Now()
+
+	if err := r.usecase.extractionRepository.UpdateExtractionProgress(r.extraction); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,380 @@
+package usecase
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type fakeExtractionRepo struct {
+	extraction models.Extraction
+	message    string
+}
+
+func (f *fakeExtractionRepo) CreateExtraction(userId int, archiveKey string, targetPrefix string) (*models.Extraction, error) {
+	f.extraction = models.Extraction{ID: 1, UserID: userId, ArchiveKey: archiveKey, TargetPrefix: targetPrefix, Status: models.ExtractionPending}
+	return &f.extraction, nil
+}
+
+func (f *fakeExtractionRepo) GetExtraction(id int) (*models.Extraction, error) {
+	extraction := f.extraction
+	return &extraction, nil
+}
+
+func (f *fakeExtractionRepo) UpdateExtractionProgress(extraction models.Extraction) error {
+	f.extraction = extraction
+	return nil
+}
+
+func (f *fakeExtractionRepo) FinishExtraction(extraction models.Extraction, message string) error {
+	f.extraction = extraction
+	f.extraction.Status = models.ExtractionDone
+	if message != "" {
+		f.extraction.Status = models.ExtractionFailed
+	}
+	f.message = message
+	return nil
+}
+
+// memoryBucket is the bucket of user 7 held in memory, with ranged reads.
+type memoryBucket struct {
+	objects map[string][]byte
+	reads   []string
+}
+
+func (m *memoryBucket) client() *fakeAwsClient {
+	return &fakeAwsClient{
+		listBucketsFn: func(context.Context) ([]types.Bucket, error) {
+			return []types.Bucket{{Name: aws.String(userBucketName(7))}}, nil
+		},
+		listBucketPageFn: func(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error) {
+			var objects []types.Object
+			for key, data := range m.objects {
+				objects = append(objects, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(data)))})
+			}
+			return objects, "", nil
+		},
+		headObjectFn: func(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+			data, ok := m.objects[key]
+			if !ok {
+				return nil, &types.NotFound{}
+			}
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(data))), ETag: aws.String(memoryETag(data))}, nil
+		},
+		readObjectFn: func(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error) {
+			data, ok := m.objects[key]
+			if !ok {
+				return nil, &types.NoSuchKey{}
+			}
+			m.reads = append(m.reads, byteRange)
+
+			if byteRange != "" {
+				var start, end int
+				if _, err := fmt.Sscanf(byteRange, "bytes=%d-%d", &start, &end); err != nil {
+					return nil, err
+				}
+				data = data[start : end+1]
+			}
+			return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ContentLength: aws.Int64(int64(len(data))), ETag: aws.String(memoryETag(m.objects[key]))}, nil
+		},
+		writeObjectFn: func(ctx context.Context, bucket, key string, body io.Reader) (string, error) {
+			data, err := io.ReadAll(body)
+			if err != nil {
+				return "", err
+			}
+			m.objects[key] = data
+			return `"etag"`, nil
+		},
+	}
+}
+
+func memoryETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+}
+
+func buildZip(t *testing.T, files map[string]string, method uint16) []byte {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		entry, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatalf("não foi possível criar o ZIP: %v", err)
+		}
+		io.WriteString(entry, content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("não foi possível criar o ZIP: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func extractionJob(t *testing.T, queue *fakeJobQueue) models.Job {
+	if len(queue.jobs) != 1 || queue.jobs[0].Type != JobExtractArchive {
+		t.Fatalf("esperava um job de extração, veio %#v", queue.jobs)
+	}
+	return queue.jobs[0]
+}
+
+func TestExtractUsecaseListsZipWithRangeReads(t *testing.T) {
+	files := map[string]string{"docs/": "", "docs/a.txt": "olá", "b.bin": strings.Repeat("x", 3*rangeBlockSize)}
+	bucket := &memoryBucket{objects: map[string][]byte{"projeto.zip": buildZip(t, files, zip.Store)}}
+	extract := NewExtractUsecase(NewStorageUsecase(bucket.client(), nil), &fakeExtractionRepo{}, &fakeJobQueue{}, ExtractLimitsFromEnv())
+
+	entries, err := extract.ListArchive(context.Background(), 7, "projeto.zip")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("esperava 3 entradas, veio %#v", entries)
+	}
+
+	// Only the end of the archive, where the directory is, is read.
+	if len(bucket.reads) > 2 {
+		t.Fatalf("esperava no máximo duas leituras, veio %v", bucket.reads)
+	}
+	for _, byteRange := range bucket.reads {
+		var start int
+		if _, err := fmt.Sscanf(byteRange, "bytes=%d-", &start); err != nil || start < 2*rangeBlockSize {
+			t.Fatalf("leitura inesperada %s", byteRange)
+		}
+	}
+
+	if _, err := extract.ListArchive(context.Background(), 7, "projeto.tar.gz"); err != ErrNotZipArchive {
+		t.Fatalf("esperava ErrNotZipArchive, veio %v", err)
+	}
+}
+
+func TestExtractUsecaseExtractsZipAndSkipsUnsafePaths(t *testing.T) {
+	files := map[string]string{
+		"assets/":          "",
+		"assets/logo.svg":  "<svg/>",
+		"assets/texto.txt": strings.Repeat("cloud ", 1000),
+		"../fora.txt":      "não deveria sair da pasta",
+		"/etc/senha":       "nem esta",
+	}
+	bucket := &memoryBucket{objects: map[string][]byte{"up/projeto.zip": buildZip(t, files, zip.Deflate)}}
+	repo := &fakeExtractionRepo{}
+	queue := &fakeJobQueue{}
+	extract := NewExtractUsecase(NewStorageUsecase(bucket.client(), nil), repo, queue, ExtractLimitsFromEnv())
+
+	extraction, err := extract.StartExtraction(context.Background(), 7, dto.ExtractArchiveDto{Key: "up/projeto.zip"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if extraction.TargetPrefix != "up/projeto/" {
+		t.Fatalf("esperava o destino up/projeto/, veio %s", extraction.TargetPrefix)
+	}
+
+	if err := extract.HandleExtractionJob(context.Background(), extractionJob(t, queue)); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if repo.extraction.Status != models.ExtractionDone || repo.message != "" {
+		t.Fatalf("a extração deveria terminar, veio %#v (%s)", repo.extraction, repo.message)
+	}
+	if repo.extraction.FilesDone != 2 || *repo.extraction.FilesTotal != 4 || repo.extraction.Skipped != 2 {
+		t.Fatalf("progresso inesperado %#v", repo.extraction)
+	}
+
+	if string(bucket.objects["up/projeto/assets/texto.txt"]) != files["assets/texto.txt"] {
+		t.Fatalf("conteúdo extraído inesperado")
+	}
+	if _, ok := bucket.objects["up/projeto/assets/"]; !ok {
+		t.Fatalf("a pasta assets/ deveria ser criada")
+	}
+	for key := range bucket.objects {
+		if !strings.HasPrefix(key, "up/") {
+			t.Fatalf("nada deveria ser gravado fora do destino, veio %s", key)
+		}
+	}
+	if len(bucket.objects) != 4 {
+		t.Fatalf("objetos inesperados %v", len(bucket.objects))
+	}
+}
+
+func TestExtractUsecaseRefusesZipBombAndCorruptEntries(t *testing.T) {
+	bomb := buildZip(t, map[string]string{"zeros.bin": strings.Repeat("\x00", 4<<20)}, zip.Deflate)
+
+	// The checksum of the single entry is the 4 bytes before its sizes in
+	// the central directory, at offset 16 of the record.
+	corrupt := buildZip(t, map[string]string{"a.txt": "conteúdo"}, zip.Store)
+	directory := bytes.LastIndex(corrupt, []byte("PK\x01\x02"))
+	corrupt[directory+16] ^= 0xff
+
+	for name, archive := range map[string][]byte{"bomba.zip": bomb, "corrompido.zip": corrupt} {
+		bucket := &memoryBucket{objects: map[string][]byte{name: archive}}
+		repo := &fakeExtractionRepo{}
+		queue := &fakeJobQueue{}
+		extract := NewExtractUsecase(NewStorageUsecase(bucket.client(), nil), repo, queue, ExtractLimitsFromEnv())
+
+		if _, err := extract.StartExtraction(context.Background(), 7, dto.ExtractArchiveDto{Key: name, Target: "/"}); err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+
+		err := extract.HandleExtractionJob(context.Background(), extractionJob(t, queue))
+		if !jobs.IsPermanent(err) {
+			t.Fatalf("%s: esperava um erro permanente, veio %v", name, err)
+		}
+		if repo.extraction.Status != models.ExtractionFailed {
+			t.Fatalf("%s: a extração deveria falhar, veio %#v", name, repo.extraction)
+		}
+		if len(bucket.objects) != 1 {
+			t.Fatalf("%s: nada deveria ser gravado, veio %d objetos", name, len(bucket.objects))
+		}
+	}
+}
+
+func TestExtractUsecaseCountsTarGzAgainstQuota(t *testing.T) {
+	var buffer bytes.Buffer
+	compressed := gzip.NewWriter(&buffer)
+	archive := tar.NewWriter(compressed)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		content := strings.Repeat(name, 100)
+		archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		io.WriteString(archive, content)
+	}
+	archive.WriteHeader(&tar.Header{Name: "atalho", Linkname: "a.txt", Typeflag: tar.TypeSymlink})
+	archive.Close()
+	compressed.Close()
+
+	bucket := &memoryBucket{objects: map[string][]byte{"pacote.tgz": buffer.Bytes()}}
+	repo := &fakeExtractionRepo{}
+	queue := &fakeJobQueue{}
+	limits := ExtractLimitsFromEnv()
+
+	// Room for the archive already stored and two of its three files.
+	limits.Quota = int64(buffer.Len()) + 1100
+	extract := NewExtractUsecase(NewStorageUsecase(bucket.client(), nil), repo, queue, limits)
+
+	if _, err := extract.StartExtraction(context.Background(), 7, dto.ExtractArchiveDto{Key: "pacote.tgz", Target: "pacote"}); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	err := extract.HandleExtractionJob(context.Background(), extractionJob(t, queue))
+	if !jobs.IsPermanent(err) || repo.message != ErrQuotaExceeded.Error() {
+		t.Fatalf("esperava a cota estourada, veio %v (%s)", err, repo.message)
+	}
+	if repo.extraction.FilesDone != 2 {
+		t.Fatalf("esperava dois arquivos extraídos, veio %#v", repo.extraction)
+	}
+	if _, ok := bucket.objects["pacote/c.txt"]; ok {
+		t.Fatalf("o arquivo que estoura a cota não deveria ser gravado")
+	}
+
+	// Without the quota, the link is left out and the rest extracted.
+	bucket.objects = map[string][]byte{"pacote.tgz": buffer.Bytes()}
+	repo.extraction.Status = models.ExtractionPending
+	extract = NewExtractUsecase(NewStorageUsecase(bucket.client(), nil), repo, queue, ExtractLimitsFromEnv())
+
+	if err := extract.HandleExtractionJob(context.Background(), extractionJob(t, queue)); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if repo.extraction.FilesDone != 3 || repo.extraction.Skipped != 1 || repo.extraction.FilesTotal != nil {
+		t.Fatalf("progresso inesperado %#v", repo.extraction)
+	}
+
+	var payload extractionPayload
+	json.Unmarshal(queue.jobs[0].Payload, &payload)
+	if payload.ExtractionID != 1 || payload.UserID != 7 {
+		t.Fatalf("payload inesperado %#v", payload)
+	}
+}
+
+func TestExtractUsecaseRejectsTruncatedTar(t *testing.T) {
+	var buffer bytes.Buffer
+	archive := tar.NewWriter(&buffer)
+	archive.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0o644, Size: 1000, Typeflag: tar.TypeReg})
+	io.WriteString(archive, strings.Repeat("a", 1000))
+	archive.Close()
+
+	// The archive ends halfway through its only entry.
+	bucket := &memoryBucket{objects: map[string][]byte{"pacote.tar": buffer.Bytes()[:1000]}}
+	repo := &fakeExtractionRepo{}
+	queue := &fakeJobQueue{}
+	client := bucket.client()
+	write := client.writeObjectFn
+	var readErr error
+	client.writeObjectFn = func(ctx context.Context, bucketName, key string, body io.Reader) (string, error) {
+		etag, err := write(ctx, bucketName, key, body)
+		readErr = err
+		return etag, err
+	}
+	extract := NewExtractUsecase(NewStorageUsecase(client, nil), repo, queue, ExtractLimitsFromEnv())
+
+	if _, err := extract.StartExtraction(context.Background(), 7, dto.ExtractArchiveDto{Key: "pacote.tar", Target: "pacote"}); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	err := extract.HandleExtractionJob(context.Background(), extractionJob(t, queue))
+	if !jobs.IsPermanent(err) || repo.message != ErrInvalidArchive.Error() {
+		t.Fatalf("esperava o arquivo inválido, veio %v (%s)", err, repo.message)
+	}
+	// Storage must see the entry fail, not a body that merely ended.
+	if readErr != ErrInvalidArchive {
+		t.Fatalf("o armazenamento deveria ler ErrInvalidArchive, veio %v", readErr)
+	}
+	if _, ok := bucket.objects["pacote/a.txt"]; ok {
+		t.Fatalf("a entrada cortada não deveria ser gravada")
+	}
+}
+
+func TestExtractUsecaseRetryDoesNotCountItsOwnFilesAgainstQuota(t *testing.T) {
+	files := map[string]string{"a.txt": strings.Repeat("a", 300), "b.txt": strings.Repeat("b", 300), "c.txt": strings.Repeat("c", 300)}
+	archive := buildZip(t, files, zip.Store)
+	bucket := &memoryBucket{objects: map[string][]byte{"pacote.zip": archive}}
+	repo := &fakeExtractionRepo{}
+	queue := &fakeJobQueue{}
+	limits := ExtractLimitsFromEnv()
+
+	// Room for the archive and exactly what it holds.
+	limits.Quota = int64(len(archive)) + 900
+
+	client := bucket.client()
+	write := client.writeObjectFn
+	writes := 0
+	client.writeObjectFn = func(ctx context.Context, bucketName, key string, body io.Reader) (string, error) {
+		// The first attempt drops on the last of the three files.
+		writes++
+		if writes == 3 {
+			return "", fmt.Errorf("conexão perdida")
+		}
+		return write(ctx, bucketName, key, body)
+	}
+	extract := NewExtractUsecase(NewStorageUsecase(client, nil), repo, queue, limits)
+
+	if _, err := extract.StartExtraction(context.Background(), 7, dto.ExtractArchiveDto{Key: "pacote.zip"}); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	job := extractionJob(t, queue)
+	job.Attempts, job.MaxAttempts = 1, 3
+	if err := extract.HandleExtractionJob(context.Background(), job); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("esperava uma falha que se resolve tentando de novo, veio %v", err)
+	}
+	if len(bucket.objects) != 3 {
+		t.Fatalf("a primeira tentativa deveria ter deixado dois arquivos, veio %d objetos", len(bucket.objects))
+	}
+
+	job.Attempts = 2
+	if err := extract.HandleExtractionJob(context.Background(), job); err != nil {
+		t.Fatalf("a nova tentativa não deveria estourar a cota, veio %v (%s)", err, repo.message)
+	}
+	if repo.extraction.FilesDone != 3 || len(bucket.objects) != 4 {
+		t.Fatalf("progresso inesperado %#v", repo.extraction)
+	}
+}
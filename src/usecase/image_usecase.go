@@ -0,0 +1,402 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"golang.org/x/image/draw"
+)
+
+const (
+	FitCover   = "cover"
+	FitContain = "contain"
+	FitFill    = "fill"
+)
+
+var (
+	ErrImagesDisabled        = errors.New("a transformação de imagens não está habilitada neste servidor")
+	ErrInvalidImageParams    = errors.New("parâmetros de imagem inválidos")
+	ErrInvalidImageSignature = errors.New("assinatura da imagem inválida")
+	ErrImageURLExpired       = errors.New("o link da imagem expirou")
+)
+
+// imageContentTypes are the formats images can be converted to.
+var imageContentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// ImageConfig is read by ImageConfigFromEnv. Transformations are off while
+// Bucket or SigningKey is empty.
+type ImageConfig struct {
+	// Bucket holds the transformed images; it is the thumbnail bucket.
+	Bucket     string
+	SigningKey []byte
+	// URLTTL is how long a signed URL lasts, rounded up to the hour so the
+	// same URL is handed out, and cached by browsers, for an hour.
+	URLTTL       time.Duration
+	MaxDimension int
+	// Sizes are the widths and heights that may be asked for, so that an
+	// image has a bounded number of variants.
+	Sizes          []int
+	MaxSourceBytes int64
+	MaxPixels      int
+	// MaxTransforms is how many images are transformed at once; each one
+	// holds its decoded pixels in memory.
+	MaxTransforms int
+}
+
+// ImageConfigFromEnv reads THUMBNAIL_BUCKET, IMAGE_SIGNING_KEY,
+// IMAGE_URL_TTL, IMAGE_MAX_DIMENSION, the largest width or height
+// returned, IMAGE_SIZES, a comma separated list such as "128,256,512",
+// and IMAGE_MAX_TRANSFORMS.
+func ImageConfigFromEnv() ImageConfig {
+	config := ImageConfig{
+		Bucket:         os.Getenv("THUMBNAIL_BUCKET"),
+		SigningKey:     []byte(os.Getenv("IMAGE_SIGNING_KEY")),
+		URLTTL:         24 * time.Hour,
+		MaxDimension:   2048,
+		Sizes:          []int{32, 64, 128, 256, 320, 400, 512, 640, 800, 1024, 1280, 1600, 2048},
+		MaxSourceBytes: 50 << 20,
+		MaxPixels:      50_000_000,
+		MaxTransforms:  2,
+	}
+
+	if ttl, err := time.ParseDuration(os.Getenv("IMAGE_URL_TTL")); err == nil && ttl > 0 {
+		config.URLTTL = ttl
+	}
+	if dimension, err := strconv.Atoi(os.Getenv("IMAGE_MAX_DIMENSION")); err == nil && dimension > 0 {
+		config.MaxDimension = dimension
+	}
+	if spec := os.Getenv("IMAGE_SIZES"); spec != "" {
+		var sizes []int
+		for _, field := range strings.Split(spec, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err == nil && size > 0 {
+				sizes = append(sizes, size)
+			}
+		}
+		if len(sizes) > 0 {
+			slices.Sort(sizes)
+			config.Sizes = slices.Compact(sizes)
+		}
+	}
+	config.Sizes = slices.DeleteFunc(config.Sizes, func(size int) bool { return size > config.MaxDimension })
+	if transforms, err := strconv.Atoi(os.Getenv("IMAGE_MAX_TRANSFORMS")); err == nil && transforms > 0 {
+		config.MaxTransforms = transforms
+	}
+
+	return config
+}
+
+// ImageParams describe a transformation. A zero Width or Height follows
+// the proportions of the image; cover and fill need both.
+type ImageParams struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+func (p ImageParams) String() string {
+	return fmt.Sprintf("%dx%d-%s.%s", p.Width, p.Height, p.Fit, p.Format)
+}
+
+// ImageVariant is a transformation of one version of an object.
+type ImageVariant struct {
+	UserID        int
+	Key           string
+	Params        ImageParams
+	SourceETag    string
+	DerivativeKey string
+	ContentType   string
+	ETag          string
+}
+
+type ImageUsecase struct {
+	storage StorageUsecase
+	config  ImageConfig
+	now     func() time.Time
+	// transforms holds a slot per image being transformed.
+	transforms chan struct{}
+}
+
+func NewImageUsecase(storage StorageUsecase, config ImageConfig) ImageUsecase {
+	return ImageUsecase{
+		storage:    storage,
+		config:     config,
+		now:        time.Now,
+		transforms: make(chan struct{}, max(config.MaxTransforms, 1)),
+	}
+}
+
+func (iu *ImageUsecase) enabled() bool {
+	return iu.config.Bucket != "" && len(iu.config.SigningKey) > 0
+}
+
+// normalize checks the params against the limits and fills in the
+// defaults, so equal requests are signed and cached alike.
+func (iu *ImageUsecase) normalize(key string, params ImageParams) (ImageParams, error) {
+	if params.Width < 0 || params.Height < 0 || params.Width > iu.config.MaxDimension || params.Height > iu.config.MaxDimension {
+		return params, fmt.Errorf("%w: a largura e a altura não podem passar de %d pixels", ErrInvalidImageParams, iu.config.MaxDimension)
+	}
+	if params.Width == 0 && params.Height == 0 {
+		return params, fmt.Errorf("%w: informe a largura ou a altura", ErrInvalidImageParams)
+	}
+	for _, dimension := range []int{params.Width, params.Height} {
+		if dimension != 0 && !slices.Contains(iu.config.Sizes, dimension) {
+			return params, fmt.Errorf("%w: a largura e a altura devem ser uma destas medidas: %s", ErrInvalidImageParams, strings.ReplaceAll(strings.Trim(fmt.Sprint(iu.config.Sizes), "[]"), " ", ", "))
+		}
+	}
+
+	switch params.Fit {
+	case "":
+		params.Fit = FitContain
+	case FitContain:
+	case FitCover, FitFill:
+		if params.Width == 0 || params.Height == 0 {
+			return params, fmt.Errorf("%w: %s precisa da largura e da altura", ErrInvalidImageParams, params.Fit)
+		}
+	default:
+		return params, fmt.Errorf("%w: fit deve ser cover, contain ou fill", ErrInvalidImageParams)
+	}
+
+	params.Format = strings.ToLower(params.Format)
+	switch params.Format {
+	case "":
+		// Photos stay JPEGs; the rest may have transparency to keep.
+		params.Format = "png"
+		if extension := strings.ToLower(path.Ext(key)); extension == ".jpg" || extension == ".jpeg" {
+			params.Format = "jpeg"
+		}
+	case "jpg":
+		params.Format = "jpeg"
+	default:
+		if _, ok := imageContentTypes[params.Format]; !ok {
+			return params, fmt.Errorf("%w: format deve ser jpeg, png ou webp", ErrInvalidImageParams)
+		}
+	}
+
+	return params, nil
+}
+
+func (iu *ImageUsecase) signature(userId int, key string, params ImageParams, expires int64) string {
+	mac := hmac.New(sha256.New, iu.config.SigningKey)
+	fmt.Fprintf(mac, "%d\n%s\n%s\n%d", userId, key, params, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignImageURL returns the path, under /images, that serves the object
+// transformed with params to anyone holding it until it expires.
+func (iu *ImageUsecase) SignImageURL(userId int, key string, params ImageParams) (string, error) {
+	if !iu.enabled() {
+		return "", ErrImagesDisabled
+	}
+	if !isThumbnailSource(key) {
+		return "", ErrNotImage
+	}
+
+	params, err := iu.normalize(key, params)
+	if err != nil {
+		return "", err
+	}
+
+	expires := iu.now().Add(iu.config.URLTTL).Truncate(time.Hour).Add(time.Hour).Unix()
+
+	query := url.Values{}
+	query.Set("u", strconv.Itoa(userId))
+	query.Set("w", strconv.Itoa(params.Width))
+	query.Set("h", strconv.Itoa(params.Height))
+	query.Set("fit", params.Fit)
+	query.Set("format", params.Format)
+	query.Set("exp", strconv.FormatInt(expires, 10))
+	query.Set("sig", iu.signature(userId, key, params, expires))
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return "/images/" + strings.Join(segments, "/") + "?" + query.Encode(), nil
+}
+
+// VerifyImageURL checks the signature of an /images URL and returns its
+// params.
+func (iu *ImageUsecase) VerifyImageURL(userId int, key string, params ImageParams, expires int64, signature string) (ImageParams, error) {
+	if !iu.enabled() {
+		return params, ErrImagesDisabled
+	}
+
+	params, err := iu.normalize(key, params)
+	if err != nil {
+		return params, err
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(iu.signature(userId, key, params, expires))) {
+		return params, ErrInvalidImageSignature
+	}
+	if iu.now().Unix() > expires {
+		return params, ErrImageURLExpired
+	}
+
+	return params, nil
+}
+
+// Variant finds the current version of the object, which is all that is
+// needed to revalidate a cached copy.
+func (iu *ImageUsecase) Variant(ctx context.Context, userId int, key string, params ImageParams) (*ImageVariant, error) {
+	if !isThumbnailSource(key) {
+		return nil, ErrNotImage
+	}
+
+	source, err := iu.storage.Stat(ctx, userId, key)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", key, source.ETag, params)))
+
+	return &ImageVariant{
+		UserID:        userId,
+		Key:           key,
+		Params:        params,
+		SourceETag:    source.ETag,
+		DerivativeKey: fmt.Sprintf("%d/images/%x.%s", userId, digest, params.Format),
+		ContentType:   imageContentTypes[params.Format],
+		ETag:          `"` + hex.EncodeToString(digest[:16]) + `"`,
+	}, nil
+}
+
+// OpenImage streams the variant from the derivatives store, transforming
+// the object and storing the result the first time it is asked for. No
+// more than MaxTransforms images are transformed at once; the others wait
+// for a slot.
+func (iu *ImageUsecase) OpenImage(ctx context.Context, variant *ImageVariant) (io.ReadCloser, int64, error) {
+	cached, err := iu.storage.AwsService.ReadObject(ctx, iu.config.Bucket, variant.DerivativeKey, "")
+	if err == nil {
+		return cached.Body, aws.ToInt64(cached.ContentLength), nil
+	}
+	if !errors.Is(storageError(err), ErrObjectNotFound) {
+		fmt.Println(err)
+	}
+
+	select {
+	case iu.transforms <- struct{}{}:
+		defer func() { <-iu.transforms }()
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+
+	bucketName := userBucketName(variant.UserID)
+
+	output, err := iu.storage.AwsService.ReadObject(ctx, bucketName, variant.Key, "")
+	if err != nil {
+		return nil, 0, storageError(err)
+	}
+	defer output.Body.Close()
+
+	source, err := readImage(output, iu.config.MaxSourceBytes, iu.config.MaxPixels)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	content, err := encodeImage(transformImage(source, variant.Params), variant.Params.Format)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A version uploaded since the variant was found is served, but not
+	// stored under the older ETag.
+	if aws.ToString(output.ETag) == variant.SourceETag {
+		if _, err := iu.storage.AwsService.WriteObject(ctx, iu.config.Bucket, variant.DerivativeKey, bytes.NewReader(content)); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), int64(len(content)), nil
+}
+
+// transformImage scales the image into the box of params. Contain and
+// cover never enlarge it; cover crops the centre to the proportions of the
+// box, and fill stretches it to the box.
+func transformImage(source image.Image, params ImageParams) *image.RGBA {
+	bounds := source.Bounds()
+	sourceWidth, sourceHeight := float64(bounds.Dx()), float64(bounds.Dy())
+	width, height := params.Width, params.Height
+	crop := bounds
+
+	switch params.Fit {
+	case FitFill:
+	case FitCover:
+		scale := max(float64(width)/sourceWidth, float64(height)/sourceHeight)
+		if scale > 1 {
+			width, height = max(int(float64(width)/scale), 1), max(int(float64(height)/scale), 1)
+			scale = 1
+		}
+		cropWidth := min(bounds.Dx(), int(math.Round(float64(width)/scale)))
+		cropHeight := min(bounds.Dy(), int(math.Round(float64(height)/scale)))
+		crop.Min = crop.Min.Add(image.Pt((bounds.Dx()-cropWidth)/2, (bounds.Dy()-cropHeight)/2))
+		crop.Max = crop.Min.Add(image.Pt(cropWidth, cropHeight))
+	default:
+		scale := 1.0
+		if width > 0 {
+			scale = min(scale, float64(width)/sourceWidth)
+		}
+		if height > 0 {
+			scale = min(scale, float64(height)/sourceHeight)
+		}
+		width, height = max(int(math.Round(sourceWidth*scale)), 1), max(int(math.Round(sourceHeight*scale)), 1)
+	}
+
+	transformed := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(transformed, transformed.Bounds(), source, crop, draw.Src, nil)
+	return transformed
+}
+
+// encodeImage writes the image in format. WebPs are lossless; JPEGs lose
+// their transparency over white.
+func encodeImage(img *image.RGBA, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+
+	switch format {
+	case "png":
+		err = png.Encode(&buffer, img)
+	case "webp":
+		err = nativewebp.Encode(&buffer, img, nil)
+	default:
+		if !img.Opaque() {
+			flattened := image.NewRGBA(img.Bounds())
+			draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+			draw.Draw(flattened, flattened.Bounds(), img, img.Bounds().Min, draw.Over)
+			img = flattened
+		}
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+func newTestImages(bucket *memoryBucket) ImageUsecase {
+	config := ImageConfigFromEnv()
+	config.Bucket = "miniaturas"
+	config.SigningKey = []byte("segredo")
+	images := NewImageUsecase(NewStorageUsecase(bucket.client(), nil), config)
+	images.now = func() time.Time { return time.Date(2026, 3, 1, 10, 20, 0, 0, time.UTC) }
+	return images
+}
+
+// verifySigned reads back a signed URL the way the /images route does.
+func verifySigned(images *ImageUsecase, signed string) (string, ImageParams, error) {
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		return "", ImageParams{}, err
+	}
+	query := parsed.Query()
+	userId, _ := strconv.Atoi(query.Get("u"))
+	width, _ := strconv.Atoi(query.Get("w"))
+	height, _ := strconv.Atoi(query.Get("h"))
+	expires, _ := strconv.ParseInt(query.Get("exp"), 10, 64)
+
+	key := strings.TrimPrefix(parsed.Path, "/images/")
+	params, err := images.VerifyImageURL(userId, key, ImageParams{Width: width, Height: height, Fit: query.Get("fit"), Format: query.Get("format")}, expires, query.Get("sig"))
+	return key, params, err
+}
+
+func TestImageUsecaseSignsAndVerifiesUrls(t *testing.T) {
+	images := newTestImages(&memoryBucket{objects: map[string][]byte{}})
+
+	signed, err := images.SignImageURL(7, "fotos/férias 2025.jpg", ImageParams{Width: 400, Height: 320, Fit: FitCover, Format: "WEBP"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !strings.HasPrefix(signed, "/images/fotos/f%C3%A9rias%202025.jpg?") {
+		t.Fatalf("link inesperado %s", signed)
+	}
+
+	// The same URL is handed out for the rest of the hour.
+	images.now = func() time.Time { return time.Date(2026, 3, 1, 10, 50, 0, 0, time.UTC) }
+	again, _ := images.SignImageURL(7, "fotos/férias 2025.jpg", ImageParams{Width: 400, Height: 320, Fit: FitCover, Format: "webp"})
+	if again != signed {
+		t.Fatalf("esperava o mesmo link, veio %s e %s", signed, again)
+	}
+
+	key, params, err := verifySigned(&images, signed)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if key != "fotos/férias 2025.jpg" || params != (ImageParams{Width: 400, Height: 320, Fit: FitCover, Format: "webp"}) {
+		t.Fatalf("link verificado inesperado %s %#v", key, params)
+	}
+
+	for _, tampered := range []string{
+		strings.Replace(signed, "w=400", "w=2048", 1),
+		strings.Replace(signed, "u=7", "u=8", 1),
+		strings.Replace(signed, "f%C3%A9rias", "outras", 1),
+	} {
+		if _, _, err := verifySigned(&images, tampered); !errors.Is(err, ErrInvalidImageSignature) {
+			t.Fatalf("esperava ErrInvalidImageSignature para %s, veio %v", tampered, err)
+		}
+	}
+
+	images.now = func() time.Time { return time.Date(2026, 3, 2, 11, 0, 1, 0, time.UTC) }
+	if _, _, err := verifySigned(&images, signed); !errors.Is(err, ErrImageURLExpired) {
+		t.Fatalf("esperava ErrImageURLExpired, veio %v", err)
+	}
+
+	for _, params := range []ImageParams{{}, {Width: 5000}, {Width: 100}, {Width: 128, Height: 90}, {Width: 128, Fit: FitCover}, {Width: 128, Fit: "stretch"}, {Width: 128, Format: "gif"}} {
+		if _, err := images.SignImageURL(7, "foto.png", params); !errors.Is(err, ErrInvalidImageParams) {
+			t.Fatalf("esperava ErrInvalidImageParams para %#v, veio %v", params, err)
+		}
+	}
+	if _, err := images.SignImageURL(7, "notas.txt", ImageParams{Width: 128}); !errors.Is(err, ErrNotImage) {
+		t.Fatalf("esperava ErrNotImage, veio %v", err)
+	}
+}
+
+func TestImageUsecaseTransformsAndCachesVariants(t *testing.T) {
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, testImage(1000, 500, 255), nil)
+	var logo bytes.Buffer
+	png.Encode(&logo, testImage(100, 60, 128))
+
+	bucket := &memoryBucket{objects: map[string][]byte{"fotos/praia.jpg": photo.Bytes(), "logo.png": logo.Bytes()}}
+	images := newTestImages(bucket)
+	ctx := context.Background()
+
+	open := func(key string, params ImageParams) (*ImageVariant, []byte) {
+		t.Helper()
+		variant, err := images.Variant(ctx, 7, key, params)
+		if err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+		body, size, err := images.OpenImage(ctx, variant)
+		if err != nil {
+			t.Fatalf("não esperava erro, veio %v", err)
+		}
+		defer body.Close()
+		data, _ := io.ReadAll(body)
+		if int64(len(data)) != size {
+			t.Fatalf("esperava %d bytes, veio %d", size, len(data))
+		}
+		return variant, data
+	}
+
+	cover := ImageParams{Width: 400, Height: 320, Fit: FitCover, Format: "webp"}
+	variant, data := open("fotos/praia.jpg", cover)
+	decoded, err := webp.Decode(bytes.NewReader(data))
+	if err != nil || decoded.Bounds().Dx() != 400 || decoded.Bounds().Dy() != 320 || variant.ContentType != "image/webp" {
+		t.Fatalf("esperava um WebP de 400x320, veio %v %v", decoded.Bounds(), err)
+	}
+	if _, ok := bucket.objects[variant.DerivativeKey]; !ok {
+		t.Fatalf("a imagem transformada deveria ficar guardada")
+	}
+
+	// The second request is served from the derivatives store.
+	reads := len(bucket.reads)
+	open("fotos/praia.jpg", cover)
+	if len(bucket.reads) != reads+1 {
+		t.Fatalf("esperava uma única leitura, veio %v", bucket.reads[reads:])
+	}
+
+	_, data = open("fotos/praia.jpg", ImageParams{Width: 320, Fit: FitContain, Format: "jpeg"})
+	decoded, err = jpeg.Decode(bytes.NewReader(data))
+	if err != nil || decoded.Bounds().Dx() != 320 || decoded.Bounds().Dy() != 160 {
+		t.Fatalf("esperava um JPEG de 320x160, veio %v %v", decoded.Bounds(), err)
+	}
+
+	// Small images are not enlarged, nor have their proportions changed.
+	_, data = open("logo.png", ImageParams{Width: 400, Height: 400, Fit: FitCover, Format: "png"})
+	decoded, err = png.Decode(bytes.NewReader(data))
+	if err != nil || decoded.Bounds().Dx() != 60 || decoded.Bounds().Dy() != 60 {
+		t.Fatalf("esperava um PNG de 60x60, veio %v %v", decoded.Bounds(), err)
+	}
+
+	_, data = open("logo.png", ImageParams{Width: 256, Height: 64, Fit: FitFill, Format: "png"})
+	decoded, _ = png.Decode(bytes.NewReader(data))
+	if decoded.Bounds().Dx() != 256 || decoded.Bounds().Dy() != 64 {
+		t.Fatalf("esperava um PNG de 256x64, veio %v", decoded.Bounds())
+	}
+
+	// A new version of the original gets a variant of its own.
+	bucket.objects["fotos/praia.jpg"] = logo.Bytes()
+	updated, _ := open("fotos/praia.jpg", cover)
+	if updated.ETag == variant.ETag || updated.DerivativeKey == variant.DerivativeKey {
+		t.Fatalf("a nova versão deveria ter outra ETag")
+	}
+
+	if _, err := images.Variant(ctx, 7, "sumiu.png", cover); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("esperava ErrObjectNotFound, veio %v", err)
+	}
+}
+
+func TestImageUsecaseWaitsForATransformSlot(t *testing.T) {
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, testImage(100, 100, 255), nil)
+
+	bucket := &memoryBucket{objects: map[string][]byte{"foto.jpg": photo.Bytes()}}
+	images := newTestImages(bucket)
+
+	variant, err := images.Variant(context.Background(), 7, "foto.jpg", ImageParams{Width: 64})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	// Every slot is taken by transforms still running.
+	for range cap(images.transforms) {
+		images.transforms <- struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := images.OpenImage(ctx, variant); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("esperava esperar por uma vaga até o prazo, veio %v", err)
+	}
+
+	<-images.transforms
+	body, _, err := images.OpenImage(context.Background(), variant)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	body.Close()
+}
@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/models"
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultJobListLimit = 50
+	maxJobListLimit     = 500
+)
+
+var ErrInvalidJobStatus = errors.New("status de job inválido, use queued, running, done ou dead")
+
+type JobUsecase struct {
+	repository JobRepository
+}
+
+func NewJobUsecase(repo JobRepository) JobUsecase {
+	return JobUsecase{
+		repository: repo,
+	}
+}
+
+// ListJobs returns the most recent jobs, optionally filtered by status.
+func (ju *JobUsecase) ListJobs(status string, limit int) ([]models.Job, error) {
+	switch status {
+	case "", models.JobQueued, models.JobRunning, models.JobDone, models.JobDead:
+	default:
+		return nil, ErrInvalidJobStatus
+	}
+
+	if limit <= 0 {
+		limit = defaultJobListLimit
+	}
+	if limit > maxJobListLimit {
+		limit = maxJobListLimit
+	}
+
+	jobs, err := ju.repository.ListJobs(status, limit)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (ju *JobUsecase) GetJob(id int) (*models.Job, error) {
+	return ju.repository.GetJob(id)
+}
+
+// RetryJob requeues a dead-lettered job. It reports false when the job does
+// not exist or is not dead.
+func (ju *JobUsecase) RetryJob(id int) (bool, error) {
+	return ju.repository.RetryJob(id)
+}
@@ -0,0 +1,234 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials   = errors.New("email ou senha inválidos")
+	ErrTooManyLoginAttempts = errors.New("muitas tentativas de login")
+	ErrAccountLocked        = errors.New("conta bloqueada temporariamente")
+)
+
+// LoginBlockedError carries how long the client has to wait before trying
+// again. It wraps ErrTooManyLoginAttempts or ErrAccountLocked.
+type LoginBlockedError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return e.Err
+}
+
+type LoginGuardConfig struct {
+	PerIP            ratelimit.Rule
+	PerAccount       ratelimit.Rule
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+	// MfaAttempts is how many codes one pending two-factor token may try.
+	MfaAttempts int
+}
+
+// DefaultLoginGuardConfig locks an account for 1 minute after 5 failures in a
+// row, doubling on each further failure up to one hour. A two-factor token
+// gets 5 codes.
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		PerIP:            ratelimit.Rule{Limit: 20, Window: 5 * time.Minute},
+		PerAccount:       ratelimit.Rule{Limit: 10, Window: 15 * time.Minute},
+		LockoutThreshold: 5,
+		LockoutBase:      time.Minute,
+		LockoutMax:       time.Hour,
+		MfaAttempts:      5,
+	}
+}
+
+// LoginGuard protects the password login against brute force: sliding
+// window limits per IP and per account, and a lockout with exponential
+// backoff after consecutive failures.
+type LoginGuard struct {
+	store             ratelimit.Store
+	lockoutRepository LoginLockoutRepository
+	userRepository    UserRepository
+	config            LoginGuardConfig
+}
+
+func NewLoginGuard(store ratelimit.Store, lockoutRepo LoginLockoutRepository, userRepo UserRepository, config LoginGuardConfig) LoginGuard {
+	return LoginGuard{
+		store:             store,
+		lockoutRepository: lockoutRepo,
+		userRepository:    userRepo,
+		config:            config,
+	}
+}
+
+// Check runs before the password is verified and counts the attempt
+// against both windows.
+func (lg *LoginGuard) Check(ip string, email string) error {
+	email = normalizeEmail(email)
+
+	lockedUntil, err := lg.lockoutRepository.GetLockedUntil(email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	ratelimit.RequestsTotal.Inc("limiter", "login")
+
+	if lockedUntil != nil {
+		ratelimit.HitsTotal.Inc("limiter", "login", "reason", "locked")
+		return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: time.Until(*lockedUntil)}
+	}
+
+	allowed, retryAfter, err := lg.config.PerIP.Allow(lg.store, "login:ip:"+ip)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if !allowed {
+		ratelimit.HitsTotal.Inc("limiter", "login", "reason", "ip")
+		return &LoginBlockedError{Err: ErrTooManyLoginAttempts, RetryAfter: retryAfter}
+	}
+
+	allowed, retryAfter, err = lg.config.PerAccount.Allow(lg.store, "login:account:"+email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if !allowed {
+		ratelimit.HitsTotal.Inc("limiter", "login", "reason", "account")
+		return &LoginBlockedError{Err: ErrTooManyLoginAttempts, RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a wrong password and locks the account once the
+// streak reaches the threshold.
+func (lg *LoginGuard) RecordFailure(email string) error {
+	email = normalizeEmail(email)
+
+	failures, err := lg.lockoutRepository.RecordLoginFailure(email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if failures < lg.config.LockoutThreshold {
+		return nil
+	}
+
+	duration := lg.lockoutDuration(failures)
+	err = lg.lockoutRepository.LockAccount(email, time.Now().Add(duration))
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: duration}
+}
+
+// CheckMfa runs before the code of a two-factor login is verified. It
+// honours the account lockout and counts the attempt against the pending
+// token, which is burnt after MfaAttempts tries. Wrong codes are then
+// reported with RecordFailure, like wrong passwords.
+func (lg *LoginGuard) CheckMfa(mfaToken string, email string) error {
+	email = normalizeEmail(email)
+
+	lockedUntil, err := lg.lockoutRepository.GetLockedUntil(email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	ratelimit.RequestsTotal.Inc("limiter", "mfa")
+
+	if lockedUntil != nil {
+		ratelimit.HitsTotal.Inc("limiter", "mfa", "reason", "locked")
+		return &LoginBlockedError{Err: ErrAccountLocked, RetryAfter: time.Until(*lockedUntil)}
+	}
+
+	allowed, _, err := lg.store.Hit("login:mfa:"+utils.HashToken(mfaToken), lg.config.MfaAttempts, handlers.MfaTokenTTL)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if !allowed {
+		ratelimit.HitsTotal.Inc("limiter", "mfa", "reason", "token")
+		return handlers.ErrInvalidMfaToken
+	}
+
+	return nil
+}
+
+// SpendMfaToken makes a two-factor token single use. It reports false when
+// the token already completed a login.
+func (lg *LoginGuard) SpendMfaToken(mfaToken string) (bool, error) {
+	allowed, _, err := lg.store.Hit("login:mfa:used:"+utils.HashToken(mfaToken), 1, handlers.MfaTokenTTL)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return allowed, nil
+}
+
+func (lg *LoginGuard) RecordSuccess(email string) error {
+	return lg.lockoutRepository.ResetLoginFailures(normalizeEmail(email))
+}
+
+// Unlock lets an admin clear a lockout and the account's rate limit window.
+func (lg *LoginGuard) Unlock(userId int) (bool, error) {
+	user, err := lg.userRepository.GetUserById(userId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	if user == nil {
+		return false, nil
+	}
+
+	email := normalizeEmail(user.Email)
+	err = lg.lockoutRepository.ResetLoginFailures(email)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	err = lg.store.Reset("login:account:" + email)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (lg *LoginGuard) lockoutDuration(failures int) time.Duration {
+	duration := lg.config.LockoutBase
+	for i := lg.config.LockoutThreshold; i < failures && duration < lg.config.LockoutMax; i++ {
+		duration *= 2
+	}
+
+	return min(duration, lg.config.LockoutMax)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+)
+
+type fakeLoginLockoutRepo struct {
+	failures    map[string]int
+	lockedUntil map[string]time.Time
+	resetErr    error
+}
+
+func newFakeLoginLockoutRepo() *fakeLoginLockoutRepo {
+	return &fakeLoginLockoutRepo{failures: map[string]int{}, lockedUntil: map[string]time.Time{}}
+}
+
+func (f *fakeLoginLockoutRepo) GetLockedUntil(email string) (*time.Time, error) {
+	until, ok := f.lockedUntil[email]
+	if !ok || !until.After(time.Now()) {
+		return nil, nil
+	}
+	return &until, nil
+}
+
+func (f *fakeLoginLockoutRepo) RecordLoginFailure(email string) (int, error) {
+	f.failures[email]++
+	return f.failures[email], nil
+}
+
+func (f *fakeLoginLockoutRepo) LockAccount(email string, until time.Time) error {
+	f.lockedUntil[email] = until
+	return nil
+}
+
+func (f *fakeLoginLockoutRepo) ResetLoginFailures(email string) error {
+	if f.resetErr != nil {
+		return f.resetErr
+	}
+	delete(f.failures, email)
+	delete(f.lockedUntil, email)
+	return nil
+}
+
+func TestLoginGuardLocksWithExponentialBackoff(t *testing.T) {
+	lockouts := newFakeLoginLockoutRepo()
+	config := DefaultLoginGuardConfig()
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), lockouts, &fakeUserRepo{}, config)
+
+	for i := 1; i < config.LockoutThreshold; i++ {
+		if err := guard.RecordFailure("Ana@Example.com"); err != nil {
+			t.Fatalf("falha %d não deveria bloquear, veio %v", i, err)
+		}
+	}
+
+	var blocked *LoginBlockedError
+	err := guard.RecordFailure("ana@example.com")
+	if !errors.As(err, &blocked) || !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("esperava ErrAccountLocked, veio %v", err)
+	}
+	if blocked.RetryAfter != time.Minute {
+		t.Fatalf("esperava bloqueio de 1 minuto, veio %s", blocked.RetryAfter)
+	}
+
+	err = guard.Check("10.0.0.1", "ANA@example.com")
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("conta bloqueada deveria recusar login, veio %v", err)
+	}
+
+	errors.As(guard.RecordFailure("ana@example.com"), &blocked)
+	if blocked.RetryAfter != 2*time.Minute {
+		t.Fatalf("esperava bloqueio de 2 minutos, veio %s", blocked.RetryAfter)
+	}
+
+	lockouts.failures["ana@example.com"] = 40
+	errors.As(guard.RecordFailure("ana@example.com"), &blocked)
+	if blocked.RetryAfter != config.LockoutMax {
+		t.Fatalf("esperava bloqueio máximo, veio %s", blocked.RetryAfter)
+	}
+}
+
+func TestLoginGuardLimitsPerIP(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	config.PerIP = ratelimit.Rule{Limit: 2, Window: time.Minute}
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), newFakeLoginLockoutRepo(), &fakeUserRepo{}, config)
+
+	guard.Check("10.0.0.1", "a@example.com")
+	guard.Check("10.0.0.1", "b@example.com")
+
+	err := guard.Check("10.0.0.1", "c@example.com")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("esperava ErrTooManyLoginAttempts, veio %v", err)
+	}
+	if blocked.RetryAfter <= 0 {
+		t.Fatalf("esperava tempo de espera positivo, veio %s", blocked.RetryAfter)
+	}
+
+	if err := guard.Check("10.0.0.2", "c@example.com"); err != nil {
+		t.Fatalf("outro IP não deveria ser limitado, veio %v", err)
+	}
+}
+
+func TestLoginGuardUnlock(t *testing.T) {
+	lockouts := newFakeLoginLockoutRepo()
+	lockouts.lockedUntil["ana@example.com"] = time.Now().Add(time.Hour)
+
+	userRepo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Email: "Ana@example.com"}, nil
+		},
+	}
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), lockouts, userRepo, DefaultLoginGuardConfig())
+
+	found, err := guard.Unlock(5)
+	if err != nil || !found {
+		t.Fatalf("esperava desbloqueio, veio %v %v", found, err)
+	}
+
+	if err := guard.Check("10.0.0.1", "ana@example.com"); err != nil {
+		t.Fatalf("conta desbloqueada deveria aceitar login, veio %v", err)
+	}
+}
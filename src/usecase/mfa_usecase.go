@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/totp"
+	"cloud_file_manager/src/utils"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMfaAlreadyEnabled = errors.New("a autenticação em duas etapas já está ativa")
+	ErrMfaNotEnrolled    = errors.New("inicie o cadastro da autenticação em duas etapas primeiro")
+	ErrInvalidMfaCode    = errors.New("código de verificação inválido")
+)
+
+type MfaUsecase struct {
+	mfaRepository     MfaRepository
+	userRepository    UserRepository
+	sessionRepository SessionRepository
+	loginGuard        LoginGuard
+}
+
+func NewMfaUsecase(mfaRepo MfaRepository, userRepo UserRepository, sessionRepo SessionRepository, loginGuard LoginGuard) MfaUsecase {
+	return MfaUsecase{
+		mfaRepository:     mfaRepo,
+		userRepository:    userRepo,
+		sessionRepository: sessionRepo,
+		loginGuard:        loginGuard,
+	}
+}
+
+// Enroll creates a new secret for the user. The factor only becomes active
+// after Confirm receives a valid code generated from it.
+func (mu *MfaUsecase) Enroll(userId int) (*dto.MfaEnrollmentDto, error) {
+	user, err := mu.userRepository.GetUserById(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrMfaNotEnrolled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := mu.mfaRepository.SaveMfaSecret(userId, secret)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if !saved {
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "Cloud File Manager"
+	}
+
+	return &dto.MfaEnrollmentDto{
+		Secret:          secret,
+		ProvisioningUri: totp.ProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm activates the factor and returns the recovery codes. They are only
+// shown here; the database keeps their hashes.
+func (mu *MfaUsecase) Confirm(userId int, code string) ([]string, error) {
+	mfa, err := mu.mfaRepository.GetMfa(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if mfa == nil {
+		return nil, ErrMfaNotEnrolled
+	}
+
+	if mfa.EnabledAt != nil {
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	step, valid := totp.Validate(mfa.Secret, code, time.Now())
+	if !valid {
+		return nil, ErrInvalidMfaCode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = utils.HashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	err = mu.mfaRepository.EnableMfa(userId, step, hashes)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CompleteLogin exchanges the pending token from the password step plus a
+// TOTP or recovery code for a regular session token. The token is single
+// use and allows a few codes only; wrong codes count towards the account
+// lockout like wrong passwords.
+func (mu *MfaUsecase) CompleteLogin(input dto.MfaLoginDto) (*dto.UserResponseDto, error) {
+	userId, scopes, err := handlers.ParseMfaToken(input.MfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := mu.userRepository.GetUserById(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, handlers.ErrInvalidMfaToken
+	}
+
+	err = mu.loginGuard.CheckMfa(input.MfaToken, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := mu.mfaRepository.GetMfa(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if mfa == nil || mfa.EnabledAt == nil {
+		return nil, handlers.ErrInvalidMfaToken
+	}
+
+	valid, err := mu.checkCode(mfa.UserID, mfa.Secret, input.Code)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if !valid {
+		if err := mu.loginGuard.RecordFailure(user.Email); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMfaCode
+	}
+
+	spent, err := mu.loginGuard.SpendMfaToken(input.MfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !spent {
+		return nil, handlers.ErrInvalidMfaToken
+	}
+
+	err = mu.loginGuard.RecordSuccess(user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId, err := mu.sessionRepository.CreateSession(user.ID, input.IP, input.UserAgent)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	token, err := handlers.CreateToken(user.Name, user.ID, sessionId, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.UserResponseDto{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Token: token,
+	}, nil
+}
+
+func (mu *MfaUsecase) checkCode(userId int, secret string, code string) (bool, error) {
+	if step, valid := totp.Validate(secret, code, time.Now()); valid {
+		return mu.mfaRepository.MarkStepUsed(userId, step)
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+
+	return mu.mfaRepository.ConsumeRecoveryCode(userId, utils.HashToken(normalized))
+}
+
+func generateRecoveryCode() (string, error) {
+	buffer := make([]byte, 6)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buffer))
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+	"cloud_file_manager/src/totp"
+	"cloud_file_manager/src/utils"
+)
+
+type fakeMfaRepo struct {
+	getMfaFn              func(int) (*models.UserMfa, error)
+	saveMfaSecretFn       func(int, string) (bool, error)
+	enableMfaFn           func(int, int64, []string) error
+	markStepUsedFn        func(int, int64) (bool, error)
+	consumeRecoveryCodeFn func(int, string) (bool, error)
+}
+
+func (f *fakeMfaRepo) GetMfa(userId int) (*models.UserMfa, error) {
+	if f.getMfaFn == nil {
+		panic("GetMfa not implemented")
+	}
+	return f.getMfaFn(userId)
+}
+
+func (f *fakeMfaRepo) SaveMfaSecret(userId int, secret string) (bool, error) {
+	if f.saveMfaSecretFn == nil {
+		panic("SaveMfaSecret not implemented")
+	}
+	return f.saveMfaSecretFn(userId, secret)
+}
+
+func (f *fakeMfaRepo) EnableMfa(userId int, step int64, hashes []string) error {
+	if f.enableMfaFn == nil {
+		panic("EnableMfa not implemented")
+	}
+	return f.enableMfaFn(userId, step, hashes)
+}
+
+func (f *fakeMfaRepo) MarkStepUsed(userId int, step int64) (bool, error) {
+	if f.markStepUsedFn == nil {
+		panic("MarkStepUsed not implemented")
+	}
+	return f.markStepUsedFn(userId, step)
+}
+
+func (f *fakeMfaRepo) ConsumeRecoveryCode(userId int, hash string) (bool, error) {
+	if f.consumeRecoveryCodeFn == nil {
+		panic("ConsumeRecoveryCode not implemented")
+	}
+	return f.consumeRecoveryCodeFn(userId, hash)
+}
+
+func newTestMfaGuard() LoginGuard {
+	return NewLoginGuard(ratelimit.NewMemoryStore(), newFakeLoginLockoutRepo(), &fakeUserRepo{}, DefaultLoginGuardConfig())
+}
+
+func TestMfaUsecaseConfirmReturnsHashedRecoveryCodes(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+
+	var storedHashes []string
+	repo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 2, Secret: secret}, nil
+		},
+		enableMfaFn: func(userId int, step int64, hashes []string) error {
+			storedHashes = hashes
+			return nil
+		},
+	}
+
+	usecase := NewMfaUsecase(repo, &fakeUserRepo{}, &fakeSessionRepo{}, newTestMfaGuard())
+
+	codes, err := usecase.Confirm(2, code)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(storedHashes) != recoveryCodeCount {
+		t.Fatalf("esperava %d códigos, veio %d/%d", recoveryCodeCount, len(codes), len(storedHashes))
+	}
+	if storedHashes[0] == codes[0] || storedHashes[0] != utils.HashToken(normalizeRecoveryCode(codes[0])) {
+		t.Fatalf("os códigos de recuperação devem ser salvos como hash")
+	}
+}
+
+func TestMfaUsecaseConfirmInvalidCode(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+	repo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 2, Secret: secret}, nil
+		},
+	}
+
+	usecase := NewMfaUsecase(repo, &fakeUserRepo{}, &fakeSessionRepo{}, newTestMfaGuard())
+
+	_, err := usecase.Confirm(2, "000000x")
+	if !errors.Is(err, ErrInvalidMfaCode) {
+		t.Fatalf("esperava ErrInvalidMfaCode, veio %v", err)
+	}
+}
+
+func TestMfaUsecaseCompleteLoginWithTotp(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+	enabledAt := time.Now()
+
+	mfaRepo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 6, Secret: secret, EnabledAt: &enabledAt}, nil
+		},
+		markStepUsedFn: func(int, int64) (bool, error) {
+			return true, nil
+		},
+	}
+	userRepo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+	}
+	sessions := &fakeSessionRepo{
+		createSessionFn: func(userId int, ip string, userAgent string) (int, error) {
+			if userId != 6 || ip != "10.0.0.1" {
+				t.Fatalf("sessão inesperada %d/%s", userId, ip)
+			}
+			return 30, nil
+		},
+	}
+
+	usecase := NewMfaUsecase(mfaRepo, userRepo, sessions, newTestMfaGuard())
+
+	mfaToken, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+	response, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: code, IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if response.Token == "" || response.ID != 6 {
+		t.Fatalf("resposta inesperada %#v", response)
+	}
+}
+
+func TestMfaUsecaseCompleteLoginRejectsReplayedCode(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+	enabledAt := time.Now()
+
+	mfaRepo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 6, Secret: secret, EnabledAt: &enabledAt}, nil
+		},
+		markStepUsedFn: func(int, int64) (bool, error) {
+			return false, nil
+		},
+	}
+
+	userRepo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+	}
+
+	usecase := NewMfaUsecase(mfaRepo, userRepo, &fakeSessionRepo{}, newTestMfaGuard())
+
+	mfaToken, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+	_, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: code})
+	if !errors.Is(err, ErrInvalidMfaCode) {
+		t.Fatalf("esperava ErrInvalidMfaCode, veio %v", err)
+	}
+}
+
+func TestMfaUsecaseCompleteLoginRejectsSessionToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	usecase := NewMfaUsecase(&fakeMfaRepo{}, &fakeUserRepo{}, &fakeSessionRepo{}, newTestMfaGuard())
+
+	sessionToken, _ := handlers.CreateToken("Ana", 6, 1, handlers.AllScopes)
+	_, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: sessionToken, Code: "123456"})
+	if !errors.Is(err, handlers.ErrInvalidMfaToken) {
+		t.Fatalf("esperava ErrInvalidMfaToken, veio %v", err)
+	}
+}
+
+func TestMfaUsecaseCompleteLoginLimitsCodesPerToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+	enabledAt := time.Now()
+
+	mfaRepo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 6, Secret: secret, EnabledAt: &enabledAt}, nil
+		},
+		markStepUsedFn: func(int, int64) (bool, error) {
+			return true, nil
+		},
+		consumeRecoveryCodeFn: func(int, string) (bool, error) {
+			return false, nil
+		},
+	}
+	userRepo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+	}
+	sessions := &fakeSessionRepo{
+		createSessionFn: func(int, string, string) (int, error) {
+			return 30, nil
+		},
+	}
+
+	config := DefaultLoginGuardConfig()
+	config.LockoutThreshold = 100
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), newFakeLoginLockoutRepo(), userRepo, config)
+	usecase := NewMfaUsecase(mfaRepo, userRepo, sessions, guard)
+
+	// A token that ran out of attempts is burnt, even for the right code.
+	burnt, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+	for range config.MfaAttempts {
+		if _, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: burnt, Code: "errado"}); !errors.Is(err, ErrInvalidMfaCode) {
+			t.Fatalf("esperava ErrInvalidMfaCode, veio %v", err)
+		}
+	}
+	if _, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: burnt, Code: code}); !errors.Is(err, handlers.ErrInvalidMfaToken) {
+		t.Fatalf("esperava ErrInvalidMfaToken, veio %v", err)
+	}
+
+	// A token that completed a login cannot be used again.
+	mfaToken, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+	if _, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: code}); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if _, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: code}); !errors.Is(err, handlers.ErrInvalidMfaToken) {
+		t.Fatalf("esperava ErrInvalidMfaToken, veio %v", err)
+	}
+}
+
+func TestMfaUsecaseCompleteLoginLocksAccountAfterWrongCodes(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	enabledAt := time.Now()
+	mfaRepo := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			return &models.UserMfa{UserID: 6, Secret: "JBSWY3DPEHPK3PXP", EnabledAt: &enabledAt}, nil
+		},
+		consumeRecoveryCodeFn: func(int, string) (bool, error) {
+			return false, nil
+		},
+	}
+	userRepo := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+	}
+
+	lockouts := newFakeLoginLockoutRepo()
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), lockouts, userRepo, DefaultLoginGuardConfig())
+	usecase := NewMfaUsecase(mfaRepo, userRepo, &fakeSessionRepo{}, guard)
+
+	// New tokens from the password step do not reset the streak.
+	var err error
+	for range DefaultLoginGuardConfig().LockoutThreshold {
+		mfaToken, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+		_, err = usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: "errado"})
+	}
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("esperava ErrAccountLocked, veio %v", err)
+	}
+
+	mfaToken, _ := handlers.CreateMfaToken(6, handlers.AllScopes)
+	if _, err := usecase.CompleteLogin(dto.MfaLoginDto{MfaToken: mfaToken, Code: "123456"}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("esperava ErrAccountLocked, veio %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/oidc"
+)
+
+type fakeOidcProvider struct {
+	identity *oidc.Identity
+}
+
+func (f *fakeOidcProvider) AuthCodeURL(ctx context.Context, login oidc.PendingLogin) (string, error) {
+	return "https://idp.example.com/authorize?state=" + login.State, nil
+}
+
+func (f *fakeOidcProvider) Exchange(ctx context.Context, code string, login oidc.PendingLogin) (*oidc.Identity, error) {
+	return f.identity, nil
+}
+
+type fakeIdentityRepo struct {
+	linked map[string]int
+}
+
+func (f *fakeIdentityRepo) GetUserIdByIdentity(issuer string, subject string) (int, error) {
+	return f.linked[issuer+"|"+subject], nil
+}
+
+func (f *fakeIdentityRepo) LinkIdentity(userId int, issuer string, subject string) error {
+	f.linked[issuer+"|"+subject] = userId
+	return nil
+}
+
+func startOidcLogin(t *testing.T, usecase OidcUsecase) string {
+	address, err := usecase.StartLogin(context.Background())
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	return address[len("https://idp.example.com/authorize?state="):]
+}
+
+func TestOidcUsecaseCreatesUserFromVerifiedEmail(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	var created models.User
+	var verified int
+	users := &fakeUserRepo{
+		getByEmailFn: func(string) (*models.User, error) { return nil, nil },
+		createUserFn: func(user models.User) (int, error) {
+			created = user
+			return 15, nil
+		},
+		isVerifiedFn: func(int) (bool, error) { return false, nil },
+		markVerified: func(id int) error {
+			verified = id
+			return nil
+		},
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana", Email: "ana@example.com"}, nil
+		},
+	}
+
+	provisioner := &fakeProvisioner{}
+
+	identities := &fakeIdentityRepo{linked: map[string]int{}}
+	states := oidc.NewMemoryStateStore()
+	provider := &fakeOidcProvider{identity: &oidc.Identity{
+		Issuer: "https://idp.example.com", Subject: "abc", Email: "ana@example.com", EmailVerified: true, Name: "Ana",
+	}}
+	sessions := &fakeSessionRepo{
+		createSessionFn: func(int, string, string) (int, error) { return 1, nil },
+	}
+	mfa := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) { return nil, nil },
+	}
+
+	usecase := NewOidcUsecase(provider, states, users, identities, mfa, sessions, provisioner)
+	state := startOidcLogin(t, usecase)
+
+	response, err := usecase.CompleteLogin(context.Background(), "code", state, "10.0.0.1", "Firefox")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if created.Email != "ana@example.com" || created.Password == "" {
+		t.Fatalf("usuário criado inesperado %#v", created)
+	}
+	if verified != 15 || len(provisioner.enqueued) != 1 || provisioner.enqueued[0] != 15 {
+		t.Fatalf("esperava verificar e provisionar o usuário 15, veio %d/%v", verified, provisioner.enqueued)
+	}
+	if identities.linked["https://idp.example.com|abc"] != 15 {
+		t.Fatalf("esperava vincular a identidade ao usuário 15")
+	}
+	if response.Token == "" {
+		t.Fatalf("esperava token de sessão")
+	}
+
+	if _, err := usecase.CompleteLogin(context.Background(), "code", state, "", ""); !errors.Is(err, ErrInvalidOidcState) {
+		t.Fatalf("o state não pode ser reutilizado, veio %v", err)
+	}
+}
+
+func TestOidcUsecaseRejectsUnverifiedEmail(t *testing.T) {
+	states := oidc.NewMemoryStateStore()
+	provider := &fakeOidcProvider{identity: &oidc.Identity{
+		Issuer: "https://idp.example.com", Subject: "abc", Email: "ana@example.com", EmailVerified: false,
+	}}
+
+	usecase := NewOidcUsecase(provider, states, &fakeUserRepo{}, &fakeIdentityRepo{linked: map[string]int{}}, &fakeMfaRepo{}, &fakeSessionRepo{}, &fakeProvisioner{})
+	state := startOidcLogin(t, usecase)
+
+	_, err := usecase.CompleteLogin(context.Background(), "code", state, "", "")
+	if !errors.Is(err, oidc.ErrEmailNotVerified) {
+		t.Fatalf("esperava ErrEmailNotVerified, veio %v", err)
+	}
+}
+
+func TestOidcUsecaseLinkedUserWithMfaGetsPendingToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "secret")
+
+	states := oidc.NewMemoryStateStore()
+	provider := &fakeOidcProvider{identity: &oidc.Identity{Issuer: "https://idp.example.com", Subject: "abc"}}
+	identities := &fakeIdentityRepo{linked: map[string]int{"https://idp.example.com|abc": 4}}
+	users := &fakeUserRepo{
+		getUserByIDFn: func(id int) (*models.User, error) {
+			return &models.User{ID: id, Name: "Ana"}, nil
+		},
+	}
+	mfa := &fakeMfaRepo{
+		getMfaFn: func(int) (*models.UserMfa, error) {
+			enabled := models.UserMfa{UserID: 4}
+			enabled.EnabledAt = new(time.Time)
+			return &enabled, nil
+		},
+	}
+
+	usecase := NewOidcUsecase(provider, states, users, identities, mfa, &fakeSessionRepo{}, &fakeProvisioner{})
+	state := startOidcLogin(t, usecase)
+
+	response, err := usecase.CompleteLogin(context.Background(), "code", state, "", "")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if !response.MfaRequired || response.Token != "" {
+		t.Fatalf("esperava login pendente de duas etapas, veio %#v", response)
+	}
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	PreviewText     = "text"
+	PreviewMarkdown = "markdown"
+	PreviewCSV      = "csv"
+	PreviewJSON     = "json"
+
+	defaultPreviewKB = 64
+	maxPreviewKB     = 1024
+	// maxPreviewRows bounds the CSV rows returned, header included.
+	maxPreviewRows = 100
+)
+
+var (
+	ErrNotText              = errors.New("o arquivo não parece ser de texto")
+	ErrInvalidPreviewFormat = errors.New("o formato da prévia deve ser text, markdown, csv ou json")
+	ErrInvalidPreviewSize   = errors.New("o tamanho da prévia deve ser entre 1 e 1024 KB")
+)
+
+// markdown renders without raw HTML and drops javascript: and similar
+// links, so what it outputs is safe to show as is.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// previewFormat guesses how to show an object from its extension.
+func previewFormat(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".md", ".markdown":
+		return PreviewMarkdown
+	case ".csv", ".tsv":
+		return PreviewCSV
+	case ".json":
+		return PreviewJSON
+	default:
+		return PreviewText
+	}
+}
+
+// decodeText turns the start of an object into a string, telling which
+// encoding it was in. UTF-16 is recognised by its BOM, anything else that
+// is not UTF-8 is read as Windows-1252, and NUL bytes mean it is binary.
+func decodeText(data []byte, truncated bool) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		if truncated {
+			data = data[:len(data)&^1]
+		}
+		decoded, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err != nil {
+			return "", "", ErrNotText
+		}
+		return string(decoded), "utf-16", nil
+	}
+
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", "", ErrNotText
+	}
+
+	if truncated {
+		// The read may have stopped halfway through a character.
+		for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+			if utf8.RuneStart(data[len(data)-i]) {
+				if !utf8.FullRune(data[len(data)-i:]) {
+					data = data[:len(data)-i]
+				}
+				break
+			}
+		}
+	}
+
+	if utf8.Valid(data) {
+		return string(data), "utf-8", nil
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", ErrNotText
+	}
+	return string(decoded), "windows-1252", nil
+}
+
+// renderMarkdown converts Markdown to HTML.
+func renderMarkdown(text string) (string, error) {
+	var buffer bytes.Buffer
+	if err := markdown.Convert([]byte(text), &buffer); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// csvRows parses the first rows of a CSV. The delimiter is a tab for .tsv
+// files and otherwise whichever of , ; or tab the first line has most of.
+// A row cut by the end of the preview is left out.
+func csvRows(key string, text string, truncated bool) [][]string {
+	if truncated {
+		if end := strings.LastIndexByte(text, '\n'); end >= 0 {
+			text = text[:end+1]
+		}
+	}
+
+	delimiter := '\t'
+	if strings.ToLower(path.Ext(key)) != ".tsv" {
+		firstLine, _, _ := strings.Cut(text, "\n")
+		delimiter = ','
+		for _, candidate := range []rune{';', '\t'} {
+			if strings.Count(firstLine, string(candidate)) > strings.Count(firstLine, string(delimiter)) {
+				delimiter = candidate
+			}
+		}
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for len(rows) < maxPreviewRows {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Whatever was read before is still worth showing.
+			break
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// indentJSON pretty prints a complete JSON document, leaving anything else
+// as it came.
+func indentJSON(text string, truncated bool) string {
+	if truncated {
+		return text
+	}
+
+	var buffer bytes.Buffer
+	if err := json.Indent(&buffer, []byte(text), "", "  "); err != nil {
+		return text
+	}
+	return buffer.String()
+}
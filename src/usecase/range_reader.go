@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const (
+	rangeBlockSize = 256 << 10
+	rangeBlocks    = 4
+)
+
+// rangeReaderAt reads an object through ranged GETs of whole blocks and
+// keeps the last few, so the small reads archive/zip makes while walking
+// a central directory, or a run of small entries, cost few requests.
+type rangeReaderAt struct {
+	ctx     context.Context
+	storage *StorageUsecase
+	userId  int
+	key     string
+	size    int64
+	blocks  map[int64][]byte
+	order   []int64
+}
+
+func newRangeReaderAt(ctx context.Context, storage *StorageUsecase, userId int, key string, size int64) *rangeReaderAt {
+	return &rangeReaderAt{
+		ctx:     ctx,
+		storage: storage,
+		userId:  userId,
+		key:     key,
+		size:    size,
+		blocks:  map[int64][]byte{},
+	}
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, ErrInvalidRange
+	}
+
+	n := 0
+	for n < len(p) && offset+int64(n) < r.size {
+		position := offset + int64(n)
+		block, err := r.block(position / rangeBlockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[position%rangeBlockSize:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *rangeReaderAt) block(index int64) ([]byte, error) {
+	if block, ok := r.blocks[index]; ok {
+		return block, nil
+	}
+
+	start := index * rangeBlockSize
+	end := min(start+rangeBlockSize, r.size)
+	stream, err := r.storage.ReadRange(r.ctx, r.userId, r.key, fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Body.Close()
+
+	block := make([]byte, end-start)
+	if _, err := io.ReadFull(stream.Body, block); err != nil {
+		return nil, err
+	}
+
+	if len(r.order) == rangeBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.blocks[index] = block
+	r.order = append(r.order, index)
+	return block, nil
+}
+
+// section opens length bytes from offset. Short ones are served from the
+// blocks, the rest with a GET of their own.
+func (r *rangeReaderAt) section(offset int64, length int64) (io.ReadCloser, error) {
+	if length < rangeBlockSize {
+		return io.NopCloser(io.NewSectionReader(r, offset, length)), nil
+	}
+
+	stream, err := r.storage.ReadRange(r.ctx, r.userId, r.key, fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	if err != nil {
+		return nil, err
+	}
+	return stream.Body, nil
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/models"
+	"fmt"
+)
+
+type SessionUsecase struct {
+	repository SessionRepository
+}
+
+func NewSessionUsecase(repo SessionRepository) SessionUsecase {
+	return SessionUsecase{
+		repository: repo,
+	}
+}
+
+func (su *SessionUsecase) GetSessions(userId int, currentSessionId int) ([]models.Session, error) {
+	sessions, err := su.repository.GetSessionsByUser(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionId
+	}
+
+	return sessions, nil
+}
+
+func (su *SessionUsecase) RevokeSession(userId int, sessionId int) (bool, error) {
+	revoked, err := su.repository.RevokeSession(userId, sessionId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return revoked, nil
+}
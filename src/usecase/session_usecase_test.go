@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/models"
+)
+
+type fakeSessionRepo struct {
+	getSessionsByUserFn func(int) ([]models.Session, error)
+	revokeSessionFn     func(int, int) (bool, error)
+	revokeAllFn         func(int) error
+	createSessionFn     func(int, string, string) (int, error)
+	revokeOthersFn      func(int, int) error
+	setRefreshFn        func(int, string, []string, time.Time) (bool, error)
+	rotateRefreshFn     func(string, string, time.Time) (*models.Session, error)
+}
+
+func (f *fakeSessionRepo) SetRefreshToken(sessionId int, tokenHash string, scopes []string, expiresAt time.Time) (bool, error) {
+	if f.setRefreshFn == nil {
+		panic("SetRefreshToken not implemented")
+	}
+	return f.setRefreshFn(sessionId, tokenHash, scopes, expiresAt)
+}
+
+func (f *fakeSessionRepo) RotateRefreshToken(tokenHash string, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	if f.rotateRefreshFn == nil {
+		panic("RotateRefreshToken not implemented")
+	}
+	return f.rotateRefreshFn(tokenHash, newTokenHash, expiresAt)
+}
+
+func (f *fakeSessionRepo) GetSessionsByUser(userId int) ([]models.Session, error) {
+	if f.getSessionsByUserFn == nil {
+		panic("GetSessionsByUser not implemented")
+	}
+	return f.getSessionsByUserFn(userId)
+}
+
+func (f *fakeSessionRepo) RevokeSession(userId int, sessionId int) (bool, error) {
+	if f.revokeSessionFn == nil {
+		panic("RevokeSession not implemented")
+	}
+	return f.revokeSessionFn(userId, sessionId)
+}
+
+func (f *fakeSessionRepo) RevokeAllSessions(userId int) error {
+	if f.revokeAllFn == nil {
+		panic("RevokeAllSessions not implemented")
+	}
+	return f.revokeAllFn(userId)
+}
+
+func (f *fakeSessionRepo) CreateSession(userId int, ip string, userAgent string) (int, error) {
+	if f.createSessionFn == nil {
+		panic("CreateSession not implemented")
+	}
+	return f.createSessionFn(userId, ip, userAgent)
+}
+
+func (f *fakeSessionRepo) RevokeOtherSessions(userId int, currentSessionId int) error {
+	if f.revokeOthersFn == nil {
+		panic("RevokeOtherSessions not implemented")
+	}
+	return f.revokeOthersFn(userId, currentSessionId)
+}
+
+func TestSessionUsecaseGetSessionsMarksCurrent(t *testing.T) {
+	repo := &fakeSessionRepo{
+		getSessionsByUserFn: func(userId int) ([]models.Session, error) {
+			if userId != 4 {
+				t.Fatalf("usuário inesperado %d", userId)
+			}
+			return []models.Session{{ID: 1}, {ID: 2}}, nil
+		},
+	}
+
+	usecase := NewSessionUsecase(repo)
+
+	sessions, err := usecase.GetSessions(4, 2)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if sessions[0].Current || !sessions[1].Current {
+		t.Fatalf("sessão atual marcada errada: %#v", sessions)
+	}
+}
+
+func TestSessionUsecaseRevokeSessionError(t *testing.T) {
+	expectedErr := errors.New("db error")
+	repo := &fakeSessionRepo{
+		revokeSessionFn: func(int, int) (bool, error) {
+			return false, expectedErr
+		},
+	}
+
+	usecase := NewSessionUsecase(repo)
+
+	_, err := usecase.RevokeSession(1, 2)
+	if !errors.Is(err, expectedErr) {
+		t.Fatalf("esperava erro %v, veio %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrSftpRequiresSshKey = errors.New("contas com verificação em duas etapas precisam entrar no SFTP com uma chave SSH")
+
+// SftpAuthUsecase logs users in to the SFTP server, with their email as
+// the SSH user name.
+type SftpAuthUsecase struct {
+	userRepository   UserRepository
+	mfaRepository    MfaRepository
+	sshKeyRepository SshKeyRepository
+	loginGuard       LoginGuard
+}
+
+func NewSftpAuthUsecase(userRepo UserRepository, mfaRepo MfaRepository, sshKeyRepo SshKeyRepository, loginGuard LoginGuard) SftpAuthUsecase {
+	return SftpAuthUsecase{
+		userRepository:   userRepo,
+		mfaRepository:    mfaRepo,
+		sshKeyRepository: sshKeyRepo,
+		loginGuard:       loginGuard,
+	}
+}
+
+// PasswordLogin checks the account password under the same brute force
+// limits and lockout as the HTTP login. A password alone cannot satisfy
+// two-factor accounts, which have to use a key.
+func (sa *SftpAuthUsecase) PasswordLogin(ip string, email string, password string) (int, error) {
+	err := sa.loginGuard.Check(ip, email)
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := sa.userRepository.GetUserByEmail(email)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		if err := sa.loginGuard.RecordFailure(email); err != nil {
+			return 0, err
+		}
+		return 0, ErrInvalidCredentials
+	}
+
+	mfa, err := sa.mfaRepository.GetMfa(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	if mfa != nil && mfa.EnabledAt != nil {
+		return 0, ErrSftpRequiresSshKey
+	}
+
+	err = sa.loginGuard.RecordSuccess(email)
+	if err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+// PublicKeyLogin finds the user with the given email who registered the
+// key. Proving possession of the private key is left to the SSH handshake.
+func (sa *SftpAuthUsecase) PublicKeyLogin(email string, publicKey ssh.PublicKey) (int, error) {
+	userId, err := sa.sshKeyRepository.UseSshKey(email, ssh.FingerprintSHA256(publicKey))
+	if err != nil {
+		fmt.Println(err)
+		return 0, err
+	}
+
+	if userId == 0 {
+		return 0, ErrInvalidCredentials
+	}
+
+	return userId, nil
+}
+
+// IsEmailVerified tells whether the user may write over SFTP.
+func (sa *SftpAuthUsecase) IsEmailVerified(userId int) (bool, error) {
+	return sa.userRepository.IsEmailVerified(userId)
+}
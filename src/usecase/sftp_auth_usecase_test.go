@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud_file_manager/src/models"
+	"cloud_file_manager/src/ratelimit"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+)
+
+type fakeSshKeyRepo struct {
+	createFn    func(models.SshKey) (int, time.Time, error)
+	getFn       func(int) ([]models.SshKey, error)
+	deleteFn    func(int, int) (bool, error)
+	deleteAllFn func(int) error
+	useFn       func(string, string) (int, error)
+}
+
+func (f *fakeSshKeyRepo) CreateSshKey(sshKey models.SshKey) (int, time.Time, error) {
+	if f.createFn == nil {
+		panic("CreateSshKey not implemented")
+	}
+	return f.createFn(sshKey)
+}
+
+func (f *fakeSshKeyRepo) GetSshKeysByUser(userId int) ([]models.SshKey, error) {
+	if f.getFn == nil {
+		panic("GetSshKeysByUser not implemented")
+	}
+	return f.getFn(userId)
+}
+
+func (f *fakeSshKeyRepo) DeleteSshKey(userId int, sshKeyId int) (bool, error) {
+	if f.deleteFn == nil {
+		panic("DeleteSshKey not implemented")
+	}
+	return f.deleteFn(userId, sshKeyId)
+}
+
+func (f *fakeSshKeyRepo) DeleteAllSshKeys(userId int) error {
+	if f.deleteAllFn == nil {
+		panic("DeleteAllSshKeys not implemented")
+	}
+	return f.deleteAllFn(userId)
+}
+
+func (f *fakeSshKeyRepo) UseSshKey(email string, fingerprint string) (int, error) {
+	if f.useFn == nil {
+		panic("UseSshKey not implemented")
+	}
+	return f.useFn(email, fingerprint)
+}
+
+func TestSftpPasswordLoginUsesLockoutAndRefusesMfaAccounts(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("senha-certa"), bcrypt.MinCost)
+	users := &fakeUserRepo{getByEmailFn: func(email string) (*models.User, error) {
+		return &models.User{ID: 4, Email: email, Password: string(hash)}, nil
+	}}
+	var mfaEnabledAt *time.Time
+	mfa := &fakeMfaRepo{getMfaFn: func(int) (*models.UserMfa, error) {
+		return &models.UserMfa{UserID: 4, EnabledAt: mfaEnabledAt}, nil
+	}}
+	lockouts := newFakeLoginLockoutRepo()
+	guard := NewLoginGuard(ratelimit.NewMemoryStore(), lockouts, users, DefaultLoginGuardConfig())
+	auth := NewSftpAuthUsecase(users, mfa, &fakeSshKeyRepo{}, guard)
+
+	userId, err := auth.PasswordLogin("10.0.0.1", "ana@example.com", "senha-certa")
+	if err != nil || userId != 4 {
+		t.Fatalf("esperava entrar como o usuário 4, veio %d, %v", userId, err)
+	}
+
+	_, err = auth.PasswordLogin("10.0.0.1", "ana@example.com", "senha-errada")
+	if !errors.Is(err, ErrInvalidCredentials) || lockouts.failures["ana@example.com"] != 1 {
+		t.Fatalf("a senha errada deveria contar como falha, veio %v", err)
+	}
+
+	// A streak that could not be reset is not silently kept.
+	lockouts.resetErr = errors.New("banco fora do ar")
+	if _, err = auth.PasswordLogin("10.0.0.1", "ana@example.com", "senha-certa"); err == nil {
+		t.Fatalf("esperava o erro ao zerar as falhas")
+	}
+	lockouts.resetErr = nil
+
+	now := time.Now()
+	mfaEnabledAt = &now
+	_, err = auth.PasswordLogin("10.0.0.1", "ana@example.com", "senha-certa")
+	if !errors.Is(err, ErrSftpRequiresSshKey) {
+		t.Fatalf("contas com MFA deveriam exigir chave, veio %v", err)
+	}
+}
+
+func TestSftpPublicKeyLoginMatchesFingerprint(t *testing.T) {
+	public, _, _ := ed25519.GenerateKey(rand.Reader)
+	publicKey, _ := ssh.NewPublicKey(public)
+
+	sshKeys := &fakeSshKeyRepo{useFn: func(email string, fingerprint string) (int, error) {
+		if email == "ana@example.com" && fingerprint == ssh.FingerprintSHA256(publicKey) {
+			return 4, nil
+		}
+		return 0, nil
+	}}
+	auth := NewSftpAuthUsecase(&fakeUserRepo{}, &fakeMfaRepo{}, sshKeys, LoginGuard{})
+
+	if userId, err := auth.PublicKeyLogin("ana@example.com", publicKey); err != nil || userId != 4 {
+		t.Fatalf("esperava entrar como o usuário 4, veio %d, %v", userId, err)
+	}
+	if _, err := auth.PublicKeyLogin("bia@example.com", publicKey); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("a chave de outro usuário não deveria entrar, veio %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrInvalidSshKey = errors.New("chave pública SSH inválida")
+	ErrSshKeyExists  = errors.New("esta chave SSH já está cadastrada")
+)
+
+type SshKeyUsecase struct {
+	repository SshKeyRepository
+}
+
+func NewSshKeyUsecase(repo SshKeyRepository) SshKeyUsecase {
+	return SshKeyUsecase{
+		repository: repo,
+	}
+}
+
+// CreateSshKey registers a public key, given as an authorized_keys line,
+// for logging in to the SFTP server.
+func (su *SshKeyUsecase) CreateSshKey(userId int, input dto.CreateSshKeyDto) (*models.SshKey, error) {
+	publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(input.PublicKey))
+	if err != nil {
+		return nil, ErrInvalidSshKey
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = comment
+	}
+	if name == "" {
+		name = publicKey.Type()
+	}
+
+	sshKey := models.SshKey{
+		UserID:      userId,
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+	}
+
+	sshKey.ID, sshKey.CreatedAt, err = su.repository.CreateSshKey(sshKey)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	if sshKey.ID == 0 {
+		return nil, ErrSshKeyExists
+	}
+
+	return &sshKey, nil
+}
+
+func (su *SshKeyUsecase) GetSshKeys(userId int) ([]models.SshKey, error) {
+	sshKeys, err := su.repository.GetSshKeysByUser(userId)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	return sshKeys, nil
+}
+
+func (su *SshKeyUsecase) DeleteSshKey(userId int, sshKeyId int) (bool, error) {
+	deleted, err := su.repository.DeleteSshKey(userId, sshKeyId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return deleted, nil
+}
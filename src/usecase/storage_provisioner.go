@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	JobProvisionStorage = "storage.provision"
+	JobReconcileStorage = "storage.reconcile"
+
+	// storageStallTimeout is well past the backoff of every provisioning
+	// attempt, so only users whose job went missing are picked up again.
+	storageStallTimeout = 2 * time.Hour
+)
+
+type storageJobPayload struct {
+	UserID int `json:"userId"`
+}
+
+// BucketProvisioner runs the storage half of the signup saga on the job
+// queue. A failed attempt removes whatever part of the bucket was created
+// before the job is retried, and the final outcome is recorded on the user
+// so clients can poll it.
+type BucketProvisioner struct {
+	userRepository UserRepository
+	awsService     AwsClient
+	jobQueue       JobQueue
+}
+
+func NewBucketProvisioner(userRepo UserRepository, aws AwsClient, jobQueue JobQueue) *BucketProvisioner {
+	return &BucketProvisioner{
+		userRepository: userRepo,
+		awsService:     aws,
+		jobQueue:       jobQueue,
+	}
+}
+
+// Enqueue marks the storage as pending and queues the provisioning job.
+// Users whose bucket is ready, such as one confirming a changed email, are
+// left as they are.
+func (bp *BucketProvisioner) Enqueue(userId int) error {
+	ready, err := bp.isReady(userId)
+	if err != nil || ready {
+		return err
+	}
+
+	err = bp.userRepository.SetStorageStatus(userId, models.StoragePending, "")
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	_, err = bp.jobQueue.Enqueue(JobProvisionStorage, storageJobPayload{UserID: userId})
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// HandleJob makes one attempt at creating the user's bucket.
+func (bp *BucketProvisioner) HandleJob(ctx context.Context, job models.Job) error {
+	var payload storageJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	ready, err := bp.isReady(payload.UserID)
+	if err != nil || ready {
+		return err
+	}
+
+	err = bp.userRepository.SetStorageStatus(payload.UserID, models.StorageProvisioning, "")
+	if err != nil {
+		return err
+	}
+
+	bucket := userBucketName(payload.UserID)
+
+	existed, err := bp.awsService.BucketExists(ctx, bucket)
+	if err == nil && existed {
+		return bp.userRepository.SetStorageStatus(payload.UserID, models.StorageReady, "")
+	}
+	// Only a bucket this attempt created may be removed when it fails.
+	// If the check itself failed we cannot tell, so nothing is removed.
+	missing := err == nil
+
+	err = bp.createBucket(ctx, bucket)
+	if err == nil {
+		return bp.userRepository.SetStorageStatus(payload.UserID, models.StorageReady, "")
+	}
+
+	// The name belongs to another account, so retrying cannot help and
+	// there is nothing of ours to clean up.
+	var exists *types.BucketAlreadyExists
+	if errors.As(err, &exists) {
+		err = jobs.Permanent(err)
+	} else if missing {
+		bp.compensate(ctx, bucket)
+	}
+
+	if jobs.IsPermanent(err) || jobs.IsLastAttempt(job) {
+		statusErr := bp.userRepository.SetStorageStatus(payload.UserID, models.StorageFailed, err.Error())
+		if statusErr != nil {
+			fmt.Println(statusErr)
+		}
+	}
+
+	return err
+}
+
+// Reconcile queues provisioning again for verified users whose storage has
+// been stuck in pending or provisioning. It runs as a cron job.
+func (bp *BucketProvisioner) Reconcile(ctx context.Context, job models.Job) error {
+	userIds, err := bp.userRepository.GetStalledStorageUsers(time.Now().Add(-storageStallTimeout))
+	if err != nil {
+		return err
+	}
+
+	for _, userId := range userIds {
+		if err := bp.Enqueue(userId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (bp *BucketProvisioner) isReady(userId int) (bool, error) {
+	status, err := bp.userRepository.GetStorageStatus(userId)
+	if err != nil {
+		fmt.Println(err)
+		return false, err
+	}
+
+	return status != nil && status.Status == models.StorageReady, nil
+}
+
+func (bp *BucketProvisioner) createBucket(ctx context.Context, bucket string) error {
+	_, err := bp.awsService.CreateBucket(ctx, bucket)
+
+	// Another attempt may have created it since BucketExists looked.
+	var owned *types.BucketAlreadyOwnedByYou
+	if errors.As(err, &owned) {
+		return nil
+	}
+
+	return err
+}
+
+// compensate drops the bucket a failed attempt created, for example when
+// configuring CORS failed after it.
+func (bp *BucketProvisioner) compensate(ctx context.Context, bucket string) {
+	err := bp.awsService.DeleteBucket(ctx, bucket)
+
+	var missing *types.NoSuchBucket
+	if err != nil && !errors.As(err, &missing) {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,279 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+type fakeProvisioner struct {
+	enqueued []int
+	err      error
+}
+
+func (f *fakeProvisioner) Enqueue(userId int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.enqueued = append(f.enqueued, userId)
+	return nil
+}
+
+type fakeJobQueue struct {
+	jobs   []models.Job
+	unique map[string]int
+}
+
+func (f *fakeJobQueue) Enqueue(jobType string, payload any) (int, error) {
+	return f.EnqueueWith(jobType, payload, jobs.EnqueueOptions{})
+}
+
+func (f *fakeJobQueue) EnqueueWith(jobType string, payload any, opts jobs.EnqueueOptions) (int, error) {
+	if opts.UniqueKey != "" {
+		if _, ok := f.unique[opts.UniqueKey]; ok {
+			return 0, nil
+		}
+		if f.unique == nil {
+			f.unique = map[string]int{}
+		}
+		f.unique[opts.UniqueKey] = len(f.jobs)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	f.jobs = append(f.jobs, models.Job{
+		ID:          len(f.jobs) + 1,
+		Type:        jobType,
+		Payload:     body,
+		Attempts:    1,
+		MaxAttempts: jobs.DefaultMaxAttempts,
+	})
+	return len(f.jobs), nil
+}
+
+func (f *fakeJobQueue) GetJobByUniqueKey(uniqueKey string) (*models.Job, error) {
+	i, ok := f.unique[uniqueKey]
+	if !ok {
+		return nil, nil
+	}
+	return &f.jobs[i], nil
+}
+
+func (f *fakeJobQueue) RetryJob(id int) (bool, error) {
+	if f.jobs[id-1].Status != models.JobDead {
+		return false, nil
+	}
+	f.jobs[id-1].Status = models.JobQueued
+	return true, nil
+}
+
+func newTestProvisioner(aws AwsClient) (*BucketProvisioner, *[]string) {
+	var statuses []string
+	repo := &fakeUserRepo{
+		setStorageFn: func(id int, status string, message string) error {
+			statuses = append(statuses, status)
+			return nil
+		},
+		getStorageFn: func(id int) (*models.StorageStatus, error) {
+			if len(statuses) == 0 {
+				return &models.StorageStatus{Status: models.StoragePending}, nil
+			}
+			return &models.StorageStatus{Status: statuses[len(statuses)-1]}, nil
+		},
+	}
+
+	return NewBucketProvisioner(repo, aws, &fakeJobQueue{}), &statuses
+}
+
+func storageJob(userId int, attempts int) models.Job {
+	payload, _ := json.Marshal(storageJobPayload{UserID: userId})
+	return models.Job{
+		Type:        JobProvisionStorage,
+		Payload:     payload,
+		Attempts:    attempts,
+		MaxAttempts: 3,
+	}
+}
+
+func TestBucketProvisionerEnqueueMarksPending(t *testing.T) {
+	var status string
+	repo := &fakeUserRepo{
+		setStorageFn: func(id int, value string, message string) error {
+			status = value
+			return nil
+		},
+		getStorageFn: func(int) (*models.StorageStatus, error) {
+			return &models.StorageStatus{Status: models.StorageFailed}, nil
+		},
+	}
+	queue := &fakeJobQueue{}
+
+	if err := NewBucketProvisioner(repo, &fakeAwsClient{}, queue).Enqueue(42); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if status != models.StoragePending {
+		t.Errorf("esperava status pending, veio %s", status)
+	}
+	if len(queue.jobs) != 1 || queue.jobs[0].Type != JobProvisionStorage || string(queue.jobs[0].Payload) != `{"userId":42}` {
+		t.Errorf("job inesperado %v", queue.jobs)
+	}
+}
+
+func TestBucketProvisionerMarksReady(t *testing.T) {
+	var bucket string
+	provisioner, statuses := newTestProvisioner(&fakeAwsClient{
+		bucketExistsFn: func(context.Context, string) (bool, error) { return false, nil },
+		createBucketFn: func(ctx context.Context, name string) (*s3.CreateBucketOutput, error) {
+			bucket = name
+			return &s3.CreateBucketOutput{}, nil
+		},
+	})
+
+	if err := provisioner.HandleJob(context.Background(), storageJob(42, 1)); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if bucket != "myawss3bucket-90902222345-42" {
+		t.Errorf("bucket inesperado %q", bucket)
+	}
+	if len(*statuses) != 2 || (*statuses)[1] != models.StorageReady {
+		t.Errorf("status inesperados %v", *statuses)
+	}
+}
+
+func TestBucketProvisionerCompensatesAndFailsOnLastAttempt(t *testing.T) {
+	deleted := 0
+	provisioner, statuses := newTestProvisioner(&fakeAwsClient{
+		bucketExistsFn: func(context.Context, string) (bool, error) { return false, nil },
+		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
+			return nil, errors.New("cors failure")
+		},
+		deleteBucketFn: func(context.Context, string) error {
+			deleted++
+			return nil
+		},
+	})
+
+	err := provisioner.HandleJob(context.Background(), storageJob(7, 1))
+	if err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("esperava erro recuperável, veio %v", err)
+	}
+	if last := (*statuses)[len(*statuses)-1]; last != models.StorageProvisioning {
+		t.Errorf("o status só deveria falhar na última tentativa, veio %s", last)
+	}
+
+	if err := provisioner.HandleJob(context.Background(), storageJob(7, 3)); err == nil {
+		t.Fatalf("esperava erro na última tentativa")
+	}
+	if deleted != 2 {
+		t.Errorf("esperava remover o bucket parcial a cada falha, veio %d", deleted)
+	}
+	if last := (*statuses)[len(*statuses)-1]; last != models.StorageFailed {
+		t.Errorf("esperava status failed, veio %s", last)
+	}
+}
+
+func TestBucketProvisionerLeavesReadyStorageAlone(t *testing.T) {
+	var statuses []string
+	repo := &fakeUserRepo{
+		setStorageFn: func(id int, status string, message string) error {
+			statuses = append(statuses, status)
+			return nil
+		},
+		getStorageFn: func(int) (*models.StorageStatus, error) {
+			return &models.StorageStatus{Status: models.StorageReady}, nil
+		},
+	}
+	queue := &fakeJobQueue{}
+	provisioner := NewBucketProvisioner(repo, &fakeAwsClient{}, queue)
+
+	if err := provisioner.Enqueue(7); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if err := provisioner.HandleJob(context.Background(), storageJob(7, 1)); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(statuses) != 0 || len(queue.jobs) != 0 {
+		t.Errorf("um bucket pronto não deveria ser provisionado de novo, veio %v e %d jobs", statuses, len(queue.jobs))
+	}
+}
+
+func TestBucketProvisionerOnlyRemovesTheBucketItCreated(t *testing.T) {
+	provisioner, statuses := newTestProvisioner(&fakeAwsClient{
+		bucketExistsFn: func(context.Context, string) (bool, error) { return true, nil },
+	})
+
+	if err := provisioner.HandleJob(context.Background(), storageJob(7, 1)); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if last := (*statuses)[len(*statuses)-1]; last != models.StorageReady {
+		t.Errorf("um bucket existente deveria ficar pronto, veio %s", last)
+	}
+
+	// When the bucket cannot be looked up, a failure leaves it alone.
+	provisioner, _ = newTestProvisioner(&fakeAwsClient{
+		bucketExistsFn: func(context.Context, string) (bool, error) {
+			return false, errors.New("timeout")
+		},
+		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
+			return nil, errors.New("waiter timeout")
+		},
+	})
+	if err := provisioner.HandleJob(context.Background(), storageJob(7, 1)); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("esperava erro recuperável, veio %v", err)
+	}
+}
+
+func TestBucketProvisionerStopsWhenNameIsTaken(t *testing.T) {
+	provisioner, statuses := newTestProvisioner(&fakeAwsClient{
+		bucketExistsFn: func(context.Context, string) (bool, error) { return false, nil },
+		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
+			return nil, &types.BucketAlreadyExists{}
+		},
+	})
+
+	err := provisioner.HandleJob(context.Background(), storageJob(7, 1))
+
+	var exists *types.BucketAlreadyExists
+	if !errors.As(err, &exists) || !jobs.IsPermanent(err) {
+		t.Fatalf("esperava BucketAlreadyExists permanente, veio %v", err)
+	}
+	if last := (*statuses)[len(*statuses)-1]; last != models.StorageFailed {
+		t.Errorf("esperava status failed, veio %s", last)
+	}
+}
+
+func TestBucketProvisionerReconcileRequeuesStalledUsers(t *testing.T) {
+	repo := &fakeUserRepo{
+		getStalledFn: func(before time.Time) ([]int, error) {
+			if time.Since(before) < time.Hour {
+				t.Fatalf("janela de reconciliação curta demais: %v", before)
+			}
+			return []int{3, 5}, nil
+		},
+		setStorageFn: func(int, string, string) error { return nil },
+		getStorageFn: func(int) (*models.StorageStatus, error) {
+			return &models.StorageStatus{Status: models.StorageProvisioning}, nil
+		},
+	}
+	queue := &fakeJobQueue{}
+
+	err := NewBucketProvisioner(repo, &fakeAwsClient{}, queue).Reconcile(context.Background(), models.Job{})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(queue.jobs) != 2 {
+		t.Fatalf("esperava 2 jobs, veio %d", len(queue.jobs))
+	}
+}
@@ -0,0 +1,320 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	ErrObjectNotFound = errors.New("objeto não encontrado")
+	ErrUploadNotFound = errors.New("upload não encontrado")
+	ErrInvalidRange   = errors.New("o intervalo pedido está fora do objeto")
+)
+
+// StorageUsecase streams objects of the caller's bucket through the
+// server, for the file protocols (WebDAV, S3, SFTP) and archives that
+// cannot hand out presigned URLs. The objects it writes get their
+// thumbnails queued, unless thumbnails is nil.
+type StorageUsecase struct {
+	AwsService AwsClient
+	thumbnails ThumbnailQueue
+}
+
+func NewStorageUsecase(awsService AwsClient, thumbnails ThumbnailQueue) StorageUsecase {
+	return StorageUsecase{
+		AwsService: awsService,
+		thumbnails: thumbnails,
+	}
+}
+
+// List returns the objects directly under prefix and its sub-prefixes.
+func (su *StorageUsecase) List(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, []string, error) {
+	bucketName := userBucketName(userId)
+
+	objects, prefixes, err := su.AwsService.ListObjectsDelimited(ctx, bucketName, prefix)
+	if err != nil {
+		return nil, nil, storageError(err)
+	}
+
+	return objectInfos(objects), prefixes, nil
+}
+
+// ListAll returns every object under prefix, at any depth.
+func (su *StorageUsecase) ListAll(ctx context.Context, userId int, prefix string) ([]models.ObjectInfo, error) {
+	bucketName := userBucketName(userId)
+
+	var infos []models.ObjectInfo
+	token := ""
+	for {
+		objects, next, err := su.AwsService.ListBucketPage(ctx, bucketName, prefix, token, maxPageSize)
+		if err != nil {
+			return nil, storageError(err)
+		}
+
+		infos = append(infos, objectInfos(objects)...)
+		if next == "" {
+			return infos, nil
+		}
+		token = next
+	}
+}
+
+// HasPrefix tells whether any object lives under prefix.
+func (su *StorageUsecase) HasPrefix(ctx context.Context, userId int, prefix string) (bool, error) {
+	bucketName := userBucketName(userId)
+
+	objects, _, err := su.AwsService.ListBucketPage(ctx, bucketName, prefix, "", 1)
+	if err != nil {
+		return false, storageError(err)
+	}
+
+	return len(objects) > 0, nil
+}
+
+func (su *StorageUsecase) Stat(ctx context.Context, userId int, key string) (*models.ObjectInfo, error) {
+	bucketName := userBucketName(userId)
+
+	output, err := su.AwsService.HeadObject(ctx, bucketName, key)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &models.ObjectInfo{
+		Key:          key,
+		Size:         aws.ToInt64(output.ContentLength),
+		ETag:         aws.ToString(output.ETag),
+		LastModified: aws.ToTime(output.LastModified),
+		ContentType:  aws.ToString(output.ContentType),
+	}, nil
+}
+
+// Read opens the object from offset on. The caller closes the reader.
+func (su *StorageUsecase) Read(ctx context.Context, userId int, key string, offset int64) (io.ReadCloser, error) {
+	byteRange := ""
+	if offset > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-", offset)
+	}
+
+	stream, err := su.ReadRange(ctx, userId, key, byteRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.Body, nil
+}
+
+// ReadRange opens the part of the object an HTTP Range value selects, or
+// all of it when byteRange is empty.
+func (su *StorageUsecase) ReadRange(ctx context.Context, userId int, key string, byteRange string) (*models.ObjectStream, error) {
+	bucketName := userBucketName(userId)
+
+	output, err := su.AwsService.ReadObject(ctx, bucketName, key, byteRange)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &models.ObjectStream{
+		Body: output.Body,
+		Info: models.ObjectInfo{
+			Key:          key,
+			Size:         aws.ToInt64(output.ContentLength),
+			ETag:         aws.ToString(output.ETag),
+			LastModified: aws.ToTime(output.LastModified),
+			ContentType:  aws.ToString(output.ContentType),
+		},
+		ContentRange: aws.ToString(output.ContentRange),
+	}, nil
+}
+
+// Write stores body under key, replacing any object there, and returns
+// the new ETag. It holds at most one upload part in memory.
+func (su *StorageUsecase) Write(ctx context.Context, userId int, key string, body io.Reader) (string, error) {
+	bucketName := userBucketName(userId)
+
+	etag, err := su.AwsService.WriteObject(ctx, bucketName, key, body)
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	queueThumbnails(su.thumbnails, userId, key)
+	return etag, nil
+}
+
+// Copy duplicates an object inside the bucket without moving its bytes
+// through the server.
+func (su *StorageUsecase) Copy(ctx context.Context, userId int, sourceKey string, destinationKey string) error {
+	bucketName := userBucketName(userId)
+
+	if err := su.AwsService.CopyObject(ctx, bucketName, sourceKey, destinationKey); err != nil {
+		return storageError(err)
+	}
+
+	queueThumbnails(su.thumbnails, userId, destinationKey)
+	return nil
+}
+
+func (su *StorageUsecase) Delete(ctx context.Context, userId int, key string) error {
+	bucketName := userBucketName(userId)
+
+	if err := su.AwsService.DeleteObject(ctx, bucketName, key); err != nil {
+		return storageError(err)
+	}
+
+	return nil
+}
+
+// ListPage returns one page of a listing. MaxKeys 0 asks for the largest
+// page.
+func (su *StorageUsecase) ListPage(ctx context.Context, userId int, query models.ListQuery) (*models.ObjectPage, error) {
+	if query.MaxKeys < 0 || query.MaxKeys > maxPageSize {
+		return nil, ErrInvalidPageSize
+	}
+	if query.MaxKeys == 0 {
+		query.MaxKeys = maxPageSize
+	}
+
+	bucketName := userBucketName(userId)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucketName),
+		MaxKeys: aws.Int32(int32(query.MaxKeys)),
+	}
+	if query.Prefix != "" {
+		input.Prefix = aws.String(query.Prefix)
+	}
+	if query.Delimiter != "" {
+		input.Delimiter = aws.String(query.Delimiter)
+	}
+	if query.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(query.ContinuationToken)
+	}
+	if query.StartAfter != "" {
+		input.StartAfter = aws.String(query.StartAfter)
+	}
+
+	output, err := su.AwsService.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	page := &models.ObjectPage{
+		Objects:               objectInfos(output.Contents),
+		Truncated:             aws.ToBool(output.IsTruncated),
+		NextContinuationToken: aws.ToString(output.NextContinuationToken),
+	}
+	for _, common := range output.CommonPrefixes {
+		page.Prefixes = append(page.Prefixes, aws.ToString(common.Prefix))
+	}
+
+	return page, nil
+}
+
+func (su *StorageUsecase) StartMultipart(ctx context.Context, userId int, key string) (string, error) {
+	bucketName := userBucketName(userId)
+
+	uploadId, err := su.AwsService.CreateMultipartUpload(ctx, bucketName, key)
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	return uploadId, nil
+}
+
+// UploadPart streams one part of size bytes through the server.
+func (su *StorageUsecase) UploadPart(ctx context.Context, userId int, key string, uploadId string, partNumber int32, body io.Reader, size int64) (string, error) {
+	if partNumber < 1 || partNumber > maxUploadParts {
+		return "", ErrInvalidPart
+	}
+
+	bucketName := userBucketName(userId)
+
+	etag, err := su.AwsService.UploadPart(ctx, bucketName, key, uploadId, partNumber, body, size)
+	if err != nil {
+		return "", storageError(err)
+	}
+
+	return etag, nil
+}
+
+// CompleteMultipart assembles the parts and returns the new object.
+func (su *StorageUsecase) CompleteMultipart(ctx context.Context, userId int, key string, uploadId string, parts []dto.CompletedPartDto) (*models.ObjectInfo, error) {
+	completed, err := completedParts(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketName := userBucketName(userId)
+
+	if err := su.AwsService.CompleteMultipartUpload(ctx, bucketName, key, uploadId, completed); err != nil {
+		return nil, storageError(err)
+	}
+
+	queueThumbnails(su.thumbnails, userId, key)
+
+	return su.Stat(ctx, userId, key)
+}
+
+func (su *StorageUsecase) AbortMultipart(ctx context.Context, userId int, key string, uploadId string) error {
+	bucketName := userBucketName(userId)
+
+	return storageError(su.AwsService.AbortMultipartUpload(ctx, bucketName, key, uploadId))
+}
+
+func objectInfos(objects []types.Object) []models.ObjectInfo {
+	infos := make([]models.ObjectInfo, 0, len(objects))
+	for _, object := range objects {
+		infos = append(infos, models.ObjectInfo{
+			Key:          aws.ToString(object.Key),
+			Size:         aws.ToInt64(object.Size),
+			ETag:         aws.ToString(object.ETag),
+			LastModified: aws.ToTime(object.LastModified),
+		})
+	}
+	return infos
+}
+
+// storageError turns the S3 errors callers act on into sentinels: missing
+// buckets, objects and uploads, and unsatisfiable ranges. Others are logged and
+// returned as they are.
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	var noSuchUpload *types.NoSuchUpload
+	var noSuchBucket *types.NoSuchBucket
+	var apiErr smithy.APIError
+	switch {
+	case errors.As(err, &noSuchBucket):
+		return ErrBucketNotFound
+	case errors.As(err, &noSuchKey), errors.As(err, &notFound):
+		return ErrObjectNotFound
+	case errors.As(err, &noSuchUpload):
+		return ErrUploadNotFound
+	case errors.As(err, &apiErr):
+		switch apiErr.ErrorCode() {
+		case "NoSuchBucket":
+			return ErrBucketNotFound
+		case "NoSuchKey", "NotFound":
+			return ErrObjectNotFound
+		case "NoSuchUpload":
+			return ErrUploadNotFound
+		case "InvalidRange":
+			return ErrInvalidRange
+		}
+	}
+
+	fmt.Println(err)
+	return err
+}
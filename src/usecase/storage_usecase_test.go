@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/dto"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestStorageUsecaseUsesUserBucketAndMapsMissingObjects(t *testing.T) {
+	client := &fakeAwsClient{
+		headObjectFn: func(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+			if bucket != userBucketName(7) {
+				t.Fatalf("esperava o bucket do usuário 7, veio %s", bucket)
+			}
+			if key == "sumiu.txt" {
+				return nil, &types.NotFound{}
+			}
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(3), ETag: aws.String(`"abc"`)}, nil
+		},
+		listDelimitedFn: func(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error) {
+			return nil, nil, &types.NoSuchBucket{}
+		},
+	}
+
+	usecase := NewStorageUsecase(client, nil)
+
+	info, err := usecase.Stat(context.Background(), 7, "a.txt")
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if info.Size != 3 || info.ETag != `"abc"` {
+		t.Fatalf("informações inesperadas %#v", info)
+	}
+
+	if _, err := usecase.Stat(context.Background(), 7, "sumiu.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("esperava ErrObjectNotFound, veio %v", err)
+	}
+
+	if _, _, err := usecase.List(context.Background(), 2, ""); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("esperava ErrBucketNotFound, veio %v", err)
+	}
+}
+
+func TestStorageUsecaseQueuesThumbnailsOfWrittenObjects(t *testing.T) {
+	client := &fakeAwsClient{
+		writeObjectFn: func(ctx context.Context, bucket, key string, body io.Reader) (string, error) {
+			return `"etag"`, nil
+		},
+		copyObjectFn: func(ctx context.Context, bucket, sourceKey, destinationKey string) error {
+			return nil
+		},
+		completeMultipartFn: func(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error {
+			return nil
+		},
+		headObjectFn: func(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+			return &s3.HeadObjectOutput{ContentLength: aws.Int64(3)}, nil
+		},
+	}
+
+	thumbnails := &fakeThumbnailQueue{}
+	usecase := NewStorageUsecase(client, thumbnails)
+	ctx := context.Background()
+
+	if _, err := usecase.Write(ctx, 7, "foto.jpg", strings.NewReader("abc")); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if err := usecase.Copy(ctx, 7, "foto.jpg", "copia.jpg"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if _, err := usecase.CompleteMultipart(ctx, 7, "grande.png", "upload-1", []dto.CompletedPartDto{{PartNumber: 1, ETag: `"a"`}}); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	expected := []string{"foto.jpg", "copia.jpg", "grande.png"}
+	if !reflect.DeepEqual(thumbnails.enqueued, expected) {
+		t.Fatalf("esperava as miniaturas de %v, veio %v", expected, thumbnails.enqueued)
+	}
+}
@@ -0,0 +1,351 @@
+package usecase
+
+import (
+	"bytes"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "image/gif"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const JobGenerateThumbnails = "thumbnails.generate"
+
+var (
+	ErrThumbnailsDisabled = errors.New("as miniaturas não estão habilitadas neste servidor")
+	ErrNotImage           = errors.New("só há miniaturas de imagens JPEG, PNG, GIF e WebP")
+	ErrThumbnailPending   = errors.New("a miniatura ainda está sendo gerada")
+	ErrImageTooLarge      = errors.New("a imagem é grande demais para ser processada")
+	ErrInvalidImage       = errors.New("o arquivo não é uma imagem válida")
+)
+
+// thumbnailExtensions are the images thumbnails are made of.
+var thumbnailExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// ThumbnailConfig is read by ThumbnailConfigFromEnv. Thumbnails are off
+// while Bucket is empty.
+type ThumbnailConfig struct {
+	// Bucket holds the thumbnails of every user, apart from their files.
+	Bucket string
+	Sizes  []int
+	// MaxSourceBytes and MaxPixels bound the images that are decoded, so
+	// a small file cannot unpack into gigabytes of pixels.
+	MaxSourceBytes int64
+	MaxPixels      int
+}
+
+// ThumbnailConfigFromEnv reads THUMBNAIL_BUCKET and THUMBNAIL_SIZES, a
+// comma separated list of box sizes such as "128,256,512".
+func ThumbnailConfigFromEnv() ThumbnailConfig {
+	config := ThumbnailConfig{
+		Bucket:         os.Getenv("THUMBNAIL_BUCKET"),
+		Sizes:          []int{128, 256, 512},
+		MaxSourceBytes: 50 << 20,
+		MaxPixels:      50_000_000,
+	}
+
+	if spec := os.Getenv("THUMBNAIL_SIZES"); spec != "" {
+		var sizes []int
+		for _, field := range strings.Split(spec, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err == nil && size > 0 && size <= 2048 {
+				sizes = append(sizes, size)
+			}
+		}
+		if len(sizes) > 0 {
+			slices.Sort(sizes)
+			config.Sizes = slices.Compact(sizes)
+		}
+	}
+
+	return config
+}
+
+type thumbnailPayload struct {
+	UserID int    `json:"userId"`
+	Key    string `json:"key"`
+}
+
+type ThumbnailUsecase struct {
+	storage             StorageUsecase
+	thumbnailRepository ThumbnailRepository
+	jobQueue            ThumbnailJobQueue
+	config              ThumbnailConfig
+}
+
+func NewThumbnailUsecase(storage StorageUsecase, thumbnailRepo ThumbnailRepository, jobQueue ThumbnailJobQueue, config ThumbnailConfig) ThumbnailUsecase {
+	return ThumbnailUsecase{
+		storage:             storage,
+		thumbnailRepository: thumbnailRepo,
+		jobQueue:            jobQueue,
+		config:              config,
+	}
+}
+
+func isThumbnailSource(key string) bool {
+	return slices.Contains(thumbnailExtensions, strings.ToLower(path.Ext(key)))
+}
+
+// EnqueueThumbnails queues the thumbnails of an uploaded object, if it is
+// an image.
+func (tu *ThumbnailUsecase) EnqueueThumbnails(userId int, key string) error {
+	return tu.EnqueueThumbnailsAt(userId, key, time.Time{})
+}
+
+// EnqueueThumbnailsAt is EnqueueThumbnails delayed until runAt.
+func (tu *ThumbnailUsecase) EnqueueThumbnailsAt(userId int, key string, runAt time.Time) error {
+	if tu.config.Bucket == "" || !isThumbnailSource(key) {
+		return nil
+	}
+
+	_, err := tu.jobQueue.EnqueueWith(JobGenerateThumbnails, thumbnailPayload{UserID: userId, Key: key}, jobs.EnqueueOptions{RunAt: runAt})
+	return err
+}
+
+// queueThumbnails schedules the thumbnails of an object just written. The
+// write stands either way; a thumbnail still missing is queued again when
+// it is first asked for.
+func queueThumbnails(queue ThumbnailQueue, userId int, key string) {
+	if queue == nil {
+		return
+	}
+
+	if err := queue.EnqueueThumbnails(userId, key); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// Thumbnail finds the thumbnail of the current version of an object, in
+// the smallest size holding size. When there is none yet, it is queued
+// and ErrThumbnailPending returned; objects uploaded straight to S3 get
+// theirs this way. A version whose job failed for good reports why.
+func (tu *ThumbnailUsecase) Thumbnail(ctx context.Context, userId int, key string, size int) (*models.Thumbnail, error) {
+	if tu.config.Bucket == "" {
+		return nil, ErrThumbnailsDisabled
+	}
+	if !isThumbnailSource(key) {
+		return nil, ErrNotImage
+	}
+
+	size = tu.boxSize(size)
+
+	source, err := tu.storage.Stat(ctx, userId, key)
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnail, err := tu.thumbnailRepository.GetThumbnail(userId, key, size)
+	if err != nil {
+		return nil, err
+	}
+	if thumbnail != nil && thumbnail.SourceETag == source.ETag {
+		return thumbnail, nil
+	}
+
+	// One job per version of the object, however often it is asked for.
+	uniqueKey := fmt.Sprintf("thumbnails:%d:%s:%s", userId, source.ETag, key)
+	id, err := tu.jobQueue.EnqueueWith(JobGenerateThumbnails, thumbnailPayload{UserID: userId, Key: key}, jobs.EnqueueOptions{
+		UniqueKey: uniqueKey,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	if id == 0 {
+		return nil, tu.queuedThumbnailError(uniqueKey)
+	}
+
+	return nil, ErrThumbnailPending
+}
+
+// queuedThumbnailError tells why the thumbnails of a version already
+// queued are missing. Images that cannot be made into thumbnails say so;
+// a job that ran out of attempts for any other reason is queued again.
+func (tu *ThumbnailUsecase) queuedThumbnailError(uniqueKey string) error {
+	job, err := tu.jobQueue.GetJobByUniqueKey(uniqueKey)
+	if err != nil {
+		return err
+	}
+	if job == nil || job.Status != models.JobDead {
+		return ErrThumbnailPending
+	}
+
+	for _, failure := range []error{ErrInvalidImage, ErrImageTooLarge} {
+		if strings.HasPrefix(job.LastError, failure.Error()) {
+			return failure
+		}
+	}
+
+	if _, err := tu.jobQueue.RetryJob(job.ID); err != nil {
+		return err
+	}
+	return ErrThumbnailPending
+}
+
+func (tu *ThumbnailUsecase) boxSize(size int) int {
+	for _, box := range tu.config.Sizes {
+		if box >= size {
+			return box
+		}
+	}
+	return tu.config.Sizes[len(tu.config.Sizes)-1]
+}
+
+// OpenThumbnail streams a thumbnail from the thumbnail bucket.
+func (tu *ThumbnailUsecase) OpenThumbnail(ctx context.Context, thumbnail *models.Thumbnail) (io.ReadCloser, error) {
+	output, err := tu.storage.AwsService.ReadObject(ctx, tu.config.Bucket, thumbnail.DerivativeKey, "")
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return output.Body, nil
+}
+
+// derivativeKey places the thumbnails of an object in the thumbnail
+// bucket. The key is hashed, as it may be longer than a key allows once
+// prefixed.
+func derivativeKey(userId int, key string, size int) string {
+	return fmt.Sprintf("%d/%x/%d", userId, sha256.Sum256([]byte(key)), size)
+}
+
+// HandleThumbnailJob reads the object and stores one thumbnail per size.
+// Images that cannot be decoded fail the job for good.
+func (tu *ThumbnailUsecase) HandleThumbnailJob(ctx context.Context, job models.Job) error {
+	var payload thumbnailPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	if tu.config.Bucket == "" {
+		return nil
+	}
+
+	bucketName := userBucketName(payload.UserID)
+
+	output, err := tu.storage.AwsService.ReadObject(ctx, bucketName, payload.Key, "")
+	if err != nil {
+		if errors.Is(storageError(err), ErrObjectNotFound) {
+			// Deleted since; there is nothing left to do.
+			return nil
+		}
+		return err
+	}
+	defer output.Body.Close()
+
+	source, err := readImage(output, tu.config.MaxSourceBytes, tu.config.MaxPixels)
+	if err != nil {
+		if errors.Is(err, ErrImageTooLarge) || errors.Is(err, ErrInvalidImage) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	// The largest size is scaled from the image and each smaller one from
+	// the size before, which is much cheaper for photos.
+	for i := len(tu.config.Sizes) - 1; i >= 0; i-- {
+		size := tu.config.Sizes[i]
+		thumbnail, resized, content, err := resizeImage(source, size)
+		if err != nil {
+			return err
+		}
+		source = resized
+
+		thumbnail.UserID = payload.UserID
+		thumbnail.ObjectKey = payload.Key
+		thumbnail.SourceETag = aws.ToString(output.ETag)
+		thumbnail.DerivativeKey = derivativeKey(payload.UserID, payload.Key, size)
+
+		if _, err := tu.storage.AwsService.WriteObject(ctx, tu.config.Bucket, thumbnail.DerivativeKey, bytes.NewReader(content)); err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if err := tu.thumbnailRepository.SaveThumbnail(*thumbnail); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readImage decodes the image being read, refusing those over maxBytes or
+// maxPixels before their pixels are allocated.
+func readImage(output *s3.GetObjectOutput, maxBytes int64, maxPixels int) (image.Image, error) {
+	if aws.ToInt64(output.ContentLength) > maxBytes {
+		return nil, ErrImageTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(output.Body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrImageTooLarge
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	return source, nil
+}
+
+// resizeImage fits the image in a size by size box, never enlarging it.
+// Opaque images become JPEGs; the rest keep their transparency as PNGs.
+func resizeImage(source image.Image, size int) (*models.Thumbnail, image.Image, []byte, error) {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(height*size/width, 1)
+		} else {
+			width, height = max(width*size/height, 1), size
+		}
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Src, nil)
+
+	var buffer bytes.Buffer
+	thumbnail := &models.Thumbnail{Size: size, Width: width, Height: height}
+
+	if resized.Opaque() {
+		thumbnail.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buffer, resized, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		thumbnail.ContentType = "image/png"
+		if err := png.Encode(&buffer, resized); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	thumbnail.Bytes = int64(buffer.Len())
+	return thumbnail, resized, buffer.Bytes(), nil
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"bytes"
+	"cloud_file_manager/src/jobs"
+	"cloud_file_manager/src/models"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+type fakeThumbnailRepo struct {
+	thumbnails map[string]models.Thumbnail
+}
+
+func (f *fakeThumbnailRepo) SaveThumbnail(thumbnail models.Thumbnail) error {
+	f.thumbnails[fmt.Sprintf("%s@%d", thumbnail.ObjectKey, thumbnail.Size)] = thumbnail
+	return nil
+}
+
+func (f *fakeThumbnailRepo) GetThumbnail(userId int, objectKey string, size int) (*models.Thumbnail, error) {
+	thumbnail, ok := f.thumbnails[fmt.Sprintf("%s@%d", objectKey, size)]
+	if !ok || thumbnail.UserID != userId {
+		return nil, nil
+	}
+	return &thumbnail, nil
+}
+
+func testImage(width, height int, alpha uint8) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: alpha})
+		}
+	}
+	return img
+}
+
+func newTestThumbnails(bucket *memoryBucket) (ThumbnailUsecase, *fakeThumbnailRepo, *fakeJobQueue) {
+	repo := &fakeThumbnailRepo{thumbnails: map[string]models.Thumbnail{}}
+	queue := &fakeJobQueue{}
+	config := ThumbnailConfigFromEnv()
+	config.Bucket = "miniaturas"
+	return NewThumbnailUsecase(NewStorageUsecase(bucket.client(), nil), repo, queue, config), repo, queue
+}
+
+func TestThumbnailUsecaseGeneratesSizesOnFirstRequest(t *testing.T) {
+	var photo, logo bytes.Buffer
+	jpeg.Encode(&photo, testImage(1000, 500, 255), nil)
+	png.Encode(&logo, testImage(100, 60, 128))
+
+	bucket := &memoryBucket{objects: map[string][]byte{"fotos/praia.JPG": photo.Bytes(), "logo.png": logo.Bytes(), "notas.txt": []byte("oi")}}
+	thumbnails, repo, queue := newTestThumbnails(bucket)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := thumbnails.Thumbnail(ctx, 7, "fotos/praia.JPG", 200); !errors.Is(err, ErrThumbnailPending) {
+			t.Fatalf("esperava ErrThumbnailPending, veio %v", err)
+		}
+	}
+	if len(queue.jobs) != 1 || queue.jobs[0].Type != JobGenerateThumbnails {
+		t.Fatalf("esperava um único job, veio %#v", queue.jobs)
+	}
+
+	if err := thumbnails.HandleThumbnailJob(ctx, queue.jobs[0]); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(repo.thumbnails) != 3 {
+		t.Fatalf("esperava três tamanhos, veio %d", len(repo.thumbnails))
+	}
+
+	thumbnail, err := thumbnails.Thumbnail(ctx, 7, "fotos/praia.JPG", 200)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if thumbnail.Size != 256 || thumbnail.Width != 256 || thumbnail.Height != 128 || thumbnail.ContentType != "image/jpeg" {
+		t.Fatalf("miniatura inesperada %#v", thumbnail)
+	}
+
+	body, err := thumbnails.OpenThumbnail(ctx, thumbnail)
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	decoded, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil || decoded.Bounds().Dx() != 256 {
+		t.Fatalf("a miniatura guardada deveria ser um JPEG de 256 pixels: %v", err)
+	}
+
+	// Uploading a new version makes the thumbnail stale.
+	bucket.objects["fotos/praia.JPG"] = logo.Bytes()
+	if _, err := thumbnails.Thumbnail(ctx, 7, "fotos/praia.JPG", 200); !errors.Is(err, ErrThumbnailPending) {
+		t.Fatalf("esperava ErrThumbnailPending para a nova versão, veio %v", err)
+	}
+
+	// Small images are not enlarged, and transparency is kept.
+	if err := thumbnails.EnqueueThumbnails(7, "logo.png"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if err := thumbnails.HandleThumbnailJob(ctx, queue.jobs[len(queue.jobs)-1]); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	small := repo.thumbnails["logo.png@512"]
+	if small.Width != 100 || small.Height != 60 || small.ContentType != "image/png" {
+		t.Fatalf("miniatura inesperada %#v", small)
+	}
+
+	if _, err := thumbnails.Thumbnail(ctx, 7, "notas.txt", 128); !errors.Is(err, ErrNotImage) {
+		t.Fatalf("esperava ErrNotImage, veio %v", err)
+	}
+	jobCount := len(queue.jobs)
+	thumbnails.EnqueueThumbnails(7, "notas.txt")
+	if len(queue.jobs) != jobCount {
+		t.Fatalf("arquivos que não são imagens não deveriam ir para a fila")
+	}
+}
+
+func TestThumbnailUsecaseRefusesOversizedImages(t *testing.T) {
+	var picture, broken bytes.Buffer
+	png.Encode(&picture, testImage(40, 40, 255))
+	broken.WriteString("não é uma imagem")
+
+	bucket := &memoryBucket{objects: map[string][]byte{"grande.png": picture.Bytes(), "quebrada.png": broken.Bytes()}}
+	thumbnails, repo, queue := newTestThumbnails(bucket)
+	thumbnails.config.MaxPixels = 1000
+
+	for key, expected := range map[string]error{"grande.png": ErrImageTooLarge, "quebrada.png": ErrInvalidImage} {
+		if _, err := thumbnails.Thumbnail(context.Background(), 7, key, 128); !errors.Is(err, ErrThumbnailPending) {
+			t.Fatalf("%s: esperava ErrThumbnailPending, veio %v", key, err)
+		}
+		job := &queue.jobs[len(queue.jobs)-1]
+		err := thumbnails.HandleThumbnailJob(context.Background(), *job)
+		if !jobs.IsPermanent(err) {
+			t.Fatalf("%s: esperava um erro permanente, veio %v", key, err)
+		}
+
+		// Once the job is buried, asking again tells why instead of
+		// waiting for it forever.
+		job.Status, job.LastError = models.JobDead, err.Error()
+		if _, err := thumbnails.Thumbnail(context.Background(), 7, key, 128); !errors.Is(err, expected) {
+			t.Fatalf("%s: esperava %v, veio %v", key, expected, err)
+		}
+	}
+
+	if len(repo.thumbnails) != 0 {
+		t.Fatalf("nenhuma miniatura deveria ser gerada, veio %d", len(repo.thumbnails))
+	}
+}
+
+func TestThumbnailUsecaseRequeuesJobsThatRanOutOfAttempts(t *testing.T) {
+	var picture bytes.Buffer
+	png.Encode(&picture, testImage(40, 40, 255))
+
+	bucket := &memoryBucket{objects: map[string][]byte{"foto.png": picture.Bytes()}}
+	thumbnails, _, queue := newTestThumbnails(bucket)
+
+	if _, err := thumbnails.Thumbnail(context.Background(), 7, "foto.png", 128); !errors.Is(err, ErrThumbnailPending) {
+		t.Fatalf("esperava ErrThumbnailPending, veio %v", err)
+	}
+	queue.jobs[0].Status, queue.jobs[0].LastError = models.JobDead, "conexão perdida"
+
+	if _, err := thumbnails.Thumbnail(context.Background(), 7, "foto.png", 128); !errors.Is(err, ErrThumbnailPending) {
+		t.Fatalf("esperava ErrThumbnailPending, veio %v", err)
+	}
+	if len(queue.jobs) != 1 || queue.jobs[0].Status != models.JobQueued {
+		t.Fatalf("o job deveria voltar para a fila, veio %#v", queue.jobs)
+	}
+}
@@ -0,0 +1,22 @@
+package usecase
+
+import (
+	"cloud_file_manager/src/utils"
+	"time"
+)
+
+// issueUserToken creates a single-use token for the user, stores its hash and
+// returns the plain value to be mailed.
+func issueUserToken(repo UserTokenRepository, userId int, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	err = repo.CreateUserToken(userId, purpose, utils.HashToken(token), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
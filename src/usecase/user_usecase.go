@@ -2,21 +2,38 @@ package usecase
 
 import (
 	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
 	"cloud_file_manager/src/models"
-	"context"
+	"cloud_file_manager/src/utils"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	emailVerificationPurpose = "email_verification"
+	emailVerificationTTL     = 24 * time.Hour
 )
 
+var ErrStorageNotFailed = errors.New("o armazenamento só pode ser reprovisionado após uma falha")
+
 type UserUsecase struct {
-	repository UserRepository
-	awsService AwsClient
+	repository      UserRepository
+	provisioner     StorageProvisioner
+	tokenRepository UserTokenRepository
+	mailer          Mailer
 }
 
-func NewUserUseCase(repo UserRepository, aws AwsClient) UserUsecase {
+func NewUserUseCase(repo UserRepository, provisioner StorageProvisioner, tokenRepo UserTokenRepository, mailer Mailer) UserUsecase {
 	return UserUsecase{
-		repository: repo,
-		awsService: aws,
+		repository:      repo,
+		provisioner:     provisioner,
+		tokenRepository: tokenRepo,
+		mailer:          mailer,
 	}
 }
 
@@ -24,25 +41,36 @@ func (uu *UserUsecase) GetUsers() ([]models.User, error) {
 	return uu.repository.GetUsers()
 }
 
-func (uu *UserUsecase) CreateUser(user models.User) (models.User, error) {
+// CreateUser stores the account as unverified and mails the verification
+// link. Storage is only provisioned once the address is confirmed. When the
+// link cannot be sent the user row is removed again, so a failed signup
+// does not leave behind an account nobody can activate.
+func (uu *UserUsecase) CreateUser(input dto.CreateUserDto) (dto.PublicUserDto, error) {
+	user := models.User{
+		Name:     input.Name,
+		Email:    input.Email,
+		Password: input.Password,
+	}
 
 	userId, err := uu.repository.CreateUser(user)
 	if err != nil {
 		fmt.Println(err)
-		return models.User{}, err
+		return dto.PublicUserDto{}, err
 	}
 
-	ctx := context.Background()
-	bucketName := "myawss3bucket-90902222345-" + strconv.Itoa(userId)
-	_, err = uu.awsService.CreateBucket(ctx, bucketName)
+	user.ID = userId
+
+	err = uu.sendVerificationEmail(user)
 	if err != nil {
 		fmt.Println(err)
-		return models.User{}, err
-	}
 
-	user.ID = userId
+		if deleteErr := uu.repository.DeleteUnverifiedUser(userId); deleteErr != nil {
+			fmt.Println(deleteErr)
+		}
+		return dto.PublicUserDto{}, err
+	}
 
-	return user, nil
+	return dto.ToPublicUser(user), nil
 }
 
 func (uu *UserUsecase) GetUserById(id int) (*models.User, error) {
@@ -56,9 +84,21 @@ func (uu *UserUsecase) GetUserById(id int) (*models.User, error) {
 	return user, nil
 }
 
+// Login accepts an optional list of scopes so clients such as kiosks can ask
+// for a narrower token than a full login.
 func (uu *UserUsecase) Login(userDto dto.UserLoginDto) (*dto.UserResponseDto, error) {
 
+	scopes, err := handlers.NormalizeScopes(userDto.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	userDto.Scopes = scopes
+
 	user, err := uu.repository.Login(userDto)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, ErrInvalidCredentials
+	}
+
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -66,3 +106,122 @@ func (uu *UserUsecase) Login(userDto dto.UserLoginDto) (*dto.UserResponseDto, er
 
 	return user, nil
 }
+
+func (uu *UserUsecase) IsAdmin(userId int) (bool, error) {
+	return uu.repository.IsAdmin(userId)
+}
+
+func (uu *UserUsecase) IsEmailVerified(userId int) (bool, error) {
+	return uu.repository.IsEmailVerified(userId)
+}
+
+// VerifyEmail confirms the address behind the token and starts provisioning
+// the user's bucket. The outcome is reported by GetStorageStatus. The token
+// is spent only once provisioning is queued, so the link can be followed
+// again if queueing fails.
+func (uu *UserUsecase) VerifyEmail(token string) error {
+	tokenHash := utils.HashToken(token)
+
+	userId, err := uu.tokenRepository.GetUserTokenOwner(emailVerificationPurpose, tokenHash)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if userId == 0 {
+		return ErrInvalidToken
+	}
+
+	err = uu.repository.MarkEmailVerified(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	err = uu.provisioner.Enqueue(userId)
+	if err != nil {
+		return err
+	}
+
+	// A concurrent request may have spent it since; the email is verified
+	// either way.
+	_, err = uu.tokenRepository.ConsumeUserToken(emailVerificationPurpose, tokenHash)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (uu *UserUsecase) GetStorageStatus(userId int) (*models.StorageStatus, error) {
+	return uu.repository.GetStorageStatus(userId)
+}
+
+// RetryStorage starts provisioning again after every attempt failed.
+func (uu *UserUsecase) RetryStorage(userId int) error {
+	status, err := uu.repository.GetStorageStatus(userId)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if status == nil {
+		return ErrUserNotFound
+	}
+
+	if status.Status != models.StorageFailed {
+		return ErrStorageNotFailed
+	}
+
+	return uu.provisioner.Enqueue(userId)
+}
+
+// ResendVerification mails a new link to unverified accounts and does
+// nothing otherwise, without telling the caller which case happened.
+func (uu *UserUsecase) ResendVerification(email string) error {
+	user, err := uu.repository.GetUserByEmail(email)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	verified, err := uu.repository.IsEmailVerified(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	if verified {
+		return nil
+	}
+
+	return uu.sendVerificationEmail(*user)
+}
+
+func (uu *UserUsecase) sendVerificationEmail(user models.User) error {
+	return sendVerificationEmail(uu.tokenRepository, uu.mailer, user)
+}
+
+func sendVerificationEmail(tokenRepository UserTokenRepository, mailer Mailer, user models.User) error {
+	token, err := issueUserToken(tokenRepository, user.ID, emailVerificationPurpose, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := os.Getenv("APP_URL") + "/verify-email?token=" + token
+	body := "Olá, " + user.Name + ".\n\n" +
+		"Confirme seu email para liberar o envio de arquivos:\n\n" +
+		link + "\n\n" +
+		"O link expira em 24 horas."
+
+	return mailer.Send(user.Email, "Confirme seu email", body)
+}
+
+func userBucketName(userId int) string {
+	return "myawss3bucket-90902222345-" + strconv.Itoa(userId)
+}
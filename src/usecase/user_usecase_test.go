@@ -3,9 +3,13 @@ package usecase
 import (
 	"context"
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"time"
 
 	"cloud_file_manager/src/dto"
+	"cloud_file_manager/src/handlers"
 	"cloud_file_manager/src/models"
 
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
@@ -14,10 +18,21 @@ import (
 )
 
 type fakeUserRepo struct {
-	createUserFn  func(models.User) (int, error)
-	getUsersFn    func() ([]models.User, error)
-	getUserByIDFn func(int) (*models.User, error)
-	loginFn       func(dto.UserLoginDto) (*dto.UserResponseDto, error)
+	createUserFn     func(models.User) (int, error)
+	getUsersFn       func() ([]models.User, error)
+	getUserByIDFn    func(int) (*models.User, error)
+	loginFn          func(dto.UserLoginDto) (*dto.UserResponseDto, error)
+	getByEmailFn     func(string) (*models.User, error)
+	updatePassFn     func(int, string) error
+	isVerifiedFn     func(int) (bool, error)
+	markVerified     func(int) error
+	isAdminFn        func(int) (bool, error)
+	updateProfile    func(int, string, string) error
+	softDeleteFn     func(int) error
+	deleteUnverified func(int) error
+	setStorageFn     func(int, string, string) error
+	getStorageFn     func(int) (*models.StorageStatus, error)
+	getStalledFn     func(time.Time) ([]int, error)
 }
 
 func (f *fakeUserRepo) CreateUser(u models.User) (int, error) {
@@ -48,12 +63,211 @@ func (f *fakeUserRepo) Login(input dto.UserLoginDto) (*dto.UserResponseDto, erro
 	return f.loginFn(input)
 }
 
+func (f *fakeUserRepo) GetUserByEmail(email string) (*models.User, error) {
+	if f.getByEmailFn == nil {
+		panic("GetUserByEmail not implemented")
+	}
+	return f.getByEmailFn(email)
+}
+
+func (f *fakeUserRepo) UpdatePassword(id int, password string) error {
+	if f.updatePassFn == nil {
+		panic("UpdatePassword not implemented")
+	}
+	return f.updatePassFn(id, password)
+}
+
+func (f *fakeUserRepo) IsEmailVerified(id int) (bool, error) {
+	if f.isVerifiedFn == nil {
+		panic("IsEmailVerified not implemented")
+	}
+	return f.isVerifiedFn(id)
+}
+
+func (f *fakeUserRepo) MarkEmailVerified(id int) error {
+	if f.markVerified == nil {
+		panic("MarkEmailVerified not implemented")
+	}
+	return f.markVerified(id)
+}
+
+func (f *fakeUserRepo) IsAdmin(id int) (bool, error) {
+	if f.isAdminFn == nil {
+		panic("IsAdmin not implemented")
+	}
+	return f.isAdminFn(id)
+}
+
+func (f *fakeUserRepo) UpdateProfile(id int, name string, email string) error {
+	if f.updateProfile == nil {
+		panic("UpdateProfile not implemented")
+	}
+	return f.updateProfile(id, name, email)
+}
+
+func (f *fakeUserRepo) SoftDeleteUser(id int) error {
+	if f.softDeleteFn == nil {
+		panic("SoftDeleteUser not implemented")
+	}
+	return f.softDeleteFn(id)
+}
+
+func (f *fakeUserRepo) DeleteUnverifiedUser(id int) error {
+	if f.deleteUnverified == nil {
+		panic("DeleteUnverifiedUser not implemented")
+	}
+	return f.deleteUnverified(id)
+}
+
+func (f *fakeUserRepo) SetStorageStatus(id int, status string, message string) error {
+	if f.setStorageFn == nil {
+		panic("SetStorageStatus not implemented")
+	}
+	return f.setStorageFn(id, status, message)
+}
+
+func (f *fakeUserRepo) GetStorageStatus(id int) (*models.StorageStatus, error) {
+	if f.getStorageFn == nil {
+		panic("GetStorageStatus not implemented")
+	}
+	return f.getStorageFn(id)
+}
+
+func (f *fakeUserRepo) GetStalledStorageUsers(before time.Time) ([]int, error) {
+	if f.getStalledFn == nil {
+		panic("GetStalledStorageUsers not implemented")
+	}
+	return f.getStalledFn(before)
+}
+
 type fakeAwsClient struct {
 	createBucketFn          func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error)
+	bucketExistsFn          func(ctx context.Context, bucket string) (bool, error)
 	listBucketsFn           func(ctx context.Context) ([]types.Bucket, error)
 	listBucketItemsFn       func(ctx context.Context, bucket string) ([]types.Object, error)
+	listBucketPageFn        func(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error)
 	getObjectFn             func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
 	putObjectPresignedURLFn func(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error)
+	emptyBucketFn           func(ctx context.Context, bucket string) error
+	deletePrefixFn          func(ctx context.Context, bucket, prefix string) error
+	deleteBucketFn          func(ctx context.Context, bucket string) error
+	deleteObjectFn          func(ctx context.Context, bucket, key string) error
+	copyObjectFn            func(ctx context.Context, bucket, sourceKey, destinationKey string) error
+	createMultipartFn       func(ctx context.Context, bucket, key string) (string, error)
+	presignUploadPartFn     func(ctx context.Context, bucket, key, uploadId string, partNumber int32, ttl int64) (*v4.PresignedHTTPRequest, error)
+	completeMultipartFn     func(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error
+	abortMultipartFn        func(ctx context.Context, bucket, key, uploadId string) error
+	listDelimitedFn         func(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error)
+	headObjectFn            func(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error)
+	readObjectFn            func(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error)
+	writeObjectFn           func(ctx context.Context, bucket, key string, body io.Reader) (string, error)
+	listObjectsV2Fn         func(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	uploadPartFn            func(ctx context.Context, bucket, key, uploadId string, partNumber int32, body io.Reader, size int64) (string, error)
+}
+
+func (f *fakeAwsClient) ListObjectsDelimited(ctx context.Context, bucket, prefix string) ([]types.Object, []string, error) {
+	if f.listDelimitedFn == nil {
+		panic("ListObjectsDelimited not implemented")
+	}
+	return f.listDelimitedFn(ctx, bucket, prefix)
+}
+
+func (f *fakeAwsClient) HeadObject(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+	if f.headObjectFn == nil {
+		panic("HeadObject not implemented")
+	}
+	return f.headObjectFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) ReadObject(ctx context.Context, bucket, key, byteRange string) (*s3.GetObjectOutput, error) {
+	if f.readObjectFn == nil {
+		panic("ReadObject not implemented")
+	}
+	return f.readObjectFn(ctx, bucket, key, byteRange)
+}
+
+func (f *fakeAwsClient) WriteObject(ctx context.Context, bucket, key string, body io.Reader) (string, error) {
+	if f.writeObjectFn == nil {
+		panic("WriteObject not implemented")
+	}
+	return f.writeObjectFn(ctx, bucket, key, body)
+}
+
+func (f *fakeAwsClient) UploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, body io.Reader, size int64) (string, error) {
+	if f.uploadPartFn == nil {
+		panic("UploadPart not implemented")
+	}
+	return f.uploadPartFn(ctx, bucket, key, uploadId, partNumber, body, size)
+}
+
+func (f *fakeAwsClient) ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	if f.listObjectsV2Fn == nil {
+		panic("ListObjectsV2 not implemented")
+	}
+	return f.listObjectsV2Fn(ctx, input)
+}
+
+func (f *fakeAwsClient) DeleteObject(ctx context.Context, bucket, key string) error {
+	if f.deleteObjectFn == nil {
+		panic("DeleteObject not implemented")
+	}
+	return f.deleteObjectFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) CopyObject(ctx context.Context, bucket, sourceKey, destinationKey string) error {
+	if f.copyObjectFn == nil {
+		panic("CopyObject not implemented")
+	}
+	return f.copyObjectFn(ctx, bucket, sourceKey, destinationKey)
+}
+
+func (f *fakeAwsClient) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	if f.createMultipartFn == nil {
+		panic("CreateMultipartUpload not implemented")
+	}
+	return f.createMultipartFn(ctx, bucket, key)
+}
+
+func (f *fakeAwsClient) PresignUploadPart(ctx context.Context, bucket, key, uploadId string, partNumber int32, ttl int64) (*v4.PresignedHTTPRequest, error) {
+	if f.presignUploadPartFn == nil {
+		panic("PresignUploadPart not implemented")
+	}
+	return f.presignUploadPartFn(ctx, bucket, key, uploadId, partNumber, ttl)
+}
+
+func (f *fakeAwsClient) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadId string, parts []types.CompletedPart) error {
+	if f.completeMultipartFn == nil {
+		panic("CompleteMultipartUpload not implemented")
+	}
+	return f.completeMultipartFn(ctx, bucket, key, uploadId, parts)
+}
+
+func (f *fakeAwsClient) AbortMultipartUpload(ctx context.Context, bucket, key, uploadId string) error {
+	if f.abortMultipartFn == nil {
+		panic("AbortMultipartUpload not implemented")
+	}
+	return f.abortMultipartFn(ctx, bucket, key, uploadId)
+}
+
+func (f *fakeAwsClient) EmptyBucket(ctx context.Context, bucket string) error {
+	if f.emptyBucketFn == nil {
+		panic("EmptyBucket not implemented")
+	}
+	return f.emptyBucketFn(ctx, bucket)
+}
+
+func (f *fakeAwsClient) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	if f.deletePrefixFn == nil {
+		panic("DeletePrefix not implemented")
+	}
+	return f.deletePrefixFn(ctx, bucket, prefix)
+}
+
+func (f *fakeAwsClient) DeleteBucket(ctx context.Context, bucket string) error {
+	if f.deleteBucketFn == nil {
+		panic("DeleteBucket not implemented")
+	}
+	return f.deleteBucketFn(ctx, bucket)
 }
 
 func (f *fakeAwsClient) CreateBucket(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
@@ -63,6 +277,13 @@ func (f *fakeAwsClient) CreateBucket(ctx context.Context, bucket string) (*s3.Cr
 	return f.createBucketFn(ctx, bucket)
 }
 
+func (f *fakeAwsClient) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	if f.bucketExistsFn == nil {
+		panic("BucketExists not implemented")
+	}
+	return f.bucketExistsFn(ctx, bucket)
+}
+
 func (f *fakeAwsClient) ListBuckets(ctx context.Context) ([]types.Bucket, error) {
 	if f.listBucketsFn == nil {
 		panic("ListBuckets not implemented")
@@ -77,6 +298,13 @@ func (f *fakeAwsClient) ListBucketItems(ctx context.Context, bucket string) ([]t
 	return f.listBucketItemsFn(ctx, bucket)
 }
 
+func (f *fakeAwsClient) ListBucketPage(ctx context.Context, bucket, prefix, token string, maxKeys int32) ([]types.Object, string, error) {
+	if f.listBucketPageFn == nil {
+		panic("ListBucketPage not implemented")
+	}
+	return f.listBucketPageFn(ctx, bucket, prefix, token, maxKeys)
+}
+
 func (f *fakeAwsClient) GetObject(ctx context.Context, bucket, key string, ttl int64) (*v4.PresignedHTTPRequest, error) {
 	if f.getObjectFn == nil {
 		panic("GetObject not implemented")
@@ -101,17 +329,20 @@ func TestUserUsecaseCreateUser(t *testing.T) {
 		},
 	}
 
-	var capturedBucket string
-	awsClient := &fakeAwsClient{
-		createBucketFn: func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
-			capturedBucket = bucket
-			return &s3.CreateBucketOutput{}, nil
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(userId int, purpose string, tokenHash string, expiresAt time.Time) error {
+			if userId != 42 || purpose != emailVerificationPurpose {
+				t.Fatalf("token inesperado para %d/%s", userId, purpose)
+			}
+			return nil
 		},
 	}
+	mailer := &fakeMailer{}
+	provisioner := &fakeProvisioner{}
 
-	usecase := NewUserUseCase(repo, awsClient)
+	usecase := NewUserUseCase(repo, provisioner, tokens, mailer)
 
-	created, err := usecase.CreateUser(models.User{
+	created, err := usecase.CreateUser(dto.CreateUserDto{
 		Name:     "Alice",
 		Email:    "alice@example.com",
 		Password: "secret",
@@ -124,9 +355,12 @@ func TestUserUsecaseCreateUser(t *testing.T) {
 		t.Errorf("esperava ID 42, veio %d", created.ID)
 	}
 
-	expectedBucket := "myawss3bucket-90902222345-42"
-	if capturedBucket != expectedBucket {
-		t.Errorf("bucket esperado %q, veio %q", expectedBucket, capturedBucket)
+	if len(mailer.sent) != 1 || !strings.HasPrefix(mailer.sent[0], "alice@example.com") {
+		t.Errorf("esperava email de verificação para alice, veio %v", mailer.sent)
+	}
+
+	if len(provisioner.enqueued) != 0 {
+		t.Errorf("não deveria criar bucket antes da verificação do email")
 	}
 }
 
@@ -138,40 +372,166 @@ func TestUserUsecaseCreateUserRepoError(t *testing.T) {
 		},
 	}
 
-	awsClient := &fakeAwsClient{
-		createBucketFn: func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
-			t.Fatalf("não deveria chamar CreateBucket quando o repositório falha")
-			return nil, nil
-		},
-	}
-
-	usecase := NewUserUseCase(repo, awsClient)
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
-	_, err := usecase.CreateUser(models.User{})
+	_, err := usecase.CreateUser(dto.CreateUserDto{})
 	if !errors.Is(err, repoErr) {
 		t.Fatalf("esperava erro %v, veio %v", repoErr, err)
 	}
 }
 
-func TestUserUsecaseCreateUserAwsError(t *testing.T) {
+func TestUserUsecaseCreateUserCompensatesWhenTokenFails(t *testing.T) {
+	var deleted int
 	repo := &fakeUserRepo{
 		createUserFn: func(models.User) (int, error) {
+			return 42, nil
+		},
+		deleteUnverified: func(id int) error {
+			deleted = id
+			return nil
+		},
+	}
+
+	tokenErr := errors.New("token failure")
+	tokens := &fakeUserTokenRepo{
+		createUserTokenFn: func(int, string, string, time.Time) error {
+			return tokenErr
+		},
+	}
+
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, tokens, &fakeMailer{})
+
+	_, err := usecase.CreateUser(dto.CreateUserDto{Name: "Alice", Email: "alice@example.com"})
+	if !errors.Is(err, tokenErr) {
+		t.Fatalf("esperava erro %v, veio %v", tokenErr, err)
+	}
+	if deleted != 42 {
+		t.Fatalf("esperava desfazer o cadastro do usuário 42, veio %d", deleted)
+	}
+}
+
+func TestUserUsecaseVerifyEmailStartsProvisioning(t *testing.T) {
+	var verified int
+	repo := &fakeUserRepo{
+		markVerified: func(id int) error {
+			verified = id
+			return nil
+		},
+	}
+
+	consumed := false
+	tokens := &fakeUserTokenRepo{
+		getOwnerFn: func(purpose string, tokenHash string) (int, error) {
+			if purpose != emailVerificationPurpose {
+				t.Fatalf("propósito inesperado %s", purpose)
+			}
+			return 42, nil
+		},
+		consumeUserTokenFn: func(purpose string, tokenHash string) (int, error) {
+			consumed = true
+			return 42, nil
+		},
+	}
+	provisioner := &fakeProvisioner{}
+
+	usecase := NewUserUseCase(repo, provisioner, tokens, &fakeMailer{})
+
+	if err := usecase.VerifyEmail("token"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+
+	if verified != 42 {
+		t.Errorf("esperava verificar usuário 42, veio %d", verified)
+	}
+
+	if len(provisioner.enqueued) != 1 || provisioner.enqueued[0] != 42 {
+		t.Errorf("esperava provisionar o usuário 42, veio %v", provisioner.enqueued)
+	}
+	if !consumed {
+		t.Errorf("o token deveria ser gasto")
+	}
+}
+
+func TestUserUsecaseVerifyEmailProvisionerError(t *testing.T) {
+	repo := &fakeUserRepo{
+		markVerified: func(int) error {
+			return nil
+		},
+	}
+
+	enqueueErr := errors.New("status failure")
+	// ConsumeUserToken is left unset: the token must stay usable.
+	tokens := &fakeUserTokenRepo{
+		getOwnerFn: func(string, string) (int, error) {
 			return 7, nil
 		},
 	}
 
-	awsErr := errors.New("aws failure")
-	awsClient := &fakeAwsClient{
-		createBucketFn: func(ctx context.Context, bucket string) (*s3.CreateBucketOutput, error) {
-			return nil, awsErr
+	usecase := NewUserUseCase(repo, &fakeProvisioner{err: enqueueErr}, tokens, &fakeMailer{})
+
+	err := usecase.VerifyEmail("token")
+	if !errors.Is(err, enqueueErr) {
+		t.Fatalf("esperava erro %v, veio %v", enqueueErr, err)
+	}
+}
+
+func TestUserUsecaseRetryStorageOnlyAfterFailure(t *testing.T) {
+	status := models.StorageReady
+	repo := &fakeUserRepo{
+		getStorageFn: func(int) (*models.StorageStatus, error) {
+			return &models.StorageStatus{Status: status}, nil
+		},
+	}
+	provisioner := &fakeProvisioner{}
+
+	usecase := NewUserUseCase(repo, provisioner, &fakeUserTokenRepo{}, &fakeMailer{})
+
+	if err := usecase.RetryStorage(3); !errors.Is(err, ErrStorageNotFailed) {
+		t.Fatalf("esperava ErrStorageNotFailed, veio %v", err)
+	}
+
+	status = models.StorageFailed
+	if err := usecase.RetryStorage(3); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(provisioner.enqueued) != 1 || provisioner.enqueued[0] != 3 {
+		t.Fatalf("esperava reprovisionar o usuário 3, veio %v", provisioner.enqueued)
+	}
+}
+
+func TestUserUsecaseVerifyEmailInvalidToken(t *testing.T) {
+	tokens := &fakeUserTokenRepo{
+		getOwnerFn: func(string, string) (int, error) {
+			return 0, nil
+		},
+	}
+
+	usecase := NewUserUseCase(&fakeUserRepo{}, &fakeProvisioner{}, tokens, &fakeMailer{})
+
+	err := usecase.VerifyEmail("token")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("esperava ErrInvalidToken, veio %v", err)
+	}
+}
+
+func TestUserUsecaseResendVerificationSkipsVerifiedUser(t *testing.T) {
+	repo := &fakeUserRepo{
+		getByEmailFn: func(email string) (*models.User, error) {
+			return &models.User{ID: 3, Email: email}, nil
+		},
+		isVerifiedFn: func(int) (bool, error) {
+			return true, nil
 		},
 	}
+	mailer := &fakeMailer{}
 
-	usecase := NewUserUseCase(repo, awsClient)
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, mailer)
 
-	_, err := usecase.CreateUser(models.User{})
-	if !errors.Is(err, awsErr) {
-		t.Fatalf("esperava erro %v, veio %v", awsErr, err)
+	if err := usecase.ResendVerification("ana@example.com"); err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("não deveria reenviar para conta já verificada")
 	}
 }
 
@@ -186,11 +546,7 @@ func TestUserUsecaseGetUsers(t *testing.T) {
 		},
 	}
 
-	usecase := NewUserUseCase(repo, &fakeAwsClient{
-		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
-			panic("não deveria chamar aws")
-		},
-	})
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
 	users, err := usecase.GetUsers()
 	if err != nil {
@@ -214,11 +570,7 @@ func TestUserUsecaseGetUserById(t *testing.T) {
 		},
 	}
 
-	usecase := NewUserUseCase(repo, &fakeAwsClient{
-		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
-			panic("não deveria chamar aws")
-		},
-	})
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
 	user, err := usecase.GetUserById(5)
 	if err != nil {
@@ -237,11 +589,7 @@ func TestUserUsecaseGetUserByIdError(t *testing.T) {
 		},
 	}
 
-	usecase := NewUserUseCase(repo, &fakeAwsClient{
-		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
-			panic("não deveria chamar aws")
-		},
-	})
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
 	_, err := usecase.GetUserById(1)
 	if !errors.Is(err, expectedErr) {
@@ -256,15 +604,14 @@ func TestUserUsecaseLogin(t *testing.T) {
 			if input.Email != "leo@example.com" {
 				t.Fatalf("email inesperado %s", input.Email)
 			}
+			if len(input.Scopes) != len(handlers.AllScopes) {
+				t.Fatalf("login sem escopos deveria receber todos, veio %v", input.Scopes)
+			}
 			return response, nil
 		},
 	}
 
-	usecase := NewUserUseCase(repo, &fakeAwsClient{
-		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
-			panic("não deveria chamar aws")
-		},
-	})
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
 	result, err := usecase.Login(dto.UserLoginDto{
 		Email:    "leo@example.com",
@@ -286,14 +633,41 @@ func TestUserUsecaseLoginError(t *testing.T) {
 		},
 	}
 
-	usecase := NewUserUseCase(repo, &fakeAwsClient{
-		createBucketFn: func(context.Context, string) (*s3.CreateBucketOutput, error) {
-			panic("não deveria chamar aws")
-		},
-	})
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
 
 	_, err := usecase.Login(dto.UserLoginDto{})
 	if !errors.Is(err, expectedErr) {
 		t.Fatalf("esperava erro %v, veio %v", expectedErr, err)
 	}
 }
+
+func TestUserUsecaseLoginNarrowsScopes(t *testing.T) {
+	repo := &fakeUserRepo{
+		loginFn: func(input dto.UserLoginDto) (*dto.UserResponseDto, error) {
+			if len(input.Scopes) != 1 || input.Scopes[0] != handlers.ScopeFilesRead {
+				t.Fatalf("escopos inesperados %v", input.Scopes)
+			}
+			return &dto.UserResponseDto{ID: 3}, nil
+		},
+	}
+
+	usecase := NewUserUseCase(repo, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
+
+	_, err := usecase.Login(dto.UserLoginDto{
+		Email:    "leo@example.com",
+		Password: "pwd",
+		Scopes:   []string{handlers.ScopeFilesRead, handlers.ScopeFilesRead},
+	})
+	if err != nil {
+		t.Fatalf("não esperava erro, veio %v", err)
+	}
+}
+
+func TestUserUsecaseLoginRejectsUnknownScope(t *testing.T) {
+	usecase := NewUserUseCase(&fakeUserRepo{}, &fakeProvisioner{}, &fakeUserTokenRepo{}, &fakeMailer{})
+
+	_, err := usecase.Login(dto.UserLoginDto{Scopes: []string{"files:delete"}})
+	if !errors.Is(err, handlers.ErrInvalidScope) {
+		t.Fatalf("esperava ErrInvalidScope, veio %v", err)
+	}
+}